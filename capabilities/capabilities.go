@@ -0,0 +1,136 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package capabilities
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// A platform feature that some of the measurements depend on.
+type Capability string
+
+const (
+	// Reading the kernel's statistics for a TCP connection (for the extended statistics).
+	TCPInfo Capability = "tcp_info"
+	// Having the kernel timestamp packets as they are sent and received.
+	Timestamping Capability = "so_timestamping"
+	// Setting the DSCP (the upper six bits of the IPv4 TOS) of the packets that we send.
+	DSCP Capability = "dscp"
+	// Choosing the congestion-control algorithm of a TCP connection.
+	CongestionControl Capability = "congestion_control"
+	// Opening a socket for ICMP echoes (for pings).
+	ICMP Capability = "icmp"
+)
+
+// Whether this platform (with the privileges of this process) supports a capability. The
+// detail says why not when it does not and, sometimes, how it does when it does.
+type Support struct {
+	Capability Capability `json:"capability"`
+	Available  bool       `json:"available"`
+	Detail     string     `json:"detail,omitempty"`
+}
+
+func supported(capability Capability, detail string) Support {
+	return Support{Capability: capability, Available: true, Detail: detail}
+}
+
+func unsupported(capability Capability, err error) Support {
+	return Support{Capability: capability, Available: false, Detail: err.Error()}
+}
+
+// The support for every capability, detected once at startup so that the features that
+// depend on a missing capability can be turned off before the test starts.
+type Matrix []Support
+
+// Try each of the capabilities (on loopback sockets, so nothing leaves this host).
+func Detect() Matrix {
+	return Matrix{
+		detectTCPInfo(),
+		detectTimestamping(),
+		detectDSCP(),
+		detectCongestionControl(),
+		detectICMP(),
+	}
+}
+
+func (m Matrix) Lookup(capability Capability) Support {
+	for _, support := range m {
+		if support.Capability == capability {
+			return support
+		}
+	}
+	return Support{Capability: capability, Available: false, Detail: "not detected"}
+}
+
+// Turn off a feature that the user enabled when the capability that it depends on is not
+// available. Returns a warning (and true) when the feature was turned off.
+func (m Matrix) Require(capability Capability, feature string, enabled *bool) (string, bool) {
+	if !*enabled {
+		return "", false
+	}
+	support := m.Lookup(capability)
+	if support.Available {
+		return "", false
+	}
+	*enabled = false
+	return fmt.Sprintf(
+		"%s was requested but %s is not available on this platform (%s); it was disabled.",
+		feature, capability, support.Detail,
+	), true
+}
+
+func (m Matrix) String() string {
+	var builder strings.Builder
+	for _, support := range m {
+		availability := "unavailable"
+		if support.Available {
+			availability = "available"
+		}
+		builder.WriteString(fmt.Sprintf("\t%-18s %-11s %s\n", support.Capability, availability, support.Detail))
+	}
+	return builder.String()
+}
+
+func detectDSCP() Support {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		return unsupported(DSCP, err)
+	}
+	defer conn.Close()
+	if err := ipv4.NewPacketConn(conn).SetTOS(0); err != nil {
+		return unsupported(DSCP, err)
+	}
+	return supported(DSCP, "")
+}
+
+// Like the pinger (see probe.Pinger), prefer an unprivileged (datagram) ICMP socket and
+// fall back to a raw one.
+func detectICMP() Support {
+	if conn, err := icmp.ListenPacket("udp4", "0.0.0.0"); err == nil {
+		conn.Close()
+		return supported(ICMP, "unprivileged")
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return unsupported(ICMP, err)
+	}
+	conn.Close()
+	return supported(ICMP, "raw")
+}
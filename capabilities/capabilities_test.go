@@ -0,0 +1,58 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package capabilities
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	matrix := Detect()
+	for _, capability := range []Capability{TCPInfo, Timestamping, DSCP, CongestionControl, ICMP} {
+		if support := matrix.Lookup(capability); support.Detail == "not detected" {
+			t.Fatalf("Capability %s was not detected.", capability)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	matrix := Matrix{
+		supported(DSCP, ""),
+		unsupported(ICMP, fmt.Errorf("operation not permitted")),
+	}
+
+	enabled := true
+	if _, disabled := matrix.Require(DSCP, "Marking", &enabled); disabled || !enabled {
+		t.Fatalf("A feature whose capability is available should stay enabled.")
+	}
+
+	warning, disabled := matrix.Require(ICMP, "Pinging", &enabled)
+	if !disabled || enabled {
+		t.Fatalf("A feature whose capability is not available should be disabled.")
+	}
+	if !strings.Contains(warning, "Pinging") || !strings.Contains(warning, "operation not permitted") {
+		t.Fatalf("The warning should name the feature and the reason: %s", warning)
+	}
+
+	if _, disabled := matrix.Require(ICMP, "Pinging", &enabled); disabled {
+		t.Fatalf("A feature that was not enabled should not be disabled again.")
+	}
+	enabled = true
+	if _, disabled := matrix.Require(Timestamping, "Timestamping", &enabled); !disabled {
+		t.Fatalf("A feature whose capability was not detected should be disabled.")
+	}
+}
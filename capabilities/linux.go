@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package capabilities
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Run check on the file descriptor of a TCP connection over loopback.
+func withLoopbackTCPConnection(check func(fd uintptr) error) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		return err
+	}
+	var checkErr error
+	if err := rawConn.Control(func(fd uintptr) { checkErr = check(fd) }); err != nil {
+		return err
+	}
+	return checkErr
+}
+
+func detectTCPInfo() Support {
+	if err := withLoopbackTCPConnection(func(fd uintptr) error {
+		_, err := unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+		return err
+	}); err != nil {
+		return unsupported(TCPInfo, err)
+	}
+	return supported(TCPInfo, "")
+}
+
+func detectTimestamping() Support {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		return unsupported(Timestamping, err)
+	}
+	defer conn.Close()
+	rawConn, err := conn.(*net.UDPConn).SyscallConn()
+	if err != nil {
+		return unsupported(Timestamping, err)
+	}
+	var setErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		setErr = unix.SetsockoptInt(
+			int(fd),
+			unix.SOL_SOCKET,
+			unix.SO_TIMESTAMPING,
+			unix.SOF_TIMESTAMPING_SOFTWARE|unix.SOF_TIMESTAMPING_RX_SOFTWARE|unix.SOF_TIMESTAMPING_TX_SOFTWARE,
+		)
+	}); err != nil {
+		return unsupported(Timestamping, err)
+	}
+	if setErr != nil {
+		return unsupported(Timestamping, setErr)
+	}
+	return supported(Timestamping, "software")
+}
+
+// Selecting the algorithm that a connection already uses is enough to tell whether
+// selection is allowed.
+func detectCongestionControl() Support {
+	algorithm := ""
+	if err := withLoopbackTCPConnection(func(fd uintptr) error {
+		var err error
+		if algorithm, err = unix.GetsockoptString(int(fd), unix.IPPROTO_TCP, unix.TCP_CONGESTION); err != nil {
+			return err
+		}
+		// The kernel pads the name with NULs.
+		algorithm = strings.TrimRight(algorithm, "\x00")
+		if err = unix.SetsockoptString(int(fd), unix.IPPROTO_TCP, unix.TCP_CONGESTION, algorithm); err != nil {
+			return fmt.Errorf("could not select %s: %w", algorithm, err)
+		}
+		return nil
+	}); err != nil {
+		return unsupported(CongestionControl, err)
+	}
+	return supported(CongestionControl, fmt.Sprintf("default: %s", algorithm))
+}
@@ -0,0 +1,40 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package capabilities
+
+import (
+	"fmt"
+
+	"github.com/network-quality/goresponsiveness/extendedstats"
+)
+
+// Elsewhere, the extended statistics package knows whether it can read TCP info.
+func detectTCPInfo() Support {
+	if !extendedstats.ExtendedStatsAvailable() {
+		return unsupported(TCPInfo, fmt.Errorf("not supported on this platform"))
+	}
+	return supported(TCPInfo, "")
+}
+
+func detectTimestamping() Support {
+	return unsupported(Timestamping, fmt.Errorf("only supported on Linux"))
+}
+
+func detectCongestionControl() Support {
+	return unsupported(CongestionControl, fmt.Errorf("only supported on Linux"))
+}
@@ -0,0 +1,90 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The last configuration that a configuration host returned along with what we need to
+// revalidate it (its ETag).
+type cachedConfig struct {
+	Source  string          `json:"source"`
+	ETag    string          `json:"etag"`
+	Fetched time.Time       `json:"fetched"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// Where configurations are cached (by default): a directory in the user's cache directory.
+func DefaultCacheDirectory() string {
+	cacheDirectory, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDirectory, "goresponsiveness")
+}
+
+func cacheFilename(cacheDirectory string, source string) string {
+	hash := sha256.Sum256([]byte(source))
+	return filepath.Join(cacheDirectory, "config-"+hex.EncodeToString(hash[:8])+".json")
+}
+
+func loadCachedConfig(cacheDirectory string, source string) (*cachedConfig, error) {
+	contents, err := os.ReadFile(cacheFilename(cacheDirectory, source))
+	if err != nil {
+		return nil, err
+	}
+	cached := &cachedConfig{}
+	if err := json.Unmarshal(contents, cached); err != nil {
+		return nil, err
+	}
+	// Guard against the (unlikely) collision of two sources' hashes.
+	if cached.Source != source {
+		return nil, os.ErrNotExist
+	}
+	return cached, nil
+}
+
+func storeCachedConfig(cacheDirectory string, cached *cachedConfig) error {
+	if err := os.MkdirAll(cacheDirectory, 0o700); err != nil {
+		return err
+	}
+	contents, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	// Write the new contents next to the old ones and then swap them so that a reader never
+	// sees a partially-written cache entry.
+	filename := cacheFilename(cacheDirectory, cached.Source)
+	temporary, err := os.CreateTemp(cacheDirectory, filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := temporary.Write(contents); err != nil {
+		temporary.Close()
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	return os.Rename(temporary.Name(), filename)
+}
@@ -0,0 +1,75 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testConfiguration = `{
+	"version": 1,
+	"urls": {
+		"small_https_download_url": "https://example.com/small",
+		"large_https_download_url": "https://example.com/large",
+		"https_upload_url": "https://example.com/upload"
+	}
+}`
+
+func TestConfigCacheRevalidationAndFallback(t *testing.T) {
+	requests, revalidations := 0, 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidations++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testConfiguration))
+	}))
+	hostPort := strings.TrimPrefix(server.URL, "https://")
+	cacheDirectory := t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		c := &Config{CacheDirectory: cacheDirectory}
+		if err := c.Get(hostPort, "/config", true, nil); err != nil {
+			t.Fatalf("Could not get the configuration: %v", err)
+		}
+		if c.Urls.LargeUrl != "https://example.com/large" {
+			t.Fatalf("Configuration was not parsed (run %d): %v", i, c)
+		}
+	}
+	if requests != 2 || revalidations != 1 {
+		t.Fatalf("The second run should have revalidated the cached configuration (%d requests, %d revalidations).", requests, revalidations)
+	}
+
+	// Once the host is gone, the cached configuration stands in for it.
+	server.Close()
+	c := &Config{CacheDirectory: cacheDirectory}
+	if err := c.Get(hostPort, "/config", true, nil); err != nil {
+		t.Fatalf("The cached configuration should have been used: %v", err)
+	}
+	if c.Urls.UploadUrl != "https://example.com/upload" {
+		t.Fatalf("Cached configuration was not parsed: %v", c)
+	}
+
+	// Without a cache, an unreachable host is an error.
+	if err := (&Config{}).Get(hostPort, "/config", true, nil); err == nil {
+		t.Fatalf("Getting a configuration from an unreachable host without a cache should fail.")
+	}
+}
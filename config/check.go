@@ -0,0 +1,114 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// The outcome of asking one of the configuration's URLs whether it would serve a test.
+type UrlCheck struct {
+	// The name of the URL in the configuration (e.g., small_https_download_url).
+	Name   string
+	Url    string
+	Method string
+	// The status and protocol of the response (empty when there was none).
+	Status   string
+	Protocol string
+	// What the server says the object at the URL weighs (-1 when it did not say).
+	ContentLength int64
+	// Why the URL would not serve a test (nil when it would).
+	Err error
+}
+
+func (check UrlCheck) String() string {
+	if check.Err != nil {
+		return fmt.Sprintf("%s %s: %s failed: %v", check.Name, check.Url, check.Method, check.Err)
+	}
+	length := "unknown length"
+	if check.ContentLength >= 0 {
+		length = fmt.Sprintf("%d bytes", check.ContentLength)
+	}
+	return fmt.Sprintf("%s %s: %s %s (%s, %s)", check.Name, check.Url, check.Method, check.Status, check.Protocol, length)
+}
+
+// Check, without generating any load, that every URL of the configuration answers: the
+// downloads to a HEAD (or, where the server does not allow that, to a GET whose body is never
+// read) and the uploads to an OPTIONS that allows a POST.
+func (c *Config) Check(ctx context.Context, insecureSkipVerify bool, keyLogger io.Writer) []UrlCheck {
+	client := c.newClient(insecureSkipVerify, keyLogger)
+	checks := make([]UrlCheck, 0)
+	check := func(name string, url string, method string) {
+		checks = append(checks, checkUrl(ctx, client, c.RequestHeaders, name, url, method))
+	}
+	check("small_https_download_url", c.Urls.SmallUrl, http.MethodHead)
+	for _, largeUrl := range c.Urls.AllLargeUrls() {
+		check("large_https_download_url", largeUrl, http.MethodHead)
+	}
+	for _, uploadUrl := range c.Urls.AllUploadUrls() {
+		check("https_upload_url", uploadUrl, http.MethodOptions)
+	}
+	return checks
+}
+
+func checkUrl(
+	ctx context.Context,
+	client *http.Client,
+	headers *utilities.RequestHeaders,
+	name string,
+	url string,
+	method string,
+) UrlCheck {
+	check := UrlCheck{Name: name, Url: url, Method: method, ContentLength: -1}
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	request.Header.Set("Accept-Encoding", "identity")
+	// A server behind an authenticating gateway must be asked the way that the test would ask.
+	utilities.SetRequestHeaders(request, headers)
+	response, err := client.Do(request)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	response.Body.Close()
+	if response.StatusCode == http.StatusMethodNotAllowed && method == http.MethodHead {
+		return checkUrl(ctx, client, headers, name, url, http.MethodGet)
+	}
+	check.Status, check.Protocol, check.ContentLength = response.Status, response.Proto, response.ContentLength
+	switch {
+	case method == http.MethodOptions && response.StatusCode == http.StatusMethodNotAllowed:
+		// The server does not answer OPTIONS, but it may still tell us what it does answer.
+		if !strings.Contains(response.Header.Get("Allow"), http.MethodPost) {
+			check.Err = fmt.Errorf("answered with %s (and does not allow POST)", response.Status)
+		}
+	case response.StatusCode < 200 || response.StatusCode > 299:
+		check.Err = fmt.Errorf("answered with %s", response.Status)
+	case method == http.MethodOptions && response.Header.Get("Allow") != "" &&
+		!strings.Contains(response.Header.Get("Allow"), http.MethodPost):
+		check.Err = fmt.Errorf("does not allow POST (only %s)", response.Header.Get("Allow"))
+	case method != http.MethodOptions && response.ContentLength == 0:
+		check.Err = fmt.Errorf("is empty")
+	}
+	return check
+}
@@ -16,12 +16,15 @@ package config
 
 import (
 	"crypto/tls"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/network-quality/goresponsiveness/utilities"
 )
@@ -30,16 +33,94 @@ type ConfigUrls struct {
 	SmallUrl  string `json:"small_https_download_url"`
 	LargeUrl  string `json:"large_https_download_url"`
 	UploadUrl string `json:"https_upload_url"`
+	// Servers that cap the throughput of a single object (or host) can list more URLs here;
+	// the load-generating connections are spread across all of them.
+	LargeUrls  []string `json:"large_https_download_urls,omitempty"`
+	UploadUrls []string `json:"https_upload_urls,omitempty"`
+}
+
+// All the URLs from which to download (the large URL first, without duplicates).
+func (urls *ConfigUrls) AllLargeUrls() []string {
+	return uniqueUrls(append([]string{urls.LargeUrl}, urls.LargeUrls...))
+}
+
+// All the URLs to which to upload (the upload URL first, without duplicates).
+func (urls *ConfigUrls) AllUploadUrls() []string {
+	return uniqueUrls(append([]string{urls.UploadUrl}, urls.UploadUrls...))
+}
+
+func uniqueUrls(urls []string) []string {
+	unique := make([]string, 0, len(urls))
+	seen := make(map[string]bool)
+	for _, candidate := range urls {
+		if candidate == "" || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		unique = append(unique, candidate)
+	}
+	return unique
+}
+
+// Replace the URLs from the configuration server with the given URLs (if there are any).
+func (urls *ConfigUrls) OverrideLoadUrls(downloadUrls []string, uploadUrls []string) {
+	if len(downloadUrls) > 0 {
+		urls.LargeUrl, urls.LargeUrls = downloadUrls[0], downloadUrls[1:]
+	}
+	if len(uploadUrls) > 0 {
+		urls.UploadUrl, urls.UploadUrls = uploadUrls[0], uploadUrls[1:]
+	}
+}
+
+// A list of URLs that can be given as a (repeatable) command-line flag.
+type UrlList []string
+
+func (list *UrlList) String() string {
+	if list == nil {
+		return ""
+	}
+	return strings.Join(*list, ",")
+}
+
+// The flag can also be given by any of its aliases.
+func UrlsFlag(name string, usage string, aliases ...string) *UrlList {
+	list := &UrlList{}
+	flag.Var(list, name, usage)
+	for _, alias := range aliases {
+		flag.Var(list, alias, "Same as -"+name+".")
+	}
+	return list
+}
+
+func (list *UrlList) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			*list = append(*list, entry)
+		}
+	}
+	return nil
 }
 
 type Config struct {
-	Version       int
-	Urls          ConfigUrls `json:"urls"`
-	Source        string
+	Version int
+	Urls    ConfigUrls `json:"urls"`
+	Source  string
+	// The host to connect to for the test (instead of the one in the URLs). The user's choice
+	// (see -connect-to) takes precedence over the server's.
 	ConnectToAddr string `json:"test_endpoint"`
+	// Test presets that are specific to this server (see presets.go).
+	Presets map[string]Preset `json:"presets,omitempty"`
+	// When set, the configuration is cached here (and revalidated on later runs).
+	CacheDirectory string `json:"-"`
+	// When set, the configuration's clients tunnel through the proxy with this (see
+	// utilities.ProxyDialer).
+	ProxyDialer utilities.ProxyDialer `json:"-"`
+	// The credentials and headers for the requests to the test server (may be nil).
+	RequestHeaders *utilities.RequestHeaders `json:"-"`
 }
 
-func (c *Config) Get(configHost string, configPath string, insecureSkipVerify bool, keyLogger io.Writer) error {
+// A client that talks to the configuration's servers.
+func (c *Config) newClient(insecureSkipVerify bool, keyLogger io.Writer) *http.Client {
 	configTransport := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: insecureSkipVerify,
@@ -50,9 +131,13 @@ func (c *Config) Get(configHost string, configPath string, insecureSkipVerify bo
 		configTransport.TLSClientConfig.KeyLogWriter = keyLogger
 	}
 
-	utilities.OverrideHostTransport(configTransport, c.ConnectToAddr)
+	utilities.OverrideHostTransport(configTransport, c.ConnectToAddr, c.ProxyDialer)
 
-	configClient := &http.Client{Transport: configTransport}
+	return &http.Client{Transport: configTransport}
+}
+
+func (c *Config) Get(configHost string, configPath string, insecureSkipVerify bool, keyLogger io.Writer) error {
+	configClient := c.newClient(insecureSkipVerify, keyLogger)
 
 	// Extraneous /s in URLs is normally okay, but the Apple CDN does not
 	// like them. Make sure that we put exactly one (1) / between the host
@@ -71,54 +156,159 @@ func (c *Config) Get(configHost string, configPath string, insecureSkipVerify bo
 		)
 	}
 
-	req.Header.Set("User-Agent", utilities.UserAgent())
+	utilities.SetRequestHeaders(req, c.RequestHeaders)
+
+	// If we have seen this configuration before, ask the host whether it has changed.
+	var cached *cachedConfig = nil
+	if c.CacheDirectory != "" {
+		if cached, err = loadCachedConfig(c.CacheDirectory, c.Source); err == nil && cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+	}
+
+	// When the configuration host cannot give us a configuration, the last one that it did
+	// give us is better than nothing.
+	useCachedConfig := func(reason error) error {
+		if cached == nil {
+			return reason
+		}
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: %v; using the configuration cached at %v.\n",
+			reason,
+			cached.Fetched.Format(time.RFC3339),
+		)
+		return c.parse(cached.Body)
+	}
 
 	resp, err := configClient.Do(req)
 	if err != nil {
-		return fmt.Errorf(
+		return useCachedConfig(fmt.Errorf(
 			"Error: could not connect to configuration host %s: %v",
 			configHost,
 			err,
-		)
+		))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return c.parse(cached.Body)
+	}
+
 	if resp.StatusCode != 200 {
-		return fmt.Errorf(
+		return useCachedConfig(fmt.Errorf(
 			"Error: Configuration host %s returned %d for config request",
 			configHost,
 			resp.StatusCode,
-		)
+		))
 	}
 
 	jsonConfig, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf(
+		return useCachedConfig(fmt.Errorf(
 			"Error: Could not read configuration content downloaded from %s: %v",
 			c.Source,
 			err,
-		)
+		))
 	}
 
-	err = json.Unmarshal(jsonConfig, c)
-	if err != nil {
+	if err = c.parse(jsonConfig); err != nil {
+		return err
+	}
+
+	// Only a configuration that parses is worth keeping.
+	if c.CacheDirectory != "" {
+		if err := storeCachedConfig(c.CacheDirectory, &cachedConfig{
+			Source:  c.Source,
+			ETag:    resp.Header.Get("ETag"),
+			Fetched: time.Now(),
+			Body:    jsonConfig,
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not cache the configuration: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// Configure the test with URLs that the user gave directly (e.g., for a server without a
+// configuration endpoint) rather than with a configuration from a server.
+func (c *Config) UseUrls(smallUrl string, largeUrls []string, uploadUrls []string) {
+	c.Version = 1
+	c.Source = "the command line"
+	c.Urls = ConfigUrls{SmallUrl: smallUrl}
+	c.Urls.OverrideLoadUrls(largeUrls, uploadUrls)
+}
+
+func (c *Config) parse(jsonConfig []byte) error {
+	connectToAddr := c.ConnectToAddr
+	if err := c.decode(jsonConfig); err != nil {
 		return fmt.Errorf(
 			"could not parse configuration returned from %s: %v",
 			c.Source,
 			err,
 		)
 	}
+	if connectToAddr != "" {
+		c.ConnectToAddr = connectToAddr
+	} else {
+		c.ConnectToAddr = endpointHost(c.ConnectToAddr)
+	}
+	return c.resolveUrls()
+}
 
+// Servers may give their URLs relative to that of the configuration (e.g., /large) or with the
+// configuration's host templated in (e.g., https://{config_host}/large); make them absolute.
+func (c *Config) resolveUrls() error {
+	base, err := url.Parse(c.Source)
+	if err != nil {
+		return fmt.Errorf("could not parse the configuration URL %s: %v", c.Source, err)
+	}
+	resolve := func(reference *string) error {
+		if *reference == "" {
+			return nil
+		}
+		parsed, err := url.Parse(strings.ReplaceAll(*reference, "{config_host}", base.Host))
+		if err != nil {
+			return fmt.Errorf("could not parse the URL %s in the configuration returned from %s: %v", *reference, c.Source, err)
+		}
+		*reference = base.ResolveReference(parsed).String()
+		return nil
+	}
+	references := []*string{&c.Urls.SmallUrl, &c.Urls.LargeUrl, &c.Urls.UploadUrl}
+	for i := range c.Urls.LargeUrls {
+		references = append(references, &c.Urls.LargeUrls[i])
+	}
+	for i := range c.Urls.UploadUrls {
+		references = append(references, &c.Urls.UploadUrls[i])
+	}
+	for _, reference := range references {
+		if err := resolve(reference); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Newer servers give their test endpoint with a port (e.g., [2001:db8::1]:443) or even as a
+// URL; we only connect to its host (on the port of the URL that we are after).
+func endpointHost(endpoint string) string {
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
+		return parsed.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return strings.Trim(endpoint, "[]")
+}
+
 func (c *Config) String() string {
 	return fmt.Sprintf(
 		"Version: %d\nSmall URL: %s\nLarge URL: %s\nUpload URL: %s\nEndpoint: %s\n",
 		c.Version,
 		c.Urls.SmallUrl,
-		c.Urls.LargeUrl,
-		c.Urls.UploadUrl,
+		strings.Join(c.Urls.AllLargeUrls(), ", "),
+		strings.Join(c.Urls.AllUploadUrls(), ", "),
 		c.ConnectToAddr,
 	)
 }
@@ -157,5 +347,23 @@ func (c *Config) IsValid() error {
 			),
 		)
 	}
+	for _, additional := range []struct {
+		name string
+		urls []string
+	}{
+		{"large_https_download_urls", c.Urls.LargeUrls},
+		{"https_upload_urls", c.Urls.UploadUrls},
+	} {
+		for _, additionalUrl := range additional.urls {
+			if parsedUrl, err := url.ParseRequestURI(additionalUrl); err != nil ||
+				parsedUrl.Scheme != "https" {
+				return fmt.Errorf(
+					"configuration url in %s is invalid: %s",
+					additional.name,
+					additionalUrl,
+				)
+			}
+		}
+	}
 	return nil
 }
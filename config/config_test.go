@@ -0,0 +1,198 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+func TestUseUrls(t *testing.T) {
+	c := Config{ConnectToAddr: "192.0.2.1"}
+	c.UseUrls(
+		"https://example.com/small",
+		[]string{"https://example.com/large", "https://mirror.example.com/large"},
+		[]string{"https://example.com/upload"},
+	)
+	if err := c.IsValid(); err != nil {
+		t.Fatalf("A configuration from URLs should be valid: %v", err)
+	}
+	if large := c.Urls.AllLargeUrls(); len(large) != 2 || c.Urls.UploadUrl != "https://example.com/upload" {
+		t.Fatalf("The configuration has the wrong URLs: %v", c.Urls)
+	}
+	if c.ConnectToAddr != "192.0.2.1" || c.Source == "" {
+		t.Fatalf("The configuration should keep its endpoint and describe its source: %v", c)
+	}
+}
+
+func TestMultipleLoadUrls(t *testing.T) {
+	c := Config{}
+	if err := json.Unmarshal([]byte(`{"urls": {
+		"small_https_download_url": "https://example.com/small",
+		"large_https_download_url": "https://example.com/large",
+		"https_upload_url": "https://example.com/upload",
+		"large_https_download_urls": ["https://a.example.com/large", "https://example.com/large"]
+	}}`), &c); err != nil {
+		t.Fatalf("Could not parse a configuration with several URLs: %v", err)
+	}
+	if err := c.IsValid(); err != nil {
+		t.Fatalf("A configuration with several URLs should be valid: %v", err)
+	}
+	if large := c.Urls.AllLargeUrls(); len(large) != 2 || large[0] != "https://example.com/large" {
+		t.Fatalf("The large URLs should start with the large URL and have no duplicates: %v", large)
+	}
+	if upload := c.Urls.AllUploadUrls(); len(upload) != 1 {
+		t.Fatalf("There should only be the one upload URL: %v", upload)
+	}
+
+	var uploadUrls UrlList
+	if err := uploadUrls.Set("https://a.example.com/upload, https://b.example.com/upload"); err != nil {
+		t.Fatalf("Could not parse the upload URLs: %v", err)
+	}
+	c.Urls.OverrideLoadUrls(nil, uploadUrls)
+	if upload := c.Urls.AllUploadUrls(); len(upload) != 2 || upload[1] != "https://b.example.com/upload" {
+		t.Fatalf("The upload URLs should have been overridden: %v", upload)
+	}
+
+	c.Urls.LargeUrls = append(c.Urls.LargeUrls, "http://insecure.example.com/large")
+	if err := c.IsValid(); err == nil {
+		t.Fatalf("An additional URL that is not https should be invalid.")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/small" && r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", "10")
+		case r.URL.Path == "/large" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Length", "1000000")
+			w.Write(make([]byte, 1000000))
+		case r.URL.Path == "/upload":
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/large":
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := Config{}
+	c.UseUrls(server.URL+"/small", []string{server.URL + "/large", server.URL + "/missing"}, []string{server.URL + "/upload"})
+	checks := c.Check(context.Background(), true, nil)
+	failed := make([]string, 0)
+	for _, check := range checks {
+		if check.Err != nil {
+			failed = append(failed, check.Url)
+		}
+	}
+	if len(checks) != 4 || len(failed) != 1 || failed[0] != server.URL+"/missing" {
+		t.Fatalf("Only the missing URL should fail its check: %v", checks)
+	}
+	if checks[1].Method != http.MethodGet || checks[1].ContentLength != 1000000 {
+		t.Fatalf("A server that does not allow HEAD should be asked with a GET: %v", checks[1])
+	}
+}
+
+func TestCheckWithCredentials(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/upload" {
+			w.Header().Set("Allow", "POST")
+		}
+		w.Header().Set("Content-Length", "10")
+	}))
+	defer server.Close()
+
+	c := Config{}
+	c.UseUrls(server.URL+"/small", []string{server.URL + "/large"}, []string{server.URL + "/upload"})
+	for _, check := range c.Check(context.Background(), true, nil) {
+		if check.Err == nil {
+			t.Fatalf("Without credentials, the server should have refused the check: %v", check)
+		}
+	}
+
+	c.RequestHeaders = &utilities.RequestHeaders{Hosts: []string{"127.0.0.1"}}
+	c.RequestHeaders.UseBearerToken("token")
+	for _, check := range c.Check(context.Background(), true, nil) {
+		if check.Err != nil {
+			t.Fatalf("With credentials, every check should have passed: %v", check)
+		}
+	}
+}
+
+func TestParseRelativeUrls(t *testing.T) {
+	c := Config{Source: "https://config.example.com:4043/config"}
+	err := c.parse([]byte(`{
+		"version": 1,
+		"urls": {
+			"small_https_download_url": "/small",
+			"large_https_download_url": "https://{config_host}/large",
+			"https_upload_url": "upload",
+			"large_https_download_urls": ["//mirror.example.com/large"]
+		},
+		"test_endpoint": "[2001:db8::1]:443"
+	}`))
+	if err != nil {
+		t.Fatalf("Could not parse a configuration with relative URLs: %v", err)
+	}
+	if err := c.IsValid(); err != nil {
+		t.Fatalf("A configuration with relative URLs should be valid once they are resolved: %v", err)
+	}
+	if c.Urls.SmallUrl != "https://config.example.com:4043/small" ||
+		c.Urls.LargeUrl != "https://config.example.com:4043/large" ||
+		c.Urls.UploadUrl != "https://config.example.com:4043/upload" ||
+		c.Urls.LargeUrls[0] != "https://mirror.example.com/large" {
+		t.Fatalf("The URLs were resolved wrongly: %v", c.Urls)
+	}
+	if c.ConnectToAddr != "2001:db8::1" {
+		t.Fatalf("Only the host of the test endpoint should be used (not %s).", c.ConnectToAddr)
+	}
+
+	c = Config{Source: "https://config.example.com/config", ConnectToAddr: "192.0.2.1"}
+	if err := c.parse([]byte(`{"test_endpoint": "endpoint.example.com"}`)); err != nil || c.ConnectToAddr != "192.0.2.1" {
+		t.Fatalf("The user's endpoint should take precedence over the server's (not %s): %v", c.ConnectToAddr, err)
+	}
+}
+
+func TestSchemaVersions(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config string
+	}{
+		{"version 1", `{"version": 1, "urls": {"small_https_download_url": "/small", "large_https_download_url": "/large", "https_upload_url": "/upload"}}`},
+		{"version 2", `{"version": 2, "urls": {"small_download_url": "/small", "large_download_url": "/large", "upload_url": "/upload"}}`},
+		{"sniffed version 2", `{"urls": {"small_download_url": "/small", "large_download_url": "/large", "upload_url": "/upload"}}`},
+	} {
+		c := Config{Source: "https://example.com/config"}
+		if err := c.parse([]byte(test.config)); err != nil {
+			t.Fatalf("Could not parse a configuration in %s of the schema: %v", test.name, err)
+		}
+		if err := c.IsValid(); err != nil || c.Urls.LargeUrl != "https://example.com/large" {
+			t.Fatalf("A configuration in %s of the schema was parsed wrongly (%v): %v", test.name, err, c.Urls)
+		}
+	}
+}
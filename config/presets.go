@@ -0,0 +1,130 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// A named bundle of test settings (so that, e.g., a support team can ask a customer to run
+// the test with a single flag). Settings that are not set (nil) are left at their defaults.
+type Preset struct {
+	TestDuration        *int     `json:"test_duration,omitempty"`  // seconds
+	ProbeInterval       *uint    `json:"probe_interval,omitempty"` // milliseconds
+	ProbeTimeout        *uint    `json:"probe_timeout,omitempty"`  // milliseconds
+	Warmup              *int     `json:"warmup,omitempty"`         // seconds
+	Cooldown            *int     `json:"cooldown,omitempty"`       // seconds
+	StabilityI          *uint64  `json:"stability_i,omitempty"`
+	StabilityK          *uint64  `json:"stability_k,omitempty"`
+	StabilityS          *float64 `json:"stability_s,omitempty"`
+	IntervalPercentiles *bool    `json:"interval_percentiles,omitempty"`
+	QualityAttenuation  *bool    `json:"quality_attenuation,omitempty"`
+}
+
+func setting[T any](value T) *T {
+	return &value
+}
+
+var BuiltinPresets = map[string]Preset{
+	// A short test that trades precision for time.
+	"quick": {
+		TestDuration: setting(8),
+		StabilityI:   setting(uint64(3)),
+		StabilityK:   setting(uint64(3)),
+		StabilityS:   setting(10.0),
+	},
+	// The test as the specification describes it.
+	"spec": {
+		TestDuration:  setting(constants.RPMCalculationTime),
+		ProbeInterval: setting(constants.DefaultProbeInterval),
+		StabilityI:    setting(constants.InstantaneousThroughputMeasurementCount),
+		StabilityK:    setting(constants.InstantaneousMovingAverageStabilityCount),
+		StabilityS:    setting(constants.StabilityStandardDeviation),
+	},
+	// A long, strict test that records as much as it can.
+	"research": {
+		TestDuration:        setting(60),
+		Warmup:              setting(2),
+		Cooldown:            setting(10),
+		StabilityI:          setting(uint64(6)),
+		StabilityK:          setting(uint64(6)),
+		StabilityS:          setting(3.0),
+		IntervalPercentiles: setting(true),
+		QualityAttenuation:  setting(true),
+	},
+	// For long-delay links: probe less often, wait longer for probes and give slow start
+	// time to get out of the way.
+	"satellite": {
+		TestDuration:       setting(40),
+		ProbeInterval:      setting(uint(250)),
+		ProbeTimeout:       setting(uint(5000)),
+		Warmup:             setting(3),
+		StabilityS:         setting(10.0),
+		QualityAttenuation: setting(true),
+	},
+}
+
+func override[T any](base *T, overriding *T) *T {
+	if overriding != nil {
+		return overriding
+	}
+	return base
+}
+
+// The preset with every setting that is set in overrides replaced.
+func (p Preset) Override(overrides Preset) Preset {
+	return Preset{
+		TestDuration:        override(p.TestDuration, overrides.TestDuration),
+		ProbeInterval:       override(p.ProbeInterval, overrides.ProbeInterval),
+		ProbeTimeout:        override(p.ProbeTimeout, overrides.ProbeTimeout),
+		Warmup:              override(p.Warmup, overrides.Warmup),
+		Cooldown:            override(p.Cooldown, overrides.Cooldown),
+		StabilityI:          override(p.StabilityI, overrides.StabilityI),
+		StabilityK:          override(p.StabilityK, overrides.StabilityK),
+		StabilityS:          override(p.StabilityS, overrides.StabilityS),
+		IntervalPercentiles: override(p.IntervalPercentiles, overrides.IntervalPercentiles),
+		QualityAttenuation:  override(p.QualityAttenuation, overrides.QualityAttenuation),
+	}
+}
+
+// Look up a preset by name. The configuration may override the settings of a built-in
+// preset or define presets of its own.
+func (c *Config) Preset(name string) (Preset, error) {
+	builtin, isBuiltin := BuiltinPresets[name]
+	configured, isConfigured := c.Presets[name]
+	if !isBuiltin && !isConfigured {
+		return Preset{}, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(c.PresetNames(), ", "))
+	}
+	return builtin.Override(configured), nil
+}
+
+// The names of every available preset (built-in and configured), sorted.
+func (c *Config) PresetNames() []string {
+	names := make([]string, 0)
+	for name := range BuiltinPresets {
+		names = append(names, name)
+	}
+	for name := range c.Presets {
+		if _, isBuiltin := BuiltinPresets[name]; !isBuiltin {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,50 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfiguredPresetOverridesBuiltin(t *testing.T) {
+	c := Config{}
+	if err := json.Unmarshal(
+		[]byte(`{"presets": {"quick": {"test_duration": 5}, "lab": {"probe_interval": 50}}}`),
+		&c,
+	); err != nil {
+		t.Fatalf("Could not parse a configuration with presets: %v", err)
+	}
+
+	quick, err := c.Preset("quick")
+	if err != nil {
+		t.Fatalf("Could not find the quick preset: %v", err)
+	}
+	if *quick.TestDuration != 5 {
+		t.Fatalf("The configuration should override the quick preset's test duration (got %d).", *quick.TestDuration)
+	}
+	if *quick.StabilityS != *BuiltinPresets["quick"].StabilityS {
+		t.Fatalf("Settings that the configuration does not override should come from the built-in preset.")
+	}
+
+	lab, err := c.Preset("lab")
+	if err != nil || lab.ProbeInterval == nil || *lab.ProbeInterval != 50 {
+		t.Fatalf("The configuration should be able to define its own presets: %v, %v", lab, err)
+	}
+
+	if _, err := c.Preset("nonexistent"); err == nil {
+		t.Fatalf("Looking up an unknown preset should fail.")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import "encoding/json"
+
+// Servers in the wild return their configurations in different versions of the schema. The
+// version field (or, without one, the names of the URLs) says which one a configuration is in.
+const (
+	// The original: the URLs are named after their scheme (e.g., large_https_download_url).
+	SchemaVersion1 = 1
+	// The scheme is left out of the names (e.g., large_download_url); the URLs carry it anyway.
+	SchemaVersion2 = 2
+)
+
+// The URLs as version 2 of the schema names them (with the same fields as ConfigUrls).
+type schemaVersion2Urls struct {
+	SmallUrl   string   `json:"small_download_url"`
+	LargeUrl   string   `json:"large_download_url"`
+	UploadUrl  string   `json:"upload_url"`
+	LargeUrls  []string `json:"large_download_urls,omitempty"`
+	UploadUrls []string `json:"upload_urls,omitempty"`
+}
+
+// Which version of the schema a configuration is in. One whose version we do not know (or that
+// does not say) is recognized by the names of its URLs.
+func schemaVersion(jsonConfig []byte) (int, error) {
+	var sniffed struct {
+		Version int                        `json:"version"`
+		Urls    map[string]json.RawMessage `json:"urls"`
+	}
+	if err := json.Unmarshal(jsonConfig, &sniffed); err != nil {
+		return 0, err
+	}
+	if sniffed.Version == SchemaVersion1 || sniffed.Version == SchemaVersion2 {
+		return sniffed.Version, nil
+	}
+	for _, name := range []string{"small_download_url", "large_download_url", "upload_url"} {
+		if _, found := sniffed.Urls[name]; found {
+			return SchemaVersion2, nil
+		}
+	}
+	return SchemaVersion1, nil
+}
+
+// Decode a configuration in whichever version of the schema it is in.
+func (c *Config) decode(jsonConfig []byte) error {
+	version, err := schemaVersion(jsonConfig)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(jsonConfig, c); err != nil {
+		return err
+	}
+	if version == SchemaVersion2 {
+		var urls struct {
+			Urls schemaVersion2Urls `json:"urls"`
+		}
+		if err := json.Unmarshal(jsonConfig, &urls); err != nil {
+			return err
+		}
+		c.Urls = ConfigUrls(urls.Urls)
+	}
+	if c.Version == 0 {
+		c.Version = version
+	}
+	return nil
+}
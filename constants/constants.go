@@ -32,12 +32,144 @@ var (
 	// The standard deviation cutoff used to determine stability among the K preceding moving averages
 	// of a measurement (as a percentage of the mean).
 	StabilityStandardDeviation float64 = 5.0
+	// The stabilizers that look at individual measurements (cov and mann-kendall) get the mean
+	// RTT of the probes in each interval this long (the interval between throughput
+	// measurements) rather than the RTT of every round trip.
+	ProbeStabilityInterval time.Duration = time.Second
+	// How far each new instantaneous measurement moves the exponentially weighted moving
+	// average toward itself (when that stabilizer is selected).
+	DefaultEWMADecay float64 = 0.3
+
+	// When the throughput in one direction is at least this many times the throughput in the
+	// other, the link is asymmetric enough that the slow direction gets stability parameters
+	// of its own.
+	AsymmetryRatioThreshold float64 = 10.0
+	// The number of (post warm-up) throughput measurements in each direction to gather before
+	// judging whether the link is asymmetric.
+	AsymmetryDetectionMeasurementCount int = 2
+
+	// The default amount of time (in seconds) to probe before the load starts to measure the
+	// idle RPM (0 disables the measurement).
+	DefaultIdleMeasurementTime int = 2
+	// The default amount of time (in seconds) to continue probing after the load stops (0
+	// disables the measurement).
+	DefaultCooldownMeasurementTime int = 0
+	// How close (as a percentage above idle) latency must get to idle before we consider
+	// the network to have drained after the load stops.
+	CooldownIdleLatencyTolerance float64 = 20.0
+	// How many probes in a row must see latency that close to idle (so that a lucky probe
+	// while the buffers are still draining does not count).
+	CooldownDrainedProbeCount int = 3
+
+	// The default amount of time (in ms) between probes.
+	DefaultProbeInterval uint = 100
+	// The default maximum number of probes to send during a test (0 means unlimited).
+	DefaultProbeBudget uint64 = 0
+	// The default amount of time (in ms) to let a probe run before canceling it (0 means
+	// probes never time out).
+	DefaultProbeTimeout uint = 0
+	// The default time (in ms) beyond which the round-trip time of a probe counts as a loss in
+	// the quality attenuation.
+	DefaultQualityAttenuationLossThreshold uint = 15000
+
+	// The default number of rotated files to keep of each data logger's file.
+	DefaultDataLoggerMaxFiles int = 3
+
+	// The time between UDP probe packets (about the packet rate of an interactive voice call).
+	UDPProbeInterval time.Duration = 20 * time.Millisecond
+	// A UDP probe packet whose echo has not arrived after this long is considered lost.
+	UDPProbeLossTimeout time.Duration = 1 * time.Second
+
+	// The time between pings (when the user wants a ping baseline).
+	PingInterval time.Duration = 100 * time.Millisecond
+	// How long to ping before the load starts to get a baseline for the idle network.
+	PingIdleTime time.Duration = 2 * time.Second
+	// A ping whose reply has not arrived after this long is considered lost.
+	PingLossTimeout time.Duration = 2 * time.Second
+
+	// The number of throughput measurements in each of the two windows compared when deciding
+	// whether the link was saturated.
+	SaturationAssessmentWindow int = 4
+	// The link is considered saturated when throughput grows by less than this percentage
+	// from one window to the next despite the addition of connections.
+	SaturationThroughputGainThreshold float64 = 5.0
+
+	// The default amount of time (in seconds) that the measurement loop may go without any
+	// throughput or probe measurements before the test is aborted (0 disables the watchdog).
+	DefaultWatchdogPeriod uint = 0
+
+	// The number of handshakes at the start and at the end of a test whose RTTs are compared
+	// to see how much the handshake RTT inflated.
+	HandshakeRttInflationWindow int = 10
+
+	// The compression of the t-digests that estimate RTT percentiles when the user asks for
+	// streaming percentiles (higher is more accurate but uses more memory).
+	TDigestCompression uint = 100
+
+	// The HDR histograms of the probes' RTTs (see -hgrm-file) count RTTs (in microseconds) up
+	// to this long with this many significant digits, and their percentile distributions have
+	// this many percentiles in every halving of the distance to 100% (as HdrHistogram's do).
+	HdrHistogramHighestRtt           time.Duration = 60 * time.Second
+	HdrHistogramSignificantDigits    int           = 3
+	HdrHistogramTicksPerHalfDistance int           = 5
+
+	// The number of (equal-width) throughput bins in the table of self probe RTT versus throughput.
+	ThroughputRttCorrelationBins int = 5
 
 	// The amount of time that the client will cooldown if it is in debug mode.
 	CooldownPeriod time.Duration = 4 * time.Second
+
+	// The offered loads (as percentages of estimated capacity) that a pacing experiment steps through.
+	PacingExperimentStartLoad float64 = 50.0
+	PacingExperimentEndLoad   float64 = 120.0
+	PacingExperimentLoadStep  float64 = 10.0
+	// The default amount of time (in seconds) to spend at each step of a pacing experiment.
+	DefaultPacingStepTime int = 5
+	// Measurements taken this soon after the pace changes are not attributed to the new step.
+	PacingSettleTime time.Duration = 1 * time.Second
+	// A load-generating connection whose rate is capped may transfer this much time's worth
+	// of data in a burst.
+	ConnectionRateLimitBurst time.Duration = 50 * time.Millisecond
+	// The default percentage of the RTTs trimmed from each end before calculating the
+	// trimmed-mean RPM.
+	DefaultTrimPercentage uint = 10
+	// The size of the (pre-filled) buffer from which every load-generating upload sends its
+	// body. It is also the most that an upload hands to its connection at a time.
+	UploadPayloadSize int = 32 * 1024
+	// The size of the (pooled) buffers in to which load-generating downloads read.
+	DownloadBufferSize int = 256 * 1024
+	// A load-generating download counts what it reads locally and adds it to the count that
+	// the throughput measurements see once it has this many bytes ...
+	DownloadCountBatchSize uint64 = 256 * 1024
+	// ... or once this much time has passed (so that slow downloads are counted promptly).
+	DownloadCountBatchTime time.Duration = 10 * time.Millisecond
+
+	// A run regressed when its measurement is this many (robust) standard deviations worse
+	// than the median of the previous runs (see history.DetectRegressions) ...
+	RegressionModifiedZScoreThreshold float64 = 3.5
+	// ... and at least this many percent worse than that median.
+	RegressionMinimumDegradation float64 = 10.0
+	// The fewest previous runs that a run is compared with.
+	RegressionMinimumRuns int = 3
+
+	// How often the client samples its CPU utilization during a test.
+	CPUSampleInterval time.Duration = 500 * time.Millisecond
+	// The client is CPU-bound when it (or the whole system) is, on average, at least this
+	// busy (in percent of the capacity of all cores) while generating load.
+	CPUBoundUtilization float64 = 90.0
+
+	// The amount of time that a webhook output has to accept the results.
+	OutputWebhookTimeout time.Duration = 10 * time.Second
+	// The amount of time that the URLs of the configuration have to answer when it is
+	// validated (see -validate-config).
+	ConfigCheckTimeout time.Duration = 10 * time.Second
 	// The amount of time that we give ourselves to calculate the RPM.
 	RPMCalculationTime int = 10
 
+	// The default amount of time (in seconds) at the start of a test whose measurements are
+	// excluded from stability and RPM calculations.
+	DefaultWarmupTime int = 0
+
 	// The default amount of time that a test will take to calculate the RPM.
 	DefaultTestTime int = 20
 	// The default port number to which to connect on the config host.
@@ -46,6 +178,9 @@ var (
 	DefaultDebug bool = false
 	// The default URL for the config host.
 	DefaultConfigHost string = "networkquality.example.com"
+	// When limited to HTTP/1.1, the number of idle connections to each host that a transport
+	// keeps for reuse (Go keeps only 2 by default).
+	HTTP1MaxIdleConnsPerHost int = 100
 	// The default determination of whether to verify server certificates
 	DefaultInsecureSkipVerify bool = true
 )
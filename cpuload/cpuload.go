@@ -0,0 +1,147 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package cpuload follows how busy the client's CPU is during a test. A client that runs
+// out of CPU measures its own limits rather than the network's.
+package cpuload
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// The CPU time that the process and the system had used by a point in time.
+type sample struct {
+	time time.Time
+	// The CPU time (user and system) of this process.
+	process   time.Duration
+	processOk bool
+	// The (platform-dependent) units of time that all of the system's cores were busy and
+	// that they existed at all.
+	systemBusy  uint64
+	systemTotal uint64
+	systemOk    bool
+}
+
+func takeSample() sample {
+	s := sample{time: time.Now()}
+	if process, err := processTime(); err == nil {
+		s.process, s.processOk = process, true
+	}
+	if busy, total, err := systemTimes(); err == nil {
+		s.systemBusy, s.systemTotal, s.systemOk = busy, total, true
+	}
+	return s
+}
+
+// The utilization (in percent of the capacity of all cores) of the process and of the whole
+// system between two samples.
+func utilization(previous, current sample, cores int) (process float64, processOk bool, system float64, systemOk bool) {
+	if elapsed := current.time.Sub(previous.time); previous.processOk && current.processOk && elapsed > 0 && cores > 0 {
+		process = 100 * float64(current.process-previous.process) / (float64(elapsed) * float64(cores))
+		processOk = true
+	}
+	if previous.systemOk && current.systemOk && current.systemTotal > previous.systemTotal {
+		system = 100 * float64(current.systemBusy-previous.systemBusy) / float64(current.systemTotal-previous.systemTotal)
+		systemOk = true
+	}
+	return
+}
+
+type Summary struct {
+	Samples int
+	// Utilization of the process (in percent of the capacity of all cores) ...
+	ProcessMean float64
+	ProcessPeak float64
+	// ... and of the whole system (when the platform lets us tell).
+	System     bool
+	SystemMean float64
+	SystemPeak float64
+}
+
+// Whether the client (the process or the whole system) was busy enough, on average, that it
+// probably limited the throughput.
+func (summary Summary) Bound(threshold float64) bool {
+	if summary.Samples == 0 {
+		return false
+	}
+	return summary.ProcessMean >= threshold || (summary.System && summary.SystemMean >= threshold)
+}
+
+// A Monitor samples the CPU utilization periodically until it is stopped.
+type Monitor struct {
+	lock    sync.Mutex
+	summary Summary
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewMonitor(interval time.Duration) *Monitor {
+	monitor := &Monitor{stop: make(chan struct{}), done: make(chan struct{})}
+	go monitor.run(interval)
+	return monitor
+}
+
+func (monitor *Monitor) run(interval time.Duration) {
+	defer close(monitor.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cores := runtime.NumCPU()
+	previous := takeSample()
+	processSum, systemSum, systemSamples := 0.0, 0.0, 0
+	for {
+		select {
+		case <-monitor.stop:
+			return
+		case <-ticker.C:
+		}
+		current := takeSample()
+		process, processOk, system, systemOk := utilization(previous, current, cores)
+		previous = current
+		if !processOk && !systemOk {
+			continue
+		}
+
+		monitor.lock.Lock()
+		monitor.summary.Samples++
+		if processOk {
+			processSum += process
+			monitor.summary.ProcessMean = processSum / float64(monitor.summary.Samples)
+			if process > monitor.summary.ProcessPeak {
+				monitor.summary.ProcessPeak = process
+			}
+		}
+		if systemOk {
+			systemSamples++
+			systemSum += system
+			monitor.summary.System = true
+			monitor.summary.SystemMean = systemSum / float64(systemSamples)
+			if system > monitor.summary.SystemPeak {
+				monitor.summary.SystemPeak = system
+			}
+		}
+		monitor.lock.Unlock()
+	}
+}
+
+// Stop sampling and summarize the samples.
+func (monitor *Monitor) Stop() Summary {
+	close(monitor.stop)
+	<-monitor.done
+	monitor.lock.Lock()
+	defer monitor.lock.Unlock()
+	return monitor.summary
+}
@@ -0,0 +1,64 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cpuload
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUtilization(t *testing.T) {
+	start := time.Date(2023, time.March, 15, 10, 0, 0, 0, time.UTC)
+	previous := sample{time: start, process: time.Second, processOk: true, systemBusy: 100, systemTotal: 1000, systemOk: true}
+	current := sample{time: start.Add(time.Second), process: 3 * time.Second, processOk: true, systemBusy: 400, systemTotal: 1400, systemOk: true}
+
+	process, processOk, system, systemOk := utilization(previous, current, 4)
+	if !processOk || process != 50 {
+		t.Fatalf("Two seconds of CPU time in a second on four cores is 50%%, not %v (%v).", process, processOk)
+	}
+	if !systemOk || system != 75 {
+		t.Fatalf("300 of 400 busy units is 75%%, not %v (%v).", system, systemOk)
+	}
+
+	current.systemOk = false
+	if _, _, _, systemOk := utilization(previous, current, 4); systemOk {
+		t.Fatalf("Without the system's times, there is no system utilization.")
+	}
+}
+
+func TestSummaryBound(t *testing.T) {
+	if (Summary{}).Bound(90) {
+		t.Fatalf("Without samples, the client cannot be CPU-bound.")
+	}
+	if !(Summary{Samples: 3, ProcessMean: 95}).Bound(90) {
+		t.Fatalf("A busy process should be CPU-bound.")
+	}
+	if (Summary{Samples: 3, ProcessMean: 20, SystemMean: 95}).Bound(90) {
+		t.Fatalf("The system's utilization should only count when it is known.")
+	}
+	if !(Summary{Samples: 3, ProcessMean: 20, System: true, SystemMean: 95}).Bound(90) {
+		t.Fatalf("A busy system should be CPU-bound.")
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	monitor := NewMonitor(10 * time.Millisecond)
+	for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); {
+	}
+	summary := monitor.Stop()
+	if summary.Samples == 0 || summary.ProcessPeak <= 0 {
+		t.Fatalf("The monitor should have seen the process busy: %+v", summary)
+	}
+}
@@ -0,0 +1,27 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cpuload
+
+import "testing"
+
+func TestParseSystemTimes(t *testing.T) {
+	busy, total, err := parseSystemTimes("cpu  100 10 50 800 20 5 5 10 30 0")
+	if err != nil || busy != 180 || total != 1000 {
+		t.Fatalf("Parsed the CPU statistics wrong: %d busy of %d (%v)", busy, total, err)
+	}
+	if _, _, err := parseSystemTimes("cpu0 1 2 3 4"); err == nil {
+		t.Fatalf("Only the line for all the cores should parse.")
+	}
+}
@@ -15,27 +15,107 @@
 package datalogger
 
 import (
+	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/network-quality/goresponsiveness/utilities"
 )
 
 type DataLogger[T any] interface {
 	LogRecord(record T)
+	// Attach a note about how the data was gathered (written with the rest of the metadata).
+	Annotate(note string)
 	Export() bool
 	Close() bool
 }
 
+// Information about the data set that a logger records. It is written as a
+// block of comments at the top of each exported file so that the file stands
+// on its own when it is shared.
+type DataLoggerMetadata struct {
+	Description   string
+	ClientVersion string
+	RunId         string
+	// When set, every timestamp in the log is written as the number of seconds since
+	// this instant (using the monotonic clock) rather than as a wall-clock time. All
+	// the loggers for a run should share the same Epoch.
+	Epoch time.Time
+	// Anything else that a reader of the data should know about how it was gathered.
+	Notes []string
+}
+
+// What a CSV data logger does with a record when its buffer is full because the destination
+// cannot keep up.
+type BackpressurePolicy int
+
+const (
+	// Wait for room in the buffer: no record is lost, but a slow destination slows down
+	// whoever logs the record.
+	BlockWhenFull BackpressurePolicy = iota
+	// Drop the record (and count it): the measurements never wait for the destination.
+	DropWhenFull
+)
+
+func ParseBackpressurePolicy(policy string) (BackpressurePolicy, error) {
+	switch strings.ToLower(policy) {
+	case "block":
+		return BlockWhenFull, nil
+	case "drop":
+		return DropWhenFull, nil
+	}
+	return BlockWhenFull, fmt.Errorf("unknown backpressure policy %q (use block or drop)", policy)
+}
+
+func (policy BackpressurePolicy) String() string {
+	if policy == DropWhenFull {
+		return "drop"
+	}
+	return "block"
+}
+
+// How a CSV data logger buffers the records on their way to the destination (and how it
+// rotates the file that is its destination).
+type BufferOptions struct {
+	// The number of records that can wait to be written.
+	BufferSize int
+	// How often the records that were written are flushed to the destination.
+	FlushInterval time.Duration
+	Backpressure  BackpressurePolicy
+	Rotation      RotationOptions
+}
+
+func DefaultBufferOptions() BufferOptions {
+	return BufferOptions{BufferSize: 4096, FlushInterval: time.Second, Backpressure: BlockWhenFull}
+}
+
+// A CSV data logger writes its records from a goroutine of its own so that logging a record
+// (usually in the middle of processing a measurement) never waits for the destination.
 type CSVDataLogger[T any] struct {
 	mut         *sync.Mutex
 	recordCount int
-	data        []T
 	isOpen      bool
 	destination io.WriteCloser
+	metadata    DataLoggerMetadata
+
+	// Held (for reading) while a record is handed to the writer so that the records channel
+	// is not closed under a sender.
+	sending       *sync.RWMutex
+	options       BufferOptions
+	records       chan T
+	exports       chan chan struct{}
+	writerDone    chan struct{}
+	droppedCount  uint64
+	writer        *bufio.Writer
+	fields        []reflect.StructField
+	headerWritten bool
+	// Notes that were attached after the header block was written.
+	pendingNotes []string
 }
 
 type NullDataLogger[T any] struct{}
@@ -44,26 +124,179 @@ func CreateNullDataLogger[T any]() DataLogger[T] {
 	return &NullDataLogger[T]{}
 }
 
-func (_ *NullDataLogger[T]) LogRecord(_ T) {}
-func (_ *NullDataLogger[T]) Export() bool  { return true }
-func (_ *NullDataLogger[T]) Close() bool   { return true }
+func (_ *NullDataLogger[T]) LogRecord(_ T)     {}
+func (_ *NullDataLogger[T]) Annotate(_ string) {}
+func (_ *NullDataLogger[T]) Export() bool      { return true }
+func (_ *NullDataLogger[T]) Close() bool       { return true }
 
-func CreateCSVDataLogger[T any](filename string) (DataLogger[T], error) {
-	data := make([]T, 0)
-	destination, err := os.Create(filename)
+func CreateCSVDataLogger[T any](filename string, metadata DataLoggerMetadata) (DataLogger[T], error) {
+	return CreateBufferedCSVDataLogger[T](filename, metadata, DefaultBufferOptions())
+}
+
+func CreateBufferedCSVDataLogger[T any](
+	filename string,
+	metadata DataLoggerMetadata,
+	options BufferOptions,
+) (DataLogger[T], error) {
+	destination, err := createRotatingFile(filename, options.Rotation)
 	if err != nil {
-		return &CSVDataLogger[T]{&sync.Mutex{}, 0, data, true, destination}, err
+		return nil, err
+	}
+	return newCSVDataLogger[T](destination, metadata, options), nil
+}
+
+func newCSVDataLogger[T any](
+	destination io.WriteCloser,
+	metadata DataLoggerMetadata,
+	options BufferOptions,
+) *CSVDataLogger[T] {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultBufferOptions().BufferSize
 	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultBufferOptions().FlushInterval
+	}
+	fields := make([]reflect.StructField, 0)
+	for _, v := range reflect.VisibleFields(reflect.TypeOf((*T)(nil)).Elem()) {
+		if description, success := v.Tag.Lookup("Description"); !success || description != "[OMIT]" {
+			fields = append(fields, v)
+		}
+	}
+	logger := &CSVDataLogger[T]{
+		mut:         &sync.Mutex{},
+		isOpen:      true,
+		destination: destination,
+		metadata:    metadata,
+		sending:     &sync.RWMutex{},
+		options:     options,
+		records:     make(chan T, options.BufferSize),
+		exports:     make(chan chan struct{}),
+		writerDone:  make(chan struct{}),
+		writer:      bufio.NewWriter(destination),
+		fields:      fields,
+	}
+	go logger.write(logger.records)
+	return logger
+}
 
-	result := CSVDataLogger[T]{&sync.Mutex{}, 0, data, true, destination}
-	return &result, nil
+func (logger *CSVDataLogger[T]) Annotate(note string) {
+	logger.mut.Lock()
+	defer logger.mut.Unlock()
+	logger.metadata.Notes = append(logger.metadata.Notes, note)
+	logger.pendingNotes = append(logger.pendingNotes, note)
 }
 
 func (logger *CSVDataLogger[T]) LogRecord(record T) {
+	logger.sending.RLock()
+	defer logger.sending.RUnlock()
+	if logger.records == nil {
+		return
+	}
+	if logger.options.Backpressure == DropWhenFull {
+		select {
+		case logger.records <- record:
+		default:
+			atomic.AddUint64(&logger.droppedCount, 1)
+		}
+		return
+	}
+	logger.records <- record
+}
+
+// The writer goroutine: it writes the records as they come and flushes them periodically,
+// whenever they are exported and when the logger is closed.
+func (logger *CSVDataLogger[T]) write(records <-chan T) {
+	defer close(logger.writerDone)
+	ticker := time.NewTicker(logger.options.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				logger.flush()
+				return
+			}
+			logger.writeRecord(record)
+		case exported := <-logger.exports:
+			// Everything that was logged before the export goes out with it.
+			for drained := false; !drained; {
+				select {
+				case record, ok := <-records:
+					if ok {
+						logger.writeRecord(record)
+					} else {
+						drained = true
+					}
+				default:
+					drained = true
+				}
+			}
+			logger.flush()
+			close(exported)
+		case <-ticker.C:
+			logger.flush()
+		}
+	}
+}
+
+// Write the header block (once) and the notes that were attached since it was written.
+func (logger *CSVDataLogger[T]) writeNotes() {
 	logger.mut.Lock()
 	defer logger.mut.Unlock()
+	if !logger.headerWritten {
+		writeHeaderBlock(logger.writer, logger.metadata, logger.fields)
+		for _, v := range logger.fields {
+			columnName := v.Name
+			if description, success := v.Tag.Lookup("Description"); success {
+				columnName = description
+			}
+			logger.writer.Write([]byte(fmt.Sprintf("%s, ", columnName)))
+		}
+		logger.writer.Write([]byte("\n"))
+		logger.headerWritten = true
+	} else {
+		for _, note := range logger.pendingNotes {
+			logger.writer.Write([]byte(fmt.Sprintf("# Note: %s\n", note)))
+		}
+	}
+	logger.pendingNotes = nil
+}
+
+func (logger *CSVDataLogger[T]) writeRecord(record T) {
+	logger.writeNotes()
+	data := reflect.ValueOf(record)
+	for _, v := range logger.fields {
+		toWrite := data.FieldByIndex(v.Index)
+		if v.Type == timeType && !logger.metadata.Epoch.IsZero() {
+			sinceEpoch := toWrite.Interface().(time.Time).Sub(logger.metadata.Epoch)
+			logger.writer.Write([]byte(fmt.Sprintf("%.9f, ", sinceEpoch.Seconds())))
+		} else if formattedToWrite, err := doCustomFormatting(toWrite, v.Tag); err == nil {
+			logger.writer.Write([]byte(fmt.Sprintf("%s,", formattedToWrite)))
+		} else {
+			logger.writer.Write([]byte(fmt.Sprintf("%v, ", toWrite)))
+		}
+	}
+	logger.writer.Write([]byte("\n"))
+	logger.mut.Lock()
 	logger.recordCount += 1
-	logger.data = append(logger.data, record)
+	logger.mut.Unlock()
+
+	// A rotated file starts over with the header block (and every note) so that it, too,
+	// stands on its own.
+	if rotating, ok := logger.destination.(*rotatingFile); ok && rotating.full(logger.writer.Buffered()) {
+		logger.writer.Flush()
+		if err := rotating.rotate(); err == nil {
+			logger.mut.Lock()
+			logger.headerWritten = false
+			logger.pendingNotes = nil
+			logger.mut.Unlock()
+		}
+	}
+}
+
+func (logger *CSVDataLogger[T]) flush() {
+	logger.writeNotes()
+	logger.writer.Flush()
 }
 
 func doCustomFormatting(value reflect.Value, tag reflect.StructTag) (string, error) {
@@ -101,55 +334,100 @@ func doCustomFormatting(value reflect.Value, tag reflect.StructTag) (string, err
 	return "", fmt.Errorf("Too many results returned by the format method's invocation.")
 }
 
-func (logger *CSVDataLogger[T]) Export() bool {
-	logger.mut.Lock()
-	defer logger.mut.Unlock()
-	if !logger.isOpen {
-		return false
+var timeType = reflect.TypeOf(time.Time{})
+
+// Describe the units of a field, either as given explicitly by its Units tag or as
+// implied by the way that it is formatted.
+func describeUnits(field reflect.StructField, metadata DataLoggerMetadata) string {
+	if field.Type == timeType && !metadata.Epoch.IsZero() {
+		return "seconds since the epoch"
 	}
+	tag := field.Tag
+	if units, success := tag.Lookup("Units"); success {
+		return units
+	}
+	switch formatter, _ := tag.Lookup("Formatter"); formatter {
+	case "Seconds":
+		return "seconds"
+	case "Format":
+		formatterArgument, _ := tag.Lookup("FormatterArgument")
+		return fmt.Sprintf("local time formatted as %s", formatterArgument)
+	}
+	return ""
+}
 
-	toOmit := make([]int, 0)
-	visibleFields := reflect.VisibleFields(reflect.TypeOf((*T)(nil)).Elem())
-	for i, v := range visibleFields {
-		description, success := v.Tag.Lookup("Description")
+func writeHeaderBlock(destination io.Writer, metadata DataLoggerMetadata, fields []reflect.StructField) {
+	if metadata.Description != "" {
+		destination.Write([]byte(fmt.Sprintf("# %s\n", metadata.Description)))
+	}
+	if metadata.ClientVersion != "" {
+		destination.Write([]byte(fmt.Sprintf("# Client Version: %s\n", metadata.ClientVersion)))
+	}
+	if metadata.RunId != "" {
+		destination.Write([]byte(fmt.Sprintf("# Run ID: %s\n", metadata.RunId)))
+	}
+	if !metadata.Epoch.IsZero() {
+		destination.Write(
+			[]byte(fmt.Sprintf("# Epoch: %s\n", metadata.Epoch.UTC().Format(time.RFC3339Nano))),
+		)
+	}
+	for _, note := range metadata.Notes {
+		destination.Write([]byte(fmt.Sprintf("# Note: %s\n", note)))
+	}
+	destination.Write([]byte("# Columns:\n"))
+	for _, v := range fields {
 		columnName := v.Name
-		if success {
-			if description == "[OMIT]" {
-				toOmit = append(toOmit, i)
-				continue
-			}
+		if description, success := v.Tag.Lookup("Description"); success {
 			columnName = description
 		}
-		logger.destination.Write([]byte(fmt.Sprintf("%s, ", columnName)))
+		if units := describeUnits(v, metadata); units != "" {
+			destination.Write([]byte(fmt.Sprintf("#   %s [%s]\n", columnName, units)))
+		} else {
+			destination.Write([]byte(fmt.Sprintf("#   %s\n", columnName)))
+		}
 	}
-	logger.destination.Write([]byte("\n"))
+}
 
-	// Remove the Omitted fields
-	for _, i := range toOmit {
-		visibleFields = append(visibleFields[:i], visibleFields[i+1:]...)
+// Write (and flush) everything that was logged so far.
+func (logger *CSVDataLogger[T]) Export() bool {
+	logger.mut.Lock()
+	isOpen := logger.isOpen
+	logger.mut.Unlock()
+	if !isOpen {
+		return false
 	}
-
-	for _, d := range logger.data {
-		for _, v := range visibleFields {
-			data := reflect.ValueOf(d)
-			toWrite := data.FieldByIndex(v.Index)
-			if formattedToWrite, err := doCustomFormatting(toWrite, v.Tag); err == nil {
-				logger.destination.Write([]byte(fmt.Sprintf("%s,", formattedToWrite)))
-			} else {
-				logger.destination.Write([]byte(fmt.Sprintf("%v, ", toWrite)))
-			}
-		}
-		logger.destination.Write([]byte("\n"))
+	exported := make(chan struct{})
+	select {
+	case logger.exports <- exported:
+		<-exported
+	case <-logger.writerDone:
+		return false
 	}
 	return true
 }
 
+// Stop logging, write (and flush) the records that are still buffered and close the
+// destination.
 func (logger *CSVDataLogger[T]) Close() bool {
-	logger.mut.Lock()
-	defer logger.mut.Unlock()
-	if !logger.isOpen {
+	logger.sending.Lock()
+	if logger.records == nil {
+		logger.sending.Unlock()
 		return false
 	}
+	close(logger.records)
+	logger.records = nil
+	logger.sending.Unlock()
+	<-logger.writerDone
+
+	if dropped := atomic.LoadUint64(&logger.droppedCount); dropped > 0 {
+		logger.writer.Write([]byte(fmt.Sprintf(
+			"# Note: %d records were dropped because the destination could not keep up.\n", dropped,
+		)))
+		logger.writer.Flush()
+	}
+
+	logger.mut.Lock()
+	defer logger.mut.Unlock()
 	logger.destination.Close()
 	logger.isOpen = false
 	return true
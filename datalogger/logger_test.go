@@ -0,0 +1,280 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package datalogger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testDataPoint struct {
+	Time     time.Time     `Description:"Time of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	Value    float64       `Description:"A value."                Units:"widgets"`
+	Duration time.Duration `Description:"A duration."             Formatter:"Seconds"`
+	Hidden   int           `Description:"[OMIT]"`
+}
+
+func TestCSVHeaderBlock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "header.csv")
+	logger, err := CreateCSVDataLogger[testDataPoint](
+		filename,
+		DataLoggerMetadata{Description: "Test data.", ClientVersion: "test/1.0", RunId: "abcdef"},
+	)
+	if err != nil {
+		t.Fatalf("Could not create the CSV data logger: %v", err)
+	}
+	logger.LogRecord(testDataPoint{Time: time.Now(), Value: 1.0, Duration: time.Second})
+	logger.Export()
+	logger.Close()
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read the exported CSV file: %v", err)
+	}
+	lines := strings.Split(string(contents), "\n")
+	expectedHeader := []string{
+		"# Test data.",
+		"# Client Version: test/1.0",
+		"# Run ID: abcdef",
+		"# Columns:",
+		"#   Time of the data point. [local time formatted as 01-02-2006-15-04-05.000]",
+		"#   A value. [widgets]",
+		"#   A duration. [seconds]",
+	}
+	if len(lines) < len(expectedHeader) {
+		t.Fatalf("Exported CSV file is too short: %v", lines)
+	}
+	for i, expected := range expectedHeader {
+		if lines[i] != expected {
+			t.Fatalf("Header line %d should be %q but is %q.", i, expected, lines[i])
+		}
+	}
+	if strings.HasPrefix(lines[len(expectedHeader)], "#") {
+		t.Fatalf("Column names should directly follow the header block.")
+	}
+}
+
+func TestCSVEpochRelativeTimes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "epoch.csv")
+	epoch := time.Now()
+	logger, err := CreateCSVDataLogger[testDataPoint](filename, DataLoggerMetadata{Epoch: epoch})
+	if err != nil {
+		t.Fatalf("Could not create the CSV data logger: %v", err)
+	}
+	logger.LogRecord(testDataPoint{Time: epoch.Add(1500 * time.Millisecond)})
+	logger.Export()
+	logger.Close()
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read the exported CSV file: %v", err)
+	}
+	if !strings.Contains(string(contents), "#   Time of the data point. [seconds since the epoch]") {
+		t.Fatalf("Header does not describe times relative to the epoch: %s", contents)
+	}
+	if !strings.Contains(string(contents), "\n1.500000000, ") {
+		t.Fatalf("Time was not written relative to the epoch: %s", contents)
+	}
+}
+
+// A destination that holds up the first write until it is released.
+type stalledDestination struct {
+	strings.Builder
+	stalled  chan struct{}
+	released chan struct{}
+	once     sync.Once
+}
+
+func (destination *stalledDestination) Write(p []byte) (int, error) {
+	destination.once.Do(func() {
+		close(destination.stalled)
+		<-destination.released
+	})
+	return destination.Builder.Write(p)
+}
+
+func (destination *stalledDestination) Close() error { return nil }
+
+func TestCSVDropsWhenFull(t *testing.T) {
+	destination := &stalledDestination{stalled: make(chan struct{}), released: make(chan struct{})}
+	logger := newCSVDataLogger[testDataPoint](
+		destination,
+		DataLoggerMetadata{},
+		BufferOptions{BufferSize: 1, FlushInterval: time.Hour, Backpressure: DropWhenFull},
+	)
+	go logger.Export()
+	<-destination.stalled
+
+	// The writer is stuck on the destination: one record fits in the buffer and the rest
+	// are dropped rather than holding up the caller.
+	for i := 0; i < 3; i++ {
+		logger.LogRecord(testDataPoint{Value: float64(i)})
+	}
+	close(destination.released)
+	logger.Close()
+
+	contents := destination.String()
+	if !strings.Contains(contents, "\n01-01-0001-00-00-00.000,0, ") {
+		t.Fatalf("The record that fit in the buffer was not written: %s", contents)
+	}
+	if !strings.Contains(contents, "# Note: 2 records were dropped because the destination could not keep up.\n") {
+		t.Fatalf("The dropped records were not noted: %s", contents)
+	}
+}
+
+func TestCSVPeriodicFlush(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "flush.csv")
+	logger, err := CreateBufferedCSVDataLogger[testDataPoint](
+		filename,
+		DataLoggerMetadata{},
+		BufferOptions{BufferSize: 16, FlushInterval: 10 * time.Millisecond},
+	)
+	if err != nil {
+		t.Fatalf("Could not create the CSV data logger: %v", err)
+	}
+	defer logger.Close()
+	logger.LogRecord(testDataPoint{Value: 42})
+
+	// Without an export, the record still reaches the file.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Could not read the CSV file: %v", err)
+		}
+		if strings.Contains(string(contents), ",42, ") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The record was never flushed: %s", contents)
+		}
+	}
+}
+
+func TestCSVLateNotes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "notes.csv")
+	logger, err := CreateCSVDataLogger[testDataPoint](filename, DataLoggerMetadata{})
+	if err != nil {
+		t.Fatalf("Could not create the CSV data logger: %v", err)
+	}
+	logger.Annotate("Before the first record.")
+	logger.LogRecord(testDataPoint{Value: 1})
+	logger.Export()
+	logger.Annotate("After the first record.")
+	logger.LogRecord(testDataPoint{Value: 2})
+	logger.Close()
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read the CSV file: %v", err)
+	}
+	lines := strings.Split(string(contents), "\n")
+	if lines[0] != "# Note: Before the first record." {
+		t.Fatalf("A note attached before the first record should be in the header block: %s", contents)
+	}
+	if lines[len(lines)-3] != "# Note: After the first record." {
+		t.Fatalf("A note attached later should precede the records that followed it: %s", contents)
+	}
+}
+
+func TestCSVRotation(t *testing.T) {
+	directory := t.TempDir()
+	filename := filepath.Join(directory, "rotated.csv")
+	logger, err := CreateBufferedCSVDataLogger[testDataPoint](
+		filename,
+		DataLoggerMetadata{Description: "Rotated data."},
+		BufferOptions{Rotation: RotationOptions{MaxFileSize: 600, MaxFiles: 2}},
+	)
+	if err != nil {
+		t.Fatalf("Could not create the CSV data logger: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		logger.LogRecord(testDataPoint{Value: float64(i)})
+	}
+	logger.Close()
+
+	// The current file and two rotated ones, each with a header block of its own.
+	matches, _ := filepath.Glob(filepath.Join(directory, "*"))
+	if len(matches) != 3 {
+		t.Fatalf("There should be three files, not %v.", matches)
+	}
+	for _, name := range []string{filename, filepath.Join(directory, "rotated.1.csv"), filepath.Join(directory, "rotated.2.csv")} {
+		contents, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Could not read %s: %v", name, err)
+		}
+		if !strings.HasPrefix(string(contents), "# Rotated data.\n") {
+			t.Fatalf("%s does not start with the header block: %s", name, contents)
+		}
+		if len(contents) > 700 {
+			t.Fatalf("%s is much larger than the maximum file size (%d bytes).", name, len(contents))
+		}
+	}
+	contents, _ := os.ReadFile(filename)
+	if !strings.Contains(string(contents), ",99, ") {
+		t.Fatalf("The current file should have the last record: %s", contents)
+	}
+}
+
+func TestRotateFailureKeepsFile(t *testing.T) {
+	directory := t.TempDir()
+	filename := filepath.Join(directory, "stuck.csv")
+	rotating, err := createRotatingFile(filename, RotationOptions{MaxFileSize: 10, MaxFiles: 1})
+	if err != nil {
+		t.Fatalf("Could not create the file: %v", err)
+	}
+	// A (non-empty) directory in the way of the rotated file keeps the file from rotating.
+	inTheWay := filepath.Join(directory, "stuck.1.csv")
+	if err := os.MkdirAll(filepath.Join(inTheWay, "occupied"), 0o755); err != nil {
+		t.Fatalf("Could not create %s: %v", inTheWay, err)
+	}
+
+	rotating.Write([]byte("before rotation\n"))
+	if err := rotating.rotate(); err == nil {
+		t.Fatalf("The file should not have been rotated.")
+	}
+	if _, err := rotating.Write([]byte("after rotation\n")); err != nil {
+		t.Fatalf("The file should still be open after a failed rotation: %v", err)
+	}
+	rotating.Close()
+
+	contents, _ := os.ReadFile(filename)
+	if string(contents) != "before rotation\nafter rotation\n" {
+		t.Fatalf("The file should have kept every record: %q", contents)
+	}
+}
+
+func TestPruneFiles(t *testing.T) {
+	directory := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"log-a.csv", "log-b.csv", "log-c.csv", "other.csv"} {
+		filename := filepath.Join(directory, name)
+		if err := os.WriteFile(filename, []byte("data"), 0o644); err != nil {
+			t.Fatalf("Could not create %s: %v", name, err)
+		}
+		modified := now.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(filename, modified, modified)
+	}
+	if err := PruneFiles(filepath.Join(directory, "log-*.csv"), 2); err != nil {
+		t.Fatalf("Could not prune the files: %v", err)
+	}
+	remaining, _ := filepath.Glob(filepath.Join(directory, "*"))
+	if len(remaining) != 3 || remaining[0] != filepath.Join(directory, "log-b.csv") {
+		t.Fatalf("Only the oldest matching file should have been deleted: %v", remaining)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package datalogger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Limits on how much of the disk a CSV data logger may use, for logging that goes on for a
+// long time on devices with little storage.
+type RotationOptions struct {
+	// The size (in bytes) beyond which a file is rotated: it is renamed (to the name of the
+	// file with .1 before its extension) and a new file is started. 0 means that files are
+	// never rotated.
+	MaxFileSize int64
+	// The number of rotated files to keep; the oldest of them are deleted.
+	MaxFiles int
+}
+
+// A file that is rotated once it reaches its maximum size.
+type rotatingFile struct {
+	filename string
+	options  RotationOptions
+	file     *os.File
+	size     int64
+}
+
+func createRotatingFile(filename string, options RotationOptions) (*rotatingFile, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingFile{filename: filename, options: options, file: file}, nil
+}
+
+func (rotating *rotatingFile) Write(p []byte) (int, error) {
+	written, err := rotating.file.Write(p)
+	rotating.size += int64(written)
+	return written, err
+}
+
+func (rotating *rotatingFile) Close() error {
+	return rotating.file.Close()
+}
+
+// Whether the file (with what is still to be written to it) has reached its maximum size.
+func (rotating *rotatingFile) full(pending int) bool {
+	return rotating.options.MaxFileSize > 0 &&
+		rotating.size+int64(pending) >= rotating.options.MaxFileSize
+}
+
+// The name of the file that was rotated generation times ago.
+func rotatedFilename(filename string, generation int) string {
+	extension := filepath.Ext(filename)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(filename, extension), generation, extension)
+}
+
+func (rotating *rotatingFile) rotate() error {
+	if err := rotating.file.Close(); err != nil {
+		return rotating.reopen(err)
+	}
+	if rotating.options.MaxFiles > 0 {
+		os.Remove(rotatedFilename(rotating.filename, rotating.options.MaxFiles))
+		for generation := rotating.options.MaxFiles - 1; generation > 0; generation-- {
+			os.Rename(
+				rotatedFilename(rotating.filename, generation),
+				rotatedFilename(rotating.filename, generation+1),
+			)
+		}
+		if err := os.Rename(rotating.filename, rotatedFilename(rotating.filename, 1)); err != nil {
+			return rotating.reopen(err)
+		}
+	}
+	file, err := os.Create(rotating.filename)
+	if err != nil {
+		return rotating.reopen(err)
+	}
+	rotating.file, rotating.size = file, 0
+	return nil
+}
+
+// When the file could not be rotated, keep appending to it (beyond its maximum size) rather
+// than lose the records that follow. Returns why the file could not be rotated.
+func (rotating *rotatingFile) reopen(cause error) error {
+	file, err := os.OpenFile(rotating.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("%v (and %s could not be reopened: %v)", cause, rotating.filename, err)
+	}
+	rotating.file = file
+	return cause
+}
+
+// Delete all but the keep most recently modified files that match the pattern (see
+// filepath.Match). Returns the first error, if any, but tries to delete every file.
+func PruneFiles(pattern string, keep int) error {
+	filenames, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	type candidate struct {
+		filename string
+		info     os.FileInfo
+	}
+	candidates := make([]candidate, 0, len(filenames))
+	for _, filename := range filenames {
+		if info, err := os.Stat(filename); err == nil && info.Mode().IsRegular() {
+			candidates = append(candidates, candidate{filename, info})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].info.ModTime().After(candidates[j].info.ModTime())
+	})
+	var firstErr error
+	for i := keep; i < len(candidates); i++ {
+		if err := os.Remove(candidates[i].filename); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,265 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package datalogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// Whether a data logger's destination names a standard stream rather than a file (- is
+// stdout) and, if so, the stream.
+func StandardStream(name string) (io.Writer, bool) {
+	switch name {
+	case "-", "/dev/stdout":
+		return os.Stdout, true
+	case "/dev/stderr":
+		return os.Stderr, true
+	}
+	return nil, false
+}
+
+// A stream that several data loggers share. Each of them writes every record (and note) as
+// a line of JSON, so that the stream can be piped into other programs as the test runs. Like
+// a CSV data logger, the stream writes its lines from a goroutine of its own (and flushes
+// them periodically) so that logging never waits for the destination.
+type Stream struct {
+	// Held (for reading) while a line is handed to the writer so that the lines channel is
+	// not closed under a sender.
+	sending      sync.RWMutex
+	options      BufferOptions
+	lines        chan []byte
+	flushes      chan chan struct{}
+	writerDone   chan struct{}
+	droppedCount uint64
+	writer       *bufio.Writer
+}
+
+func NewStream(destination io.Writer, options BufferOptions) *Stream {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultBufferOptions().BufferSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultBufferOptions().FlushInterval
+	}
+	stream := &Stream{
+		options:    options,
+		lines:      make(chan []byte, options.BufferSize),
+		flushes:    make(chan chan struct{}),
+		writerDone: make(chan struct{}),
+		writer:     bufio.NewWriter(destination),
+	}
+	go stream.write(stream.lines)
+	return stream
+}
+
+// Hand one line to the writer; the lines of different loggers never interleave.
+func (stream *Stream) writeLine(line map[string]interface{}) {
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	encoded = append(encoded, '\n')
+	stream.sending.RLock()
+	defer stream.sending.RUnlock()
+	if stream.lines == nil {
+		return
+	}
+	if stream.options.Backpressure == DropWhenFull {
+		select {
+		case stream.lines <- encoded:
+		default:
+			atomic.AddUint64(&stream.droppedCount, 1)
+		}
+		return
+	}
+	stream.lines <- encoded
+}
+
+// The writer goroutine: it writes the lines as they come and flushes them periodically,
+// whenever they are flushed and when the stream is closed.
+func (stream *Stream) write(lines <-chan []byte) {
+	defer close(stream.writerDone)
+	ticker := time.NewTicker(stream.options.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				stream.writer.Flush()
+				return
+			}
+			stream.writer.Write(line)
+		case flushed := <-stream.flushes:
+			// Everything that was logged before the flush goes out with it.
+			for drained := false; !drained; {
+				select {
+				case line, ok := <-lines:
+					if ok {
+						stream.writer.Write(line)
+					} else {
+						drained = true
+					}
+				default:
+					drained = true
+				}
+			}
+			stream.writer.Flush()
+			close(flushed)
+		case <-ticker.C:
+			stream.writer.Flush()
+		}
+	}
+}
+
+// Write (and flush) everything that was logged so far.
+func (stream *Stream) Flush() bool {
+	flushed := make(chan struct{})
+	select {
+	case stream.flushes <- flushed:
+		<-flushed
+	case <-stream.writerDone:
+		return false
+	}
+	return true
+}
+
+// Stop streaming and write (and flush) the lines that are still buffered. The destination
+// (a standard stream) stays open.
+func (stream *Stream) Close() bool {
+	stream.sending.Lock()
+	if stream.lines == nil {
+		stream.sending.Unlock()
+		return false
+	}
+	close(stream.lines)
+	stream.lines = nil
+	stream.sending.Unlock()
+	<-stream.writerDone
+
+	if dropped := atomic.LoadUint64(&stream.droppedCount); dropped > 0 {
+		encoded, _ := json.Marshal(map[string]interface{}{
+			"note": fmt.Sprintf("%d lines were dropped because the destination could not keep up.", dropped),
+		})
+		stream.writer.Write(append(encoded, '\n'))
+		stream.writer.Flush()
+	}
+	return true
+}
+
+// A data logger that writes to a Stream. Every line that it writes has the name of the
+// logger as its "log" and, besides, one of "metadata" (the first line), "note" or "record".
+type StreamDataLogger[T any] struct {
+	stream   *Stream
+	name     string
+	metadata DataLoggerMetadata
+	fields   []reflect.StructField
+}
+
+func CreateStreamDataLogger[T any](stream *Stream, name string, metadata DataLoggerMetadata) DataLogger[T] {
+	logger := &StreamDataLogger[T]{stream: stream, name: name, metadata: metadata}
+	for _, v := range reflect.VisibleFields(reflect.TypeOf((*T)(nil)).Elem()) {
+		if description, success := v.Tag.Lookup("Description"); !success || description != "[OMIT]" {
+			logger.fields = append(logger.fields, v)
+		}
+	}
+
+	described := map[string]interface{}{}
+	if metadata.Description != "" {
+		described["description"] = metadata.Description
+	}
+	if metadata.ClientVersion != "" {
+		described["client_version"] = metadata.ClientVersion
+	}
+	if metadata.RunId != "" {
+		described["run_id"] = metadata.RunId
+	}
+	if !metadata.Epoch.IsZero() {
+		described["epoch"] = metadata.Epoch.UTC().Format(time.RFC3339Nano)
+	}
+	stream.writeLine(map[string]interface{}{"log": name, "metadata": described})
+	for _, note := range metadata.Notes {
+		logger.Annotate(note)
+	}
+	return logger
+}
+
+func (logger *StreamDataLogger[T]) Annotate(note string) {
+	logger.stream.writeLine(map[string]interface{}{"log": logger.name, "note": note})
+}
+
+func (logger *StreamDataLogger[T]) LogRecord(record T) {
+	data := reflect.ValueOf(record)
+	fields := make(map[string]interface{}, len(logger.fields))
+	for _, v := range logger.fields {
+		fields[snakeCase(v.Name)] = logger.value(data.FieldByIndex(v.Index), v)
+	}
+	logger.stream.writeLine(map[string]interface{}{"log": logger.name, "record": fields})
+}
+
+// The value of a field as it is streamed: times and durations are in seconds (since the
+// epoch, for times, when there is one), values with a Formatter are formatted and everything
+// else is as it is.
+func (logger *StreamDataLogger[T]) value(value reflect.Value, field reflect.StructField) interface{} {
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if !logger.metadata.Epoch.IsZero() {
+			return v.Sub(logger.metadata.Epoch).Seconds()
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.Seconds()
+	}
+	if _, success := field.Tag.Lookup("Formatter"); success {
+		if formatted, err := doCustomFormatting(value, field.Tag); err == nil {
+			return formatted
+		}
+	}
+	return value.Interface()
+}
+
+// Write (and flush) everything that was logged to the stream so far (by any logger).
+func (logger *StreamDataLogger[T]) Export() bool { return logger.stream.Flush() }
+
+// The stream belongs to no one logger, so it stays open (see Stream.Close).
+func (logger *StreamDataLogger[T]) Close() bool { return true }
+
+// Name a field the way that JSON usually does (e.g., TCPRtt is tcp_rtt).
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var result strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			startsWord := i > 0 &&
+				(unicode.IsLower(runes[i-1]) ||
+					(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])))
+			if startsWord {
+				result.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
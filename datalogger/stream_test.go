@@ -0,0 +1,93 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package datalogger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamDataLogger(t *testing.T) {
+	var output strings.Builder
+	stream := NewStream(&output, DefaultBufferOptions())
+	epoch := time.Now()
+	logger := CreateStreamDataLogger[testDataPoint](stream, "test", DataLoggerMetadata{RunId: "abcdef", Epoch: epoch})
+	logger.LogRecord(testDataPoint{Time: epoch.Add(1500 * time.Millisecond), Value: 2, Duration: time.Second, Hidden: 7})
+	logger.Annotate("A note.")
+	if output.Len() != 0 {
+		t.Fatalf("The lines should have waited for the writer to flush them: %s", output.String())
+	}
+	if !logger.Export() {
+		t.Fatalf("Could not flush the stream.")
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("There should be a line for the metadata, the record and the note: %s", output.String())
+	}
+	var record struct {
+		Log    string                 `json:"log"`
+		Record map[string]interface{} `json:"record"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("The record is not JSON: %v", err)
+	}
+	if record.Log != "test" || record.Record["time"] != 1.5 || record.Record["value"] != 2.0 ||
+		record.Record["duration"] != 1.0 {
+		t.Fatalf("The record was not streamed as it should have been: %s", lines[1])
+	}
+	if _, hidden := record.Record["hidden"]; hidden {
+		t.Fatalf("An omitted field was streamed: %s", lines[1])
+	}
+	if !strings.Contains(lines[0], `"run_id":"abcdef"`) || lines[2] != `{"log":"test","note":"A note."}` {
+		t.Fatalf("The metadata and the note were not streamed as they should have been: %s", output.String())
+	}
+}
+
+func TestStreamClose(t *testing.T) {
+	var output strings.Builder
+	stream := NewStream(&output, DefaultBufferOptions())
+	logger := CreateStreamDataLogger[testDataPoint](stream, "test", DataLoggerMetadata{})
+	logger.LogRecord(testDataPoint{Value: 1})
+	if !logger.Close() || output.Len() != 0 {
+		t.Fatalf("Closing a logger should leave the stream to the other loggers: %s", output.String())
+	}
+	if !stream.Close() {
+		t.Fatalf("Could not close the stream.")
+	}
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 2 {
+		t.Fatalf("Closing the stream should have written the metadata and the record: %s", output.String())
+	}
+	logger.LogRecord(testDataPoint{Value: 2})
+	if stream.Close() || stream.Flush() || strings.Contains(output.String(), `"value":2`) {
+		t.Fatalf("A closed stream should write nothing more: %s", output.String())
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Time":           "time",
+		"TCPRtt":         "tcp_rtt",
+		"RoundTripCount": "round_trip_count",
+		"DNSDuration":    "dns_duration",
+		"RunId":          "run_id",
+	} {
+		if snakeCase(name) != expected {
+			t.Fatalf("%s should be %s, not %s.", name, expected, snakeCase(name))
+		}
+	}
+}
@@ -14,6 +14,8 @@
 
 package debug
 
+import "sync/atomic"
+
 type DebugLevel int8
 
 const (
@@ -36,12 +38,30 @@ func (d *DebugWithPrefix) String() string {
 	return d.Prefix
 }
 
+// Components capture their debug level when they are made. Forcing debug output (e.g., on
+// SIGUSR2; see ToggleOnSignal) makes every one of them debug without having to restart.
+var forced int32 = 0
+
+// Force debug output on (or back off) and report whether it is now on.
+func ToggleForced() bool {
+	for {
+		old := atomic.LoadInt32(&forced)
+		if atomic.CompareAndSwapInt32(&forced, old, 1-old) {
+			return old == 0
+		}
+	}
+}
+
+func Forced() bool {
+	return atomic.LoadInt32(&forced) != 0
+}
+
 func IsDebug(level DebugLevel) bool {
-	return level <= Debug
+	return level <= Debug || atomic.LoadInt32(&forced) != 0
 }
 
 func IsWarn(level DebugLevel) bool {
-	return level <= Warn
+	return level <= Warn || atomic.LoadInt32(&forced) != 0
 }
 
 func IsError(level DebugLevel) bool {
@@ -0,0 +1,42 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package debug
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestToggleOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ToggleOnSignal(ctx)
+
+	for _, expected := range []bool{true, false} {
+		syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+		deadline := time.Now().Add(5 * time.Second)
+		for Forced() != expected && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if Forced() != expected || IsDebug(Error) != expected {
+			t.Fatalf("After SIGUSR2, debugging should be forced %v (not %v).", expected, Forced())
+		}
+	}
+}
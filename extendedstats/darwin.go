@@ -32,9 +32,12 @@ type AggregateExtendedStats struct {
 	totalSent            uint64
 	TotalReorderings     uint64
 	AverageRtt           float64
-	rtt_measurements     uint64
-	total_rtt            float64
-	RetransmitRatio      float64
+	// In bytes.
+	AverageCwnd      float64
+	rtt_measurements uint64
+	total_rtt        float64
+	total_cwnd       float64
+	RetransmitRatio  float64
 }
 
 func ExtendedStatsAvailable() bool {
@@ -57,11 +60,18 @@ func (es *AggregateExtendedStats) IncorporateConnectionStats(basicConn net.Conn)
 		es.total_rtt += float64(info.Rtt)
 		es.rtt_measurements += 1
 		es.AverageRtt = es.total_rtt / float64(es.rtt_measurements)
+		es.total_cwnd += float64(info.Snd_cwnd)
+		es.AverageCwnd = es.total_cwnd / float64(es.rtt_measurements)
 		es.RetransmitRatio = (float64(es.TotalRetransmissions) / float64(es.totalSent)) * 100.0
 	}
 	return nil
 }
 
+// The largest MSS of the connections (0 before any were incorporated).
+func (es *AggregateExtendedStats) Mss() uint64 {
+	return es.Maxseg
+}
+
 func (es *AggregateExtendedStats) Repr() string {
 	return fmt.Sprintf(`Extended Statistics:
 	Maximum Segment Size: %v
@@ -69,7 +79,8 @@ func (es *AggregateExtendedStats) Repr() string {
 	Retransmission Ratio: %.2f%%
 	Total Bytes Reordered: %v
 	Average RTT: %v
-`, es.Maxseg, es.TotalRetransmissions, es.RetransmitRatio, es.TotalReorderings, es.AverageRtt)
+	Average Congestion Window: %.0f bytes
+`, es.Maxseg, es.TotalRetransmissions, es.RetransmitRatio, es.TotalReorderings, es.AverageRtt, es.AverageCwnd)
 }
 
 func GetTCPInfo(basicConn net.Conn) (*TCPInfo, error) {
@@ -28,6 +28,10 @@ func (es *ExtendedStats) IncorporateConnectionStats(conn net.Conn) error {
 	return fmt.Errorf("IncorporateConnectionStats is not supported on this platform")
 }
 
+func (es *ExtendedStats) Mss() uint64 {
+	return 0
+}
+
 func (es *ExtendedStats) Repr() string {
 	return ""
 }
@@ -21,6 +21,9 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"strings"
+	"syscall"
+	"unsafe"
 
 	"github.com/network-quality/goresponsiveness/utilities"
 	"golang.org/x/sys/unix"
@@ -31,10 +34,63 @@ type AggregateExtendedStats struct {
 	MaxSendMss           uint64
 	MaxRecvMss           uint64
 	TotalRetransmissions uint64
-	TotalReorderings     uint64
-	AverageRtt           float64
-	rtt_measurements     uint64
-	total_rtt            float64
+	// Retransmissions that the other end reported (with D-SACKs) were not needed.
+	SpuriousRetransmissions uint64
+	TotalReorderings        uint64
+	AverageRtt              float64
+	// In segments.
+	AverageCwnd float64
+	// The kernel's pacing rate (in bytes per second) of each connection whose kernel reports it.
+	PacingRates []uint64
+	// What BBR estimates the bandwidth (in bytes per second) and the minimum RTT (in
+	// microseconds) of the path to be, for each connection that uses it.
+	BBRBandwidths    []uint64
+	BBRMinRtts       []uint64
+	rtt_measurements uint64
+	total_rtt        float64
+	total_cwnd       float64
+}
+
+// unix.TCPInfo stops at tcpi_total_retrans, but the kernel's struct tcp_info has grown since.
+// This is the rest of it (as far as we need it). Kernels that are too old to have a field leave
+// it 0.
+type TCPInfo struct {
+	unix.TCPInfo
+	Pacing_rate     uint64
+	Max_pacing_rate uint64
+	Bytes_acked     uint64
+	Bytes_received  uint64
+	Segs_out        uint32
+	Segs_in         uint32
+	Notsent_bytes   uint32
+	Min_rtt         uint32
+	Data_segs_in    uint32
+	Data_segs_out   uint32
+	Delivery_rate   uint64
+	Busy_time       uint64
+	Rwnd_limited    uint64
+	Sndbuf_limited  uint64
+	Delivered       uint32
+	Delivered_ce    uint32
+	Bytes_sent      uint64
+	Bytes_retrans   uint64
+	Dsack_dups      uint32
+	Reord_seen      uint32
+}
+
+// What BBR (the congestion controller) thinks of the path of a connection: the kernel's struct
+// tcp_bbr_info.
+type BBRInfo struct {
+	Bw_lo       uint32 // The lower 32 bits of the bandwidth estimate (in bytes per second)
+	Bw_hi       uint32 // ... and the upper 32 bits.
+	Min_rtt     uint32 // In microseconds
+	Pacing_gain uint32 // Fixed point (<< 8)
+	Cwnd_gain   uint32 // Fixed point (<< 8)
+}
+
+// In bytes per second.
+func (info *BBRInfo) Bandwidth() uint64 {
+	return uint64(info.Bw_hi)<<32 | uint64(info.Bw_lo)
 }
 
 func ExtendedStatsAvailable() bool {
@@ -50,26 +106,84 @@ func (es *AggregateExtendedStats) IncorporateConnectionStats(basicConn net.Conn)
 		es.MaxSendMss = utilities.Max(es.MaxSendMss, uint64(info.Snd_mss))
 		// https://lkml.iu.edu/hypermail/linux/kernel/1705.0/01790.html
 		es.TotalRetransmissions += uint64(info.Total_retrans)
+		// Every D-SACK tells of a segment that arrived twice, i.e., one that was retransmitted
+		// needlessly.
+		es.SpuriousRetransmissions += uint64(info.Dsack_dups)
+		// A connection that is not paced reports the largest rate there is (and a kernel that
+		// does not know about pacing, none at all).
+		if info.Pacing_rate != 0 && info.Pacing_rate != ^uint64(0) {
+			es.PacingRates = append(es.PacingRates, info.Pacing_rate)
+		}
 		es.TotalReorderings += uint64(info.Reordering)
 		es.total_rtt += float64(info.Rtt)
 		es.rtt_measurements += 1
 		es.AverageRtt = es.total_rtt / float64(es.rtt_measurements)
+		es.total_cwnd += float64(info.Snd_cwnd)
+		es.AverageCwnd = es.total_cwnd / float64(es.rtt_measurements)
+	}
+	if info, err := GetBBRInfo(basicConn); err != nil {
+		return fmt.Errorf("OOPS: Could not get the BBR info for the connection: %v", err)
+	} else if info != nil {
+		es.BBRBandwidths = append(es.BBRBandwidths, info.Bandwidth())
+		es.BBRMinRtts = append(es.BBRMinRtts, uint64(info.Min_rtt))
 	}
 	return nil
 }
 
+// Summarize values (one per connection) in unit (after scaling them).
+func summarize(values []uint64, scale func(float64) float64, unit string) string {
+	if len(values) == 0 {
+		return "n/a"
+	}
+	minimum, maximum, total := values[0], values[0], uint64(0)
+	for _, value := range values {
+		minimum = utilities.Min(minimum, value)
+		maximum = utilities.Max(maximum, value)
+		total += value
+	}
+	return fmt.Sprintf(
+		"%.3f %s minimum, %.3f %s average, %.3f %s maximum (%d connections)",
+		scale(float64(minimum)), unit,
+		scale(float64(total)/float64(len(values))), unit,
+		scale(float64(maximum)), unit,
+		len(values),
+	)
+}
+
+// The largest MSS of the connections (0 before any were incorporated).
+func (es *AggregateExtendedStats) Mss() uint64 {
+	return es.MaxSendMss
+}
+
 func (es *AggregateExtendedStats) Repr() string {
-	return fmt.Sprintf(`Extended Statistics:
+	repr := fmt.Sprintf(`Extended Statistics:
 	Maximum Path MTU: %v
 	Maximum Send MSS: %v
 	Maximum Recv MSS: %v
 	Total Retransmissions: %v
+	Spurious Retransmissions: %v
 	Total Reorderings: %v
 	Average RTT: %v
-`, es.MaxPathMtu, es.MaxSendMss, es.MaxRecvMss, es.TotalRetransmissions, es.TotalReorderings, es.AverageRtt)
+	Average Congestion Window: %.1f segments
+	Pacing Rate: %v
+`, es.MaxPathMtu, es.MaxSendMss, es.MaxRecvMss, es.TotalRetransmissions, es.SpuriousRetransmissions,
+		es.TotalReorderings, es.AverageRtt, es.AverageCwnd, summarize(es.PacingRates, utilities.ToMbps, "Mbps"))
+	if len(es.BBRBandwidths) > 0 {
+		// The sum of the estimates is what BBR thinks that all the connections together
+		// could transfer (to compare with the throughput that the test measured).
+		total := uint64(0)
+		for _, bandwidth := range es.BBRBandwidths {
+			total += bandwidth
+		}
+		repr += fmt.Sprintf(`	BBR Bandwidth Estimate: %.3f Mbps in total; %v
+	BBR Minimum RTT Estimate: %v
+`, utilities.ToMbps(float64(total)), summarize(es.BBRBandwidths, utilities.ToMbps, "Mbps"),
+			summarize(es.BBRMinRtts, func(microseconds float64) float64 { return microseconds / 1000.0 }, "ms"))
+	}
+	return repr
 }
 
-func GetTCPInfo(basicConn net.Conn) (*unix.TCPInfo, error) {
+func rawConnection(basicConn net.Conn) (syscall.RawConn, error) {
 	tlsConn, ok := basicConn.(*tls.Conn)
 	if !ok {
 		return nil, fmt.Errorf("OOPS: Outermost connection is not a TLS connection")
@@ -80,13 +194,69 @@ func GetTCPInfo(basicConn net.Conn) (*unix.TCPInfo, error) {
 			"OOPS: Could not get the TCP info for the connection (not a TCP connection)",
 		)
 	}
-	rawConn, err := tcpConn.SyscallConn()
+	return tcpConn.SyscallConn()
+}
+
+func GetTCPInfo(basicConn net.Conn) (*TCPInfo, error) {
+	rawConn, err := rawConnection(basicConn)
 	if err != nil {
 		return nil, err
 	}
-	var info *unix.TCPInfo = nil
-	rawConn.Control(func(fd uintptr) {
-		info, err = unix.GetsockoptTCPInfo(int(fd), unix.SOL_TCP, unix.TCP_INFO)
+	// unix.GetsockoptTCPInfo would only ask for as much as unix.TCPInfo holds.
+	info := &TCPInfo{}
+	length := uint32(unsafe.Sizeof(*info))
+	rerr := rawConn.Control(func(fd uintptr) {
+		err = getsockopt(fd, unix.TCP_INFO, unsafe.Pointer(info), &length)
 	})
+	if rerr != nil {
+		return nil, rerr
+	}
 	return info, err
 }
+
+// Returns nil (and no error) when the connection does not use BBR.
+func GetBBRInfo(basicConn net.Conn) (*BBRInfo, error) {
+	rawConn, err := rawConnection(basicConn)
+	if err != nil {
+		return nil, err
+	}
+	var info *BBRInfo = nil
+	rerr := rawConn.Control(func(fd uintptr) {
+		var congestionControl string
+		if congestionControl, err = unix.GetsockoptString(int(fd), unix.SOL_TCP, unix.TCP_CONGESTION); err != nil {
+			return
+		}
+		// The name comes padded (with NULs) to the longest that there can be.
+		if strings.TrimRight(congestionControl, "\x00") != "bbr" {
+			return
+		}
+		info = &BBRInfo{}
+		length := uint32(unsafe.Sizeof(*info))
+		err = getsockopt(fd, unix.TCP_CC_INFO, unsafe.Pointer(info), &length)
+	})
+	if rerr != nil {
+		return nil, rerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Read a TCP-level socket option in to value (whose length, on the way in, is how much room
+// there is and, on the way out, how much the kernel filled in).
+func getsockopt(fd uintptr, option int, value unsafe.Pointer, length *uint32) error {
+	_, _, errno := unix.Syscall6(
+		unix.SYS_GETSOCKOPT,
+		fd,
+		unix.SOL_TCP,
+		uintptr(option),
+		uintptr(value),
+		uintptr(unsafe.Pointer(length)),
+		0,
+	)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
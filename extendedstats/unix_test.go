@@ -0,0 +1,108 @@
+//go:build dragonfly || freebsd || linux || netbsd || openbsd
+// +build dragonfly freebsd linux netbsd openbsd
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+package extendedstats
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// Connect (over TLS) to a test server, using the congestion controller congestionControl (when
+// it is not "").
+func dialTestServer(t *testing.T, congestionControl string) *tls.Conn {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// The connection never makes a request, which the server would otherwise complain about.
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	dialer := &net.Dialer{}
+	if congestionControl != "" {
+		dialer.Control = func(network, address string, rawConn syscall.RawConn) (err error) {
+			rawConn.Control(func(fd uintptr) {
+				err = unix.SetsockoptString(int(fd), unix.SOL_TCP, unix.TCP_CONGESTION, congestionControl)
+			})
+			return
+		}
+	}
+	connection, err := tls.DialWithDialer(dialer, "tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		if congestionControl != "" {
+			t.Skipf("Could not connect to the test server using %s: %v", congestionControl, err)
+		}
+		t.Fatalf("Could not connect to the test server: %v", err)
+	}
+	t.Cleanup(func() { connection.Close() })
+	if err := connection.Handshake(); err != nil {
+		t.Fatalf("Could not complete the handshake with the test server: %v", err)
+	}
+	return connection
+}
+
+func TestIncorporateConnectionStats(t *testing.T) {
+	connection := dialTestServer(t, "reno")
+
+	info, err := GetTCPInfo(connection)
+	if err != nil {
+		t.Fatalf("Could not get the TCP info for the connection: %v", err)
+	}
+	if info.Snd_mss == 0 || info.Bytes_sent == 0 {
+		t.Fatalf("The TCP info should have been filled in (including the fields that unix.TCPInfo does not have): %+v", info)
+	}
+
+	stats := AggregateExtendedStats{}
+	if err := stats.IncorporateConnectionStats(connection); err != nil {
+		t.Fatalf("Could not incorporate the stats of the connection: %v", err)
+	}
+	if len(stats.PacingRates) != 1 {
+		t.Fatalf("The connection's pacing rate should have been collected: %v", stats.PacingRates)
+	}
+	if repr := stats.Repr(); !strings.Contains(repr, "Spurious Retransmissions: 0\n") || !strings.Contains(repr, "(1 connections)") {
+		t.Fatalf("The extended statistics should report retransmissions and pacing: %s", repr)
+	}
+	if info, err := GetBBRInfo(connection); err != nil || info != nil {
+		t.Fatalf("A connection that does not use BBR should have no BBR info: %v (%v)", info, err)
+	}
+}
+
+func TestBBRInfo(t *testing.T) {
+	connection := dialTestServer(t, "bbr")
+	info, err := GetBBRInfo(connection)
+	if err != nil || info == nil {
+		t.Fatalf("Could not get the BBR info for the connection: %v", err)
+	}
+	if info.Pacing_gain == 0 || info.Cwnd_gain == 0 {
+		t.Fatalf("The BBR info should have been filled in: %+v", info)
+	}
+
+	stats := AggregateExtendedStats{}
+	if err := stats.IncorporateConnectionStats(connection); err != nil {
+		t.Fatalf("Could not incorporate the stats of the connection: %v", err)
+	}
+	if len(stats.BBRBandwidths) != 1 || !strings.Contains(stats.Repr(), "BBR Minimum RTT Estimate: ") {
+		t.Fatalf("The extended statistics should report BBR's estimates: %s", stats.Repr())
+	}
+}
@@ -35,10 +35,13 @@ type AggregateExtendedStats struct {
 	TotalBytesReordered     uint64
 	TotalBytesRetransmitted uint64
 
-	RetransmitRatio  float64
-	AverageRtt       float64
+	RetransmitRatio float64
+	AverageRtt      float64
+	// In bytes.
+	AverageCwnd      float64
 	rtt_measurements uint64
 	total_rtt        float64
+	total_cwnd       float64
 }
 
 type TCPINFO_BASE struct {
@@ -134,11 +137,18 @@ func (es *AggregateExtendedStats) IncorporateConnectionStats(basicConn net.Conn)
 		es.total_rtt += float64(info.RttUs)
 		es.rtt_measurements += 1
 		es.AverageRtt = es.total_rtt / float64(es.rtt_measurements)
+		es.total_cwnd += float64(info.Cwnd)
+		es.AverageCwnd = es.total_cwnd / float64(es.rtt_measurements)
 		es.RetransmitRatio = (float64(es.TotalBytesRetransmitted) / float64(es.TotalBytesSent)) * 100.0
 	}
 	return nil
 }
 
+// The largest MSS of the connections (0 before any were incorporated).
+func (es *AggregateExtendedStats) Mss() uint64 {
+	return es.MaxMss
+}
+
 func (es *AggregateExtendedStats) Repr() string {
 	return fmt.Sprintf(`Extended Statistics:
 	Maximum Segment Size: %v
@@ -146,7 +156,8 @@ func (es *AggregateExtendedStats) Repr() string {
 	Retransmission Ratio: %.2f%%
 	Total Bytes Reordered: %v
 	Average RTT: %v
-`, es.MaxMss, es.TotalBytesRetransmitted, es.RetransmitRatio, es.TotalBytesReordered, es.AverageRtt)
+	Average Congestion Window: %.0f bytes
+`, es.MaxMss, es.TotalBytesRetransmitted, es.RetransmitRatio, es.TotalBytesReordered, es.AverageRtt, es.AverageCwnd)
 }
 
 func getTCPInfoRaw(basicConn net.Conn) (*TCPINFO_V1, error) {
@@ -0,0 +1,251 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package hdr is a high dynamic range (HDR) histogram: it counts values over a wide range
+// with a fixed number of significant digits and in fixed memory, and writes them out as a
+// percentile distribution (.hgrm) that the HdrHistogram tools can plot.
+package hdr
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+type Histogram struct {
+	highestTrackableValue       int64
+	significantDigits           int
+	subBucketHalfCountMagnitude int
+	subBucketCount              int
+	subBucketHalfCount          int
+	subBucketMask               int64
+	bucketCount                 int
+	counts                      []int64
+	totalCount                  int64
+	maxValue                    int64
+}
+
+// A histogram of the values from 1 to highestTrackableValue (larger values are counted as
+// that) with significantDigits (1 to 5) significant digits.
+func New(highestTrackableValue int64, significantDigits int) (*Histogram, error) {
+	if significantDigits < 1 || significantDigits > 5 {
+		return nil, fmt.Errorf("a histogram has 1 to 5 significant digits (not %d)", significantDigits)
+	}
+	if highestTrackableValue < 2 {
+		return nil, fmt.Errorf("a histogram must be able to track values of at least 2 (not %d)", highestTrackableValue)
+	}
+
+	largestValueWithSingleUnitResolution := 2 * int64(math.Pow10(significantDigits))
+	subBucketCountMagnitude := int(math.Ceil(math.Log2(float64(largestValueWithSingleUnitResolution))))
+	h := &Histogram{
+		highestTrackableValue:       highestTrackableValue,
+		significantDigits:           significantDigits,
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+		subBucketCount:              1 << subBucketCountMagnitude,
+		subBucketHalfCount:          1 << (subBucketCountMagnitude - 1),
+	}
+	h.subBucketMask = int64(h.subBucketCount - 1)
+
+	// Enough buckets (each twice as wide as the one before it) to reach the highest value.
+	smallestUntrackableValue := int64(h.subBucketCount)
+	h.bucketCount = 1
+	for smallestUntrackableValue <= highestTrackableValue {
+		if smallestUntrackableValue > math.MaxInt64/2 {
+			h.bucketCount++
+			break
+		}
+		smallestUntrackableValue <<= 1
+		h.bucketCount++
+	}
+	h.counts = make([]int64, (h.bucketCount+1)*h.subBucketHalfCount)
+	return h, nil
+}
+
+// Count value (values below 1 count as 1; values above the highest trackable value count as
+// that).
+func (h *Histogram) Record(value int64) {
+	if value < 1 {
+		value = 1
+	}
+	if value > h.highestTrackableValue {
+		value = h.highestTrackableValue
+	}
+	h.counts[h.countsIndexFor(value)]++
+	h.totalCount++
+	if value > h.maxValue {
+		h.maxValue = value
+	}
+}
+
+func (h *Histogram) TotalCount() int64 {
+	return h.totalCount
+}
+
+// The largest value that was recorded (to the histogram's precision).
+func (h *Histogram) Max() int64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	return h.highestEquivalentValue(h.maxValue)
+}
+
+// The value (to the histogram's precision) that percentile (0 to 100) percent of the recorded
+// values are at or below.
+func (h *Histogram) ValueAtPercentile(percentile float64) int64 {
+	percentile = math.Min(math.Max(percentile, 0), 100)
+	countAtPercentile := int64(percentile/100*float64(h.totalCount) + 0.5)
+	if countAtPercentile < 1 {
+		countAtPercentile = 1
+	}
+	total := int64(0)
+	for i, count := range h.counts {
+		total += count
+		if total >= countAtPercentile {
+			return h.highestEquivalentValue(h.valueFromIndex(i))
+		}
+	}
+	return 0
+}
+
+func (h *Histogram) Mean() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	total := 0.0
+	for i, count := range h.counts {
+		if count > 0 {
+			total += float64(count) * float64(h.medianEquivalentValue(h.valueFromIndex(i)))
+		}
+	}
+	return total / float64(h.totalCount)
+}
+
+func (h *Histogram) StdDev() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	mean := h.Mean()
+	total := 0.0
+	for i, count := range h.counts {
+		if count > 0 {
+			deviation := float64(h.medianEquivalentValue(h.valueFromIndex(i))) - mean
+			total += deviation * deviation * float64(count)
+		}
+	}
+	return math.Sqrt(total / float64(h.totalCount))
+}
+
+// Write the percentile distribution of the histogram the way that HdrHistogram does (with
+// ticksPerHalfDistance reports for every halving of the distance to 100%) with the values
+// divided by scale (e.g., to write values that were recorded in microseconds in
+// milliseconds).
+func (h *Histogram) WritePercentileDistribution(w io.Writer, ticksPerHalfDistance int, scale float64) error {
+	valueFormat := fmt.Sprintf("%%12.%df", h.significantDigits)
+	if _, err := fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)"); err != nil {
+		return err
+	}
+
+	var writeErr error
+	h.eachPercentile(ticksPerHalfDistance, func(value int64, percentile float64, totalCount int64) {
+		if writeErr != nil {
+			return
+		}
+		if percentile != 100 {
+			_, writeErr = fmt.Fprintf(w, valueFormat+" %2.12f %10d %14.2f\n",
+				float64(value)/scale, percentile/100, totalCount, 1/(1-percentile/100))
+		} else {
+			_, writeErr = fmt.Fprintf(w, valueFormat+" %2.12f %10d\n", float64(value)/scale, percentile/100, totalCount)
+		}
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+
+	_, err := fmt.Fprintf(w,
+		"#[Mean    = "+valueFormat+", StdDeviation   = "+valueFormat+"]\n"+
+			"#[Max     = "+valueFormat+", Total count    = %12d]\n"+
+			"#[Buckets = %12d, SubBuckets     = %12d]\n",
+		h.Mean()/scale, h.StdDev()/scale,
+		float64(h.Max())/scale, h.totalCount,
+		h.bucketCount, h.subBucketCount,
+	)
+	return err
+}
+
+// Call report at every percentile level (as HdrHistogram's percentile iterator does): the
+// levels get closer together (ticksPerHalfDistance of them between 0% and 50%, between 50%
+// and 75%, and so on) until every value has been reported; the last level is 100%.
+func (h *Histogram) eachPercentile(ticksPerHalfDistance int, report func(value int64, percentile float64, totalCount int64)) {
+	level := 0.0
+	totalCount := int64(0)
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		totalCount += count
+		value := h.highestEquivalentValue(h.valueFromIndex(i))
+		for 100*float64(totalCount)/float64(h.totalCount) >= level {
+			report(value, level, totalCount)
+			level += 100 / h.reportingTicks(ticksPerHalfDistance, level)
+			if totalCount == h.totalCount {
+				report(value, 100, totalCount)
+				return
+			}
+		}
+	}
+}
+
+func (h *Histogram) reportingTicks(ticksPerHalfDistance int, level float64) float64 {
+	return float64(ticksPerHalfDistance) * math.Pow(2, math.Floor(math.Log2(100/(100-level)))+1)
+}
+
+func (h *Histogram) countsIndexFor(value int64) int {
+	bucketIndex := 64 - h.subBucketHalfCountMagnitude - 1 - bits.LeadingZeros64(uint64(value|h.subBucketMask))
+	subBucketIndex := int(value >> bucketIndex)
+	return ((bucketIndex + 1) << h.subBucketHalfCountMagnitude) + (subBucketIndex - h.subBucketHalfCount)
+}
+
+// The lowest value that is counted at index.
+func (h *Histogram) valueFromIndex(index int) int64 {
+	bucketIndex := (index >> h.subBucketHalfCountMagnitude) - 1
+	subBucketIndex := (index & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucketIndex < 0 {
+		subBucketIndex -= h.subBucketHalfCount
+		bucketIndex = 0
+	}
+	return int64(subBucketIndex) << bucketIndex
+}
+
+// How many values are counted together with value (i.e., are equivalent to it).
+func (h *Histogram) sizeOfEquivalentValueRange(value int64) int64 {
+	bucketIndex := 64 - h.subBucketHalfCountMagnitude - 1 - bits.LeadingZeros64(uint64(value|h.subBucketMask))
+	subBucketIndex := int(value >> bucketIndex)
+	if subBucketIndex >= h.subBucketCount {
+		bucketIndex++
+	}
+	return 1 << bucketIndex
+}
+
+func (h *Histogram) lowestEquivalentValue(value int64) int64 {
+	return h.valueFromIndex(h.countsIndexFor(value))
+}
+
+func (h *Histogram) highestEquivalentValue(value int64) int64 {
+	return h.lowestEquivalentValue(value) + h.sizeOfEquivalentValueRange(value) - 1
+}
+
+func (h *Histogram) medianEquivalentValue(value int64) int64 {
+	return h.lowestEquivalentValue(value) + h.sizeOfEquivalentValueRange(value)>>1
+}
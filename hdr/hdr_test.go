@@ -0,0 +1,89 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hdr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewHistogram(t *testing.T) {
+	for _, digits := range []int{0, 6} {
+		if _, err := New(1000, digits); err == nil {
+			t.Fatalf("A histogram with %d significant digits should not be made.", digits)
+		}
+	}
+	// The same layout as HdrHistogram's for an hour in microseconds.
+	h, err := New(3600*1000*1000, 3)
+	if err != nil {
+		t.Fatalf("Could not make a histogram: %v", err)
+	}
+	if h.bucketCount != 22 || h.subBucketCount != 2048 || len(h.counts) != 23552 {
+		t.Fatalf("The histogram has %d buckets of %d sub-buckets (%d counts).", h.bucketCount, h.subBucketCount, len(h.counts))
+	}
+}
+
+func TestHistogramValues(t *testing.T) {
+	h, _ := New(60*1000*1000, 3)
+	for value := int64(1); value <= 10000; value++ {
+		h.Record(value)
+	}
+	h.Record(120 * 1000 * 1000)
+
+	if h.TotalCount() != 10001 {
+		t.Fatalf("The histogram should have counted 10001 values, not %d.", h.TotalCount())
+	}
+	// Values up to 2048 are exact; from 4096 to 8192, they are counted in fours.
+	if p10 := h.ValueAtPercentile(10); p10 != 1000 {
+		t.Fatalf("The P10 should have been 1000, not %d.", p10)
+	}
+	if p50 := h.ValueAtPercentile(50); p50 != 5003 {
+		t.Fatalf("The P50 should have been 5003, not %d.", p50)
+	}
+	// Values above the highest trackable value count as that.
+	if max := h.Max(); max < 60*1000*1000 || max > 60*1000*1000*1001/1000 {
+		t.Fatalf("The maximum should have been the highest trackable value, not %d.", max)
+	}
+}
+
+func TestWritePercentileDistribution(t *testing.T) {
+	h, _ := New(60*1000*1000, 3)
+	for value := int64(1); value <= 100; value++ {
+		h.Record(value * 1000)
+	}
+
+	var distribution strings.Builder
+	if err := h.WritePercentileDistribution(&distribution, 5, 1000); err != nil {
+		t.Fatalf("Could not write the percentile distribution: %v", err)
+	}
+	lines := strings.Split(distribution.String(), "\n")
+	for _, expected := range []string{
+		"       Value     Percentile TotalCount 1/(1-Percentile)",
+		"       1.000 0.000000000000          1           1.00",
+		"      50.015 0.500000000000         50           2.00",
+		"     100.031 1.000000000000        100",
+		"#[Mean    =       50.504, StdDeviation   =       28.866]",
+		"#[Max     =      100.031, Total count    =          100]",
+		"#[Buckets =           16, SubBuckets     =         2048]",
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == expected
+		}
+		if !found {
+			t.Fatalf("The percentile distribution should have the line %q: %s", expected, distribution.String())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package history
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/output"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// Parse a time for a filter: RFC 3339, a date (2006-01-02, in local time) or a duration
+// before now (e.g., 24h).
+func parseTime(specification string, now time.Time) (time.Time, error) {
+	if specification == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, specification); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", specification, time.Local); err == nil {
+		return t, nil
+	}
+	if ago, err := time.ParseDuration(specification); err == nil {
+		return now.Add(-ago), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time (e.g., 2006-01-02T15:04:05Z07:00), a date (e.g., 2006-01-02) or a duration (e.g., 24h)", specification)
+}
+
+// The history subcommand: history list|export [flags].
+func Command(store *Store, arguments []string, w io.Writer) error {
+	if len(arguments) == 0 || (arguments[0] != "list" && arguments[0] != "export") {
+		return fmt.Errorf("usage: history list|export [-from TIME] [-to TIME] [-server HOST:PORT] [-run-id ID] [-format json|csv]")
+	}
+	action := arguments[0]
+	flags := flag.NewFlagSet("history "+action, flag.ContinueOnError)
+	flags.SetOutput(w)
+	from := flags.String("from", "", "Only the results of tests that started at or after this time (RFC 3339, a date or a duration before now).")
+	to := flags.String("to", "", "Only the results of tests that started before this time (RFC 3339, a date or a duration before now).")
+	server := flags.String("server", "", "Only the results of tests against this server (host:port).")
+	runId := flags.String("run-id", "", "Only the result of the test with this run ID.")
+	format := flags.String("format", "json", "How to export the results: json (one document per line) or csv.")
+	if err := flags.Parse(arguments[1:]); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	filter := Filter{Server: *server, RunId: *runId}
+	var err error
+	if filter.From, err = parseTime(*from, now); err != nil {
+		return err
+	}
+	if filter.To, err = parseTime(*to, now); err != nil {
+		return err
+	}
+	results, err := store.Read(filter)
+	if err != nil {
+		return err
+	}
+
+	if action == "list" {
+		for _, result := range results {
+			fmt.Fprintf(
+				w,
+				"%s  %-16s  %-24s  %6.0f RPM  %9.3f Mbps down  %9.3f Mbps up%s\n",
+				result.Time.Format(time.RFC3339),
+				result.RunId,
+				result.Server,
+				float64(result.Rpm),
+				utilities.ToMbps(result.DownloadThroughput),
+				utilities.ToMbps(result.UploadThroughput),
+				utilities.Conditional(result.Stable, "", "  (unstable)"),
+			)
+		}
+		return nil
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		for _, result := range results {
+			if err := encoder.Encode(result); err != nil {
+				return err
+			}
+		}
+	case "csv":
+		for i, result := range results {
+			if err := output.WriteCSV(w, result, i == 0); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unrecognized format %q (use json or csv)", *format)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package history
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/output"
+)
+
+func TestStore(t *testing.T) {
+	store := &Store{Filename: filepath.Join(t.TempDir(), "nested", "history.jsonl")}
+	if results, err := store.Read(Filter{}); err != nil || len(results) != 0 {
+		t.Fatalf("A store that does not exist should be empty: %v %v", results, err)
+	}
+
+	start := time.Date(2023, time.March, 15, 10, 0, 0, 0, time.UTC)
+	for i, server := range []string{"a.example.com:443", "b.example.com:443", "a.example.com:443"} {
+		result := output.Result{
+			Time:   start.Add(time.Duration(i) * time.Hour),
+			RunId:  strings.Repeat(string(rune('a'+i)), 4),
+			Server: server,
+			Rpm:    output.Float(1000 * (i + 1)),
+		}
+		if err := store.Write(result); err != nil {
+			t.Fatalf("Could not store a result: %v", err)
+		}
+	}
+	if err := store.Write(output.Result{Time: start, Interim: true}); err != nil {
+		t.Fatalf("Could not store an interim result: %v", err)
+	}
+
+	all, err := store.Read(Filter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("The store should have the three final results: %v %v", all, err)
+	}
+	if all[2].RunId != "cccc" || all[2].Rpm != 3000 {
+		t.Fatalf("The results were not stored in order: %v", all)
+	}
+
+	filtered, _ := store.Read(Filter{From: start.Add(30 * time.Minute), Server: "a.example.com:443"})
+	if len(filtered) != 1 || filtered[0].RunId != "cccc" {
+		t.Fatalf("The filter should match only the last result: %v", filtered)
+	}
+	filtered, _ = store.Read(Filter{To: start.Add(time.Hour)})
+	if len(filtered) != 1 || filtered[0].RunId != "aaaa" {
+		t.Fatalf("The filter should match only the first result: %v", filtered)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	store := &Store{Filename: filepath.Join(t.TempDir(), "history.jsonl")}
+	for _, runId := range []string{"first", "second"} {
+		store.Write(output.Result{Time: time.Now(), RunId: runId, Server: "example.com:443", Stable: true})
+	}
+
+	var listing strings.Builder
+	if err := Command(store, []string{"list", "-from", "1h"}, &listing); err != nil {
+		t.Fatalf("Could not list the history: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(listing.String()), "\n"); len(lines) != 2 ||
+		!strings.Contains(lines[1], "second") {
+		t.Fatalf("The listing should have both results: %s", listing.String())
+	}
+
+	var export strings.Builder
+	if err := Command(store, []string{"export", "-run-id", "first", "-format", "csv"}, &export); err != nil {
+		t.Fatalf("Could not export the history: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(export.String()), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "time,") {
+		t.Fatalf("The export should have a header and one result: %s", export.String())
+	}
+
+	if err := Command(store, []string{"remove"}, &export); err == nil {
+		t.Fatalf("An unknown action should be an error.")
+	}
+	if err := Command(store, []string{"list", "-from", "yesterday"}, &export); err == nil {
+		t.Fatalf("An invalid time should be an error.")
+	}
+}
+
+func TestDetectRegressions(t *testing.T) {
+	previous := make([]output.Result, 0)
+	for _, rpm := range []float64{1000, 1100, 950, 1050, 300} {
+		previous = append(previous, output.Result{Rpm: output.Float(rpm), DownloadThroughput: 1e6, UploadThroughput: 1e6})
+	}
+
+	if regressions := DetectRegressions(output.Result{Rpm: 980, DownloadThroughput: 1e6, UploadThroughput: 1e6}, previous); len(regressions) != 0 {
+		t.Fatalf("A typical result should not regress: %v", regressions)
+	}
+
+	regressions := DetectRegressions(output.Result{Rpm: 400, DownloadThroughput: 5e5, UploadThroughput: 1e6}, previous)
+	if len(regressions) != 2 || regressions[0].Metric != "RPM" || regressions[0].Median != 1000 ||
+		regressions[1].Metric != "Download throughput" {
+		t.Fatalf("The RPM and the download throughput should have regressed: %v", regressions)
+	}
+
+	if regressions := DetectRegressions(output.Result{Rpm: 400}, previous[:2]); len(regressions) != 0 {
+		t.Fatalf("Too few previous runs should not be compared: %v", regressions)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package history
+
+import (
+	"math"
+	"sort"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/output"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// Compare a result with the previous results (e.g., the last N from the history, against
+// the same server) and return the measurements that were significantly worse. Higher is
+// better for all of them. A measurement is significantly worse when its modified z-score
+// (see Iglewicz and Hoaglin), which uses the median and the median absolute deviation and
+// so is not thrown off by the occasional bad run in the history, is beyond
+// constants.RegressionModifiedZScoreThreshold and it is also at least
+// constants.RegressionMinimumDegradation percent worse than the median.
+func DetectRegressions(current output.Result, previous []output.Result) []output.Regression {
+	regressions := make([]output.Regression, 0)
+	if len(previous) < constants.RegressionMinimumRuns {
+		return regressions
+	}
+	for _, metric := range []struct {
+		name  string
+		units string
+		value func(output.Result) float64
+	}{
+		{"RPM", "RPM", func(r output.Result) float64 { return float64(r.Rpm) }},
+		{"Download throughput", "Mbps", func(r output.Result) float64 { return utilities.ToMbps(r.DownloadThroughput) }},
+		{"Upload throughput", "Mbps", func(r output.Result) float64 { return utilities.ToMbps(r.UploadThroughput) }},
+	} {
+		values := make([]float64, 0, len(previous))
+		for _, result := range previous {
+			// E.g., the RPM of a test without any probes.
+			if value := metric.value(result); !math.IsInf(value, 0) && !math.IsNaN(value) {
+				values = append(values, value)
+			}
+		}
+		value := metric.value(current)
+		if len(values) < constants.RegressionMinimumRuns || math.IsInf(value, 0) || math.IsNaN(value) {
+			continue
+		}
+		typical := median(values)
+		deviations := make([]float64, len(values))
+		for i, v := range values {
+			deviations[i] = math.Abs(v - typical)
+		}
+		deviation := median(deviations)
+
+		if typical <= 0 || -utilities.SignedPercentDifference(value, typical) < constants.RegressionMinimumDegradation {
+			continue
+		}
+		// When the previous runs all agree, any degradation beyond the minimum is significant.
+		if deviation > 0 && 0.6745*(typical-value)/deviation < constants.RegressionModifiedZScoreThreshold {
+			continue
+		}
+		regressions = append(regressions, output.Regression{
+			Metric:    metric.name,
+			Units:     metric.units,
+			Value:     output.Float(value),
+			Median:    output.Float(typical),
+			Deviation: output.Float(deviation),
+			Runs:      len(values),
+		})
+	}
+	return regressions
+}
@@ -0,0 +1,103 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/output"
+)
+
+// A Store keeps the final results of every test, one JSON document per line, in the order
+// that the tests ran. It is an output (see output.Sink) that ignores interim results.
+type Store struct {
+	Filename string
+}
+
+func (store *Store) Write(result output.Result) error {
+	if result.Interim {
+		return nil
+	}
+	document, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(store.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(store.Filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(document, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (store *Store) String() string {
+	return "history:" + store.Filename
+}
+
+// Which of the stored results to read. Zero values match everything.
+type Filter struct {
+	From   time.Time
+	To     time.Time
+	RunId  string
+	Server string
+}
+
+func (filter Filter) Matches(result output.Result) bool {
+	return (filter.From.IsZero() || !result.Time.Before(filter.From)) &&
+		(filter.To.IsZero() || result.Time.Before(filter.To)) &&
+		(filter.RunId == "" || result.RunId == filter.RunId) &&
+		(filter.Server == "" || result.Server == filter.Server)
+}
+
+// The stored results that match the filter, oldest first. A store that does not exist
+// (yet) is empty.
+func (store *Store) Read(filter Filter) ([]output.Result, error) {
+	results := make([]output.Result, 0)
+	file, err := os.Open(store.Filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return results, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var result output.Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return nil, fmt.Errorf("line %d of %s: %w", line, store.Filename, err)
+		}
+		if filter.Matches(result) {
+			results = append(results, result)
+		}
+	}
+	return results, scanner.Err()
+}
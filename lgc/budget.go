@@ -0,0 +1,162 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// Limits on the bytes that the load-generating connections may transfer in each direction
+// and in total. 0 means that there is no limit.
+type ByteLimits struct {
+	Total    uint64
+	Download uint64
+	Upload   uint64
+}
+
+func (limits ByteLimits) IsSet() bool {
+	return limits.Total > 0 || limits.Download > 0 || limits.Upload > 0
+}
+
+// Parse limits of the form "<total>" or "download=<bytes>,upload=<bytes>,total=<bytes>" (with
+// any of the parts left out), where every number of bytes may have a unit (e.g., 500MB).
+func ParseByteLimits(specification string) (ByteLimits, error) {
+	limits := ByteLimits{}
+	for _, part := range strings.Split(specification, ",") {
+		name, count, found := strings.Cut(part, "=")
+		if !found {
+			name, count = "total", part
+		}
+		bytes, err := utilities.ParseByteCount(count)
+		if err != nil {
+			return ByteLimits{}, err
+		}
+		switch strings.TrimSpace(name) {
+		case "total":
+			limits.Total = bytes
+		case "download":
+			limits.Download = bytes
+		case "upload":
+			limits.Upload = bytes
+		default:
+			return ByteLimits{}, fmt.Errorf("%q is not a direction (download, upload or total)", name)
+		}
+	}
+	return limits, nil
+}
+
+func ByteLimitsFlag(name string, usage string) *ByteLimits {
+	limits := &ByteLimits{}
+	flag.Var(limits, name, usage)
+	return limits
+}
+
+func (limits *ByteLimits) String() string {
+	if limits == nil || !limits.IsSet() {
+		return ""
+	}
+	parts := make([]string, 0)
+	for _, limit := range []struct {
+		name  string
+		bytes uint64
+	}{{"download", limits.Download}, {"upload", limits.Upload}, {"total", limits.Total}} {
+		if limit.bytes > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", limit.name, limit.bytes))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (limits *ByteLimits) Set(value string) error {
+	parsed, err := ParseByteLimits(value)
+	if err != nil {
+		return err
+	}
+	*limits = parsed
+	return nil
+}
+
+type direction int
+
+const (
+	downloadDirection direction = iota
+	uploadDirection
+)
+
+// Keeps the load-generating connections (that share it) within their ByteLimits. Once any
+// of the limits is reached, the budget is exhausted and the connections stop transferring.
+type ByteBudget struct {
+	limits ByteLimits
+	// Accessed atomically.
+	downloaded uint64
+	uploaded   uint64
+
+	exhaustedOnce sync.Once
+	exhausted     chan struct{}
+}
+
+func NewByteBudget(limits ByteLimits) *ByteBudget {
+	return &ByteBudget{limits: limits, exhausted: make(chan struct{})}
+}
+
+// Closed once the budget is exhausted.
+func (budget *ByteBudget) Exhausted() <-chan struct{} {
+	return budget.exhausted
+}
+
+func (budget *ByteBudget) Spent() (downloaded uint64, uploaded uint64) {
+	return atomic.LoadUint64(&budget.downloaded), atomic.LoadUint64(&budget.uploaded)
+}
+
+// Limit the size of a transfer in direction to what is left of the budget.
+func (budget *ByteBudget) limit(direction direction, size int) int {
+	downloaded, uploaded := budget.Spent()
+	remaining := uint64(size)
+	capAt := func(limit uint64, spent uint64) {
+		if limit == 0 {
+			return
+		}
+		if spent >= limit {
+			remaining = 0
+		} else if limit-spent < remaining {
+			remaining = limit - spent
+		}
+	}
+	capAt(budget.limits.Total, downloaded+uploaded)
+	if direction == downloadDirection {
+		capAt(budget.limits.Download, downloaded)
+	} else {
+		capAt(budget.limits.Upload, uploaded)
+	}
+	return int(remaining)
+}
+
+// Take count bytes (transferred in direction) from the budget.
+func (budget *ByteBudget) spend(direction direction, count int) {
+	if direction == downloadDirection {
+		atomic.AddUint64(&budget.downloaded, uint64(count))
+	} else {
+		atomic.AddUint64(&budget.uploaded, uint64(count))
+	}
+	if budget.limit(downloadDirection, 1) == 0 || budget.limit(uploadDirection, 1) == 0 {
+		budget.exhaustedOnce.Do(func() { close(budget.exhausted) })
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import "testing"
+
+func TestParseByteLimits(t *testing.T) {
+	limits, err := ParseByteLimits("500MB")
+	if err != nil || limits != (ByteLimits{Total: 500000000}) {
+		t.Fatalf("A single count should be the total limit: %+v %v", limits, err)
+	}
+	limits, err = ParseByteLimits("download=400MB,upload=100MB")
+	if err != nil || limits != (ByteLimits{Download: 400000000, Upload: 100000000}) {
+		t.Fatalf("Could not parse limits per direction: %+v %v", limits, err)
+	}
+	if limits.String() != "download=400000000,upload=100000000" {
+		t.Fatalf("The limits should print the way that they parse: %s", limits.String())
+	}
+	if _, err := ParseByteLimits("sideways=1MB"); err == nil {
+		t.Fatalf("Only download, upload and total should have limits.")
+	}
+}
+
+func TestByteBudget(t *testing.T) {
+	budget := NewByteBudget(ByteLimits{Total: 1000, Upload: 300})
+
+	if size := budget.limit(uploadDirection, 500); size != 300 {
+		t.Fatalf("An upload should be limited to the upload budget, not %d bytes.", size)
+	}
+	budget.spend(uploadDirection, 300)
+	if size := budget.limit(uploadDirection, 500); size != 0 {
+		t.Fatalf("The upload budget should be spent, not have %d bytes.", size)
+	}
+	select {
+	case <-budget.Exhausted():
+	default:
+		t.Fatalf("Spending a direction's budget should exhaust the budget.")
+	}
+	if size := budget.limit(downloadDirection, 1000); size != 700 {
+		t.Fatalf("A download should be limited to the rest of the total, not %d bytes.", size)
+	}
+	budget.spend(downloadDirection, 700)
+	if downloaded, uploaded := budget.Spent(); downloaded != 700 || uploaded != 300 {
+		t.Fatalf("The budget should have been spent in both directions: %d %d", downloaded, uploaded)
+	}
+}
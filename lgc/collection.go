@@ -49,6 +49,20 @@ func (collection *LoadGeneratingConnectionCollection) Append(conn LoadGenerating
 	return nil
 }
 
+// Put conn in the place of the connection at idx (e.g., because that connection failed).
+func (collection *LoadGeneratingConnectionCollection) Replace(idx int, conn LoadGeneratingConnection) error {
+	if collection.Lock.TryLock() {
+		collection.Lock.Unlock()
+		return fmt.Errorf("collection is unlocked")
+	}
+
+	if idx >= len(*collection.LGCs) {
+		return fmt.Errorf("index too large")
+	}
+	(*collection.LGCs)[idx] = conn
+	return nil
+}
+
 func (collection *LoadGeneratingConnectionCollection) Len() int {
 	return len(*collection.LGCs)
 }
@@ -21,10 +21,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptrace"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/network-quality/goresponsiveness/constants"
 	"github.com/network-quality/goresponsiveness/debug"
 	"github.com/network-quality/goresponsiveness/stats"
 	"github.com/network-quality/goresponsiveness/traceable"
@@ -44,12 +47,33 @@ type LoadGeneratingConnectionDownload struct {
 	debug              debug.DebugLevel
 	InsecureSkipVerify bool
 	KeyLogger          io.Writer
-	clientId           uint64
-	tracer             *httptrace.ClientTrace
-	stats              stats.TraceStats
-	status             LgcStatus
-	statusLock         *sync.Mutex
-	statusWaiter       *sync.Cond
+	// Optional: when set, tunnels through the proxy (see utilities.ProxyDialer).
+	ProxyDialer utilities.ProxyDialer
+	// Optional: the credentials and headers for the requests to the test server.
+	Headers *utilities.RequestHeaders
+	// Optional: when set, reading is paced (together with every other connection that
+	// shares the Pacer).
+	Pacer *Pacer
+	// Optional: when set, caps the rate of this connection alone.
+	Limiter *RateLimiter
+	// Optional: when set, what the connection transfers comes out of this budget (which
+	// it shares with the other connections); once it is exhausted, the connection stops.
+	Budget *ByteBudget
+	// Optional: when more than 1, the connection multiplexes this many downloads at once over
+	// its (HTTP/2) connection instead of making a single one.
+	Streams int
+	// Optional: when set, the download asks for ranges of this many bytes of the URL, one
+	// after another (starting over at the end of the object), instead of for all of it at once.
+	RangeSize  uint64
+	clientId   uint64
+	identifier *connectionIdentifier
+	tracer     *httptrace.ClientTrace
+	stats      stats.TraceStats
+	status     LgcStatus
+	// Why the connection failed (see Err).
+	err          error
+	statusLock   *sync.Mutex
+	statusWaiter *sync.Cond
 }
 
 func NewLoadGeneratingConnectionDownload(url string, keyLogger io.Writer, connectToAddr string, insecureSkipVerify bool) LoadGeneratingConnectionDownload {
@@ -59,6 +83,7 @@ func NewLoadGeneratingConnectionDownload(url string, keyLogger io.Writer, connec
 		ConnectToAddr:      connectToAddr,
 		InsecureSkipVerify: insecureSkipVerify,
 		statusLock:         &sync.Mutex{},
+		identifier:         &connectionIdentifier{},
 	}
 	lgd.statusWaiter = sync.NewCond(lgd.statusLock)
 	return lgd
@@ -150,6 +175,7 @@ func (lgd *LoadGeneratingConnectionDownload) SetGotConnTimeInfo(
 	}
 	lgd.stats.GetConnectionDoneTime = now
 	lgd.stats.ConnInfo = gotConnInfo
+	lgd.identifier.gotConn(gotConnInfo.Conn)
 	if debug.IsDebug(lgd.debug) {
 		fmt.Printf(
 			"Got connection for %v at %v with info %v\n",
@@ -218,6 +244,10 @@ func (lgd *LoadGeneratingConnectionDownload) SetHttpResponseReadyTime(
 	}
 }
 
+func (lgd *LoadGeneratingConnectionDownload) Identity() ConnectionIdentity {
+	return lgd.identifier.Identity()
+}
+
 func (lgd *LoadGeneratingConnectionDownload) ClientId() uint64 {
 	return lgd.clientId
 }
@@ -237,28 +267,78 @@ func (lgd *LoadGeneratingConnectionDownload) Client() *http.Client {
 	return lgd.client
 }
 
-type loadGeneratingConnectionDownloadReader struct {
+// Downloads read in to large buffers (so that a fast link takes few reads) that they share
+// through a pool.
+var downloadBufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, constants.DownloadBufferSize)
+		return &buffer
+	},
+}
+
+type loadGeneratingConnectionDownloadDiscarder struct {
 	n        *uint64
 	ctx      context.Context
 	readable io.Reader
 	lgd      *LoadGeneratingConnectionDownload
 }
 
-func (cr *loadGeneratingConnectionDownloadReader) Read(p []byte) (n int, err error) {
-	if cr.ctx.Err() != nil {
-		return 0, io.EOF
-	}
-
-	if *cr.n == 0 {
-		cr.lgd.statusLock.Lock()
-		cr.lgd.status = LGC_STATUS_RUNNING
-		cr.lgd.statusWaiter.Broadcast()
-		cr.lgd.statusLock.Unlock()
+// Read (and throw away) everything until the download ends (or is canceled), counting what
+// was read. Rather than touching the shared count on every read, what was read is added to it
+// in batches (see DownloadCountBatchSize and DownloadCountBatchTime).
+func (cd *loadGeneratingConnectionDownloadDiscarder) discard() (int64, error) {
+	buffer := downloadBufferPool.Get().(*[]byte)
+	defer downloadBufferPool.Put(buffer)
+
+	cd.lgd.statusLock.Lock()
+	cd.lgd.status = LGC_STATUS_RUNNING
+	cd.lgd.statusWaiter.Broadcast()
+	cd.lgd.statusLock.Unlock()
+
+	total := int64(0)
+	pending := uint64(0)
+	lastCount := time.Now()
+	defer func() {
+		atomic.AddUint64(cd.n, pending)
+	}()
+	for cd.ctx.Err() == nil {
+		p := *buffer
+		if cd.lgd.Pacer != nil {
+			p = p[:cd.lgd.Pacer.limit(len(p))]
+		}
+		if cd.lgd.Limiter != nil {
+			p = p[:cd.lgd.Limiter.limit(len(p))]
+		}
+		if cd.lgd.Budget != nil {
+			if p = p[:cd.lgd.Budget.limit(downloadDirection, len(p))]; len(p) == 0 {
+				return total, nil
+			}
+		}
+		n, err := cd.readable.Read(p)
+		total += int64(n)
+		pending += uint64(n)
+		if pending >= constants.DownloadCountBatchSize || time.Since(lastCount) >= constants.DownloadCountBatchTime {
+			atomic.AddUint64(cd.n, pending)
+			pending = 0
+			lastCount = time.Now()
+		}
+		if cd.lgd.Pacer != nil {
+			cd.lgd.Pacer.Wait(cd.ctx, n)
+		}
+		if cd.lgd.Limiter != nil {
+			cd.lgd.Limiter.Wait(cd.ctx, n)
+		}
+		if cd.lgd.Budget != nil {
+			cd.lgd.Budget.spend(downloadDirection, n)
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
 	}
-
-	n, err = cr.readable.Read(p)
-	atomic.AddUint64(cr.n, uint64(n))
-	return
+	return total, nil
 }
 
 func (lgd *LoadGeneratingConnectionDownload) Start(
@@ -290,11 +370,17 @@ func (lgd *LoadGeneratingConnectionDownload) Start(
 		// depend on whether the url contains
 		// https:// or http://:
 		// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L74
-		transport.TLSClientConfig.KeyLogWriter = lgd.KeyLogger
+		lgd.identifier.keyLogger = utilities.TraceKeyLog(
+			lgd.KeyLogger,
+			debug.IsDebug(lgd.debug),
+			fmt.Sprintf("load-generating download %v", lgd.clientId),
+		)
+		transport.TLSClientConfig.KeyLogWriter = lgd.identifier
 	}
 	transport.TLSClientConfig.InsecureSkipVerify = lgd.InsecureSkipVerify
 
-	utilities.OverrideHostTransport(transport, lgd.ConnectToAddr)
+	utilities.OverrideHostTransport(transport, lgd.ConnectToAddr, lgd.ProxyDialer)
+	lgd.identifier.watchDials(transport)
 
 	lgd.client = &http.Client{Transport: transport}
 	lgd.tracer = traceable.GenerateHttpTimingTracer(lgd, lgd.debug)
@@ -314,64 +400,159 @@ func (lgd *LoadGeneratingConnectionDownload) Status() LgcStatus {
 	return lgd.status
 }
 
+func (lgd *LoadGeneratingConnectionDownload) Err() error {
+	lgd.statusLock.Lock()
+	defer lgd.statusLock.Unlock()
+	return lgd.err
+}
+
 func (lgd *LoadGeneratingConnectionDownload) Stats() *stats.TraceStats {
 	return &lgd.stats
 }
 
 func (lgd *LoadGeneratingConnectionDownload) doDownload(ctx context.Context) error {
-	var request *http.Request = nil
-	var get *http.Response = nil
-	var err error = nil
-
-	if request, err = http.NewRequestWithContext(
-		httptrace.WithClientTrace(ctx, lgd.tracer),
-		"GET",
-		lgd.URL,
-		nil,
-	); err != nil {
-		lgd.statusLock.Lock()
-		lgd.status = LGC_STATUS_ERROR
-		lgd.statusWaiter.Broadcast()
-		lgd.statusLock.Unlock()
-		return err
-	}
-
-	// Used to disable compression
-	request.Header.Set("Accept-Encoding", "identity")
-	request.Header.Set("User-Agent", utilities.UserAgent())
-
 	lgd.downloadStartTime = time.Now()
 	lgd.lastIntervalEnd = 0
 
-	if get, err = lgd.client.Do(request); err != nil {
+	get, err := lgd.get(httptrace.WithClientTrace(ctx, lgd.tracer), 0)
+	if err != nil {
 		lgd.statusLock.Lock()
 		lgd.status = LGC_STATUS_ERROR
+		lgd.err = err
 		lgd.statusWaiter.Broadcast()
 		lgd.statusLock.Unlock()
 		return err
 	}
 
-	// Header.Get returns "" when not set
-	if get.Header.Get("Content-Encoding") != "" {
-		lgd.statusLock.Lock()
-		lgd.status = LGC_STATUS_ERROR
-		lgd.statusWaiter.Broadcast()
-		lgd.statusLock.Unlock()
-		fmt.Printf("Content-Encoding header was set (compression not allowed)")
-		return fmt.Errorf("Content-Encoding header was set (compression not allowed)")
+	// The other streams (if any) go over the connection that the first one made -- which they
+	// can only share when it speaks HTTP/2.
+	streams := sync.WaitGroup{}
+	streamErrs := make([]error, lgd.Streams)
+	if lgd.Streams > 1 {
+		if get.ProtoMajor == 2 {
+			for i := 1; i < lgd.Streams; i++ {
+				streams.Add(1)
+				go func(i int) {
+					defer streams.Done()
+					streamErrs[i] = lgd.stream(ctx)
+				}(i)
+			}
+		} else if debug.IsDebug(lgd.debug) {
+			fmt.Printf(
+				"Load-generating download %v is using %s; it will only have a single stream.\n",
+				lgd.clientId,
+				get.Proto,
+			)
+		}
+	}
+
+	err = lgd.transfer(ctx, get)
+	streams.Wait()
+	for _, streamErr := range streamErrs {
+		if err == nil {
+			err = streamErr
+		}
 	}
-	cr := &loadGeneratingConnectionDownloadReader{n: &lgd.downloaded, ctx: ctx, lgd: lgd, readable: get.Body}
-	_, _ = io.Copy(io.Discard, cr)
 
+	// A download that fails in the middle of the transfer (e.g., because the connection was
+	// reset) is an error rather than the end of the download, so that it can be replaced.
 	lgd.statusLock.Lock()
-	lgd.status = LGC_STATUS_DONE
+	if err != nil && ctx.Err() == nil {
+		lgd.status = LGC_STATUS_ERROR
+		lgd.err = err
+	} else {
+		lgd.status = LGC_STATUS_DONE
+	}
 	lgd.statusWaiter.Broadcast()
 	lgd.statusLock.Unlock()
 
-	get.Body.Close()
 	if debug.IsDebug(lgd.debug) {
 		fmt.Printf("Ending a load-generating download.\n")
 	}
 
 	return nil
 }
+
+// Request the download (in ctx) and make sure that the response is one that we can use. With a
+// RangeSize, request the range that starts at offset.
+func (lgd *LoadGeneratingConnectionDownload) get(ctx context.Context, offset uint64) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", lgd.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Used to disable compression
+	request.Header.Set("Accept-Encoding", "identity")
+	utilities.SetRequestHeaders(request, lgd.Headers)
+	if lgd.RangeSize > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+lgd.RangeSize-1))
+	}
+
+	get, err := lgd.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	// The object ended before offset (and its size was not known); start over.
+	if get.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		get.Body.Close()
+		return lgd.get(ctx, 0)
+	}
+	if err = CheckResponse(get); err != nil {
+		get.Body.Close()
+		return nil, err
+	}
+
+	// Header.Get returns "" when not set
+	if get.Header.Get("Content-Encoding") != "" {
+		get.Body.Close()
+		fmt.Printf("Content-Encoding header was set (compression not allowed)")
+		return nil, fmt.Errorf("Content-Encoding header was set (compression not allowed)")
+	}
+	return get, nil
+}
+
+// One of the other streams of the download (see Streams). Unlike the first, it is not traced:
+// it reuses the connection that the first one made.
+func (lgd *LoadGeneratingConnectionDownload) stream(ctx context.Context) error {
+	get, err := lgd.get(ctx, 0)
+	if err != nil {
+		return err
+	}
+	return lgd.transfer(ctx, get)
+}
+
+// Read (and throw away) the body of response and, when the download asks for ranges (see
+// RangeSize), those of the ranges after it until ctx is canceled. Closes every body.
+func (lgd *LoadGeneratingConnectionDownload) transfer(ctx context.Context, response *http.Response) error {
+	offset := uint64(0)
+	for {
+		cd := &loadGeneratingConnectionDownloadDiscarder{n: &lgd.downloaded, ctx: ctx, lgd: lgd, readable: response.Body}
+		_, err := cd.discard()
+		response.Body.Close()
+		// A server that ignores the range sends all of the object at once.
+		if err != nil || ctx.Err() != nil || lgd.RangeSize == 0 || response.StatusCode != http.StatusPartialContent {
+			return err
+		}
+		if lgd.Budget != nil && lgd.Budget.limit(downloadDirection, 1) == 0 {
+			return nil
+		}
+		offset += lgd.RangeSize
+		if size, known := rangedObjectSize(response); known && offset >= size {
+			offset = 0
+		}
+		if response, err = lgd.get(ctx, offset); err != nil {
+			return err
+		}
+	}
+}
+
+// The size of the object that a partial response is a range of (from its Content-Range, e.g.,
+// bytes 0-999/5000), if the server said.
+func rangedObjectSize(response *http.Response) (uint64, bool) {
+	_, size, found := strings.Cut(response.Header.Get("Content-Range"), "/")
+	if !found {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(size, 10, 64)
+	return parsed, err == nil
+}
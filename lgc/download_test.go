@@ -0,0 +1,245 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+// Hands out at most recordSize bytes per read (like a TLS connection does) until it has
+// handed out remaining bytes.
+type recordReader struct {
+	remaining  int64
+	recordSize int
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if n > r.recordSize {
+		n = r.recordSize
+	}
+	if int64(n) > r.remaining {
+		n = int(r.remaining)
+	}
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+func newTestDownloadDiscarder(ctx context.Context, body io.Reader) *loadGeneratingConnectionDownloadDiscarder {
+	lgd := NewLoadGeneratingConnectionDownload("https://example.com/large", nil, "", false)
+	return &loadGeneratingConnectionDownloadDiscarder{n: &lgd.downloaded, ctx: ctx, lgd: &lgd, readable: body}
+}
+
+func TestDownloadDiscard(t *testing.T) {
+	body := bytes.NewReader(make([]byte, 3*1024*1024+17))
+	discarder := newTestDownloadDiscarder(context.Background(), body)
+	total, err := discarder.discard()
+	if err != nil || total != int64(body.Size()) {
+		t.Fatalf("The download should have read the whole body: %d %v", total, err)
+	}
+	if *discarder.n != uint64(body.Size()) {
+		t.Fatalf("The download should have counted %d bytes, not %d.", body.Size(), *discarder.n)
+	}
+	if discarder.lgd.Status() != LGC_STATUS_RUNNING {
+		t.Fatalf("The download should be running, not %v.", discarder.lgd.Status())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	discarder = newTestDownloadDiscarder(ctx, &recordReader{remaining: 1024, recordSize: 16})
+	if total, err := discarder.discard(); total != 0 || err != nil {
+		t.Fatalf("A canceled download should read nothing: %d %v", total, err)
+	}
+}
+
+func BenchmarkDownloadDiscard(b *testing.B) {
+	// A TLS connection hands over (at most) one 16 KiB record per read.
+	recordSize := 16 * 1024
+	discarder := newTestDownloadDiscarder(
+		context.Background(),
+		&recordReader{remaining: int64(b.N) * int64(recordSize), recordSize: recordSize},
+	)
+	b.SetBytes(int64(recordSize))
+	b.ReportAllocs()
+	b.ResetTimer()
+	if _, err := discarder.discard(); err != nil {
+		b.Fatalf("Could not discard the download: %v", err)
+	}
+}
+
+func TestDownloadFailureIsAnError(t *testing.T) {
+	// The server promises a large body but resets the connection after a little of it.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		w.Write(make([]byte, 1000))
+		w.(http.Flusher).Flush()
+		connection, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			connection.Close()
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgd := NewLoadGeneratingConnectionDownload(server.URL, nil, "", false)
+	lgd.Start(ctx, debug.NoDebug)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		lgd.statusLock.Lock()
+		status := lgd.status
+		lgd.statusLock.Unlock()
+		if status == LGC_STATUS_ERROR {
+			break
+		}
+		if status == LGC_STATUS_DONE || time.Now().After(deadline) {
+			t.Fatalf("A download that failed midway should be an error, not %v.", status)
+		}
+	}
+}
+
+func TestDownloadErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgd := NewLoadGeneratingConnectionDownload(server.URL, nil, "", false)
+	lgd.Start(ctx, debug.NoDebug)
+	for deadline := time.Now().Add(5 * time.Second); lgd.Err() == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("A download that the server answered with a 404 should have failed.")
+		}
+	}
+	var responseError *ResponseError
+	if !errors.As(lgd.Err(), &responseError) || responseError.StatusCode != http.StatusNotFound {
+		t.Fatalf("A download that the server answered with a 404 should fail with a response error: %v", lgd.Err())
+	}
+	if status := lgd.Status(); status != LGC_STATUS_ERROR {
+		t.Fatalf("A download that the server answered with a 404 should be an error, not %v.", status)
+	}
+}
+
+// An HTTP/2 server that reports the remote address of every request on requests (and then lets
+// handle answer it).
+func newStreamsServer(requests chan<- string, handle http.HandlerFunc) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "HTTP/2 only", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		requests <- r.RemoteAddr
+		handle(w, r)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	return server
+}
+
+// Wait for count requests and make sure that they all came over the same connection.
+func expectStreams(t *testing.T, requests <-chan string, count int) {
+	remotes := make(map[string]int)
+	for i := 0; i < count; i++ {
+		select {
+		case remote := <-requests:
+			remotes[remote]++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("There should have been %d requests (not %d).", count, i)
+		}
+	}
+	if len(remotes) != 1 {
+		t.Fatalf("All %d requests should have gone over a single connection: %v", count, remotes)
+	}
+}
+
+func TestDownloadStreams(t *testing.T) {
+	requests := make(chan string, 3)
+	server := newStreamsServer(requests, func(w http.ResponseWriter, r *http.Request) {
+		for r.Context().Err() == nil {
+			w.Write(make([]byte, 1000))
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgd := NewLoadGeneratingConnectionDownload(server.URL, nil, "", true)
+	lgd.Streams = 3
+	lgd.Start(ctx, debug.NoDebug)
+	expectStreams(t, requests, 3)
+}
+
+func TestDownloadHTTPProtocol(t *testing.T) {
+	requests := make(chan string, 1)
+	server := newStreamsServer(requests, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgd := NewLoadGeneratingConnectionDownload(server.URL, nil, "", true)
+	lgd.Start(ctx, debug.NoDebug)
+	expectStreams(t, requests, 1)
+	if protocol := lgd.Identity().HTTPProtocol; protocol != "HTTP/2" {
+		t.Fatalf("The download should have negotiated HTTP/2, not %q.", protocol)
+	}
+}
+
+func TestDownloadRanges(t *testing.T) {
+	object := make([]byte, 10000)
+	ranges := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case ranges <- r.Header.Get("Range"):
+		default:
+		}
+		http.ServeContent(w, r, "object", time.Time{}, bytes.NewReader(object))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgd := NewLoadGeneratingConnectionDownload(server.URL, nil, "", false)
+	lgd.RangeSize = 3000
+	lgd.Start(ctx, debug.NoDebug)
+	for _, expected := range []string{"bytes=0-2999", "bytes=3000-5999", "bytes=6000-8999", "bytes=9000-11999", "bytes=0-2999"} {
+		select {
+		case requested := <-ranges:
+			if requested != expected {
+				t.Fatalf("The download should have asked for %s, not %s.", expected, requested)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The download should have asked for %s.", expected)
+		}
+	}
+	if downloaded := atomic.LoadUint64(&lgd.downloaded); downloaded < uint64(len(object)) {
+		t.Fatalf("The download should have read the whole object (not %d bytes) before starting over.", downloaded)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// What identifies the network connection of a load-generating connection in a packet capture:
+// its transport protocol and addresses and the client random of its TLS session (which is how
+// the SSL key log file identifies the session's secrets). Alongside, the HTTP protocol that the connection ended up
+// speaking (empty until the transport has it) and whether it is encrypted.
+type ConnectionIdentity struct {
+	Network       string
+	LocalAddress  string
+	RemoteAddress string
+	ClientRandom  string
+	HTTPProtocol  string
+	TLS           bool
+}
+
+// A connectionIdentifier learns a load-generating connection's identity by watching it
+// dial and log its TLS keys.
+type connectionIdentifier struct {
+	lock      sync.Mutex
+	identity  ConnectionIdentity
+	keyLogger io.Writer
+}
+
+// Record the client random from a line of the key log (e.g., CLIENT_RANDOM <client random>
+// <secret>) before passing it along to the real key logger.
+func (ci *connectionIdentifier) Write(line []byte) (int, error) {
+	if fields := bytes.Fields(line); len(fields) == 3 {
+		ci.lock.Lock()
+		ci.identity.ClientRandom = string(fields[1])
+		ci.lock.Unlock()
+	}
+	return ci.keyLogger.Write(line)
+}
+
+// Record the addresses of the connections that transport dials.
+func (ci *connectionIdentifier) watchDials(transport *http.Transport) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err == nil {
+			ci.lock.Lock()
+			ci.identity.Network = conn.LocalAddr().Network()
+			ci.identity.LocalAddress = conn.LocalAddr().String()
+			ci.identity.RemoteAddress = conn.RemoteAddr().String()
+			ci.lock.Unlock()
+		}
+		return conn, err
+	}
+}
+
+// Record the HTTP protocol of the connection that the transport got (see httptrace.GotConn),
+// whatever its TLS session negotiated or, without TLS, HTTP/1.1, and whether it is encrypted.
+func (ci *connectionIdentifier) gotConn(conn net.Conn) {
+	protocol := "HTTP/1.1"
+	tlsConn, encrypted := conn.(*tls.Conn)
+	if encrypted && tlsConn.ConnectionState().NegotiatedProtocol == "h2" {
+		protocol = "HTTP/2"
+	}
+	ci.lock.Lock()
+	ci.identity.HTTPProtocol = protocol
+	ci.identity.TLS = encrypted
+	ci.lock.Unlock()
+}
+
+func (ci *connectionIdentifier) Identity() ConnectionIdentity {
+	ci.lock.Lock()
+	defer ci.lock.Unlock()
+	return ci.identity
+}
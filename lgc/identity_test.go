@@ -0,0 +1,55 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestConnectionIdentifier(t *testing.T) {
+	var keyLog bytes.Buffer
+	identifier := &connectionIdentifier{keyLogger: &keyLog}
+	line := "CLIENT_HANDSHAKE_TRAFFIC_SECRET 0011223344 aabbccddeeff\n"
+	if _, err := identifier.Write([]byte(line)); err != nil {
+		t.Fatalf("Could not write to the key log: %v", err)
+	}
+	if keyLog.String() != line {
+		t.Fatalf("Key log lines should be passed along to the key logger: %q", keyLog.String())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	defer listener.Close()
+	transport := &http.Transport{}
+	identifier.watchDials(transport)
+	conn, err := transport.DialContext(context.Background(), "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	identity := identifier.Identity()
+	if identity.ClientRandom != "0011223344" ||
+		identity.RemoteAddress != listener.Addr().String() ||
+		identity.LocalAddress != conn.LocalAddr().String() {
+		t.Fatalf("Connection identity is wrong: %v", identity)
+	}
+}
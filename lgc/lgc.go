@@ -28,8 +28,12 @@ type LoadGeneratingConnection interface {
 	TransferredInInterval() (uint64, time.Duration)
 	Client() *http.Client
 	Status() LgcStatus
+	// Why the connection failed when its status is LGC_STATUS_ERROR (a *ResponseError when
+	// the server answered with a status other than 2xx).
+	Err() error
 	ClientId() uint64
 	Stats() *stats.TraceStats
+	Identity() ConnectionIdentity
 	WaitUntilStarted(context.Context) bool
 }
 
@@ -15,8 +15,10 @@
 package lgc_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/network-quality/goresponsiveness/lgc"
 )
@@ -27,3 +29,70 @@ func TestStatusString(t *testing.T) {
 		t.Fatalf("String() on an LGC status did not work properly.")
 	}
 }
+
+func TestPacerPacesAggregateRate(t *testing.T) {
+	pacer := lgc.NewPacer()
+	pacer.SetRate(100 * 1024)
+
+	start := time.Now()
+	// 30 KiB at 100 KiB/s: the third 10 KiB cannot start until 200ms in.
+	for i := 0; i < 3; i++ {
+		pacer.Wait(context.Background(), 10*1024)
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Fatalf("Pacer did not pace: 30 KiB took only %v.", elapsed)
+	}
+}
+
+func TestPacerWithoutRateDoesNotWait(t *testing.T) {
+	pacer := lgc.NewPacer()
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		pacer.Wait(context.Background(), 1024*1024)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("A pacer without a rate should not wait (waited %v).", elapsed)
+	}
+}
+
+func TestRateLimiterCapsRate(t *testing.T) {
+	// 100 KiB/s with a 10 KiB bucket: after the first (full) bucket, the next 20 KiB take
+	// 200ms.
+	limiter := lgc.NewRateLimiter(100*1024, 10*1024)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		limiter.Wait(context.Background(), 10*1024)
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Fatalf("Rate limiter did not limit: 30 KiB took only %v.", elapsed)
+	}
+}
+
+func TestRateLimiterStopsWaitingWhenCanceled(t *testing.T) {
+	limiter := lgc.NewRateLimiter(1024, 1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	limiter.Wait(ctx, 1024*1024)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("A canceled wait should not wait (waited %v).", elapsed)
+	}
+}
+
+func TestPacerCeiling(t *testing.T) {
+	pacer := lgc.NewPacer()
+	pacer.SetCeiling(100 * 1024)
+	if pacer.Rate() != 100*1024 {
+		t.Fatalf("A pacer without a rate should pace at its ceiling, not %v.", pacer.Rate())
+	}
+	pacer.SetRate(1024 * 1024)
+	if pacer.Rate() != 100*1024 {
+		t.Fatalf("A pacer should not pace above its ceiling (%v).", pacer.Rate())
+	}
+	pacer.SetRate(10 * 1024)
+	if pacer.Rate() != 10*1024 {
+		t.Fatalf("A pacer should pace below its ceiling (%v).", pacer.Rate())
+	}
+}
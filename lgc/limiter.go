@@ -0,0 +1,81 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"context"
+	"time"
+)
+
+// Caps the rate of a single load-generating connection with a token bucket: the bucket
+// holds up to burst bytes and refills at the limiter's rate. Unlike a Pacer, a RateLimiter
+// is not shared; every connection gets one of its own.
+type RateLimiter struct {
+	// In bytes per second.
+	rate  float64
+	burst float64
+	// Only the connection that owns the limiter uses it, so there is nothing to lock.
+	tokens float64
+	last   time.Time
+}
+
+// Buckets smaller than this would make for needlessly small transfers.
+const minimumRateLimiterBurst = 1024
+
+func NewRateLimiter(bytesPerSecond float64, burst int) *RateLimiter {
+	if burst < minimumRateLimiterBurst {
+		burst = minimumRateLimiterBurst
+	}
+	return &RateLimiter{
+		rate:   bytesPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (l *RateLimiter) Rate() float64 {
+	return l.rate
+}
+
+// Limit the size of a transfer to what the bucket can hold.
+func (l *RateLimiter) limit(size int) int {
+	if float64(size) > l.burst {
+		return int(l.burst)
+	}
+	return size
+}
+
+// Take count bytes from the bucket, waiting (unless ctx is canceled) until it has refilled
+// enough to cover them.
+func (l *RateLimiter) Wait(ctx context.Context, count int) {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(count)
+	if l.tokens >= 0 {
+		return
+	}
+
+	timer := time.NewTimer(time.Duration(-l.tokens / l.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// The most that a paced connection will transfer at once. Keeping this small keeps the
+// paced connections from sending (or reading) in large bursts.
+const pacingChunkSize = 16 * 1024
+
+// Paces the aggregate rate of every load-generating connection that shares it. A Pacer
+// whose rate is 0 does not slow anything down.
+type Pacer struct {
+	m sync.Mutex
+	// In bytes per second.
+	rate float64
+	// The pace never exceeds the ceiling, whatever the rate (unless the ceiling is 0).
+	ceiling float64
+	// The time at which the next transfer may begin.
+	next time.Time
+}
+
+func NewPacer() *Pacer {
+	return &Pacer{}
+}
+
+func (p *Pacer) SetRate(bytesPerSecond float64) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.rate = bytesPerSecond
+	p.next = time.Now()
+}
+
+// Cap the rate (in bytes per second) for as long as the Pacer is used. The cap stays in
+// place when the rate changes; 0 removes it.
+func (p *Pacer) SetCeiling(bytesPerSecond float64) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.ceiling = bytesPerSecond
+	p.next = time.Now()
+}
+
+// The rate at which the Pacer paces: its rate, capped at its ceiling.
+func (p *Pacer) Rate() float64 {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.effectiveRate()
+}
+
+func (p *Pacer) effectiveRate() float64 {
+	if p.ceiling > 0 && (p.rate <= 0 || p.rate > p.ceiling) {
+		return p.ceiling
+	}
+	return p.rate
+}
+
+// Limit the size of a transfer so that pacing stays smooth.
+func (p *Pacer) limit(size int) int {
+	if p.Rate() > 0 && size > pacingChunkSize {
+		return pacingChunkSize
+	}
+	return size
+}
+
+// Wait until the pace allows for count more bytes to be transferred (or ctx is canceled).
+func (p *Pacer) Wait(ctx context.Context, count int) {
+	p.m.Lock()
+	rate := p.effectiveRate()
+	if rate <= 0 {
+		p.m.Unlock()
+		return
+	}
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	start := p.next
+	p.next = p.next.Add(time.Duration(float64(count) / rate * float64(time.Second)))
+	p.m.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+}
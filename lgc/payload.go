@@ -0,0 +1,77 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// What the bodies of the load-generating uploads are made of. Servers (and middleboxes) that
+// compress or deduplicate what they receive make uploads of anything but random bytes look
+// faster than the network is.
+type UploadPattern int
+
+const (
+	RandomPayload UploadPattern = iota
+	ZerosPayload
+	// Text-like: random letters from a small alphabet (which compress to about half).
+	CompressiblePayload
+)
+
+func ParseUploadPattern(pattern string) (UploadPattern, error) {
+	switch pattern {
+	case "", "random":
+		return RandomPayload, nil
+	case "zeros":
+		return ZerosPayload, nil
+	case "compressible":
+		return CompressiblePayload, nil
+	}
+	return RandomPayload, fmt.Errorf("unrecognized upload pattern: %s", pattern)
+}
+
+func (pattern UploadPattern) String() string {
+	switch pattern {
+	case ZerosPayload:
+		return "zeros"
+	case CompressiblePayload:
+		return "compressible"
+	}
+	return "random"
+}
+
+// The body of every load-generating upload comes from the buffer for its pattern, filled once.
+// Sending a body costs no more than copying it; nothing is generated or allocated per write.
+var uploadPayloads = func() map[UploadPattern][]byte {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	payloads := make(map[UploadPattern][]byte)
+	for _, pattern := range []UploadPattern{RandomPayload, ZerosPayload, CompressiblePayload} {
+		payload := make([]byte, constants.UploadPayloadSize)
+		switch pattern {
+		case RandomPayload:
+			random.Read(payload)
+		case CompressiblePayload:
+			for i := range payload {
+				payload[i] = 'a' + byte(random.Intn(16))
+			}
+		}
+		payloads[pattern] = payload
+	}
+	return payloads
+}()
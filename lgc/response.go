@@ -0,0 +1,83 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// A response from the server with a status other than 2xx.
+type ResponseError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// How long the server asked us to wait before trying again (from its Retry-After
+	// header); 0 when it did not say.
+	RetryAfter time.Duration
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s answered with %s", e.URL, e.Status)
+}
+
+// Whether trying again might succeed.
+func (e *ResponseError) Retryable() bool {
+	return RetryableStatus(e.StatusCode)
+}
+
+func SuccessfulStatus(statusCode int) bool {
+	return statusCode >= 200 && statusCode <= 299
+}
+
+// Too Many Requests and the server errors (e.g., Service Unavailable) say something about the
+// state of the server at the moment; other errors (e.g., Not Found) say something about the
+// request itself and will not go away by themselves.
+func RetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || (statusCode >= 500 && statusCode <= 599)
+}
+
+// The HTTP protocol (e.g., HTTP/1.1, HTTP/2 or HTTP/3) that a response came over.
+func HTTPProtocol(response *http.Response) string {
+	if response.ProtoMajor >= 2 {
+		return fmt.Sprintf("HTTP/%d", response.ProtoMajor)
+	}
+	return fmt.Sprintf("HTTP/%d.%d", response.ProtoMajor, response.ProtoMinor)
+}
+
+// Returns a *ResponseError unless the response was successful.
+func CheckResponse(response *http.Response) error {
+	if SuccessfulStatus(response.StatusCode) {
+		return nil
+	}
+	responseError := &ResponseError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+	}
+	if response.Request != nil && response.Request.URL != nil {
+		responseError.URL = response.Request.URL.String()
+	}
+	// Retry-After is either a number of seconds or a date.
+	if retryAfter := response.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+			responseError.RetryAfter = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(retryAfter); err == nil && time.Until(date) > 0 {
+			responseError.RetryAfter = time.Until(date)
+		}
+	}
+	return responseError
+}
@@ -0,0 +1,57 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+package lgc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(&http.Response{StatusCode: http.StatusNoContent, Status: "204 No Content"}); err != nil {
+		t.Fatalf("A 204 should be a successful response: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "30")
+	err := CheckResponse(&http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Header: header})
+	responseError, ok := err.(*ResponseError)
+	if !ok || !responseError.Retryable() || responseError.RetryAfter != 30*time.Second {
+		t.Fatalf("A 503 with a Retry-After of 30 seconds should be retried after 30 seconds: %v", err)
+	}
+
+	header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	err = CheckResponse(&http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", Header: header})
+	if responseError, ok = err.(*ResponseError); !ok || !responseError.Retryable() ||
+		responseError.RetryAfter < 59*time.Minute || responseError.RetryAfter > time.Hour {
+		t.Fatalf("A 429 with a Retry-After an hour from now should be retried in an hour: %v", err)
+	}
+
+	err = CheckResponse(&http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Header: http.Header{}})
+	if responseError, ok = err.(*ResponseError); !ok || responseError.Retryable() || responseError.RetryAfter != 0 {
+		t.Fatalf("A 404 should not be retried: %v", err)
+	}
+}
+
+func TestHTTPProtocol(t *testing.T) {
+	for _, test := range []struct {
+		major, minor int
+		protocol     string
+	}{{1, 1, "HTTP/1.1"}, {2, 0, "HTTP/2"}, {3, 0, "HTTP/3"}} {
+		if protocol := HTTPProtocol(&http.Response{ProtoMajor: test.major, ProtoMinor: test.minor}); protocol != test.protocol {
+			t.Fatalf("HTTP/%d.%d should be %s, not %s.", test.major, test.minor, test.protocol, protocol)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -42,10 +43,32 @@ type LoadGeneratingConnectionUpload struct {
 	debug              debug.DebugLevel
 	InsecureSkipVerify bool
 	KeyLogger          io.Writer
-	clientId           uint64
-	status             LgcStatus
-	statusLock         *sync.Mutex
-	statusWaiter       *sync.Cond
+	// Optional: when set, tunnels through the proxy (see utilities.ProxyDialer).
+	ProxyDialer utilities.ProxyDialer
+	// Optional: the credentials and headers for the requests to the test server.
+	Headers *utilities.RequestHeaders
+	// Optional: when set, sending is paced (together with every other connection that
+	// shares the Pacer).
+	Pacer *Pacer
+	// Optional: when set, caps the rate of this connection alone.
+	Limiter *RateLimiter
+	// Optional: when set, what the connection transfers comes out of this budget (which
+	// it shares with the other connections); once it is exhausted, the connection stops.
+	Budget *ByteBudget
+	// Optional: when more than 1, the connection multiplexes this many uploads at once over
+	// its (HTTP/2) connection instead of making a single one.
+	Streams int
+	// What the body of the upload is made of (random bytes unless set).
+	Pattern    UploadPattern
+	clientId   uint64
+	identifier *connectionIdentifier
+	// Only the connection (ConnInfo) is filled in: uploads are not timed.
+	stats  stats.TraceStats
+	status LgcStatus
+	// Why the connection failed (see Err).
+	err          error
+	statusLock   *sync.Mutex
+	statusWaiter *sync.Cond
 }
 
 func NewLoadGeneratingConnectionUpload(url string, keyLogger io.Writer, connectToAddr string, insecureSkipVerify bool) LoadGeneratingConnectionUpload {
@@ -55,6 +78,7 @@ func NewLoadGeneratingConnectionUpload(url string, keyLogger io.Writer, connectT
 		ConnectToAddr:      connectToAddr,
 		InsecureSkipVerify: insecureSkipVerify,
 		statusLock:         &sync.Mutex{},
+		identifier:         &connectionIdentifier{},
 	}
 	lgu.status = LGC_STATUS_NOT_STARTED
 	lgu.statusWaiter = sync.NewCond(lgu.statusLock)
@@ -67,6 +91,10 @@ func (lgu *LoadGeneratingConnectionUpload) WaitUntilStarted(ctxt context.Context
 	return utilities.WaitWithContext(ctxt, &conditional, lgu.statusLock, lgu.statusWaiter)
 }
 
+func (lgu *LoadGeneratingConnectionUpload) Identity() ConnectionIdentity {
+	return lgu.identifier.Identity()
+}
+
 func (lgu *LoadGeneratingConnectionUpload) ClientId() uint64 {
 	return lgu.clientId
 }
@@ -90,6 +118,12 @@ func (lgu *LoadGeneratingConnectionUpload) Status() LgcStatus {
 	return lgu.status
 }
 
+func (lgu *LoadGeneratingConnectionUpload) Err() error {
+	lgu.statusLock.Lock()
+	defer lgu.statusLock.Unlock()
+	return lgu.err
+}
+
 type syntheticCountingReader struct {
 	n   *uint64
 	ctx context.Context
@@ -97,17 +131,65 @@ type syntheticCountingReader struct {
 }
 
 func (s *syntheticCountingReader) Read(p []byte) (n int, err error) {
+	if n, err = s.reserve(len(p)); err != nil {
+		return
+	}
+	payload := uploadPayloads[s.lgu.Pattern]
+	for filled := 0; filled < n; {
+		filled += copy(p[filled:n], payload)
+	}
+	return
+}
+
+// Write the body straight from the payload buffer. Where the transport copies the body (as
+// it does with HTTP/1.1), this saves it from allocating a buffer and copying in to it.
+func (s *syntheticCountingReader) WriteTo(w io.Writer) (int64, error) {
+	payload := uploadPayloads[s.lgu.Pattern]
+	written := int64(0)
+	for {
+		n, err := s.reserve(len(payload))
+		if err != nil {
+			return written, nil
+		}
+		wrote, err := w.Write(payload[:n])
+		written += int64(wrote)
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// Account for (and pace) sending the next size bytes of the body; the upload may send fewer.
+func (s *syntheticCountingReader) reserve(size int) (n int, err error) {
 	if s.ctx.Err() != nil {
 		return 0, io.EOF
 	}
-	if *s.n == 0 {
+	if atomic.LoadUint64(s.n) == 0 {
 		s.lgu.statusLock.Lock()
 		s.lgu.status = LGC_STATUS_RUNNING
 		s.lgu.statusWaiter.Broadcast()
 		s.lgu.statusLock.Unlock()
 	}
 	err = nil
-	n = len(p)
+	n = size
+	if s.lgu.Pacer != nil {
+		n = s.lgu.Pacer.limit(n)
+	}
+	if s.lgu.Limiter != nil {
+		n = s.lgu.Limiter.limit(n)
+	}
+	if s.lgu.Budget != nil {
+		if n = s.lgu.Budget.limit(uploadDirection, n); n == 0 {
+			return 0, io.EOF
+		}
+		s.lgu.Budget.spend(uploadDirection, n)
+	}
+	if s.lgu.Pacer != nil {
+		s.lgu.Pacer.Wait(s.ctx, n)
+	}
+	if s.lgu.Limiter != nil {
+		s.lgu.Limiter.Wait(s.ctx, n)
+	}
 
 	atomic.AddUint64(s.n, uint64(n))
 	return
@@ -115,27 +197,6 @@ func (s *syntheticCountingReader) Read(p []byte) (n int, err error) {
 
 func (lgu *LoadGeneratingConnectionUpload) doUpload(ctx context.Context) error {
 	lgu.uploaded = 0
-	s := &syntheticCountingReader{n: &lgu.uploaded, ctx: ctx, lgu: lgu}
-	var resp *http.Response = nil
-	var request *http.Request = nil
-	var err error
-
-	if request, err = http.NewRequest(
-		"POST",
-		lgu.URL,
-		s,
-	); err != nil {
-		lgu.statusLock.Lock()
-		lgu.status = LGC_STATUS_ERROR
-		lgu.statusWaiter.Broadcast()
-		lgu.statusLock.Unlock()
-		return err
-	}
-
-	// Used to disable compression
-	request.Header.Set("Accept-Encoding", "identity")
-	request.Header.Set("User-Agent", utilities.UserAgent())
-
 	lgu.uploadStartTime = time.Now()
 	lgu.lastIntervalEnd = 0
 
@@ -144,9 +205,28 @@ func (lgu *LoadGeneratingConnectionUpload) doUpload(ctx context.Context) error {
 	lgu.statusWaiter.Broadcast()
 	lgu.statusLock.Unlock()
 
-	if resp, err = lgu.client.Do(request); err != nil {
+	// The other streams (if any) wait for the connection that the first one makes (see Start).
+	streams := sync.WaitGroup{}
+	streamErrs := make([]error, lgu.Streams)
+	for i := 1; i < lgu.Streams; i++ {
+		streams.Add(1)
+		go func(i int) {
+			defer streams.Done()
+			streamErrs[i] = lgu.post(ctx, false)
+		}(i)
+	}
+	err := lgu.post(ctx, true)
+	streams.Wait()
+	for _, streamErr := range streamErrs {
+		if err == nil {
+			err = streamErr
+		}
+	}
+
+	if err != nil {
 		lgu.statusLock.Lock()
 		lgu.status = LGC_STATUS_ERROR
+		lgu.err = err
 		lgu.statusWaiter.Broadcast()
 		lgu.statusLock.Unlock()
 		return err
@@ -157,13 +237,53 @@ func (lgu *LoadGeneratingConnectionUpload) doUpload(ctx context.Context) error {
 	lgu.statusWaiter.Broadcast()
 	lgu.statusLock.Unlock()
 
-	resp.Body.Close()
 	if debug.IsDebug(lgu.debug) {
 		fmt.Printf("Ending a load-generating upload.\n")
 	}
 	return nil
 }
 
+// Send an upload (whose body only ends when ctx is done) and make sure that the response is one
+// that we can use. The first stream of the upload is traced to learn its connection (see Stats).
+func (lgu *LoadGeneratingConnectionUpload) post(ctx context.Context, traced bool) error {
+	s := &syntheticCountingReader{n: &lgu.uploaded, ctx: ctx, lgu: lgu}
+	request, err := http.NewRequest("POST", lgu.URL, s)
+	if err != nil {
+		return err
+	}
+	if traced {
+		request = request.WithContext(httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{
+			DNSStart: func(info httptrace.DNSStartInfo) {
+				lgu.statusLock.Lock()
+				lgu.stats.DnsStartTime, lgu.stats.DnsStart = time.Now(), info
+				lgu.statusLock.Unlock()
+			},
+			DNSDone: func(info httptrace.DNSDoneInfo) {
+				lgu.statusLock.Lock()
+				lgu.stats.DnsDoneTime, lgu.stats.DnsDone = time.Now(), info
+				lgu.statusLock.Unlock()
+			},
+			GotConn: func(info httptrace.GotConnInfo) {
+				lgu.statusLock.Lock()
+				lgu.stats.ConnInfo = info
+				lgu.statusLock.Unlock()
+				lgu.identifier.gotConn(info.Conn)
+			},
+		}))
+	}
+
+	// Used to disable compression
+	request.Header.Set("Accept-Encoding", "identity")
+	utilities.SetRequestHeaders(request, lgu.Headers)
+
+	resp, err := lgu.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return CheckResponse(resp)
+}
+
 func (lgu *LoadGeneratingConnectionUpload) Start(
 	parentCtx context.Context,
 	debugLevel debug.DebugLevel,
@@ -185,10 +305,21 @@ func (lgu *LoadGeneratingConnectionUpload) Start(
 				"Using an SSL Key Logger for this load-generating upload.\n",
 			)
 		}
-		transport.TLSClientConfig.KeyLogWriter = lgu.KeyLogger
+		lgu.identifier.keyLogger = utilities.TraceKeyLog(
+			lgu.KeyLogger,
+			debug.IsDebug(lgu.debug),
+			fmt.Sprintf("load-generating upload %v", lgu.clientId),
+		)
+		transport.TLSClientConfig.KeyLogWriter = lgu.identifier
 	}
 
-	utilities.OverrideHostTransport(transport, lgu.ConnectToAddr)
+	utilities.OverrideHostTransport(transport, lgu.ConnectToAddr, lgu.ProxyDialer)
+	lgu.identifier.watchDials(transport)
+	if lgu.Streams > 1 {
+		// All the streams start at once; rather than each making a connection of its own, they
+		// wait for the first and share it. (Over HTTP/1.1, that means taking turns.)
+		transport.MaxConnsPerHost = 1
+	}
 
 	lgu.client = &http.Client{Transport: transport}
 
@@ -200,7 +331,13 @@ func (lgu *LoadGeneratingConnectionUpload) Start(
 	return true
 }
 
+// Only the connection (and how long it took to look up its host) is known, and only once the
+// upload has one; the upload is not timed.
 func (lgu *LoadGeneratingConnectionUpload) Stats() *stats.TraceStats {
-	// Get all your stats from the download side of the LGC.
-	return nil
+	lgu.statusLock.Lock()
+	defer lgu.statusLock.Unlock()
+	if lgu.stats.ConnInfo.Conn == nil {
+		return nil
+	}
+	return &lgu.stats
 }
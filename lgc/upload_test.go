@@ -0,0 +1,176 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+func newTestUploadReader(ctx context.Context) *syntheticCountingReader {
+	lgu := NewLoadGeneratingConnectionUpload("https://example.com/upload", nil, "", false)
+	return &syntheticCountingReader{n: &lgu.uploaded, ctx: ctx, lgu: &lgu}
+}
+
+func TestSyntheticUploadBody(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := newTestUploadReader(ctx)
+
+	body := make([]byte, constants.UploadPayloadSize*2+100)
+	if n, err := reader.Read(body); err != nil || n != len(body) {
+		t.Fatalf("Could not read the upload body: %d %v", n, err)
+	}
+	if !bytes.Equal(body[:constants.UploadPayloadSize], uploadPayloads[RandomPayload]) ||
+		!bytes.Equal(body[constants.UploadPayloadSize*2:], uploadPayloads[RandomPayload][:100]) {
+		t.Fatalf("The upload body should repeat the payload.")
+	}
+	if *reader.n != uint64(len(body)) {
+		t.Fatalf("The upload should have counted %d bytes, not %d.", len(body), *reader.n)
+	}
+
+	cancel()
+	if n, err := reader.Read(body); n != 0 || err != io.EOF {
+		t.Fatalf("A canceled upload should have no more body: %d %v", n, err)
+	}
+	if n, err := reader.WriteTo(io.Discard); n != 0 || err != nil {
+		t.Fatalf("A canceled upload should write nothing: %d %v", n, err)
+	}
+}
+
+var errBenchmarkDone = errors.New("done")
+
+// Discards everything written to it until it has seen remaining bytes.
+type limitedDiscard struct {
+	remaining int64
+}
+
+func (l *limitedDiscard) Write(p []byte) (int, error) {
+	l.remaining -= int64(len(p))
+	if l.remaining <= 0 {
+		return len(p), errBenchmarkDone
+	}
+	return len(p), nil
+}
+
+func BenchmarkSyntheticUploadRead(b *testing.B) {
+	reader := newTestUploadReader(context.Background())
+	buffer := make([]byte, constants.UploadPayloadSize)
+	b.SetBytes(int64(len(buffer)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Read(buffer)
+	}
+}
+
+func BenchmarkSyntheticUploadWriteTo(b *testing.B) {
+	reader := newTestUploadReader(context.Background())
+	b.SetBytes(int64(constants.UploadPayloadSize))
+	b.ReportAllocs()
+	b.ResetTimer()
+	if _, err := reader.WriteTo(&limitedDiscard{remaining: int64(b.N) * int64(constants.UploadPayloadSize)}); err != errBenchmarkDone {
+		b.Fatalf("The upload body ended early: %v", err)
+	}
+}
+
+func TestUploadStreams(t *testing.T) {
+	requests := make(chan string, 3)
+	server := newStreamsServer(requests, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgu := NewLoadGeneratingConnectionUpload(server.URL, nil, "", true)
+	lgu.Streams = 3
+	lgu.Start(ctx, debug.NoDebug)
+	expectStreams(t, requests, 3)
+}
+
+func TestUploadStats(t *testing.T) {
+	requests := make(chan string, 1)
+	server := newStreamsServer(requests, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgu := NewLoadGeneratingConnectionUpload(server.URL, nil, "", true)
+	if lgu.Stats() != nil {
+		t.Fatalf("An upload that has not started should not know its connection.")
+	}
+	lgu.Start(ctx, debug.NoDebug)
+	expectStreams(t, requests, 1)
+	stats := lgu.Stats()
+	if stats == nil || stats.ConnInfo.Conn == nil {
+		t.Fatalf("An upload that is underway should know its connection.")
+	}
+	if remote := stats.ConnInfo.Conn.RemoteAddr().String(); remote != server.Listener.Addr().String() {
+		t.Fatalf("The upload's connection should be to the server (not %v).", remote)
+	}
+}
+
+func TestUploadStatsDNS(t *testing.T) {
+	requests := make(chan string, 1)
+	server := newStreamsServer(requests, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	lgu := NewLoadGeneratingConnectionUpload(url, nil, "", true)
+	lgu.Start(ctx, debug.NoDebug)
+	expectStreams(t, requests, 1)
+	stats := lgu.Stats()
+	if stats == nil || stats.DnsStartTime.IsZero() || stats.DnsDoneTime.Before(stats.DnsStartTime) {
+		t.Fatalf("An upload that is underway should know how long it took to look up its host: %v", stats)
+	}
+}
+
+func TestUploadPatterns(t *testing.T) {
+	compressedSize := func(pattern UploadPattern) float64 {
+		var compressed bytes.Buffer
+		writer, _ := flate.NewWriter(&compressed, flate.BestCompression)
+		writer.Write(uploadPayloads[pattern])
+		writer.Close()
+		return float64(compressed.Len()) / float64(constants.UploadPayloadSize)
+	}
+	random, zeros, compressible := compressedSize(RandomPayload), compressedSize(ZerosPayload), compressedSize(CompressiblePayload)
+	if random < 0.99 || zeros > 0.01 || compressible < 0.4 || compressible > 0.8 {
+		t.Fatalf("The patterns compress wrongly: random %.2f, zeros %.2f, compressible %.2f", random, zeros, compressible)
+	}
+
+	for _, name := range []string{"random", "zeros", "compressible"} {
+		if pattern, err := ParseUploadPattern(name); err != nil || pattern.String() != name {
+			t.Fatalf("Could not parse the %s upload pattern: %v %v", name, pattern, err)
+		}
+	}
+	if _, err := ParseUploadPattern("ones"); err == nil {
+		t.Fatalf("ones should not be an upload pattern.")
+	}
+}
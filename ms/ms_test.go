@@ -376,3 +376,98 @@ func Test_CappedDoubleSidedTrimmedMean_jumbled(test *testing.T) {
 		prev = v
 	}
 }
+
+func Test_TDigestPercentiles(test *testing.T) {
+	series := NewTDigestMathematicalSeries[float64](100)
+	exact := NewInfiniteMathematicalSeries[float64]()
+	// A deterministic, shuffled sequence of 0 ... 99999.
+	for i := 0; i < 100000; i++ {
+		value := float64((i * 7919) % 100000)
+		series.AddElement(value)
+		exact.AddElement(value)
+	}
+
+	if series.Len() != 100000 {
+		test.Fatalf("T-digest series length should be 100000 but is %v.", series.Len())
+	}
+	if !utilities.ApproximatelyEqual(exact.CalculateAverage(), series.CalculateAverage(), 0.0001) {
+		test.Fatalf("T-digest series average should be exact (%v) but is %v.", exact.CalculateAverage(), series.CalculateAverage())
+	}
+	for _, p := range []int{1, 10, 50, 90, 99} {
+		expected := exact.Percentile(p)
+		actual := series.Percentile(p)
+		// Within 0.5% of the range.
+		if !utilities.ApproximatelyEqual(expected, actual, 500) {
+			test.Fatalf("T-digest series %d percentile should be about %v but is %v.", p, expected, actual)
+		}
+	}
+	if len(series.Values()) > 1000 {
+		test.Fatalf("T-digest series should have kept far fewer centroids than elements (%d).", len(series.Values()))
+	}
+
+	trimmed := series.DoubleSidedTrim(10)
+	if trimmed.Len() != 80000 {
+		test.Fatalf("Trimmed t-digest series length should be 80000 but is %v.", trimmed.Len())
+	}
+	if !utilities.ApproximatelyEqual(exact.DoubleSidedTrim(10).CalculateAverage(), trimmed.CalculateAverage(), 500) {
+		test.Fatalf(
+			"Trimmed t-digest series average should be about %v but is %v.",
+			exact.DoubleSidedTrim(10).CalculateAverage(),
+			trimmed.CalculateAverage(),
+		)
+	}
+}
+
+func Test_TDigestSmall(test *testing.T) {
+	series := NewTDigestMathematicalSeries[float64](100)
+	for _, value := range []float64{1.0, 2.0, 3.0, 4.0} {
+		series.AddElement(value)
+	}
+	if series.Percentile(0) != 1.0 || series.Percentile(100) != 4.0 {
+		test.Fatalf("T-digest series extremes should be 1.0 and 4.0 but are %v and %v.", series.Percentile(0), series.Percentile(100))
+	}
+	if _, sd := series.StandardDeviation(); !utilities.ApproximatelyEqual(1.118, sd, 0.001) {
+		test.Fatalf("T-digest series standard deviation should be about 1.118 but is %v.", sd)
+	}
+	if islt, maxSeqIncrease := series.AllSequentialIncreasesLessThan(50.0); islt {
+		test.Fatalf("Sequential increases (up to %v) should not all be less than 50.", maxSeqIncrease)
+	}
+}
+
+func Test_WindowedPartial(test *testing.T) {
+	series := NewWindowedMathematicalSeries[float64](5)
+	series.AddElement(2.0)
+	series.AddElement(4.0)
+
+	if series.Len() != 2 || series.IsFull() {
+		test.Fatalf("Windowed series should hold 2 (of 5) elements but holds %v.", series.Len())
+	}
+	if series.CalculateAverage() != 3.0 {
+		test.Fatalf("Windowed series average should be 3.0 but is %v.", series.CalculateAverage())
+	}
+	if valid, sd := series.StandardDeviation(); !valid || sd != 1.0 {
+		test.Fatalf("Windowed series standard deviation should be 1.0 but is %v.", sd)
+	}
+}
+
+func Test_WindowedEvictsOldest(test *testing.T) {
+	series := NewWindowedMathematicalSeries[int](3)
+	for _, value := range utilities.Iota(1, 8) {
+		series.AddElement(value)
+	}
+
+	if !reflect.DeepEqual([]int{5, 6, 7}, series.Values()) {
+		test.Fatalf("Windowed series should hold the most recent elements in order but holds %v.", series.Values())
+	}
+	if series.Percentile(90) != 7 || series.Percentile(0) != 5 {
+		test.Fatalf("Windowed series percentiles should only consider the window: %v", series.Values())
+	}
+	if islt, _ := series.AllSequentialIncreasesLessThan(25.0); !islt {
+		test.Fatalf("Sequential increases in the window (%v) should be less than 25%%.", series.Values())
+	}
+
+	trimmed := series.DoubleSidedTrim(34)
+	if !reflect.DeepEqual([]int{6}, trimmed.Values()) {
+		test.Fatalf("Trimmed windowed series should hold only 6 but holds %v.", trimmed.Values())
+	}
+}
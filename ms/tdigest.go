@@ -0,0 +1,153 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ms
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/influxdata/tdigest"
+	"github.com/network-quality/goresponsiveness/utilities"
+	"golang.org/x/exp/constraints"
+)
+
+// A TDigestMathematicalSeries estimates percentiles with a t-digest: rather than keeping
+// every element, it keeps a bounded number of centroids that are small near the tails of
+// the distribution (where percentiles need to be precise) and large near its middle.
+// Memory is bounded by the compression and adding an element is (amortized) constant time,
+// which makes it suitable for long tests. The average and standard deviation are exact;
+// percentiles, trimmed series and Values() are approximations built from the centroids.
+type TDigestMathematicalSeries[T constraints.Float | constraints.Integer] struct {
+	digest *tdigest.TDigest
+
+	// Fractional after a trim, which can keep part of a centroid.
+	count float64
+	sum   float64
+	// For calculating the variance in a single pass (see Welford).
+	mean float64
+	m2   float64
+
+	// For AllSequentialIncreasesLessThan, which cannot look back at the elements.
+	previous                  float64
+	maximumSequentialIncrease float64
+}
+
+func NewTDigestMathematicalSeries[T constraints.Float | constraints.Integer](
+	compression uint,
+) MathematicalSeries[T] {
+	if compression == 0 {
+		panic("Cannot create a t-digest with a compression of 0.")
+	}
+	return &TDigestMathematicalSeries[T]{digest: tdigest.NewWithCompression(float64(compression))}
+}
+
+func (tds *TDigestMathematicalSeries[T]) AddElement(element T) {
+	value := float64(element)
+	if tds.count > 0 {
+		percentChange := utilities.SignedPercentDifference(value, tds.previous)
+		if tds.count == 1 || percentChange > tds.maximumSequentialIncrease {
+			tds.maximumSequentialIncrease = percentChange
+		}
+	}
+	tds.previous = value
+	tds.add(value, 1)
+}
+
+// Account for weight elements (each with the given value).
+func (tds *TDigestMathematicalSeries[T]) add(value float64, weight float64) {
+	tds.digest.Add(value, weight)
+	newCount := tds.count + weight
+	delta := value - tds.mean
+	tds.mean += delta * weight / newCount
+	tds.m2 += delta * (value - tds.mean) * weight
+	tds.sum += value * weight
+	tds.count = newCount
+}
+
+func (tds *TDigestMathematicalSeries[T]) CalculateAverage() float64 {
+	return tds.sum / tds.count
+}
+
+func (tds *TDigestMathematicalSeries[T]) AllSequentialIncreasesLessThan(
+	limit float64,
+) (bool, float64) {
+	if tds.count < 2 {
+		return false, 0.0
+	}
+	return tds.maximumSequentialIncrease <= limit, tds.maximumSequentialIncrease
+}
+
+func (tds *TDigestMathematicalSeries[T]) StandardDeviation() (bool, T) {
+	if tds.count == 0 {
+		return false, T(0)
+	}
+	return true, T(math.Sqrt(tds.m2 / tds.count))
+}
+
+func (tds *TDigestMathematicalSeries[T]) IsNormallyDistributed() bool {
+	return false
+}
+
+func (tds *TDigestMathematicalSeries[T]) Len() int {
+	return int(math.Round(tds.count))
+}
+
+// The means of the centroids (not the elements themselves, which are not kept).
+func (tds *TDigestMathematicalSeries[T]) Values() []T {
+	centroids := tds.digest.Centroids()
+	values := make([]T, len(centroids))
+	for i, centroid := range centroids {
+		values[i] = T(centroid.Mean)
+	}
+	return values
+}
+
+func (tds *TDigestMathematicalSeries[T]) Percentile(p int) T {
+	if p < 0 || p > 100 || tds.count == 0 {
+		return T(0)
+	}
+	return T(tds.digest.Quantile(float64(p) / 100))
+}
+
+func (tds *TDigestMathematicalSeries[T]) DoubleSidedTrim(percent uint32) MathematicalSeries[T] {
+	if percent >= 100 {
+		panic(
+			fmt.Sprintf("Cannot perform double-sided trim for an invalid percentage: %d", percent),
+		)
+	}
+
+	trimmed := &TDigestMathematicalSeries[T]{digest: tdigest.NewWithCompression(tds.digest.Compression)}
+	elementsToTrim := float64(uint64(float32(tds.count) * ((float32(percent)) / float32(100.0))))
+	low, high := elementsToTrim, tds.count-elementsToTrim
+
+	// Keep the parts of the centroids whose ranks are within the bounds.
+	cumulative := float64(0)
+	for _, centroid := range tds.digest.Centroids() {
+		weight := math.Min(cumulative+centroid.Weight, high) - math.Max(cumulative, low)
+		cumulative += centroid.Weight
+		if weight > 0 {
+			trimmed.add(centroid.Mean, weight)
+		}
+	}
+	return trimmed
+}
+
+// The centroids are always in order, so there is nothing to sort.
+func (tds *TDigestMathematicalSeries[T]) Swap(i, j int) {
+}
+
+func (tds *TDigestMathematicalSeries[T]) Less(i, j int) bool {
+	return i < j
+}
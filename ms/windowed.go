@@ -0,0 +1,166 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ms
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/network-quality/goresponsiveness/utilities"
+	"golang.org/x/exp/constraints"
+)
+
+// A WindowedMathematicalSeries keeps (only) the most recent elements, up to its capacity,
+// and calculates its statistics over just those. Unlike a CappedMathematicalSeries, whose
+// statistics are only meaningful once it is full, a windowed series can be used as soon as
+// it has any elements, which suits uses (like live displays) that care about recent behavior.
+type WindowedMathematicalSeries[T constraints.Float | constraints.Integer] struct {
+	elements []T
+	// The index of the oldest element.
+	start int
+	count int
+}
+
+func NewWindowedMathematicalSeries[T constraints.Float | constraints.Integer](
+	capacity uint64,
+) *WindowedMathematicalSeries[T] {
+	if capacity == 0 {
+		panic("Cannot create a windowed mathematical series with a capacity of 0.")
+	}
+	return &WindowedMathematicalSeries[T]{elements: make([]T, capacity)}
+}
+
+func (wms *WindowedMathematicalSeries[T]) Capacity() int {
+	return len(wms.elements)
+}
+
+// Whether the series holds as many elements as it can (i.e., whether adding another will
+// evict the oldest).
+func (wms *WindowedMathematicalSeries[T]) IsFull() bool {
+	return wms.count == len(wms.elements)
+}
+
+func (wms *WindowedMathematicalSeries[T]) AddElement(element T) {
+	if wms.IsFull() {
+		wms.elements[wms.start] = element
+		wms.start = (wms.start + 1) % len(wms.elements)
+		return
+	}
+	wms.elements[(wms.start+wms.count)%len(wms.elements)] = element
+	wms.count++
+}
+
+func (wms *WindowedMathematicalSeries[T]) CalculateAverage() float64 {
+	return calculateAverage(wms.Values())
+}
+
+func (wms *WindowedMathematicalSeries[T]) AllSequentialIncreasesLessThan(
+	limit float64,
+) (bool, float64) {
+	values := wms.Values()
+	if len(values) < 2 {
+		return false, 0.0
+	}
+
+	maximumSequentialIncrease := float64(0)
+	for i := 1; i < len(values); i++ {
+		percentChange := utilities.SignedPercentDifference(values[i], values[i-1])
+		if percentChange > limit {
+			return false, percentChange
+		}
+		if percentChange > maximumSequentialIncrease {
+			maximumSequentialIncrease = percentChange
+		}
+	}
+	return true, maximumSequentialIncrease
+}
+
+func (wms *WindowedMathematicalSeries[T]) StandardDeviation() (bool, T) {
+	if wms.count == 0 {
+		return false, T(0)
+	}
+	average := wms.CalculateAverage()
+	squaredDifferences := float64(0)
+	for _, value := range wms.Values() {
+		squaredDifferences += math.Pow(float64(value)-average, 2)
+	}
+	return true, T(math.Sqrt(squaredDifferences / float64(wms.count)))
+}
+
+func (wms *WindowedMathematicalSeries[T]) IsNormallyDistributed() bool {
+	valid, stddev := wms.StandardDeviation()
+	if !valid {
+		return false
+	}
+	avg := wms.CalculateAverage()
+
+	fstddev := float64(stddev)
+	within := float64(0)
+	for _, v := range wms.Values() {
+		if (avg-fstddev) <= float64(v) && float64(v) <= (avg+fstddev) {
+			within++
+		}
+	}
+	return within/float64(wms.count) >= 0.68
+}
+
+func (wms *WindowedMathematicalSeries[T]) Len() int {
+	return wms.count
+}
+
+// The elements, oldest first.
+func (wms *WindowedMathematicalSeries[T]) Values() []T {
+	values := make([]T, wms.count)
+	for i := range values {
+		values[i] = wms.elements[(wms.start+i)%len(wms.elements)]
+	}
+	return values
+}
+
+func (wms *WindowedMathematicalSeries[T]) Percentile(p int) T {
+	if wms.count == 0 {
+		return T(0)
+	}
+	return calculatePercentile(wms.Values(), p)
+}
+
+func (wms *WindowedMathematicalSeries[T]) DoubleSidedTrim(percent uint32) MathematicalSeries[T] {
+	if percent >= 100 {
+		panic(
+			fmt.Sprintf("Cannot perform double-sided trim for an invalid percentage: %d", percent),
+		)
+	}
+
+	values := wms.Values()
+	sort.Slice(values, func(l int, r int) bool { return values[l] < values[r] })
+	elementsToTrim := int(float32(len(values)) * ((float32(percent)) / float32(100.0)))
+	values = values[elementsToTrim : len(values)-elementsToTrim]
+
+	trimmed := NewWindowedMathematicalSeries[T](uint64(wms.Capacity()))
+	for _, value := range values {
+		trimmed.AddElement(value)
+	}
+	return trimmed
+}
+
+func (wms *WindowedMathematicalSeries[T]) Swap(i, j int) {
+	i, j = (wms.start+i)%len(wms.elements), (wms.start+j)%len(wms.elements)
+	wms.elements[i], wms.elements[j] = wms.elements[j], wms.elements[i]
+}
+
+func (wms *WindowedMathematicalSeries[T]) Less(i, j int) bool {
+	return wms.elements[(wms.start+i)%len(wms.elements)] < wms.elements[(wms.start+j)%len(wms.elements)]
+}
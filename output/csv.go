@@ -0,0 +1,121 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package output
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The columns of the CSV summary of a result.
+var csvColumns = []string{
+	"time",
+	"server",
+	"spec_version",
+	"stable",
+	"rpm",
+	"trimmed_mean_rpm",
+	"idle_rpm",
+	"idle_trimmed_mean_rpm",
+	"download_bytes_per_second",
+	"download_connections",
+	"upload_bytes_per_second",
+	"upload_connections",
+	"download_saturated",
+	"upload_saturated",
+}
+
+// Render the result as a single CSV line (after a line of column names, if header is true)
+// so that the results of many tests can be collected in one file. Values that are not
+// available (e.g., the idle RPM when it was not measured) are left empty.
+func WriteCSV(w io.Writer, result Result, header bool) error {
+	formatFloat := func(value float64) string {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return ""
+		}
+		return strconv.FormatFloat(value, 'f', 3, 64)
+	}
+	idleRpm, idleTrimmedMeanRpm := "", ""
+	if result.Idle != nil {
+		idleRpm, idleTrimmedMeanRpm = formatFloat(float64(result.Idle.Rpm)), formatFloat(float64(result.Idle.TrimmedMeanRpm))
+	}
+	downloadSaturated, uploadSaturated := "", ""
+	if result.DownloadSaturation.Assessed {
+		downloadSaturated = strconv.FormatBool(result.DownloadSaturation.Saturated)
+	}
+	if result.UploadSaturation.Assessed {
+		uploadSaturated = strconv.FormatBool(result.UploadSaturation.Saturated)
+	}
+
+	writer := csv.NewWriter(w)
+	if header {
+		writer.Write(csvColumns)
+	}
+	writer.Write([]string{
+		result.Time.UTC().Format(time.RFC3339),
+		result.Server,
+		result.SpecVersion,
+		strconv.FormatBool(result.Stable),
+		formatFloat(float64(result.Rpm)),
+		formatFloat(float64(result.TrimmedMeanRpm)),
+		idleRpm,
+		idleTrimmedMeanRpm,
+		formatFloat(result.DownloadThroughput),
+		strconv.Itoa(result.DownloadConnections),
+		formatFloat(result.UploadThroughput),
+		strconv.Itoa(result.UploadConnections),
+		downloadSaturated,
+		uploadSaturated,
+	})
+	writer.Flush()
+	return writer.Error()
+}
+
+// Append the result as a line of CSV to a file (or, when the filename is -, write it to
+// stdout). The column names are only written when the file is new (or empty).
+type CSVSink struct {
+	Filename string
+}
+
+func (sink *CSVSink) Write(result Result) error {
+	if result.Interim {
+		return nil
+	}
+	if sink.Filename == "-" {
+		return WriteCSV(os.Stdout, result, true)
+	}
+	file, err := os.OpenFile(sink.Filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := WriteCSV(file, result, info.Size() == 0); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (sink *CSVSink) String() string {
+	return "csv:" + sink.Filename
+}
@@ -0,0 +1,164 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// A Sink is somewhere that the results of a test go. Interim results (see Result.Interim) go
+// to every sink, too; the sinks that summarize a whole test (csv, prometheus and text) ignore
+// them.
+type Sink interface {
+	Write(result Result) error
+	String() string
+}
+
+// Create a sink from its specification: the kind of sink and its destination, separated
+// by a colon (e.g., json:results.json or webhook:https://example.com/results).
+func ParseSink(specification string) (Sink, error) {
+	kind, destination, found := strings.Cut(specification, ":")
+	if !found || destination == "" {
+		return nil, fmt.Errorf("output %q does not have the form kind:destination", specification)
+	}
+	switch kind {
+	case "json":
+		return &JSONSink{Filename: destination}, nil
+	case "text":
+		return &TextSink{Filename: destination}, nil
+	case "csv":
+		return &CSVSink{Filename: destination}, nil
+	case "prometheus":
+		return &PrometheusSink{Filename: destination}, nil
+	case "webhook":
+		if !strings.HasPrefix(destination, "http://") && !strings.HasPrefix(destination, "https://") {
+			return nil, fmt.Errorf("webhook output %q is not an http(s) URL", destination)
+		}
+		return &WebhookSink{URL: destination}, nil
+	}
+	return nil, fmt.Errorf("unrecognized output kind: %s (use json, text, csv, prometheus or webhook)", kind)
+}
+
+// Write the result as a JSON document to a file (or, when the filename is -, to stdout).
+type JSONSink struct {
+	Filename string
+}
+
+func (sink *JSONSink) Write(result Result) error {
+	document, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	document = append(document, '\n')
+	if sink.Filename == "-" {
+		_, err = os.Stdout.Write(document)
+		return err
+	}
+	return os.WriteFile(sink.Filename, document, 0644)
+}
+
+func (sink *JSONSink) String() string {
+	return "json:" + sink.Filename
+}
+
+// POST the result as a JSON document to a URL.
+type WebhookSink struct {
+	URL string
+}
+
+func (sink *WebhookSink) Write(result Result) error {
+	document, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, sink.URL, bytes.NewReader(document))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", utilities.UserAgent())
+
+	client := http.Client{Timeout: constants.OutputWebhookTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", response.Status)
+	}
+	return nil
+}
+
+func (sink *WebhookSink) String() string {
+	return "webhook:" + sink.URL
+}
+
+// Sinks is a flag.Value so that the user can give any number of outputs, either by
+// repeating the flag or by separating them with commas.
+type Sinks []Sink
+
+func (sinks *Sinks) String() string {
+	if sinks == nil {
+		return ""
+	}
+	specifications := make([]string, 0, len(*sinks))
+	for _, sink := range *sinks {
+		specifications = append(specifications, sink.String())
+	}
+	return strings.Join(specifications, ",")
+}
+
+func (sinks *Sinks) Set(value string) error {
+	for _, specification := range strings.Split(value, ",") {
+		sink, err := ParseSink(specification)
+		if err != nil {
+			return err
+		}
+		*sinks = append(*sinks, sink)
+	}
+	return nil
+}
+
+// Like flag.String, but for a list of sinks.
+func SinksFlag(name string, usage string) *Sinks {
+	sinks := &Sinks{}
+	flag.Var(sinks, name, usage)
+	return sinks
+}
+
+// Give the result to every sink. A sink that fails does not keep the result from the
+// others; the error describes every failure.
+func (sinks Sinks) Write(result Result) error {
+	failures := make([]string, 0)
+	for _, sink := range sinks {
+		if err := sink.Write(result); err != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", sink, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("could not write the results to %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
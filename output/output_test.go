@@ -0,0 +1,311 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/prometheus"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
+func testResult() Result {
+	return Result{
+		Time:                time.Now(),
+		Stable:              true,
+		Rpm:                 1234.5,
+		TrimmedMeanRpm:      Float(math.Inf(1)),
+		DownloadThroughput:  1e6,
+		DownloadConnections: 4,
+		UploadThroughput:    5e5,
+		UploadConnections:   2,
+	}
+}
+
+func TestParseSink(t *testing.T) {
+	var sinks Sinks
+	if err := sinks.Set("json:-,prometheus:stats.prom"); err != nil {
+		t.Fatalf("Could not parse the outputs: %v", err)
+	}
+	if err := sinks.Set("webhook:https://example.com/results?format=json"); err != nil {
+		t.Fatalf("Could not parse the outputs: %v", err)
+	}
+	if len(sinks) != 3 || sinks.String() != "json:-,prometheus:stats.prom,webhook:https://example.com/results?format=json" {
+		t.Fatalf("Outputs were not parsed correctly: %v", sinks.String())
+	}
+
+	for _, invalid := range []string{"json", "json:", "sqlite:results.db", "webhook:example.com"} {
+		if _, err := ParseSink(invalid); err == nil {
+			t.Fatalf("Output %q should not have been parsed.", invalid)
+		}
+	}
+}
+
+func TestSinksFanOut(t *testing.T) {
+	directory := t.TempDir()
+	var received Result
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Webhook received an invalid document: %s", body)
+		}
+	}))
+	defer server.Close()
+
+	sinks := Sinks{
+		&JSONSink{Filename: filepath.Join(directory, "missing", "results.json")},
+		&JSONSink{Filename: filepath.Join(directory, "results.json")},
+		&PrometheusSink{Filename: filepath.Join(directory, "stats.prom")},
+		&WebhookSink{URL: server.URL},
+	}
+	err := sinks.Write(testResult())
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("The failure of the first sink should have been reported: %v", err)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(directory, "results.json"))
+	if err != nil {
+		t.Fatalf("The JSON sink should have written its results despite the earlier failure: %v", err)
+	}
+	if !strings.Contains(string(contents), `"trimmed_mean_rpm": null`) {
+		t.Fatalf("An infinite RPM should have been written as null: %s", contents)
+	}
+
+	contents, err = os.ReadFile(filepath.Join(directory, "stats.prom"))
+	if err != nil {
+		t.Fatalf("The Prometheus sink should have written its results: %v", err)
+	}
+	if !strings.Contains(string(contents), "networkquality_rpm_value 1234\n") ||
+		!strings.Contains(string(contents), "networkquality_download_connections 4\n") {
+		t.Fatalf("The Prometheus metrics are wrong: %s", contents)
+	}
+
+	if received.Rpm != 1234.5 || received.UploadConnections != 2 {
+		t.Fatalf("The webhook did not receive the results: %v", received)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	result := testResult()
+	result.TrimmedMeanLabel = "Double-Sided 10% Trimmed Mean"
+	result.SpecVersion = "draft-02"
+	result.DNS = &Percentiles{P50: 0.0015, P90: 0.003, Count: 7}
+	result.LoadDNS = &Percentiles{P50: 0.002, P90: 0.004, Count: 16}
+	result.TLSResumed = &Percentiles{P50: 0.001, P90: 0.002, P99: 0.004, Count: 5}
+	result.Warm = &WarmResponsiveness{Rpm: 2000, TrimmedMeanRpm: 2500}
+	result.Idle = &IdleResponsiveness{Rpm: 3000, TrimmedMeanRpm: 4000, Probes: 12, RttTrimmedMean: 0.015}
+	result.ProbeQualityAttenuation = &ProbeQualityAttenuation{Foreign: &QualityAttenuation{Samples: 9, Losses: 1}}
+	result.ConnectionChurn = &ConnectionChurn{Download: 2}
+	result.ErrorResponses = &ErrorResponses{Upload: 1, Probes: 3}
+	result.Wire = &WireThroughput{Download: 1050000, Upload: 525000, DownloadOverhead: 1.05, UploadOverhead: 1.05}
+	result.HTTPProtocols = &HTTPProtocols{
+		Download: map[string]int{"HTTP/2": 4},
+		Probes:   map[string]int{"HTTP/2": 20, "HTTP/1.1": 2},
+	}
+	result.ForeignProbeReuse = "pool:4"
+	result.Bufferbloat = &rpm.BufferbloatGrade{Grade: "B", IdleLatency: 0.015, LoadedLatency: 0.06, Increase: 0.045, Inflation: 4}
+	result.Warnings = []string{"The probe budget was exhausted after 10 probes; probing has stopped."}
+
+	var text strings.Builder
+	WriteText(&text, result)
+	for _, expected := range []string{
+		"RPM:  1234 (P90)\n",
+		"RPM:  +Inf (Double-Sided 10% Trimmed Mean)\n",
+		"Warm RPM:  2000 (P90)\n",
+		"Warm RPM:  2500 (Double-Sided 10% Trimmed Mean)\n",
+		"Idle RPM:  3000 (P90)\n",
+		"Idle RPM:  4000 (Double-Sided 10% Trimmed Mean)\n",
+		"Idle Latency: 15.000 ms (12 probes)\n",
+		"Specification: draft-02\n",
+		"DNS Lookup (foreign probes): P50 1.500 ms, P90 3.000 ms (7 lookups)\n",
+		"DNS Lookup (load-generating connections): P50 2.000 ms, P90 4.000 ms (16 lookups)\n",
+		"TLS Resumed Handshake: P50 1.000 ms, P90 2.000 ms, P99 4.000 ms (5 handshakes)\n",
+		"Download:   7.629 Mbps (  0.954 MBps), using 4 parallel connections.\n",
+		"Warning: The probe budget was exhausted after 10 probes; probing has stopped.\n",
+		"Quality Attenuation Statistics (Foreign Probes):\nNumber of losses: 1\nNumber of samples: 9\n",
+		"Replaced Connections: 2 download, 0 upload (they failed during the test).\n",
+		"Error Responses: 0 download, 1 upload, 3 probe (the server answered with a status other than 2xx).\n",
+		"Wire:       8.011 Mbps down,   4.005 Mbps up (estimated; the above plus 5.0% and 5.0% of HTTP/2, TLS and TCP/IP overhead).\n",
+		"HTTP Protocols: download HTTP/2 (4); upload none; probes HTTP/1.1 (2), HTTP/2 (20).\n",
+		"Foreign Probe Connection Reuse: pool:4\n",
+		"Bufferbloat Grade: B (latency grows by 45.000 ms under load: 15.000 ms idle, 60.000 ms loaded, 4.0x)\n",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Fatalf("The text output should contain %q: %s", expected, text.String())
+		}
+	}
+	if strings.Contains(text.String(), "estimates") || strings.Contains(text.String(), "Cooldown") {
+		t.Fatalf("The text output should leave out what the result does not have: %s", text.String())
+	}
+}
+
+func TestWriteInterimText(t *testing.T) {
+	result := testResult()
+	result.Interim = true
+	result.Elapsed = 30.2
+	result.TrimmedMeanRpm = 1000
+	result.TrimmedMeanLabel = "Mean"
+
+	var text strings.Builder
+	WriteInterimText(&text, result)
+	expected := "Interim (30 s): RPM  1234 (P90),  1000 (Mean); Download   7.629 Mbps; Upload   3.815 Mbps\n"
+	if text.String() != expected {
+		t.Fatalf("The interim results should be %q but are %q.", expected, text.String())
+	}
+
+	result.Rpm, result.TrimmedMeanRpm = Float(math.NaN()), Float(math.NaN())
+	text.Reset()
+	WriteInterimText(&text, result)
+	expected = "Interim (30 s): RPM     - (P90),     - (Mean); Download   7.629 Mbps; Upload   3.815 Mbps\n"
+	if text.String() != expected {
+		t.Fatalf("Without an RPM, the interim results should be %q but are %q.", expected, text.String())
+	}
+}
+
+func TestSummarySinksIgnoreInterimResults(t *testing.T) {
+	directory := t.TempDir()
+	result := testResult()
+	result.Interim = true
+	for _, sink := range []Sink{
+		&CSVSink{Filename: filepath.Join(directory, "results.csv")},
+		&PrometheusSink{Filename: filepath.Join(directory, "stats.prom")},
+		&TextSink{Filename: filepath.Join(directory, "results.txt")},
+	} {
+		if err := sink.Write(result); err != nil {
+			t.Fatalf("%v could not ignore the interim results: %v", sink, err)
+		}
+	}
+	if entries, _ := os.ReadDir(directory); len(entries) != 0 {
+		t.Fatalf("The summary sinks should not have written the interim results (but wrote %d files).", len(entries))
+	}
+}
+
+func TestCSVSinkAppends(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.csv")
+	sink := &CSVSink{Filename: filename}
+	result := testResult()
+	result.Server = "example.com:4043"
+	result.DownloadSaturation.Assessed, result.DownloadSaturation.Saturated = true, true
+	for i := 0; i < 2; i++ {
+		if err := sink.Write(result); err != nil {
+			t.Fatalf("Could not write the CSV summary: %v", err)
+		}
+	}
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read the CSV summary: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "time,server,") {
+		t.Fatalf("The column names should only be written once: %s", contents)
+	}
+	fields := strings.Split(lines[1], ",")
+	if len(fields) != len(csvColumns) {
+		t.Fatalf("There should be a value for every column: %s", lines[1])
+	}
+	if fields[1] != "example.com:4043" || fields[4] != "1234.500" || fields[5] != "" ||
+		fields[12] != "true" || fields[13] != "" {
+		t.Fatalf("The CSV summary has the wrong values: %s", lines[1])
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	labels := []prometheus.Label{{Name: "site", Value: "home"}, {Name: "isp", Value: `Example "ISP"`}}
+	filename := filepath.Join(t.TempDir(), "stats.prom")
+	sink := &PrometheusSink{Filename: filename, Labels: labels}
+	result := testResult()
+	result.SpecVersion = "draft-02"
+	if err := sink.Write(result); err != nil {
+		t.Fatalf("Could not write the Prometheus metrics: %v", err)
+	}
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read the Prometheus metrics: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, `{site="home",isp="Example \"ISP\""`) {
+			t.Fatalf("Every metric should have the labels: %s", line)
+		}
+	}
+	if !strings.Contains(string(contents), `networkquality_spec_version_info{site="home",isp="Example \"ISP\"",version="draft-02"} 1`) {
+		t.Fatalf("The version label should follow the other labels: %s", contents)
+	}
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "stats.prom")
+	sink := &PrometheusSink{Filename: filename}
+	result := testResult()
+	result.Elapsed = 12.5
+	result.SelfProbes, result.SelfProbeTimeouts = 3, 1
+	result.SelfRttPercentiles = &Percentiles{P50: 0.01, P90: 0.02, P99: 0.04, Count: 3}
+	result.QualityAttenuation = &QualityAttenuation{Samples: 4, Losses: 1, P99: 0.05}
+	result.ProbeQualityAttenuation = &ProbeQualityAttenuation{SelfUp: &QualityAttenuation{Samples: 2, P99: 0.08}}
+	result.SelfRttHistogram = prometheus.NewHistogramValue([]float64{0.01, 0.1})
+	result.SelfRttHistogram.Observe(0.02)
+	result.SelfRttConnections = []ConnectionRtts{
+		{Direction: "download", ClientID: 7, Percentiles: Percentiles{P50: 0.01, P90: 0.03, P99: 0.06, Count: 2}},
+	}
+	result.Bufferbloat = &rpm.BufferbloatGrade{Grade: "C", IdleLatency: 0.02, LoadedLatency: 0.12, Increase: 0.1, Inflation: 6}
+	timeToSaturation := Float(4.5)
+	result.TimeToSaturation = &timeToSaturation
+	if err := sink.Write(result); err != nil {
+		t.Fatalf("Could not write the Prometheus metrics: %v", err)
+	}
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read the Prometheus metrics: %v", err)
+	}
+	for _, expected := range []string{
+		"# TYPE networkquality_rpm_value gauge\nnetworkquality_rpm_value 1234\n",
+		"networkquality_trimmed_rpm_value +Inf\n",
+		"networkquality_test_duration_seconds 12.5\n",
+		`networkquality_self_probe_rtt_seconds{quantile="0.99"} 0.04` + "\n",
+		"networkquality_self_probe_loss_ratio 0.25\n",
+		`networkquality_quality_attenuation_seconds{quantile="0.99"} 0.05` + "\n",
+		`networkquality_probe_quality_attenuation_seconds{probe="self_up",quantile="0.99"} 0.08` + "\n",
+		"networkquality_time_to_saturation_seconds 4.5\n",
+		"# TYPE networkquality_self_probe_rtt_histogram_seconds histogram\n",
+		`networkquality_self_probe_rtt_histogram_seconds_bucket{le="0.1"} 1` + "\n",
+		`networkquality_self_probe_connection_rtt_seconds{direction="download",client_id="7",quantile="0.9"} 0.03` + "\n",
+		`networkquality_bufferbloat_grade_info{grade="C"} 1` + "\n",
+		"networkquality_bufferbloat_increase_seconds 0.1\n",
+	} {
+		if !strings.Contains(string(contents), expected) {
+			t.Fatalf("The Prometheus metrics should include %q: %s", expected, contents)
+		}
+	}
+	if strings.Contains(string(contents), `probe="self_down"`) {
+		t.Fatalf("There should be no quality attenuation for probes that never ran: %s", contents)
+	}
+	if strings.Contains(string(contents), "networkquality_foreign_probe_rtt_seconds{") {
+		t.Fatalf("There should be no percentiles without RTTs: %s", contents)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package output
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/network-quality/goresponsiveness/prometheus"
+)
+
+// Write the result as Prometheus metrics to a file (overwriting it if it exists). Every
+// metric has the sink's labels. The file is replaced atomically so that a collector (e.g.,
+// the node exporter's textfile collector) never reads half of it.
+type PrometheusSink struct {
+	Filename string
+	Labels   []prometheus.Label
+}
+
+func (sink *PrometheusSink) Write(result Result) error {
+	if result.Interim {
+		return nil
+	}
+	metrics := prometheus.NewWriter(sink.Labels)
+	quantile := func(value string) prometheus.Label {
+		return prometheus.Label{Name: "quantile", Value: value}
+	}
+	percentileMetrics := func(name string, help string, percentiles *Percentiles) {
+		if percentiles == nil {
+			return
+		}
+		metrics.Gauge(name, help, percentiles.P50, quantile("0.5"))
+		metrics.Gauge(name, help, percentiles.P90, quantile("0.9"))
+		metrics.Gauge(name, help, percentiles.P99, quantile("0.99"))
+	}
+	// The metrics of a quality attenuation are named after the prefix.
+	qualityAttenuationMetrics := func(prefix string, qa *QualityAttenuation, labels ...prometheus.Label) {
+		withQuantile := func(value string) []prometheus.Label {
+			return append(append([]prometheus.Label{}, labels...), quantile(value))
+		}
+		metrics.Gauge(prefix+"_samples", "The number of samples of the quality attenuation.", float64(qa.Samples), labels...)
+		metrics.Gauge(prefix+"_losses", "The number of losses in the quality attenuation.", float64(qa.Losses), labels...)
+		metrics.Gauge(prefix+"_loss_percent", "The percentage of the quality attenuation samples that were lost.", float64(qa.Loss), labels...)
+		metrics.Gauge(prefix+"_minimum_seconds", "The minimum of the quality attenuation.", float64(qa.Minimum), labels...)
+		metrics.Gauge(prefix+"_maximum_seconds", "The maximum of the quality attenuation.", float64(qa.Maximum), labels...)
+		metrics.Gauge(prefix+"_mean_seconds", "The mean of the quality attenuation.", float64(qa.Mean), labels...)
+		metrics.Gauge(prefix+"_standard_deviation_seconds", "The standard deviation of the quality attenuation.", float64(qa.StandardDeviation), labels...)
+		metrics.Gauge(prefix+"_seconds", "Percentiles of the quality attenuation.", float64(qa.P90), withQuantile("0.9")...)
+		metrics.Gauge(prefix+"_seconds", "Percentiles of the quality attenuation.", float64(qa.P99), withQuantile("0.99")...)
+		metrics.Gauge(prefix+"_pdv_seconds", "Percentiles of the packet delay variation.", float64(qa.PDV90), withQuantile("0.9")...)
+		metrics.Gauge(prefix+"_pdv_seconds", "Percentiles of the packet delay variation.", float64(qa.PDV99), withQuantile("0.99")...)
+	}
+	// RPMs have always been written as whole numbers.
+	rpmValue := func(rpm Float) float64 {
+		return math.Trunc(float64(rpm))
+	}
+	// The fraction of the probes that timed out.
+	lossRatio := func(probes int, timeouts int) float64 {
+		if probes+timeouts == 0 {
+			return 0
+		}
+		return float64(timeouts) / float64(probes+timeouts)
+	}
+
+	testStable := 0.0
+	if result.Stable {
+		testStable = 1.0
+	}
+	metrics.Gauge("networkquality_test_stable", "Whether the test ran until its measurements were stable.", testStable)
+	metrics.Gauge("networkquality_test_duration_seconds", "How long the test took.", result.Elapsed)
+	if result.SpecVersion != "" {
+		metrics.Gauge(
+			"networkquality_spec_version_info", "The version of the RPM specification whose aggregation was used.", 1,
+			prometheus.Label{Name: "version", Value: result.SpecVersion},
+		)
+	}
+	metrics.Gauge("networkquality_rpm_value", "Round trips per minute under load (from the P90 RTTs).", rpmValue(result.Rpm))
+	metrics.Gauge("networkquality_trimmed_rpm_value", "Round trips per minute under load (from the trimmed mean RTTs).", rpmValue(result.TrimmedMeanRpm))
+	if result.Warm != nil {
+		metrics.Gauge("networkquality_warm_rpm_value", "Round trips per minute on established connections (from the P90 RTTs).", rpmValue(result.Warm.Rpm))
+		metrics.Gauge("networkquality_warm_trimmed_rpm_value", "Round trips per minute on established connections (from the trimmed mean RTTs).", rpmValue(result.Warm.TrimmedMeanRpm))
+	}
+	if result.Idle != nil {
+		metrics.Gauge("networkquality_idle_rpm_value", "Round trips per minute before the load started (from the P90 RTTs).", rpmValue(result.Idle.Rpm))
+		metrics.Gauge("networkquality_idle_trimmed_rpm_value", "Round trips per minute before the load started (from the trimmed mean RTTs).", rpmValue(result.Idle.TrimmedMeanRpm))
+	}
+	if bufferbloat := result.Bufferbloat; bufferbloat != nil {
+		metrics.Gauge(
+			"networkquality_bufferbloat_grade_info", "The grade of how much latency grew under load.", 1,
+			prometheus.Label{Name: "grade", Value: bufferbloat.Grade},
+		)
+		metrics.Gauge("networkquality_bufferbloat_increase_seconds", "How much the P90 latency grew under load (over idle).", bufferbloat.Increase)
+	}
+
+	percentileMetrics("networkquality_self_probe_rtt_seconds", "Percentiles of the RTTs of all of the self probes.", result.SelfRttPercentiles)
+	if result.SelfRttHistogram != nil {
+		metrics.Histogram("networkquality_self_probe_rtt_histogram_seconds", "The RTTs of the self probes.", result.SelfRttHistogram)
+	}
+	for _, connection := range result.SelfRttConnections {
+		labels := []prometheus.Label{
+			{Name: "direction", Value: connection.Direction},
+			{Name: "client_id", Value: strconv.FormatUint(connection.ClientID, 10)},
+		}
+		for _, percentile := range []struct {
+			quantile string
+			value    float64
+		}{{"0.5", connection.P50}, {"0.9", connection.P90}, {"0.99", connection.P99}} {
+			metrics.Gauge("networkquality_self_probe_connection_rtt_seconds", "Percentiles of the RTTs of the self probes on each load-generating connection.", percentile.value, append(labels, quantile(percentile.quantile))...)
+		}
+	}
+	metrics.Gauge("networkquality_self_probe_rtt_p90_trimmed_seconds", "The P90 of the trimmed RTTs of the self probes.", float64(result.SelfRttP90))
+	metrics.Gauge("networkquality_self_probe_rtt_trimmed_mean_seconds", "The mean of the trimmed RTTs of the self probes.", float64(result.SelfRttTrimmedMean))
+	metrics.Gauge("networkquality_self_probes", "The number of self probes that completed.", float64(result.SelfProbes))
+	metrics.Gauge("networkquality_self_probe_timeouts", "The number of self probes that timed out.", float64(result.SelfProbeTimeouts))
+	metrics.Gauge("networkquality_self_probe_loss_ratio", "The fraction of the self probes that timed out.", lossRatio(result.SelfProbes, result.SelfProbeTimeouts))
+	percentileMetrics("networkquality_foreign_probe_rtt_seconds", "Percentiles of the RTTs of all of the foreign probes.", result.ForeignRttPercentiles)
+	if result.ForeignRttHistogram != nil {
+		metrics.Histogram("networkquality_foreign_probe_rtt_histogram_seconds", "The RTTs of the foreign probes.", result.ForeignRttHistogram)
+	}
+	metrics.Gauge("networkquality_foreign_probe_rtt_p90_trimmed_seconds", "The P90 of the trimmed RTTs of the foreign probes.", float64(result.ForeignRttP90))
+	metrics.Gauge("networkquality_foreign_probe_rtt_trimmed_mean_seconds", "The mean of the trimmed RTTs of the foreign probes.", float64(result.ForeignRttTrimmedMean))
+	metrics.Gauge("networkquality_foreign_probes", "The number of foreign probes that completed.", float64(result.ForeignProbes))
+	metrics.Gauge("networkquality_foreign_probe_timeouts", "The number of foreign probes that timed out.", float64(result.ForeignProbeTimeouts))
+	metrics.Gauge("networkquality_foreign_probe_loss_ratio", "The fraction of the foreign probes that timed out.", lossRatio(result.ForeignProbes, result.ForeignProbeTimeouts))
+
+	if qa := result.QualityAttenuation; qa != nil {
+		qualityAttenuationMetrics("networkquality_quality_attenuation", qa)
+	}
+	if pqa := result.ProbeQualityAttenuation; pqa != nil {
+		pqa.each(func(kind string, _ string, qa *QualityAttenuation) {
+			qualityAttenuationMetrics("networkquality_probe_quality_attenuation", qa, prometheus.Label{Name: "probe", Value: kind})
+		})
+	}
+
+	if churn := result.ConnectionChurn; churn != nil {
+		metrics.Gauge("networkquality_replaced_connections", "The number of load-generating connections that failed and were replaced.", float64(churn.Download), prometheus.Label{Name: "direction", Value: "download"})
+		metrics.Gauge("networkquality_replaced_connections", "The number of load-generating connections that failed and were replaced.", float64(churn.Upload), prometheus.Label{Name: "direction", Value: "upload"})
+	}
+	if responses := result.ErrorResponses; responses != nil {
+		metrics.Gauge("networkquality_error_responses", "The number of requests that the server answered with a status other than 2xx.", float64(responses.Download), prometheus.Label{Name: "request", Value: "download"})
+		metrics.Gauge("networkquality_error_responses", "The number of requests that the server answered with a status other than 2xx.", float64(responses.Upload), prometheus.Label{Name: "request", Value: "upload"})
+		metrics.Gauge("networkquality_error_responses", "The number of requests that the server answered with a status other than 2xx.", float64(responses.Probes), prometheus.Label{Name: "request", Value: "probe"})
+	}
+	if protocols := result.HTTPProtocols; protocols != nil {
+		for _, request := range []struct {
+			name   string
+			counts map[string]int
+		}{{"download", protocols.Download}, {"upload", protocols.Upload}, {"probe", protocols.Probes}} {
+			for _, protocol := range protocolNames(request.counts) {
+				metrics.Gauge("networkquality_http_protocol", "The number of load-generating connections (or probes) that used an HTTP protocol.", float64(request.counts[protocol]), prometheus.Label{Name: "request", Value: request.name}, prometheus.Label{Name: "protocol", Value: protocol})
+			}
+		}
+	}
+
+	metrics.Gauge("networkquality_download_bits_per_second", "The final download throughput.", result.DownloadThroughput)
+	metrics.Gauge("networkquality_download_connections", "The number of download connections at the end of the test.", float64(result.DownloadConnections))
+	metrics.Gauge("networkquality_download_moving_average_bytes_per_second", "The final moving average of the download throughput.", result.DownloadMovingAverage)
+	metrics.Gauge("networkquality_upload_bits_per_second", "The final upload throughput.", result.UploadThroughput)
+	metrics.Gauge("networkquality_upload_connections", "The number of upload connections at the end of the test.", float64(result.UploadConnections))
+	if wire := result.Wire; wire != nil {
+		metrics.Gauge("networkquality_wire_bytes_per_second", "The estimated final throughput on the wire (with the protocols' overhead).", wire.Download, prometheus.Label{Name: "direction", Value: "download"})
+		metrics.Gauge("networkquality_wire_bytes_per_second", "The estimated final throughput on the wire (with the protocols' overhead).", wire.Upload, prometheus.Label{Name: "direction", Value: "upload"})
+	}
+	metrics.Gauge("networkquality_upload_moving_average_bytes_per_second", "The final moving average of the upload throughput.", result.UploadMovingAverage)
+	if result.TimeToSaturation != nil {
+		metrics.Gauge("networkquality_time_to_saturation_seconds", "How long after the load started throughput saturated in both directions.", float64(*result.TimeToSaturation))
+	}
+	metrics.Gauge("networkquality_probe_sent_bytes", "The bytes that the probes sent while the load was measured.", float64(result.ProbeTraffic.SentBytes))
+	metrics.Gauge("networkquality_probe_received_bytes", "The bytes that the probes received while the load was measured.", float64(result.ProbeTraffic.ReceivedBytes))
+
+	file, err := os.CreateTemp(filepath.Dir(sink.Filename), filepath.Base(sink.Filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := metrics.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), sink.Filename)
+}
+
+func (sink *PrometheusSink) String() string {
+	return "prometheus:" + sink.Filename
+}
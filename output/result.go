@@ -0,0 +1,382 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package output
+
+import (
+	"encoding/json"
+	"flag"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/capabilities"
+	"github.com/network-quality/goresponsiveness/prometheus"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
+// The results of a test. Every number in the final report is assembled here before
+// anything is printed so that every output (the text on stdout included) renders the
+// same numbers. The optional parts are nil (or empty) when the user did not ask for them.
+type Result struct {
+	Time time.Time `json:"time"`
+	// Identifies the test (in the data logs, too).
+	RunId string `json:"run_id"`
+	// The number of seconds between the start of the test and these results.
+	Elapsed float64 `json:"elapsed_seconds"`
+	// Whether these are results so far, from a test that is still running.
+	Interim       bool   `json:"interim"`
+	ClientVersion string `json:"client_version"`
+	Server        string `json:"server"`
+	// Only the final results have these.
+	EndTime        *time.Time   `json:"end_time,omitempty"`
+	Environment    *Environment `json:"environment,omitempty"`
+	SpecVersion    string       `json:"spec_version"`
+	Stable         bool         `json:"stable"`
+	Rpm            Float        `json:"rpm"`
+	TrimmedMeanRpm Float        `json:"trimmed_mean_rpm"`
+	TrimPercentage uint         `json:"trim_percentage"`
+	// How the trimmed mean is described (see rpm.SpecVersion).
+	TrimmedMeanLabel string `json:"trimmed_mean_label"`
+	// The RPM (above) is the working RPM, measured under load; this is measured before it.
+	Idle *IdleResponsiveness `json:"idle,omitempty"`
+	Warm *WarmResponsiveness `json:"warm,omitempty"`
+	// How much latency grows under load (relative to idle), as a letter grade.
+	Bufferbloat *rpm.BufferbloatGrade `json:"bufferbloat,omitempty"`
+
+	SelfProbes            int   `json:"self_probes"`
+	ForeignProbes         int   `json:"foreign_probes"`
+	TrimmedSelfProbes     int   `json:"trimmed_self_probes"`
+	TrimmedForeignProbes  int   `json:"trimmed_foreign_probes"`
+	SelfRttP90            Float `json:"self_rtt_p90_seconds"`
+	ForeignRttP90         Float `json:"foreign_rtt_p90_seconds"`
+	SelfRttTrimmedMean    Float `json:"self_rtt_trimmed_mean_seconds"`
+	ForeignRttTrimmedMean Float `json:"foreign_rtt_trimmed_mean_seconds"`
+	// Percentiles of all of the RTTs (the ones above are of the trimmed RTTs).
+	SelfRttPercentiles    *Percentiles               `json:"self_rtt_percentiles,omitempty"`
+	ForeignRttPercentiles *Percentiles               `json:"foreign_rtt_percentiles,omitempty"`
+	SelfRttHistogram      *prometheus.HistogramValue `json:"self_rtt_histogram,omitempty"`
+	ForeignRttHistogram   *prometheus.HistogramValue `json:"foreign_rtt_histogram,omitempty"`
+	// Percentiles of the RTTs of the self probes on each load-generating connection (when the
+	// self probes are spread across all of them).
+	SelfRttConnections []ConnectionRtts `json:"self_rtt_connections,omitempty"`
+
+	// In milliseconds; 0 when probes never time out.
+	ProbeTimeout         uint `json:"probe_timeout_ms"`
+	SelfProbeTimeouts    int  `json:"self_probe_timeouts"`
+	ForeignProbeTimeouts int  `json:"foreign_probe_timeouts"`
+	// How foreign probes reused connections (none, every:N or pool:N).
+	ForeignProbeReuse string `json:"foreign_probe_reuse"`
+
+	QualityAttenuation *QualityAttenuation `json:"quality_attenuation,omitempty"`
+	// The quality attenuation of each kind of probe on its own.
+	ProbeQualityAttenuation *ProbeQualityAttenuation `json:"probe_quality_attenuation,omitempty"`
+	Connect                 *ConnectRtts             `json:"connect,omitempty"`
+	// The DNS lookups of the foreign probes and of the load-generating connections.
+	DNS                   *Percentiles               `json:"dns,omitempty"`
+	LoadDNS               *Percentiles               `json:"load_dns,omitempty"`
+	HandshakeRttInflation *rpm.HandshakeRttInflation `json:"handshake_rtt_inflation,omitempty"`
+	TLS                   *Percentiles               `json:"tls,omitempty"`
+	TLSResumed            *Percentiles               `json:"tls_resumed,omitempty"`
+	Correlations          []Correlation              `json:"throughput_rtt_correlations,omitempty"`
+	UDP                   *Echoes                    `json:"udp,omitempty"`
+	Ping                  *PingBaseline              `json:"ping,omitempty"`
+
+	DownloadThroughput  float64 `json:"download_bytes_per_second"`
+	DownloadConnections int     `json:"download_connections"`
+	UploadThroughput    float64 `json:"upload_bytes_per_second"`
+	UploadConnections   int     `json:"upload_connections"`
+	// The final moving averages of the throughputs (which the stabilizers last judged).
+	DownloadMovingAverage float64 `json:"download_moving_average_bytes_per_second"`
+	UploadMovingAverage   float64 `json:"upload_moving_average_bytes_per_second"`
+	// The number of seconds between the start of the load and the saturation of the
+	// throughput in both directions; nil when throughput never saturated.
+	TimeToSaturation *Float `json:"time_to_saturation_seconds,omitempty"`
+	// What the throughputs above (which count the HTTP bodies) were on the wire; nil when no
+	// connection got far enough to tell.
+	Wire *WireThroughput `json:"wire,omitempty"`
+	// The probes' traffic during the test (which is not part of the throughputs above).
+	ProbeTraffic       ProbeTraffic             `json:"probe_traffic"`
+	DownloadSaturation rpm.SaturationAssessment `json:"download_saturation"`
+	UploadSaturation   rpm.SaturationAssessment `json:"upload_saturation"`
+	Parallelism        *Parallelism             `json:"parallelism,omitempty"`
+	// Load-generating connections that failed during the test and were replaced; nil when
+	// none failed.
+	ConnectionChurn *ConnectionChurn `json:"connection_churn,omitempty"`
+	ErrorResponses  *ErrorResponses  `json:"error_responses,omitempty"`
+	HTTPProtocols   *HTTPProtocols   `json:"http_protocols,omitempty"`
+
+	ExtendedStats string                `json:"extended_stats,omitempty"`
+	Cooldown      *Cooldown             `json:"cooldown,omitempty"`
+	Pacing        []rpm.PacingDataPoint `json:"pacing,omitempty"`
+	Phases        []rpm.PhaseSummary    `json:"phases,omitempty"`
+	CPU           *CPUUtilization       `json:"cpu,omitempty"`
+
+	// Things that went wrong during the test that make its results less trustworthy.
+	Warnings []string `json:"warnings,omitempty"`
+	// The measurements that were significantly worse than in previous runs.
+	Regressions []Regression `json:"regressions,omitempty"`
+}
+
+// A measurement that was significantly worse than the median of the previous runs (see
+// history.DetectRegressions). The values are in the given units.
+type Regression struct {
+	Metric string `json:"metric"`
+	Units  string `json:"units"`
+	Value  Float  `json:"value"`
+	Median Float  `json:"median"`
+	// The median absolute deviation of the previous runs.
+	Deviation Float `json:"median_absolute_deviation"`
+	Runs      int   `json:"runs"`
+}
+
+// How busy the client's CPU was while it generated load (in percent of the capacity of all of
+// its cores).
+type CPUUtilization struct {
+	ProcessMean Float  `json:"process_mean"`
+	ProcessPeak Float  `json:"process_peak"`
+	SystemMean  *Float `json:"system_mean,omitempty"`
+	SystemPeak  *Float `json:"system_peak,omitempty"`
+	// Whether the client appears to have been CPU-bound, in which case the throughput was
+	// probably limited by the client rather than by the network.
+	Bound bool `json:"bound"`
+}
+
+// Where (and how) the test ran, so that archived results describe themselves.
+type Environment struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	GoVersion string `json:"go_version"`
+	// The flags that were given explicitly (the others had their default values).
+	Flags      map[string]string `json:"flags"`
+	ConfigURL  string            `json:"config_url"`
+	SmallURL   string            `json:"small_url"`
+	LargeURLs  []string          `json:"large_urls"`
+	UploadURLs []string          `json:"upload_urls"`
+	// Which of the platform features that measurements depend on were available.
+	Capabilities capabilities.Matrix `json:"capabilities"`
+}
+
+// Describe the environment of this test (which had its URLs from the configuration at
+// configURL).
+func NewEnvironment(
+	configURL string,
+	smallURL string,
+	largeURLs []string,
+	uploadURLs []string,
+	platformCapabilities capabilities.Matrix,
+) *Environment {
+	flags := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+	return &Environment{
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		GoVersion:    runtime.Version(),
+		Flags:        flags,
+		ConfigURL:    configURL,
+		SmallURL:     smallURL,
+		LargeURLs:    largeURLs,
+		UploadURLs:   uploadURLs,
+		Capabilities: platformCapabilities,
+	}
+}
+
+// A test without any probes has no RTTs and, so, an infinite RPM, which JSON cannot
+// represent. Such values are written as null.
+type Float float64
+
+func (f Float) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+// The number of load-generating connections in each direction that failed (e.g., because they
+// were reset) and were replaced.
+type ConnectionChurn struct {
+	Download int `json:"download"`
+	Upload   int `json:"upload"`
+}
+
+// The number of requests that the server answered with a status other than 2xx.
+type ErrorResponses struct {
+	Download int `json:"download"`
+	Upload   int `json:"upload"`
+	Probes   int `json:"probes"`
+}
+
+// The estimated throughputs on the wire: the goodput plus the HTTP/2 frames, TLS records and
+// TCP/IP headers that carried it (see rpm.WireOverhead).
+type WireThroughput struct {
+	Download float64 `json:"download_bytes_per_second"`
+	Upload   float64 `json:"upload_bytes_per_second"`
+	// The bytes on the wire for every byte of goodput.
+	DownloadOverhead float64 `json:"download_overhead"`
+	UploadOverhead   float64 `json:"upload_overhead"`
+}
+
+// How many of the load-generating connections (and of the probes) used each HTTP protocol (e.g.,
+// HTTP/2).
+type HTTPProtocols struct {
+	Download map[string]int `json:"download"`
+	Upload   map[string]int `json:"upload"`
+	Probes   map[string]int `json:"probes"`
+}
+
+// Whether they did not all use the same HTTP protocol.
+func (protocols *HTTPProtocols) Mixed() bool {
+	seen := make(map[string]bool)
+	for _, counts := range []map[string]int{protocols.Download, protocols.Upload, protocols.Probes} {
+		for protocol := range counts {
+			seen[protocol] = true
+		}
+	}
+	return len(seen) > 1
+}
+
+// The quality attenuation of the self probes (see qualityattenuation).
+type QualityAttenuation struct {
+	Losses            int64 `json:"losses"`
+	Samples           int64 `json:"samples"`
+	Loss              Float `json:"loss_percent"`
+	Minimum           Float `json:"minimum_seconds"`
+	Maximum           Float `json:"maximum_seconds"`
+	Mean              Float `json:"mean_seconds"`
+	Variance          Float `json:"variance"`
+	StandardDeviation Float `json:"standard_deviation_seconds"`
+	PDV90             Float `json:"pdv90_seconds"`
+	PDV99             Float `json:"pdv99_seconds"`
+	P90               Float `json:"p90_seconds"`
+	P99               Float `json:"p99_seconds"`
+}
+
+// The quality attenuation of the self probes on the download and upload connections and of the
+// foreign probes, kept apart because the delays in the two directions often differ greatly.
+// There is no quality attenuation for a kind of probe that never ran.
+type ProbeQualityAttenuation struct {
+	SelfDown *QualityAttenuation `json:"self_down,omitempty"`
+	SelfUp   *QualityAttenuation `json:"self_up,omitempty"`
+	Foreign  *QualityAttenuation `json:"foreign,omitempty"`
+}
+
+// Visit the quality attenuations that there are with the kinds of their probes, as named in the
+// machine-readable outputs (kind) and in the text (title).
+func (pqa *ProbeQualityAttenuation) each(visit func(kind string, title string, qa *QualityAttenuation)) {
+	for _, kind := range []struct {
+		kind  string
+		title string
+		qa    *QualityAttenuation
+	}{
+		{"self_down", "Self Download Probes", pqa.SelfDown},
+		{"self_up", "Self Upload Probes", pqa.SelfUp},
+		{"foreign", "Foreign Probes", pqa.Foreign},
+	} {
+		if kind.qa != nil {
+			visit(kind.kind, kind.title, kind.qa)
+		}
+	}
+}
+
+// The responsiveness of the network before the load started (from foreign probes alone).
+type IdleResponsiveness struct {
+	Rpm            Float `json:"rpm"`
+	TrimmedMeanRpm Float `json:"trimmed_mean_rpm"`
+	Probes         int   `json:"probes"`
+	RttP90         Float `json:"rtt_p90_seconds"`
+	RttTrimmedMean Float `json:"rtt_trimmed_mean_seconds"`
+}
+
+// The bytes (and average throughput, in bytes per second) that probes sent and received
+// while the load was being measured.
+type ProbeTraffic struct {
+	SentBytes          uint64  `json:"sent_bytes"`
+	ReceivedBytes      uint64  `json:"received_bytes"`
+	SentThroughput     float64 `json:"sent_bytes_per_second"`
+	ReceivedThroughput float64 `json:"received_bytes_per_second"`
+}
+
+// The number of network connections that actually carried the load in each direction (when
+// the protocol needs one for every request in flight).
+type Parallelism struct {
+	Protocol            string `json:"protocol"`
+	DownloadConnections int    `json:"download_connections"`
+	UploadConnections   int    `json:"upload_connections"`
+}
+
+// The responsiveness of established connections: the RPM from the self probes and the HTTP
+// transactions of the foreign probes (leaving out the setup of the foreign probes'
+// connections).
+type WarmResponsiveness struct {
+	Rpm                       Float `json:"rpm"`
+	TrimmedMeanRpm            Float `json:"trimmed_mean_rpm"`
+	ForeignHTTPRttP90         Float `json:"foreign_http_rtt_p90_seconds"`
+	ForeignHTTPRttTrimmedMean Float `json:"foreign_http_rtt_trimmed_mean_seconds"`
+}
+
+// Percentiles (in seconds) of a set of durations.
+type Percentiles struct {
+	P50   float64 `json:"p50_seconds"`
+	P90   float64 `json:"p90_seconds"`
+	P99   float64 `json:"p99_seconds"`
+	Count int     `json:"count"`
+}
+
+type ConnectionRtts struct {
+	Direction string `json:"direction"`
+	ClientID  uint64 `json:"client_id"`
+	Percentiles
+}
+
+type ConnectRtts struct {
+	Mode string `json:"mode"`
+	Percentiles
+	Timeouts int `json:"timeouts"`
+}
+
+type Correlation struct {
+	Direction string `json:"direction"`
+	// Whether there were enough probes (with different throughputs) to correlate.
+	Correlated  bool                   `json:"correlated"`
+	Correlation Float                  `json:"correlation"`
+	Probes      int                    `json:"probes"`
+	Bins        []rpm.ThroughputRttBin `json:"bins"`
+}
+
+// The RTTs (in seconds) and losses of echo probes (UDP probes and pings).
+type Echoes struct {
+	P50     float64 `json:"p50_seconds"`
+	P90     float64 `json:"p90_seconds"`
+	Packets int     `json:"packets"`
+	Loss    float64 `json:"loss_percent"`
+}
+
+type PingBaseline struct {
+	Target string `json:"target"`
+	Idle   Echoes `json:"idle"`
+	Loaded Echoes `json:"loaded"`
+}
+
+type Cooldown struct {
+	Probes int `json:"probes"`
+	// In seconds.
+	Duration    int     `json:"duration_seconds"`
+	IdleLatency float64 `json:"idle_latency_seconds"`
+	// Latency is considered back to idle when it is within Tolerance percent of idle for
+	// ConsecutiveProbes probes in a row.
+	Tolerance         float64       `json:"tolerance_percent"`
+	ConsecutiveProbes int           `json:"consecutive_probes"`
+	Drained           bool          `json:"drained"`
+	DrainTime         time.Duration `json:"drain_time_ns"`
+}
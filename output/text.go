@@ -0,0 +1,371 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package output
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// Render the result the way that it is printed at the end of a test.
+func WriteText(w io.Writer, result Result) {
+	if qa := result.QualityAttenuation; qa != nil {
+		writeQualityAttenuation(w, "Quality Attenuation Statistics:", qa)
+	}
+	if pqa := result.ProbeQualityAttenuation; pqa != nil {
+		pqa.each(func(_ string, title string, qa *QualityAttenuation) {
+			writeQualityAttenuation(w, fmt.Sprintf("Quality Attenuation Statistics (%s):", title), qa)
+		})
+	}
+
+	if !result.Stable {
+		fmt.Fprintf(w, "Test did not run to stability, these results are estimates:\n")
+	}
+
+	fmt.Fprintf(w, "RPM: %5.0f (P90)\n", result.Rpm)
+	fmt.Fprintf(w, "RPM: %5.0f (%s)\n", result.TrimmedMeanRpm, result.TrimmedMeanLabel)
+	if warm := result.Warm; warm != nil {
+		fmt.Fprintf(w, "Warm RPM: %5.0f (P90)\n", warm.Rpm)
+		fmt.Fprintf(w, "Warm RPM: %5.0f (%s)\n", warm.TrimmedMeanRpm, result.TrimmedMeanLabel)
+	}
+	if idle := result.Idle; idle != nil {
+		fmt.Fprintf(w, "Idle RPM: %5.0f (P90)\n", idle.Rpm)
+		fmt.Fprintf(w, "Idle RPM: %5.0f (%s)\n", idle.TrimmedMeanRpm, result.TrimmedMeanLabel)
+		fmt.Fprintf(w, "Idle Latency: %.3f ms (%d probes)\n", idle.RttTrimmedMean*1000, idle.Probes)
+	}
+	if result.Bufferbloat != nil {
+		fmt.Fprintf(w, "Bufferbloat Grade: %v\n", *result.Bufferbloat)
+	}
+	fmt.Fprintf(w, "Specification: %s\n", result.SpecVersion)
+	if result.ProbeTimeout > 0 {
+		fmt.Fprintf(w,
+			"Probe Timeouts: %d self, %d foreign (after %d ms)\n",
+			result.SelfProbeTimeouts,
+			result.ForeignProbeTimeouts,
+			result.ProbeTimeout,
+		)
+	}
+	if result.ForeignProbeReuse != "" && result.ForeignProbeReuse != "none" {
+		fmt.Fprintf(w, "Foreign Probe Connection Reuse: %s\n", result.ForeignProbeReuse)
+	}
+	for _, connection := range result.SelfRttConnections {
+		fmt.Fprintf(w,
+			"Self Probe RTT (%s connection %d): P50 %.3f ms, P90 %.3f ms (%d probes)\n",
+			connection.Direction,
+			connection.ClientID,
+			connection.P50*1000,
+			connection.P90*1000,
+			connection.Count,
+		)
+	}
+	if connect := result.Connect; connect != nil {
+		fmt.Fprintf(w,
+			"Connect RTT (%s): P50 %.3f ms, P90 %.3f ms (%d round trips, %d timeouts)\n",
+			connect.Mode,
+			connect.P50*1000,
+			connect.P90*1000,
+			connect.Count,
+			connect.Timeouts,
+		)
+	}
+	if dns := result.DNS; dns != nil {
+		fmt.Fprintf(w,
+			"DNS Lookup (foreign probes): P50 %.3f ms, P90 %.3f ms (%d lookups)\n",
+			dns.P50*1000,
+			dns.P90*1000,
+			dns.Count,
+		)
+	}
+	if dns := result.LoadDNS; dns != nil {
+		fmt.Fprintf(w,
+			"DNS Lookup (load-generating connections): P50 %.3f ms, P90 %.3f ms (%d lookups)\n",
+			dns.P50*1000,
+			dns.P90*1000,
+			dns.Count,
+		)
+	}
+	if result.HandshakeRttInflation != nil {
+		fmt.Fprintf(w, "Handshake RTT: %v\n", *result.HandshakeRttInflation)
+	}
+	if tls := result.TLS; tls != nil {
+		fmt.Fprintf(w,
+			"TLS Handshake: P50 %.3f ms, P90 %.3f ms, P99 %.3f ms (%d handshakes)\n",
+			tls.P50*1000,
+			tls.P90*1000,
+			tls.P99*1000,
+			tls.Count,
+		)
+	}
+	if tls := result.TLSResumed; tls != nil {
+		fmt.Fprintf(w,
+			"TLS Resumed Handshake: P50 %.3f ms, P90 %.3f ms, P99 %.3f ms (%d handshakes)\n",
+			tls.P50*1000,
+			tls.P90*1000,
+			tls.P99*1000,
+			tls.Count,
+		)
+	}
+	for _, correlation := range result.Correlations {
+		if correlation.Correlated {
+			fmt.Fprintf(w,
+				"Self Probe RTT vs %s throughput: correlation %.3f (%d probes)\n",
+				correlation.Direction,
+				correlation.Correlation,
+				correlation.Probes,
+			)
+		} else {
+			fmt.Fprintf(w,
+				"Self Probe RTT vs %s throughput: no correlation available (%d probes)\n",
+				correlation.Direction,
+				correlation.Probes,
+			)
+		}
+		for _, bin := range correlation.Bins {
+			fmt.Fprintf(w, "  %v\n", bin)
+		}
+	}
+	if udp := result.UDP; udp != nil {
+		fmt.Fprintf(w,
+			"UDP RTT: P50 %.3f ms, P90 %.3f ms (%d packets, %.2f%% loss)\n",
+			udp.P50*1000,
+			udp.P90*1000,
+			udp.Packets,
+			udp.Loss,
+		)
+	}
+	if ping := result.Ping; ping != nil {
+		fmt.Fprintf(w, "Ping (%s):\n", ping.Target)
+		fmt.Fprintf(w,
+			"\tIdle:   P50 %.3f ms, P90 %.3f ms (%.2f%% loss)\n",
+			ping.Idle.P50*1000, ping.Idle.P90*1000, ping.Idle.Loss,
+		)
+		fmt.Fprintf(w,
+			"\tLoaded: P50 %.3f ms, P90 %.3f ms (%.2f%% loss)\n",
+			ping.Loaded.P50*1000, ping.Loaded.P90*1000, ping.Loaded.Loss,
+		)
+		// Expressing the loaded ping in the same units as RPM makes the two easy to compare.
+		if ping.Loaded.P90 > 0 {
+			fmt.Fprintf(w, "\tPing-equivalent RPM: %5.0f (P90)\n", 60.0/ping.Loaded.P90)
+		}
+	}
+
+	fmt.Fprintf(w,
+		"Download: %7.3f Mbps (%7.3f MBps), using %d parallel connections.\n",
+		utilities.ToMbps(result.DownloadThroughput),
+		utilities.ToMBps(result.DownloadThroughput),
+		result.DownloadConnections,
+	)
+	fmt.Fprintf(w,
+		"Upload:   %7.3f Mbps (%7.3f MBps), using %d parallel connections.\n",
+		utilities.ToMbps(result.UploadThroughput),
+		utilities.ToMBps(result.UploadThroughput),
+		result.UploadConnections,
+	)
+	if wire := result.Wire; wire != nil {
+		fmt.Fprintf(w,
+			"Wire:     %7.3f Mbps down, %7.3f Mbps up (estimated; the above plus %.1f%% and %.1f%% of HTTP/2, TLS and TCP/IP overhead).\n",
+			utilities.ToMbps(wire.Download),
+			utilities.ToMbps(wire.Upload),
+			(wire.DownloadOverhead-1)*100,
+			(wire.UploadOverhead-1)*100,
+		)
+	}
+	if traffic := result.ProbeTraffic; traffic.SentBytes > 0 || traffic.ReceivedBytes > 0 {
+		fmt.Fprintf(w,
+			"Probes:   %7.3f Mbps up, %7.3f Mbps down (%d bytes sent, %d bytes received; not included above).\n",
+			utilities.ToMbps(traffic.SentThroughput),
+			utilities.ToMbps(traffic.ReceivedThroughput),
+			traffic.SentBytes,
+			traffic.ReceivedBytes,
+		)
+	}
+	if parallelism := result.Parallelism; parallelism != nil {
+		fmt.Fprintf(w,
+			"Effective Parallelism (%s): %d of %d download connections, %d of %d upload connections.\n",
+			parallelism.Protocol,
+			parallelism.DownloadConnections,
+			result.DownloadConnections,
+			parallelism.UploadConnections,
+			result.UploadConnections,
+		)
+	}
+	if churn := result.ConnectionChurn; churn != nil {
+		fmt.Fprintf(w,
+			"Replaced Connections: %d download, %d upload (they failed during the test).\n",
+			churn.Download,
+			churn.Upload,
+		)
+	}
+	if responses := result.ErrorResponses; responses != nil {
+		fmt.Fprintf(w,
+			"Error Responses: %d download, %d upload, %d probe (the server answered with a status other than 2xx).\n",
+			responses.Download,
+			responses.Upload,
+			responses.Probes,
+		)
+	}
+	if protocols := result.HTTPProtocols; protocols != nil {
+		fmt.Fprintf(w,
+			"HTTP Protocols: download %s; upload %s; probes %s.\n",
+			protocolCounts(protocols.Download),
+			protocolCounts(protocols.Upload),
+			protocolCounts(protocols.Probes),
+		)
+	}
+	fmt.Fprintf(w, "Download Saturation: %v\n", result.DownloadSaturation)
+	fmt.Fprintf(w, "Upload Saturation:   %v\n", result.UploadSaturation)
+
+	if result.ExtendedStats != "" {
+		fmt.Fprintln(w, result.ExtendedStats)
+	}
+
+	if cooldown := result.Cooldown; cooldown != nil {
+		fmt.Fprintf(w, "Cooldown (%d foreign probes in %d seconds):\n", cooldown.Probes, cooldown.Duration)
+		fmt.Fprintf(w, "\tIdle Latency: %.3f ms\n", cooldown.IdleLatency*1000)
+		if cooldown.Drained {
+			fmt.Fprintf(w,
+				"\tLatency returned to within %.0f%% of idle (for %d probes in a row) %v after the load stopped.\n",
+				cooldown.Tolerance,
+				cooldown.ConsecutiveProbes,
+				cooldown.DrainTime,
+			)
+		} else {
+			fmt.Fprintf(w,
+				"\tLatency did not return to within %.0f%% of idle during the cooldown.\n",
+				cooldown.Tolerance,
+			)
+		}
+	}
+
+	if len(result.Pacing) > 0 {
+		fmt.Fprintf(w, "RTT vs. Offered Load (measured/paced):\n")
+		for _, pacingDataPoint := range result.Pacing {
+			fmt.Fprintf(w, "\t%v\n", pacingDataPoint)
+		}
+	}
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+	for _, regression := range result.Regressions {
+		fmt.Fprintf(
+			w,
+			"Regression: %s was %.3f %s (the median of the previous %d runs was %.3f %s).\n",
+			regression.Metric, regression.Value, regression.Units, regression.Runs, regression.Median, regression.Units,
+		)
+	}
+}
+
+// Write the result as text to a file (or, when the filename is -, to stdout).
+type TextSink struct {
+	Filename string
+}
+
+func (sink *TextSink) Write(result Result) error {
+	if result.Interim {
+		return nil
+	}
+	if sink.Filename == "-" {
+		WriteText(os.Stdout, result)
+		return nil
+	}
+	file, err := os.Create(sink.Filename)
+	if err != nil {
+		return err
+	}
+	WriteText(file, result)
+	return file.Close()
+}
+
+func (sink *TextSink) String() string {
+	return "text:" + sink.Filename
+}
+
+// Render the results so far on a single line (see -interim-results). Before there are round
+// trips to calculate it from, the RPM is shown as -.
+func WriteInterimText(w io.Writer, result Result) {
+	formatRpm := func(rpm Float) string {
+		if math.IsNaN(float64(rpm)) || math.IsInf(float64(rpm), 0) {
+			return "    -"
+		}
+		return fmt.Sprintf("%5.0f", rpm)
+	}
+	fmt.Fprintf(w,
+		"Interim (%.0f s): RPM %s (P90), %s (%s); Download %7.3f Mbps; Upload %7.3f Mbps\n",
+		result.Elapsed,
+		formatRpm(result.Rpm),
+		formatRpm(result.TrimmedMeanRpm),
+		result.TrimmedMeanLabel,
+		utilities.ToMbps(result.DownloadThroughput),
+		utilities.ToMbps(result.UploadThroughput),
+	)
+}
+
+// E.g., HTTP/1.1 (2), HTTP/2 (14).
+func protocolCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	protocols := protocolNames(counts)
+	for i, protocol := range protocols {
+		protocols[i] = fmt.Sprintf("%s (%d)", protocol, counts[protocol])
+	}
+	return strings.Join(protocols, ", ")
+}
+
+// The protocols in counts (in order).
+func protocolNames(counts map[string]int) []string {
+	protocols := make([]string, 0, len(counts))
+	for protocol := range counts {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+func writeQualityAttenuation(w io.Writer, title string, qa *QualityAttenuation) {
+	fmt.Fprintln(w, title)
+	fmt.Fprintf(w,
+		`Number of losses: %d
+Number of samples: %d
+Loss: %f
+Min: %.6f
+Max: %.6f
+Mean: %.6f 
+Variance: %.6f
+Standard Deviation: %.6f
+PDV(90): %.6f
+PDV(99): %.6f
+P(90): %.6f
+P(99): %.6f
+`, qa.Losses,
+		qa.Samples,
+		qa.Loss,
+		qa.Minimum,
+		qa.Maximum,
+		qa.Mean,
+		qa.Variance,
+		qa.StandardDeviation,
+		qa.PDV90,
+		qa.PDV99,
+		qa.P90,
+		qa.P99)
+}
@@ -0,0 +1,178 @@
+//go:build linux
+// +build linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pcap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// How often a capture waiting for packets checks whether it was stopped.
+const pollInterval = 100 * time.Millisecond
+
+type Capture struct {
+	fd        int
+	file      *os.File
+	buffered  *bufio.Writer
+	writer    *Writer
+	endpoints []Endpoint
+	stop      chan struct{}
+	done      chan struct{}
+	packets   uint64
+	err       error
+}
+
+func htons(value uint16) uint16 {
+	return value<<8 | value>>8
+}
+
+// Start capturing the packets from and to endpoints (on every interface) in to filename.
+// Capturing needs CAP_NET_RAW.
+func Start(filename string, endpoints []Endpoint) (*Capture, error) {
+	// A datagram packet socket hands over packets without their link-layer header, which
+	// means that a capture is the same no matter the interface (or interfaces) the test uses.
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("could not open a packet socket (capturing needs CAP_NET_RAW): %w", err)
+	}
+	if err := attachFilter(fd, endpoints); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	timeout := unix.NsecToTimeval(pollInterval.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("could not set the timeout of the packet socket: %w", err)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	buffered := bufio.NewWriter(file)
+	writer, err := NewWriter(buffered, snapLength)
+	if err != nil {
+		unix.Close(fd)
+		file.Close()
+		return nil, err
+	}
+
+	capture := &Capture{
+		fd:        fd,
+		file:      file,
+		buffered:  buffered,
+		writer:    writer,
+		endpoints: endpoints,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go capture.run()
+	return capture, nil
+}
+
+// Have the kernel drop the packets that are not from or to the endpoints, rather than
+// copy every packet on the host to the capture.
+func attachFilter(fd int, endpoints []Endpoint) error {
+	instructions, err := bpf.Assemble(Filter(endpoints))
+	if err != nil {
+		return fmt.Errorf("could not assemble the capture filter: %w", err)
+	}
+	filter := make([]unix.SockFilter, len(instructions))
+	for i, instruction := range instructions {
+		filter[i] = unix.SockFilter{Code: instruction.Op, Jt: instruction.Jt, Jf: instruction.Jf, K: instruction.K}
+	}
+	program := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &program); err != nil {
+		return fmt.Errorf("could not attach the capture filter (%d instructions) to the packet socket: %w", len(filter), err)
+	}
+	return nil
+}
+
+func (capture *Capture) run() {
+	defer close(capture.done)
+	packet := make([]byte, snapLength)
+	// Once stopped, the capture still writes the packets that the socket has queued.
+	draining := false
+	for {
+		// With MSG_TRUNC, the length is that of the whole packet, even when it did not fit.
+		flags := unix.MSG_TRUNC
+		if !draining {
+			select {
+			case <-capture.stop:
+				draining = true
+			default:
+			}
+		}
+		if draining {
+			flags |= unix.MSG_DONTWAIT
+		}
+		length, from, err := unix.Recvfrom(capture.fd, packet, flags)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) && draining {
+				return
+			}
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			capture.err = fmt.Errorf("could not read from the packet socket: %w", err)
+			return
+		}
+		// Packets over loopback show up twice: once going out and once coming in.
+		if linkLayer, ok := from.(*unix.SockaddrLinklayer); ok &&
+			linkLayer.Hatype == unix.ARPHRD_LOOPBACK && linkLayer.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+		captured := packet
+		if length < len(packet) {
+			captured = packet[:length]
+		}
+		// The packets that the socket queued before the kernel's filter was attached were not
+		// filtered.
+		if !Matches(capture.endpoints, captured) {
+			continue
+		}
+		if err := capture.writer.WritePacket(time.Now(), captured, length); err != nil {
+			capture.err = err
+			return
+		}
+		capture.packets++
+	}
+}
+
+// Stop capturing and return the number of packets that were captured.
+func (capture *Capture) Stop() (uint64, error) {
+	close(capture.stop)
+	<-capture.done
+	unix.Close(capture.fd)
+
+	err := capture.err
+	if flushErr := capture.buffered.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := capture.file.Close(); err == nil {
+		err = closeErr
+	}
+	return capture.packets, err
+}
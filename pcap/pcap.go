@@ -0,0 +1,295 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package pcap captures the packets of a test in the (classic) pcap format that tcpdump and
+// Wireshark read. Together with the SSL key log, a capture shows exactly what happened on the
+// wire during an anomalous test.
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/bpf"
+)
+
+const (
+	magic        uint32 = 0xa1b2c3d4
+	versionMajor uint16 = 2
+	versionMinor uint16 = 4
+	// Packets are captured from the network layer up; there is no link-layer header.
+	linkTypeRaw uint32 = 101
+	// Capture whole packets (up to the maximum size of an IP packet).
+	snapLength uint32 = 65535
+)
+
+type Writer struct {
+	writer     io.Writer
+	snapLength uint32
+}
+
+// Write the pcap file header to writer and return a Writer for the packets that follow it.
+func NewWriter(writer io.Writer, snapLength uint32) (*Writer, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], magic)
+	binary.LittleEndian.PutUint16(header[4:6], versionMajor)
+	binary.LittleEndian.PutUint16(header[6:8], versionMinor)
+	// The time zone offset and timestamp accuracy (8:16) are always 0.
+	binary.LittleEndian.PutUint32(header[16:20], snapLength)
+	binary.LittleEndian.PutUint32(header[20:24], linkTypeRaw)
+	if _, err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{writer: writer, snapLength: snapLength}, nil
+}
+
+// Write a packet that was originalLength bytes long on the wire (of which packet holds the
+// captured part).
+func (w *Writer) WritePacket(timestamp time.Time, packet []byte, originalLength int) error {
+	if uint32(len(packet)) > w.snapLength {
+		packet = packet[:w.snapLength]
+	}
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:4], uint32(timestamp.Unix()))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(timestamp.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(header[12:16], uint32(originalLength))
+	if _, err := w.writer.Write(header); err != nil {
+		return err
+	}
+	_, err := w.writer.Write(packet)
+	return err
+}
+
+// An address and port whose traffic is captured.
+type Endpoint struct {
+	IP   net.IP
+	Port uint16
+}
+
+func (endpoint Endpoint) String() string {
+	return net.JoinHostPort(endpoint.IP.String(), fmt.Sprintf("%d", endpoint.Port))
+}
+
+// Resolve the addresses of the servers behind urls. When connectToAddr is given, the test
+// connects there instead of to the hosts of the urls (see OverrideHostTransport).
+func ResolveEndpoints(connectToAddr string, urls ...string) ([]Endpoint, error) {
+	endpoints := make([]Endpoint, 0)
+	seen := make(map[string]bool)
+	for _, rawUrl := range urls {
+		if rawUrl == "" {
+			continue
+		}
+		parsedUrl, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", rawUrl, err)
+		}
+		host := parsedUrl.Hostname()
+		if connectToAddr != "" {
+			host = connectToAddr
+		}
+		portName := parsedUrl.Port()
+		if portName == "" {
+			portName = parsedUrl.Scheme
+		}
+		port, err := net.LookupPort("tcp", portName)
+		if err != nil {
+			return nil, fmt.Errorf("could not determine the port of %s: %w", rawUrl, err)
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve %s: %w", host, err)
+		}
+		for _, ip := range ips {
+			endpoint := Endpoint{IP: ip, Port: uint16(port)}
+			if !seen[endpoint.String()] {
+				seen[endpoint.String()] = true
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("there are no addresses to capture the packets of")
+	}
+	return endpoints, nil
+}
+
+// Whether packet (an IPv4 or IPv6 packet) is from or to one of the endpoints. Packets that
+// are neither TCP nor UDP (e.g., ICMP errors) match on address alone.
+func Matches(endpoints []Endpoint, packet []byte) bool {
+	var source, destination net.IP
+	var protocol byte
+	var transport []byte
+	switch {
+	case len(packet) >= 20 && packet[0]>>4 == 4:
+		headerLength := int(packet[0]&0x0f) * 4
+		if headerLength < 20 || len(packet) < headerLength {
+			return false
+		}
+		protocol = packet[9]
+		source, destination = net.IP(packet[12:16]), net.IP(packet[16:20])
+		transport = packet[headerLength:]
+	case len(packet) >= 40 && packet[0]>>4 == 6:
+		// Extension headers are rare enough on test traffic to match those packets on
+		// address alone.
+		protocol = packet[6]
+		source, destination = net.IP(packet[8:24]), net.IP(packet[24:40])
+		transport = packet[40:]
+	default:
+		return false
+	}
+
+	ported := (protocol == 6 || protocol == 17) && len(transport) >= 4
+	var sourcePort, destinationPort uint16
+	if ported {
+		sourcePort = binary.BigEndian.Uint16(transport[0:2])
+		destinationPort = binary.BigEndian.Uint16(transport[2:4])
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.IP.Equal(source) && (!ported || endpoint.Port == sourcePort) {
+			return true
+		}
+		if endpoint.IP.Equal(destination) && (!ported || endpoint.Port == destinationPort) {
+			return true
+		}
+	}
+	return false
+}
+
+// A classic BPF program that the kernel runs on every packet (from the network layer up)
+// so that it only hands over the packets that may match the endpoints (see Matches).
+func Filter(endpoints []Endpoint) []bpf.Instruction {
+	accept := bpf.RetConstant{Val: math.MaxUint32}
+	drop := bpf.RetConstant{Val: 0}
+
+	// A block of comparisons (of what each load reads with a value) that accepts the packet
+	// when they are all equal and otherwise goes on after the block.
+	type comparison struct {
+		load  bpf.Instruction
+		value uint32
+	}
+	block := func(comparisons ...comparison) []bpf.Instruction {
+		instructions := make([]bpf.Instruction, 0, 2*len(comparisons)+1)
+		for i, compared := range comparisons {
+			instructions = append(instructions, compared.load, bpf.JumpIf{
+				Cond:     bpf.JumpNotEqual,
+				Val:      compared.value,
+				SkipTrue: uint8(2*(len(comparisons)-i) - 1),
+			})
+		}
+		return append(instructions, accept)
+	}
+	word := func(offset uint32) bpf.Instruction {
+		return bpf.LoadAbsolute{Off: offset, Size: 4}
+	}
+	address := func(offset uint32, ip net.IP) []comparison {
+		comparisons := make([]comparison, 0, len(ip)/4)
+		for i := 0; i < len(ip); i += 4 {
+			comparisons = append(comparisons, comparison{word(offset + uint32(i)), binary.BigEndian.Uint32(ip[i:])})
+		}
+		return comparisons
+	}
+	port := func(load bpf.Instruction, port uint16) comparison {
+		return comparison{load, uint32(port)}
+	}
+
+	// The version of IP, the offsets of its addresses and protocol, and the loads of the ports
+	// (the IPv4 header has a variable length, which the X register holds).
+	type family struct {
+		version         uint32
+		addressLength   int
+		source          uint32
+		destination     uint32
+		protocol        uint32
+		prologue        []bpf.Instruction
+		sourcePort      bpf.Instruction
+		destinationPort bpf.Instruction
+	}
+	families := []family{
+		{
+			version: 0x40, addressLength: net.IPv4len, source: 12, destination: 16, protocol: 9,
+			prologue:        []bpf.Instruction{bpf.LoadMemShift{Off: 0}},
+			sourcePort:      bpf.LoadIndirect{Off: 0, Size: 2},
+			destinationPort: bpf.LoadIndirect{Off: 2, Size: 2},
+		},
+		{
+			// Like Matches, this ignores extension headers.
+			version: 0x60, addressLength: net.IPv6len, source: 8, destination: 24, protocol: 6,
+			sourcePort:      bpf.LoadAbsolute{Off: 40, Size: 2},
+			destinationPort: bpf.LoadAbsolute{Off: 42, Size: 2},
+		},
+	}
+
+	sections := make([][]bpf.Instruction, 0, len(families))
+	for _, family := range families {
+		ported := append([]bpf.Instruction{}, family.prologue...)
+		unported := make([]bpf.Instruction, 0)
+		for _, endpoint := range endpoints {
+			ip := endpoint.IP.To4()
+			if family.addressLength == net.IPv6len {
+				if ip != nil {
+					continue
+				}
+				ip = endpoint.IP.To16()
+			}
+			if ip == nil {
+				continue
+			}
+			ported = append(ported, block(append(address(family.source, ip), port(family.sourcePort, endpoint.Port))...)...)
+			ported = append(ported, block(append(address(family.destination, ip), port(family.destinationPort, endpoint.Port))...)...)
+			unported = append(unported, block(address(family.source, ip)...)...)
+			unported = append(unported, block(address(family.destination, ip)...)...)
+		}
+		ported = append(ported, drop)
+		unported = append(unported, drop)
+
+		// TCP and UDP packets match on the port too; the others on address alone.
+		section := []bpf.Instruction{
+			bpf.LoadAbsolute{Off: family.protocol, Size: 1},
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6, SkipTrue: 2},
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 17, SkipTrue: 1},
+			bpf.Jump{Skip: uint32(len(ported))},
+		}
+		section = append(section, ported...)
+		sections = append(sections, append(section, unported...))
+	}
+
+	// Dispatch on the version of IP (the long jumps are unconditional because conditional
+	// ones only go 255 instructions).
+	program := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: 0, Size: 1},
+		bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xf0},
+	}
+	dispatchLength := len(program) + 2*len(families) + 1
+	offset := 0
+	for i, family := range families {
+		// Relative to the instruction after the jump.
+		fromJump := dispatchLength - (len(program) + 2) + offset
+		program = append(program,
+			bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: family.version, SkipTrue: 1},
+			bpf.Jump{Skip: uint32(fromJump)},
+		)
+		offset += len(sections[i])
+	}
+	program = append(program, drop)
+	for _, section := range sections {
+		program = append(program, section...)
+	}
+	return program
+}
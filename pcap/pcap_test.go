@@ -0,0 +1,171 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/bpf"
+)
+
+func TestWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewWriter(&buffer, 8)
+	if err != nil {
+		t.Fatalf("Could not write the file header: %v", err)
+	}
+	timestamp := time.Unix(1678874400, 250000000)
+	if err := writer.WritePacket(timestamp, []byte("0123456789"), 1500); err != nil {
+		t.Fatalf("Could not write a packet: %v", err)
+	}
+
+	written := buffer.Bytes()
+	if len(written) != 24+16+8 {
+		t.Fatalf("The packet should have been cut to the snap length: %d bytes", len(written))
+	}
+	if binary.LittleEndian.Uint32(written[0:4]) != magic || binary.LittleEndian.Uint32(written[20:24]) != linkTypeRaw {
+		t.Fatalf("The file header is wrong: %x", written[:24])
+	}
+	record := written[24:40]
+	if binary.LittleEndian.Uint32(record[0:4]) != 1678874400 || binary.LittleEndian.Uint32(record[4:8]) != 250000 {
+		t.Fatalf("The packet's timestamp is wrong: %x", record)
+	}
+	if binary.LittleEndian.Uint32(record[8:12]) != 8 || binary.LittleEndian.Uint32(record[12:16]) != 1500 {
+		t.Fatalf("The packet's lengths are wrong: %x", record)
+	}
+	if string(written[40:]) != "01234567" {
+		t.Fatalf("The packet's contents are wrong: %q", written[40:])
+	}
+}
+
+func ipv4Packet(protocol byte, source, destination string, sourcePort, destinationPort uint16) []byte {
+	packet := make([]byte, 24)
+	packet[0] = 0x45
+	packet[9] = protocol
+	copy(packet[12:16], net.ParseIP(source).To4())
+	copy(packet[16:20], net.ParseIP(destination).To4())
+	binary.BigEndian.PutUint16(packet[20:22], sourcePort)
+	binary.BigEndian.PutUint16(packet[22:24], destinationPort)
+	return packet
+}
+
+func TestMatches(t *testing.T) {
+	endpoints := []Endpoint{
+		{IP: net.ParseIP("192.0.2.1"), Port: 443},
+		{IP: net.ParseIP("2001:db8::1"), Port: 443},
+	}
+
+	if !Matches(endpoints, ipv4Packet(6, "192.0.2.1", "198.51.100.7", 443, 50000)) {
+		t.Fatalf("A packet from the server should match.")
+	}
+	if !Matches(endpoints, ipv4Packet(6, "198.51.100.7", "192.0.2.1", 50000, 443)) {
+		t.Fatalf("A packet to the server should match.")
+	}
+	if Matches(endpoints, ipv4Packet(6, "198.51.100.7", "192.0.2.1", 50000, 22)) {
+		t.Fatalf("A packet to another port of the server should not match.")
+	}
+	if !Matches(endpoints, ipv4Packet(1, "192.0.2.1", "198.51.100.7", 0, 0)) {
+		t.Fatalf("An ICMP packet from the server should match.")
+	}
+
+	ipv6 := make([]byte, 44)
+	ipv6[0] = 0x60
+	ipv6[6] = 6
+	copy(ipv6[8:24], net.ParseIP("2001:db8::7"))
+	copy(ipv6[24:40], net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(ipv6[40:42], 50000)
+	binary.BigEndian.PutUint16(ipv6[42:44], 443)
+	if !Matches(endpoints, ipv6) {
+		t.Fatalf("An IPv6 packet to the server should match.")
+	}
+
+	if Matches(endpoints, []byte{0x45, 0x00}) {
+		t.Fatalf("A truncated packet should not match.")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	endpoints := []Endpoint{
+		{IP: net.ParseIP("192.0.2.1"), Port: 443},
+		{IP: net.ParseIP("192.0.2.2"), Port: 8443},
+		{IP: net.ParseIP("2001:db8::1"), Port: 443},
+	}
+	vm, err := bpf.NewVM(Filter(endpoints))
+	if err != nil {
+		t.Fatalf("The filter is not a valid program: %v", err)
+	}
+
+	ipv6Packet := func(protocol byte, source, destination string, sourcePort, destinationPort uint16) []byte {
+		packet := make([]byte, 44)
+		packet[0] = 0x60
+		packet[6] = protocol
+		copy(packet[8:24], net.ParseIP(source))
+		copy(packet[24:40], net.ParseIP(destination))
+		binary.BigEndian.PutUint16(packet[40:42], sourcePort)
+		binary.BigEndian.PutUint16(packet[42:44], destinationPort)
+		return packet
+	}
+	withOptions := ipv4Packet(17, "198.51.100.7", "192.0.2.2", 50000, 8443)
+	withOptions = append(append(withOptions[:20:20], 1, 1, 1, 1), withOptions[20:]...)
+	withOptions[0] = 0x46
+
+	packets := map[string][]byte{
+		"from the server":          ipv4Packet(6, "192.0.2.1", "198.51.100.7", 443, 50000),
+		"to the server":            ipv4Packet(6, "198.51.100.7", "192.0.2.1", 50000, 443),
+		"to another port":          ipv4Packet(6, "198.51.100.7", "192.0.2.1", 50000, 22),
+		"to another server's port": ipv4Packet(6, "198.51.100.7", "192.0.2.1", 50000, 8443),
+		"to another host":          ipv4Packet(6, "198.51.100.7", "198.51.100.8", 50000, 443),
+		"ICMP from the server":     ipv4Packet(1, "192.0.2.1", "198.51.100.7", 0, 0),
+		"ICMP from another host":   ipv4Packet(1, "198.51.100.8", "198.51.100.7", 0, 0),
+		"UDP with IP options":      withOptions,
+		"IPv6 to the server":       ipv6Packet(6, "2001:db8::7", "2001:db8::1", 50000, 443),
+		"IPv6 from the server":     ipv6Packet(17, "2001:db8::1", "2001:db8::7", 443, 50000),
+		"IPv6 to another port":     ipv6Packet(6, "2001:db8::7", "2001:db8::1", 50000, 80),
+		"IPv6 to another host":     ipv6Packet(6, "2001:db8::7", "2001:db8::2", 50000, 443),
+		"ICMPv6 from the server":   ipv6Packet(58, "2001:db8::1", "2001:db8::7", 0, 0),
+		"neither IPv4 nor IPv6":    {0x20, 0, 0, 0},
+	}
+	for name, packet := range packets {
+		kept, err := vm.Run(packet)
+		if err != nil {
+			t.Fatalf("Could not run the filter on the packet %s: %v", name, err)
+		}
+		if (kept > 0) != Matches(endpoints, packet) {
+			t.Fatalf("The filter and Matches disagree on the packet %s (the filter kept %d bytes).", name, kept)
+		}
+		if kept > 0 && kept < len(packet) {
+			t.Fatalf("The filter should keep all of the packet %s, not %d bytes.", name, kept)
+		}
+	}
+}
+
+func TestResolveEndpoints(t *testing.T) {
+	endpoints, err := ResolveEndpoints("", "https://192.0.2.1/small", "http://192.0.2.1:8080/large", "https://192.0.2.1/upload")
+	if err != nil {
+		t.Fatalf("Could not resolve the endpoints: %v", err)
+	}
+	if len(endpoints) != 2 || endpoints[0].String() != "192.0.2.1:443" || endpoints[1].String() != "192.0.2.1:8080" {
+		t.Fatalf("The endpoints are wrong: %v", endpoints)
+	}
+
+	endpoints, err = ResolveEndpoints("2001:db8::1", "https://192.0.2.1/small")
+	if err != nil || len(endpoints) != 1 || endpoints[0].String() != "[2001:db8::1]:443" {
+		t.Fatalf("The address to connect to should replace the URL's host: %v %v", endpoints, err)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package phase
+
+// The phase of the test that a data point belongs to. The phases follow one another in
+// order, although a test that never saturates the network never leaves the ramping phase
+// and the pacing, draining and cooldown phases only happen when the user asks for them.
+type Phase int
+
+const (
+	// Before the load starts (e.g., while measuring the idle ping baseline).
+	Idle Phase = iota
+	// While the load generators add connections until throughput is stable.
+	Ramping
+	// While throughput is stable in both directions.
+	Saturated
+	// While the load is paced to a fraction of the measured capacity.
+	Pacing
+	// After the load stops but before latency returns to its idle level.
+	Draining
+	// After latency returns to its idle level.
+	Cooldown
+)
+
+func (p Phase) String() string {
+	switch p {
+	case Idle:
+		return "idle"
+	case Ramping:
+		return "ramping"
+	case Saturated:
+		return "saturated"
+	case Pacing:
+		return "pacing"
+	case Draining:
+		return "draining"
+	case Cooldown:
+		return "cooldown"
+	}
+	return "unknown"
+}
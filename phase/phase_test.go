@@ -0,0 +1,29 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package phase
+
+import "testing"
+
+func TestPhaseNames(t *testing.T) {
+	expected := []string{"idle", "ramping", "saturated", "pacing", "draining", "cooldown"}
+	for i, name := range expected {
+		if Phase(i).String() != name {
+			t.Fatalf("Phase %d should be named %s but is named %s.", i, name, Phase(i))
+		}
+	}
+	if Phase(len(expected)).String() != "unknown" {
+		t.Fatalf("An invalid phase should be named unknown.")
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// Connect probes measure only how long it takes to establish a connection to the server
+// (no HTTP request is sent) which separates network RTT from server response time.
+type ConnectProbeMode int
+
+const (
+	NoConnectProbes ConnectProbeMode = iota
+	TCPConnectProbes
+	TLSConnectProbes
+)
+
+func ParseConnectProbeMode(mode string) (ConnectProbeMode, error) {
+	switch mode {
+	case "", "none":
+		return NoConnectProbes, nil
+	case "tcp":
+		return TCPConnectProbes, nil
+	case "tls":
+		return TLSConnectProbes, nil
+	}
+	return NoConnectProbes, fmt.Errorf("unrecognized connect probe mode: %s", mode)
+}
+
+func (mode ConnectProbeMode) String() string {
+	switch mode {
+	case TCPConnectProbes:
+		return "tcp"
+	case TLSConnectProbes:
+		return "tls"
+	}
+	return "none"
+}
+
+func ConnectProbe(
+	managingCtx context.Context,
+	waitGroup *sync.WaitGroup,
+	probeConfiguration ProbeConfiguration,
+	keyLogger io.Writer,
+	mode ConnectProbeMode,
+	timeout time.Duration, // optional: 0 means that the probe never times out
+	result *chan ProbeDataPoint,
+	debugging *debug.DebugWithPrefix,
+) error {
+	if waitGroup != nil {
+		waitGroup.Add(1)
+		defer waitGroup.Done()
+	}
+
+	if mode == NoConnectProbes {
+		return fmt.Errorf("cannot start a connect probe without a mode")
+	}
+
+	probeUrl, err := url.Parse(probeConfiguration.URL)
+	if err != nil {
+		return err
+	}
+	serverName := probeUrl.Hostname()
+	if probeConfiguration.Host != "" {
+		serverName = probeConfiguration.Host
+	}
+	port := probeUrl.Port()
+	if port == "" {
+		port = utilities.Conditional(probeUrl.Scheme == "http", "80", "443")
+	}
+	address := net.JoinHostPort(probeUrl.Hostname(), port)
+	if probeConfiguration.ConnectToAddr != "" {
+		address = net.JoinHostPort(probeConfiguration.ConnectToAddr, port)
+	}
+
+	probeCtx := managingCtx
+	if timeout > 0 {
+		var probeCtxCancel context.CancelFunc
+		probeCtx, probeCtxCancel = context.WithTimeout(managingCtx, timeout)
+		defer probeCtxCancel()
+	}
+
+	probeId := utilities.GenerateUniqueId()
+	timeBeforeProbe := time.Now()
+
+	// Establishing a TCP connection takes one round trip.
+	roundTripCount := uint64(1)
+	reportIfTimedOut := func() {
+		if managingCtx.Err() != nil || probeCtx.Err() != context.DeadlineExceeded {
+			return
+		}
+		sendDataPoint(result, ProbeDataPoint{
+			Time:           timeBeforeProbe,
+			RoundTripCount: roundTripCount,
+			Duration:       timeout,
+			Type:           Connect,
+			TimedOut:       true,
+		}, Connect, probeId, debugging)
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(probeCtx, "tcp", address)
+	if err != nil {
+		reportIfTimedOut()
+		return err
+	}
+	defer conn.Close()
+
+	if mode == TLSConnectProbes {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: probeConfiguration.InsecureSkipVerify,
+			KeyLogWriter: utilities.TraceKeyLog(
+				keyLogger,
+				debug.IsDebug(debugging.Level),
+				fmt.Sprintf("%s %s Probe %v", debugging.Prefix, Connect.Value(), probeId),
+			),
+		})
+		// TLS 1.3 completes its handshake in one round trip; earlier versions need two.
+		roundTripCount++
+		if err := tlsConn.HandshakeContext(probeCtx); err != nil {
+			reportIfTimedOut()
+			return err
+		}
+		if tlsConn.ConnectionState().Version < tls.VersionTLS13 {
+			roundTripCount++
+		}
+	}
+	timeAfterProbe := time.Now()
+
+	if debug.IsDebug(debugging.Level) {
+		fmt.Printf(
+			"(%s) (%s Probe %v) Connected (%s) to %s in %v.\n",
+			debugging.Prefix,
+			Connect.Value(),
+			probeId,
+			mode,
+			address,
+			timeAfterProbe.Sub(timeBeforeProbe),
+		)
+	}
+
+	sendDataPoint(result, ProbeDataPoint{
+		Time:           timeBeforeProbe,
+		RoundTripCount: roundTripCount,
+		Duration:       timeAfterProbe.Sub(timeBeforeProbe),
+		Type:           Connect,
+	}, Connect, probeId, debugging)
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+func TestParseConnectProbeMode(t *testing.T) {
+	for mode, expected := range map[string]ConnectProbeMode{
+		"":     NoConnectProbes,
+		"none": NoConnectProbes,
+		"tcp":  TCPConnectProbes,
+		"tls":  TLSConnectProbes,
+	} {
+		if parsed, err := ParseConnectProbeMode(mode); err != nil || parsed != expected {
+			t.Fatalf("Parsing %q should yield %v but yielded %v (%v).", mode, expected, parsed, err)
+		}
+	}
+	if _, err := ParseConnectProbeMode("udp"); err == nil {
+		t.Fatalf("Parsing an unknown connect probe mode should fail.")
+	}
+}
+
+func TestTCPConnectProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen for the connect probe: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	result := make(chan ProbeDataPoint, 1)
+	err = ConnectProbe(
+		context.Background(),
+		nil,
+		ProbeConfiguration{URL: "http://" + listener.Addr().String() + "/small"},
+		nil,
+		TCPConnectProbes,
+		0,
+		&result,
+		debug.NewDebugWithPrefix(debug.Error, "test"),
+	)
+	if err != nil {
+		t.Fatalf("Connect probe failed: %v", err)
+	}
+	dataPoint := <-result
+	if dataPoint.Type != Connect || dataPoint.RoundTripCount != 1 || dataPoint.TimedOut {
+		t.Fatalf("Connect probe reported an unexpected data point: %v", dataPoint)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/phase"
+)
+
+// A data point for a probe that echoes a (sequence-numbered) packet off of the server: the
+// UDP probes and the pings.
+type EchoProbeDataPoint struct {
+	Time            time.Time       `Description:"Time that the packet was sent."                  Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	Sequence        uint64          `Description:"Sequence number of the packet."`
+	Duration        time.Duration   `Description:"The round-trip time of the packet."              Formatter:"Seconds"`
+	Lost            bool            `Description:"Whether the packet's echo never arrived in time."`
+	Phase           phase.Phase     `Description:"The phase of the test that the packet was sent in." Formatter:"String"`
+	TimestampSource TimestampSource `Description:"Where the times that the round-trip time comes from were taken." Formatter:"String"`
+}
+
+// Where the times that the RTT of an echo probe comes from were taken.
+type TimestampSource int
+
+const (
+	// When the prober sent the packet and when it saw the echo (which includes the time that
+	// the prober waited to be scheduled).
+	UserspaceTimestamps TimestampSource = iota
+	// When the kernel sent the packet and when it received the echo (see SO_TIMESTAMPING).
+	KernelTimestamps
+)
+
+func (source TimestampSource) String() string {
+	if source == KernelTimestamps {
+		return "kernel"
+	}
+	return "userspace"
+}
+
+// How an echo prober sends its packets and receives their echoes.
+type echoTransport interface {
+	Send(sequence uint64) error
+	// Block until the echo of a packet arrives and return that packet's sequence number. A
+	// transport that knows (from the kernel) when the packet left and when its echo arrived
+	// returns those times, too; otherwise, they are zero.
+	Receive() (sequence uint64, sent time.Time, received time.Time, err error)
+	Close() error
+}
+
+// Send a packet (one every interval) over transport until proberCtx is canceled. Each echo
+// yields a data point with the packet's RTT; packets whose echo does not arrive within
+// lossTimeout yield a data point that marks them lost.
+func echoProber(
+	proberCtx context.Context,
+	transport echoTransport,
+	interval time.Duration,
+	lossTimeout time.Duration,
+	debugging *debug.DebugWithPrefix,
+) chan EchoProbeDataPoint {
+	dataPoints := make(chan EchoProbeDataPoint)
+	send := func(dataPoint EchoProbeDataPoint) {
+		select {
+		case dataPoints <- dataPoint:
+		case <-proberCtx.Done():
+		}
+	}
+
+	outstandingLock := sync.Mutex{}
+	outstanding := make(map[uint64]time.Time)
+	wg := sync.WaitGroup{}
+
+	// Receive the echoes.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			sequence, kernelSent, kernelReceived, err := transport.Receive()
+			if err != nil {
+				if proberCtx.Err() == nil && debug.IsDebug(debugging.Level) {
+					fmt.Printf("(%s) Stopped receiving echoes: %v\n", debugging.Prefix, err)
+				}
+				return
+			}
+			now := time.Now()
+			outstandingLock.Lock()
+			sent, ok := outstanding[sequence]
+			delete(outstanding, sequence)
+			outstandingLock.Unlock()
+			// An echo that arrives after its packet was declared lost is ignored.
+			if ok {
+				dataPoint := EchoProbeDataPoint{Time: sent, Sequence: sequence, Duration: now.Sub(sent)}
+				if !kernelSent.IsZero() && kernelReceived.After(kernelSent) {
+					dataPoint.Duration = kernelReceived.Sub(kernelSent)
+					dataPoint.TimestampSource = KernelTimestamps
+				}
+				send(dataPoint)
+			}
+		}
+	}()
+
+	// Send the packets (and notice the ones that were lost).
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for sequence := uint64(0); proberCtx.Err() == nil; sequence++ {
+			now := time.Now()
+			outstandingLock.Lock()
+			outstanding[sequence] = now
+			outstandingLock.Unlock()
+			if err := transport.Send(sequence); err != nil && debug.IsDebug(debugging.Level) {
+				fmt.Printf("(%s) Could not send packet %d: %v\n", debugging.Prefix, sequence, err)
+			}
+
+			lost := make([]EchoProbeDataPoint, 0)
+			outstandingLock.Lock()
+			for lostSequence, sent := range outstanding {
+				if now.Sub(sent) > lossTimeout {
+					lost = append(lost, EchoProbeDataPoint{Time: sent, Sequence: lostSequence, Lost: true})
+					delete(outstanding, lostSequence)
+				}
+			}
+			outstandingLock.Unlock()
+			for _, dataPoint := range lost {
+				send(dataPoint)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-proberCtx.Done():
+			}
+		}
+		transport.Close()
+		wg.Wait()
+		close(dataPoints)
+	}()
+	return dataPoints
+}
@@ -0,0 +1,124 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// The IANA protocol numbers that icmp.ParseMessage needs.
+const (
+	protocolICMP     = 1
+	protocolICMPIPv6 = 58
+)
+
+type icmpEchoTransport struct {
+	conn        *icmp.PacketConn
+	destination net.Addr
+	protocol    int
+	echoType    icmp.Type
+	replyType   icmp.Type
+	id          int
+	// Unprivileged (datagram) ICMP sockets get their own ID from the kernel, which also
+	// filters out replies that are not for us.
+	privileged bool
+}
+
+func (t *icmpEchoTransport) Send(sequence uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, sequence)
+	message := icmp.Message{
+		Type: t.echoType,
+		Code: 0,
+		Body: &icmp.Echo{ID: t.id, Seq: int(sequence & 0xffff), Data: data},
+	}
+	packet, err := message.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = t.conn.WriteTo(packet, t.destination)
+	return err
+}
+
+func (t *icmpEchoTransport) Receive() (uint64, time.Time, time.Time, error) {
+	packet := make([]byte, 1500)
+	for {
+		n, _, err := t.conn.ReadFrom(packet)
+		if err != nil {
+			return 0, time.Time{}, time.Time{}, err
+		}
+		message, err := icmp.ParseMessage(t.protocol, packet[:n])
+		if err != nil || message.Type != t.replyType {
+			continue
+		}
+		echo, ok := message.Body.(*icmp.Echo)
+		if !ok || len(echo.Data) < 8 || (t.privileged && echo.ID != t.id) {
+			continue
+		}
+		return binary.BigEndian.Uint64(echo.Data), time.Time{}, time.Time{}, nil
+	}
+}
+
+func (t *icmpEchoTransport) Close() error {
+	return t.conn.Close()
+}
+
+// Ping host (one echo request every interval) until proberCtx is canceled. Where the
+// platform allows it, pings are sent from an unprivileged (datagram) ICMP socket; otherwise
+// they need a raw socket (and, therefore, privileges).
+func Pinger(
+	proberCtx context.Context,
+	host string,
+	interval time.Duration,
+	lossTimeout time.Duration,
+	debugging *debug.DebugWithPrefix,
+) (chan EchoProbeDataPoint, error) {
+	address, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &icmpEchoTransport{id: os.Getpid() & 0xffff}
+	unprivilegedNetwork, privilegedNetwork, listenAddress := "udp4", "ip4:icmp", "0.0.0.0"
+	transport.protocol, transport.echoType, transport.replyType = protocolICMP, ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if address.IP.To4() == nil {
+		unprivilegedNetwork, privilegedNetwork, listenAddress = "udp6", "ip6:ipv6-icmp", "::"
+		transport.protocol, transport.echoType, transport.replyType = protocolICMPIPv6, ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	if transport.conn, err = icmp.ListenPacket(unprivilegedNetwork, listenAddress); err == nil {
+		transport.destination = &net.UDPAddr{IP: address.IP, Zone: address.Zone}
+	} else {
+		if debug.IsDebug(debugging.Level) {
+			fmt.Printf("(%s) Could not open an unprivileged ICMP socket (%v); trying a raw socket.\n", debugging.Prefix, err)
+		}
+		if transport.conn, err = icmp.ListenPacket(privilegedNetwork, listenAddress); err != nil {
+			return nil, err
+		}
+		transport.destination = address
+		transport.privileged = true
+	}
+	return echoProber(proberCtx, transport, interval, lossTimeout, debugging), nil
+}
@@ -0,0 +1,41 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+func TestPingLoopback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dataPoints, err := Pinger(ctx, "127.0.0.1", 10*time.Millisecond, time.Second, debug.NewDebugWithPrefix(debug.Error, "test"))
+	if err != nil {
+		t.Skipf("Pinging is not permitted here: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		dataPoint := <-dataPoints
+		if dataPoint.Lost || dataPoint.Duration <= 0 {
+			t.Fatalf("A ping over loopback should not be lost: %v", dataPoint)
+		}
+	}
+	cancel()
+	for range dataPoints {
+	}
+}
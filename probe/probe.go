@@ -16,6 +16,7 @@ package probe
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,6 +28,7 @@ import (
 	"github.com/network-quality/goresponsiveness/debug"
 	"github.com/network-quality/goresponsiveness/extendedstats"
 	"github.com/network-quality/goresponsiveness/lgc"
+	"github.com/network-quality/goresponsiveness/phase"
 	"github.com/network-quality/goresponsiveness/utilities"
 )
 
@@ -37,6 +39,13 @@ type ProbeConfiguration struct {
 	URL                string
 	Host               string
 	InsecureSkipVerify bool
+	// When set, the probe's TLS handshake may resume a session held in (and
+	// store its own session in) this cache.
+	SessionCache tls.ClientSessionCache
+	// When set, foreign probes tunnel through the proxy with this (see utilities.ProxyDialer).
+	ProxyDialer utilities.ProxyDialer
+	// The credentials and headers for the requests to the test server (may be nil).
+	Headers *utilities.RequestHeaders
 }
 
 type ProbeDataPoint struct {
@@ -46,12 +55,33 @@ type ProbeDataPoint struct {
 	TCPRtt         time.Duration `Description:"The underlying connection's RTT at probe time."               Formatter:"Seconds"`
 	TCPCwnd        uint32        `Description:"The underlying connection's congestion window at probe time."`
 	Type           ProbeType     `Description:"The type of the probe."                                       Formatter:"Value"`
+	TimedOut       bool          `Description:"Whether the probe was canceled because it timed out."`
+	DNSDuration    time.Duration `Description:"The duration of the probe's DNS lookup (0 when there was none)." Formatter:"Seconds"`
+	TCPDuration    time.Duration `Description:"The duration of the probe's TCP connection setup (0 when there was none)." Formatter:"Seconds"`
+	TLSDuration    time.Duration `Description:"The duration of the probe's TLS handshake (0 when there was none)." Formatter:"Seconds"`
+	TLSResumed     bool          `Description:"Whether the probe's TLS handshake resumed an earlier session."`
+	HTTPDuration   time.Duration `Description:"The duration of the probe's HTTP transaction." Formatter:"Seconds"`
+	SentBytes      uint64        `Description:"The bytes of the probe's request (headers and body)." Units:"bytes"`
+	ReceivedBytes  uint64        `Description:"The bytes of the probe's response (headers and body)." Units:"bytes"`
+	Phase          phase.Phase   `Description:"The phase of the test that the probe completed in." Formatter:"String"`
+	StatusCode     int           `Description:"The status of the probe's response (0 when there was none)."`
+	BodyBytes      uint64        `Description:"The size of the body of the probe's response." Units:"bytes"`
+	Reused         bool          `Description:"Whether the probe reused an existing connection."`
+	HTTPProtocol   string        `Description:"The HTTP protocol of the probe's response (empty when there was none)."`
+	ConnectionId   uint64        `Description:"The client id of the load-generating connection that a self probe was sent on (0 for other probes)."`
+}
+
+// Whether the server answered the probe with a status other than 2xx (in which case the data
+// point measures nothing).
+func (pdp ProbeDataPoint) ErrorResponse() bool {
+	return pdp.StatusCode != 0 && !lgc.SuccessfulStatus(pdp.StatusCode)
 }
 
 const (
 	SelfUp ProbeType = iota
 	SelfDown
 	Foreign
+	Connect
 )
 
 type ProbeRoundTripCountType uint16
@@ -68,6 +98,8 @@ func (pt ProbeType) Value() string {
 		return "SelfUp"
 	} else if pt == SelfDown {
 		return "SelfDown"
+	} else if pt == Connect {
+		return "Connect"
 	}
 	return "Foreign"
 }
@@ -76,10 +108,12 @@ func Probe(
 	managingCtx context.Context,
 	waitGroup *sync.WaitGroup,
 	client *http.Client,
-	lgc lgc.LoadGeneratingConnection,
+	connection lgc.LoadGeneratingConnection,
 	probeUrl string,
 	probeHost string, // optional: for use with a test_endpoint
+	headers *utilities.RequestHeaders, // optional: see utilities.SetRequestHeaders
 	probeType ProbeType,
+	timeout time.Duration, // optional: 0 means that the probe never times out
 	result *chan ProbeDataPoint,
 	captureExtendedStats bool,
 	debugging *debug.DebugWithPrefix,
@@ -93,11 +127,52 @@ func Probe(
 		return fmt.Errorf("cannot start a probe with a nil client")
 	}
 
+	probeCtx := managingCtx
+	if timeout > 0 {
+		var probeCtxCancel context.CancelFunc
+		probeCtx, probeCtxCancel = context.WithTimeout(managingCtx, timeout)
+		defer probeCtxCancel()
+	}
+
 	probeId := utilities.GenerateUniqueId()
 	probeTracer := NewProbeTracer(client, probeType, probeId, debugging)
 	time_before_probe := time.Now()
+	connectionId := uint64(0)
+	if !utilities.IsInterfaceNil(connection) {
+		connectionId = connection.ClientId()
+	}
+
+	// When a probe is canceled because it took too long (and not because whoever started it
+	// is done), we report it so that it can be counted as a loss.
+	reportIfTimedOut := func() {
+		if managingCtx.Err() != nil || probeCtx.Err() != context.DeadlineExceeded {
+			return
+		}
+		if debug.IsDebug(debugging.Level) {
+			fmt.Printf(
+				"(%s) (%s Probe %v) Probe timed out after %v.\n",
+				debugging.Prefix,
+				probeType.Value(),
+				probeId,
+				timeout,
+			)
+		}
+		roundTripCount := DefaultDownRoundTripCount
+		if probeType == Foreign {
+			roundTripCount = ForeignRoundTripCount
+		}
+		sendDataPoint(result, ProbeDataPoint{
+			Time:           time_before_probe,
+			RoundTripCount: uint64(roundTripCount),
+			Duration:       timeout,
+			Type:           probeType,
+			TimedOut:       true,
+			ConnectionId:   connectionId,
+		}, probeType, probeId, debugging)
+	}
+
 	probe_req, err := http.NewRequestWithContext(
-		httptrace.WithClientTrace(managingCtx, probeTracer.trace),
+		httptrace.WithClientTrace(probeCtx, probeTracer.trace),
 		"GET",
 		probeUrl,
 		nil,
@@ -108,10 +183,32 @@ func Probe(
 
 	// Used to disable compression
 	probe_req.Header.Set("Accept-Encoding", "identity")
-	probe_req.Header.Set("User-Agent", utilities.UserAgent())
+	utilities.SetRequestHeaders(probe_req, headers)
 
 	probe_resp, err := client.Do(probe_req)
 	if err != nil {
+		reportIfTimedOut()
+		return err
+	}
+
+	if err := lgc.CheckResponse(probe_resp); err != nil {
+		// The error page's size can tell what answered the probe.
+		bodyBytes, _ := io.Copy(io.Discard, probe_resp.Body)
+		probe_resp.Body.Close()
+		roundTripCount := DefaultDownRoundTripCount
+		if probeType == Foreign {
+			roundTripCount = ForeignRoundTripCount
+		}
+		sendDataPoint(result, ProbeDataPoint{
+			Time:           time_before_probe,
+			RoundTripCount: uint64(roundTripCount),
+			Type:           probeType,
+			StatusCode:     probe_resp.StatusCode,
+			BodyBytes:      uint64(bodyBytes),
+			Reused:         probeTracer.stats.ConnectionReused,
+			HTTPProtocol:   lgc.HTTPProtocol(probe_resp),
+			ConnectionId:   connectionId,
+		}, probeType, probeId, debugging)
 		return err
 	}
 
@@ -121,8 +218,10 @@ func Probe(
 	}
 
 	// TODO: Make this interruptable somehow by using _ctx_.
-	_, err = io.ReadAll(probe_resp.Body)
+	probe_body, err := io.ReadAll(probe_resp.Body)
 	if err != nil {
+		probe_resp.Body.Close()
+		reportIfTimedOut()
 		return err
 	}
 	time_after_probe := time.Now()
@@ -145,13 +244,13 @@ func Probe(
 
 	// We must have reused the connection if we are a self probe!
 	if (probeType == SelfUp || probeType == SelfDown) && !probeTracer.stats.ConnectionReused {
-		if !utilities.IsInterfaceNil(lgc) {
+		if !utilities.IsInterfaceNil(connection) {
 			fmt.Fprintf(os.Stderr,
 				"(%s) (%s Probe %v) Probe should have reused a connection, but it didn't (connection status: %v)!\n",
 				debugging.Prefix,
 				probeType.Value(),
 				probeId,
-				lgc.Status(),
+				connection.Status(),
 			)
 		}
 		panic(!probeTracer.stats.ConnectionReused)
@@ -168,7 +267,8 @@ func Probe(
 		)
 	}
 	roundTripCount := DefaultDownRoundTripCount
-	if probeType == Foreign {
+	// A foreign probe that reused a connection (see rpm.ForeignProbeReuse) made no handshakes.
+	if probeType == Foreign && !probeTracer.stats.ConnectionReused {
 		roundTripCount = ForeignRoundTripCount
 	}
 	// Careful!!! It's possible that this channel has been closed because the Prober that
@@ -206,7 +306,62 @@ func Probe(
 		TCPRtt:         tcpRtt,
 		TCPCwnd:        tcpCwnd,
 		Type:           probeType,
+		DNSDuration:    probeTracer.GetDnsDelta(),
+		TCPDuration:    probeTracer.GetTCPDelta(),
+		TLSDuration:    probeTracer.GetTLSDelta(),
+		TLSResumed:     probeTracer.GetTLSResumed(),
+		HTTPDuration:   probeTracer.GetHttpHeaderDelta() + probeTracer.GetHttpDownloadDelta(time_after_probe),
+		SentBytes: headerBytes(
+			fmt.Sprintf("%s %s HTTP/1.1", probe_req.Method, probe_req.URL.RequestURI()),
+			probe_req.Header,
+		) + uint64(len("Host: ")+len(probe_req.URL.Host)+len("\r\n")),
+		ReceivedBytes: headerBytes(
+			fmt.Sprintf("%s %s", probe_resp.Proto, probe_resp.Status),
+			probe_resp.Header,
+		) + uint64(len(probe_body)),
+		StatusCode:   probe_resp.StatusCode,
+		BodyBytes:    uint64(len(probe_body)),
+		Reused:       probeTracer.stats.ConnectionReused,
+		HTTPProtocol: lgc.HTTPProtocol(probe_resp),
+		ConnectionId: connectionId,
 	}
 	*result <- dataPoint
 	return nil
 }
+
+// The number of bytes in the header of an HTTP/1.1 message: its start line, its header fields
+// and the empty line that ends them. HTTP/2 compresses headers, so, for it, this is an upper
+// bound.
+func headerBytes(startLine string, header http.Header) uint64 {
+	size := len(startLine) + len("\r\n")
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(": ") + len(value) + len("\r\n")
+		}
+	}
+	return uint64(size + len("\r\n"))
+}
+
+// Send a data point back to the prober that started a probe. That prober may have already
+// stopped (and closed the channel) in which case writing panics -- that is okay.
+func sendDataPoint(
+	result *chan ProbeDataPoint,
+	dataPoint ProbeDataPoint,
+	probeType ProbeType,
+	probeId uint64,
+	debugging *debug.DebugWithPrefix,
+) {
+	defer func() {
+		isThreadPanicing := recover()
+		if isThreadPanicing != nil && debug.IsDebug(debugging.Level) {
+			fmt.Printf(
+				"(%s) (%s Probe %v) Probe attempted to write to the result channel after its invoker ended (official reason: %v).\n",
+				debugging.Prefix,
+				probeType.Value(),
+				probeId,
+				isThreadPanicing,
+			)
+		}
+	}()
+	*result <- dataPoint
+}
@@ -0,0 +1,71 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+package probe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/lgc"
+)
+
+func TestProbeErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	result := make(chan ProbeDataPoint, 1)
+	err := Probe(
+		context.Background(), nil, server.Client(), nil, server.URL+"/small", "", nil, Foreign, 0, &result,
+		false, debug.NewDebugWithPrefix(debug.NoDebug, "test"),
+	)
+	var responseError *lgc.ResponseError
+	if !errors.As(err, &responseError) || responseError.StatusCode != http.StatusNotFound || responseError.Retryable() {
+		t.Fatalf("The probe should have failed because of the 404: %v", err)
+	}
+	dataPoint := <-result
+	if !dataPoint.ErrorResponse() || dataPoint.StatusCode != http.StatusNotFound || dataPoint.Type != Foreign {
+		t.Fatalf("The probe should have reported its error response: %v", dataPoint)
+	}
+	if dataPoint.BodyBytes != uint64(len("404 page not found\n")) {
+		t.Fatalf("The probe should have reported the size of the error page, not %d bytes.", dataPoint.BodyBytes)
+	}
+}
+
+func TestProbeResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("small"))
+	}))
+	defer server.Close()
+
+	result := make(chan ProbeDataPoint, 2)
+	for i := 0; i < 2; i++ {
+		if err := Probe(
+			context.Background(), nil, server.Client(), nil, server.URL+"/small", "", nil, Foreign, 0, &result,
+			false, debug.NewDebugWithPrefix(debug.NoDebug, "test"),
+		); err != nil {
+			t.Fatalf("The probe failed: %v", err)
+		}
+	}
+	first, second := <-result, <-result
+	if first.ErrorResponse() || first.StatusCode != http.StatusOK || first.BodyBytes != uint64(len("small")) {
+		t.Fatalf("The probe should have reported its status and the size of its response: %v", first)
+	}
+	if first.Reused || !second.Reused {
+		t.Fatalf("Only the second probe (on the same client) should have reused a connection (%v, %v).", first.Reused, second.Reused)
+	}
+}
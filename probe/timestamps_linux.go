@@ -0,0 +1,174 @@
+//go:build linux
+// +build linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+package probe
+
+import (
+	"net"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The times that the kernel took (see SO_TIMESTAMPING) as the packets of a UDP socket left and
+// arrived.
+type kernelTimestamps struct {
+	conn    *net.UDPConn
+	rawConn syscall.RawConn
+	lock    sync.Mutex
+	// The kernel numbers the packets whose departure it timestamps in the order that they were
+	// sent; these are their sequence numbers (by that number).
+	sequences map[uint32]uint64
+	nextId    uint32
+	// When the packets (by sequence number) left.
+	departures map[uint64]time.Time
+}
+
+func enableKernelTimestamps(conn *net.UDPConn) (*kernelTimestamps, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	rerr := rawConn.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(
+			int(fd),
+			unix.SOL_SOCKET,
+			unix.SO_TIMESTAMPING,
+			unix.SOF_TIMESTAMPING_SOFTWARE|
+				unix.SOF_TIMESTAMPING_TX_SOFTWARE|
+				unix.SOF_TIMESTAMPING_RX_SOFTWARE|
+				unix.SOF_TIMESTAMPING_OPT_ID|
+				unix.SOF_TIMESTAMPING_OPT_TSONLY,
+		)
+	})
+	if rerr != nil {
+		return nil, rerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &kernelTimestamps{
+		conn:       conn,
+		rawConn:    rawConn,
+		sequences:  make(map[uint32]uint64),
+		departures: make(map[uint64]time.Time),
+	}, nil
+}
+
+// Note that the packet with sequence is about to be sent (so that we can tell its departure
+// from the others'). Its echo may well arrive before the send returns.
+func (kt *kernelTimestamps) sending(sequence uint64) {
+	kt.lock.Lock()
+	defer kt.lock.Unlock()
+	kt.sequences[kt.nextId] = sequence
+	kt.nextId++
+}
+
+// Take back the last call to sending: the packet could not be sent.
+func (kt *kernelTimestamps) notSent() {
+	kt.lock.Lock()
+	defer kt.lock.Unlock()
+	kt.nextId--
+	delete(kt.sequences, kt.nextId)
+}
+
+// Read a packet (like Read) along with the time that it arrived (zero if the kernel did not
+// say: it turns receive timestamps on in the background, so the first packets may have none).
+func (kt *kernelTimestamps) receive(packet []byte) (int, time.Time, error) {
+	oob := make([]byte, 128)
+	n, oobn, _, _, err := kt.conn.ReadMsgUDP(packet, oob)
+	if err != nil {
+		return n, time.Time{}, err
+	}
+	received := time.Time{}
+	if messages, err := unix.ParseSocketControlMessage(oob[:oobn]); err == nil {
+		for _, message := range messages {
+			if message.Header.Level == unix.SOL_SOCKET && message.Header.Type == unix.SCM_TIMESTAMPING {
+				received = softwareTimestamp(message.Data)
+			}
+		}
+	}
+	return n, received, nil
+}
+
+// When the packet with sequence left (zero if the kernel did not say). By the time that its
+// echo arrives, the kernel has long told us -- we only have to look.
+func (kt *kernelTimestamps) sentTime(sequence uint64) time.Time {
+	kt.readDepartures()
+	kt.lock.Lock()
+	defer kt.lock.Unlock()
+	departure := kt.departures[sequence]
+	delete(kt.departures, sequence)
+	return departure
+}
+
+// The kernel reports departures on the socket's error queue.
+func (kt *kernelTimestamps) readDepartures() {
+	packet := make([]byte, udpProbePacketSize)
+	oob := make([]byte, 256)
+	kt.rawConn.Control(func(fd uintptr) {
+		for {
+			_, oobn, _, _, err := unix.Recvmsg(int(fd), packet, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+			if err != nil {
+				return
+			}
+			messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+			if err != nil {
+				continue
+			}
+			departure, id, identified := time.Time{}, uint32(0), false
+			for _, message := range messages {
+				switch {
+				case message.Header.Level == unix.SOL_SOCKET && message.Header.Type == unix.SCM_TIMESTAMPING:
+					departure = softwareTimestamp(message.Data)
+				case (message.Header.Level == unix.SOL_IP && message.Header.Type == unix.IP_RECVERR) ||
+					(message.Header.Level == unix.SOL_IPV6 && message.Header.Type == unix.IPV6_RECVERR):
+					if len(message.Data) >= int(unsafe.Sizeof(unix.SockExtendedErr{})) {
+						extendedErr := (*unix.SockExtendedErr)(unsafe.Pointer(&message.Data[0]))
+						if extendedErr.Origin == unix.SO_EE_ORIGIN_TIMESTAMPING {
+							id, identified = extendedErr.Data, true
+						}
+					}
+				}
+			}
+			if !identified || departure.IsZero() {
+				continue
+			}
+			kt.lock.Lock()
+			if sequence, ok := kt.sequences[id]; ok {
+				kt.departures[sequence] = departure
+				delete(kt.sequences, id)
+			}
+			kt.lock.Unlock()
+		}
+	})
+}
+
+// The software timestamp (the first of the three that the kernel gives) in an SCM_TIMESTAMPING
+// control message.
+func softwareTimestamp(data []byte) time.Time {
+	if len(data) < int(unsafe.Sizeof(unix.Timespec{})) {
+		return time.Time{}
+	}
+	software := (*unix.Timespec)(unsafe.Pointer(&data[0]))
+	if software.Sec == 0 && software.Nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(software.Unix())
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+package probe
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+type kernelTimestamps struct{}
+
+func enableKernelTimestamps(conn *net.UDPConn) (*kernelTimestamps, error) {
+	return nil, fmt.Errorf("kernel timestamps are not supported on this platform")
+}
+
+func (kt *kernelTimestamps) sending(sequence uint64) {}
+
+func (kt *kernelTimestamps) notSent() {}
+
+func (kt *kernelTimestamps) receive(packet []byte) (int, time.Time, error) {
+	return 0, time.Time{}, fmt.Errorf("kernel timestamps are not supported on this platform")
+}
+
+func (kt *kernelTimestamps) sentTime(sequence uint64) time.Time {
+	return time.Time{}
+}
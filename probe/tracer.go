@@ -50,7 +50,8 @@ func (p *ProbeTracer) GetTrace() *httptrace.ClientTrace {
 }
 
 func (p *ProbeTracer) GetDnsDelta() time.Duration {
-	if p.stats.ConnectionReused {
+	// There is no lookup when a connection is reused (or when we connect to an address).
+	if p.stats.ConnectionReused || p.stats.DnsStartTime.IsZero() {
 		return time.Duration(0)
 	}
 	delta := p.stats.DnsDoneTime.Sub(p.stats.DnsStartTime)
@@ -71,11 +72,18 @@ func (p *ProbeTracer) GetTCPDelta() time.Duration {
 	return delta
 }
 
+// Whether the probe's TLS handshake resumed an earlier session (rather than doing a full
+// handshake).
+func (p *ProbeTracer) GetTLSResumed() bool {
+	return utilities.IsSome(p.stats.TLSDoneTime) && p.stats.TLSConnInfo.DidResume
+}
+
 func (p *ProbeTracer) GetTLSDelta() time.Duration {
-	if utilities.IsSome(p.stats.TLSDoneTime) {
-		panic("There should not be TLS information, but there is.")
+	// There is no handshake when a connection is reused.
+	if utilities.IsNone(p.stats.TLSStartTime) || utilities.IsNone(p.stats.TLSDoneTime) {
+		return time.Duration(0)
 	}
-	delta := time.Duration(0)
+	delta := utilities.GetSome(p.stats.TLSDoneTime).Sub(utilities.GetSome(p.stats.TLSStartTime))
 	if debug.IsDebug(p.debug) {
 		fmt.Printf("(Probe %v): TLS Time: %v\n", p.probeid, delta)
 	}
@@ -87,9 +95,8 @@ func (p *ProbeTracer) GetTLSAndHttpHeaderDelta() time.Duration {
 	// and *before* the HTTP transaction, we know that the delta between the time
 	// that the first HTTP response byte is available and the time that the TCP
 	// connection was established includes both the time for the HTTP header RTT
-	// *and* the TLS handshake RTT, whether we can specifically measure the latter
-	// or not. Eventually when TLS handshake tracing is fixed, we can break these
-	// into separate buckets, but for now this workaround is reasonable.
+	// *and* the TLS handshake RTT. Use GetTLSDelta() and GetHttpHeaderDelta() to
+	// break these into separate buckets.
 	before := p.stats.ConnectDoneTime
 	if p.stats.ConnectionReused {
 		// When we reuse a connection there will be no time logged for when the
@@ -105,10 +112,16 @@ func (p *ProbeTracer) GetTLSAndHttpHeaderDelta() time.Duration {
 }
 
 func (p *ProbeTracer) GetHttpHeaderDelta() time.Duration {
-	panic(
-		"Unusable until TLS tracing support is enabled! Use GetTLSAndHttpHeaderDelta() instead.\n",
-	)
-	delta := p.stats.HttpResponseReadyTime.Sub(utilities.GetSome(p.stats.TLSDoneTime))
+	// The HTTP transaction starts once the connection is ready: after the TLS handshake
+	// (if there was one), after the TCP connection (if there was not) or when we were
+	// notified about reusing a connection.
+	before := p.stats.ConnectDoneTime
+	if utilities.IsSome(p.stats.TLSDoneTime) {
+		before = utilities.GetSome(p.stats.TLSDoneTime)
+	} else if p.stats.ConnectionReused {
+		before = p.stats.GetConnectionDoneTime
+	}
+	delta := p.stats.HttpResponseReadyTime.Sub(before)
 	if debug.IsDebug(p.debug) {
 		fmt.Printf("(Probe %v): Http Header Time: %v\n", p.probeid, delta)
 	}
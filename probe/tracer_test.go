@@ -0,0 +1,43 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+func TestTracerSetupDeltas(t *testing.T) {
+	tracer := NewProbeTracer(http.DefaultClient, Foreign, 1, debug.NewDebugWithPrefix(debug.NoDebug, "test"))
+	if tracer.GetDnsDelta() != 0 || tracer.GetTLSDelta() != 0 {
+		t.Fatalf("A probe without a lookup or a handshake should have no DNS or TLS time.")
+	}
+
+	start := time.Now()
+	tracer.stats.DnsStartTime = start
+	tracer.stats.DnsDoneTime = start.Add(5 * time.Millisecond)
+	tracer.stats.TLSStartTime = utilities.Some(start.Add(10 * time.Millisecond))
+	tracer.stats.TLSDoneTime = utilities.Some(start.Add(30 * time.Millisecond))
+	if delta := tracer.GetDnsDelta(); delta != 5*time.Millisecond {
+		t.Fatalf("DNS time should have been 5ms but was %v.", delta)
+	}
+	if delta := tracer.GetTLSDelta(); delta != 20*time.Millisecond {
+		t.Fatalf("TLS time should have been 20ms but was %v.", delta)
+	}
+}
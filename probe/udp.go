@@ -0,0 +1,123 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+// Every UDP probe packet carries its sequence number; the echo endpoint sends it back
+// unchanged.
+const udpProbePacketSize = 8
+
+type udpEchoTransport struct {
+	conn *net.UDPConn
+	// Set when the kernel timestamps the packets.
+	timestamps *kernelTimestamps
+}
+
+func (t *udpEchoTransport) Send(sequence uint64) error {
+	packet := make([]byte, udpProbePacketSize)
+	binary.BigEndian.PutUint64(packet, sequence)
+	if t.timestamps != nil {
+		t.timestamps.sending(sequence)
+	}
+	_, err := t.conn.Write(packet)
+	if err != nil && t.timestamps != nil {
+		t.timestamps.notSent()
+	}
+	return err
+}
+
+func (t *udpEchoTransport) Receive() (uint64, time.Time, time.Time, error) {
+	packet := make([]byte, udpProbePacketSize)
+	for {
+		var n int
+		var err error
+		var received time.Time
+		if t.timestamps != nil {
+			n, received, err = t.timestamps.receive(packet)
+		} else {
+			n, err = t.conn.Read(packet)
+		}
+		if err != nil {
+			return 0, time.Time{}, time.Time{}, err
+		}
+		if n == udpProbePacketSize {
+			sequence := binary.BigEndian.Uint64(packet)
+			if t.timestamps != nil {
+				return sequence, t.timestamps.sentTime(sequence), received, nil
+			}
+			return sequence, time.Time{}, time.Time{}, nil
+		}
+	}
+}
+
+func (t *udpEchoTransport) Close() error {
+	return t.conn.Close()
+}
+
+// Send a stream of UDP packets (one every interval) to an echo endpoint at address until
+// proberCtx is canceled. With kernelTimestamps, the RTTs come from when the kernel sent the
+// packets and received their echoes (where the platform can tell; elsewhere, they are timed as
+// usual).
+func UDPProber(
+	proberCtx context.Context,
+	address string,
+	interval time.Duration,
+	lossTimeout time.Duration,
+	kernelTimestamps bool,
+	debugging *debug.DebugWithPrefix,
+) (chan EchoProbeDataPoint, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	transport := &udpEchoTransport{conn: conn.(*net.UDPConn)}
+	if kernelTimestamps {
+		if transport.timestamps, err = enableKernelTimestamps(transport.conn); err != nil {
+			fmt.Printf("Warning: Could not enable kernel timestamps for the UDP probes (%v); they are timed in userspace.\n", err)
+		}
+	}
+	return echoProber(proberCtx, transport, interval, lossTimeout, debugging), nil
+}
+
+// Echo every UDP packet received on conn back to its sender until ctx is canceled (or the
+// connection fails). This is the endpoint that UDPProber expects at the other end.
+func UDPEcho(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	packet := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(packet)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if _, err := conn.WriteTo(packet[:n], from); err != nil && ctx.Err() != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package probe
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+func TestUDPProberWithEcho(t *testing.T) {
+	echoConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen for UDP echoes: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go UDPEcho(ctx, echoConn)
+
+	dataPoints, err := UDPProber(
+		ctx,
+		echoConn.LocalAddr().String(),
+		10*time.Millisecond,
+		time.Second,
+		false,
+		debug.NewDebugWithPrefix(debug.Error, "test"),
+	)
+	if err != nil {
+		t.Fatalf("Could not start the UDP prober: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		dataPoint := <-dataPoints
+		if dataPoint.Lost || dataPoint.Duration <= 0 {
+			t.Fatalf("UDP probe over loopback should not be lost: %v", dataPoint)
+		}
+	}
+	cancel()
+	for range dataPoints {
+	}
+}
+
+func TestUDPProberKernelTimestamps(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Kernel timestamps are only available on Linux.")
+	}
+	echoConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen for UDP echoes: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go UDPEcho(ctx, echoConn)
+
+	dataPoints, err := UDPProber(
+		ctx,
+		echoConn.LocalAddr().String(),
+		10*time.Millisecond,
+		time.Second,
+		true,
+		debug.NewDebugWithPrefix(debug.Error, "test"),
+	)
+	if err != nil {
+		t.Fatalf("Could not start the UDP prober: %v", err)
+	}
+	// The kernel turns receive timestamps on in the background, so the first echoes may arrive
+	// before it has (and be timed in userspace). Once one is timed by the kernel, all must be.
+	kernelTimed := 0
+	deadline := time.After(5 * time.Second)
+	for kernelTimed < 5 {
+		select {
+		case dataPoint := <-dataPoints:
+			if dataPoint.Lost || dataPoint.Duration <= 0 {
+				t.Fatalf("UDP probe over loopback should not be lost: %v", dataPoint)
+			}
+			if dataPoint.TimestampSource == KernelTimestamps {
+				kernelTimed++
+			} else if kernelTimed > 0 {
+				t.Fatalf("UDP probe over loopback should have been timed by the kernel: %v", dataPoint)
+			}
+		case <-deadline:
+			t.Fatalf("No UDP probe over loopback was timed by the kernel.")
+		}
+	}
+	cancel()
+	for range dataPoints {
+	}
+}
@@ -0,0 +1,43 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package prometheus
+
+import "sort"
+
+// The upper bounds (in seconds) of the buckets of the RTT histograms.
+var DefaultRttBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// The observations of a histogram, counted (cumulatively) in buckets with the given upper
+// bounds. The +Inf bucket is implicit: it holds all Count of them.
+type HistogramValue struct {
+	UpperBounds []float64 `json:"upper_bounds"`
+	Counts      []uint64  `json:"counts"`
+	Sum         float64   `json:"sum"`
+	Count       uint64    `json:"count"`
+}
+
+func NewHistogramValue(upperBounds []float64) *HistogramValue {
+	sorted := append([]float64(nil), upperBounds...)
+	sort.Float64s(sorted)
+	return &HistogramValue{UpperBounds: sorted, Counts: make([]uint64, len(sorted))}
+}
+
+func (h *HistogramValue) Observe(value float64) {
+	for i := len(h.UpperBounds) - 1; i >= 0 && value <= h.UpperBounds[i]; i-- {
+		h.Counts[i]++
+	}
+	h.Sum += value
+	h.Count++
+}
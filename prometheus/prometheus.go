@@ -0,0 +1,155 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The types of metrics (see the Prometheus text exposition format) that we write.
+type MetricType string
+
+const (
+	Gauge     MetricType = "gauge"
+	Histogram MetricType = "histogram"
+)
+
+// A label (e.g., site, ISP or interface) that distinguishes the metrics of one client from
+// those of the others that report to the same Prometheus.
+type Label struct {
+	Name  string
+	Value string
+}
+
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Parse labels of the form name=value,name=value.
+func ParseLabels(specification string) ([]Label, error) {
+	labels := make([]Label, 0)
+	if specification == "" {
+		return labels, nil
+	}
+	for _, label := range strings.Split(specification, ",") {
+		name, value, found := strings.Cut(label, "=")
+		name = strings.TrimSpace(name)
+		if !found {
+			return nil, fmt.Errorf("prometheus label %q does not have the form name=value", label)
+		}
+		if !labelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("prometheus label name %q is invalid", name)
+		}
+		labels = append(labels, Label{Name: name, Value: value})
+	}
+	return labels, nil
+}
+
+// The labels, in the text format (e.g., {site="home",version="draft-02"}).
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	formatted := make([]string, 0, len(labels))
+	for _, label := range labels {
+		formatted = append(formatted, fmt.Sprintf(`%s="%s"`, label.Name, escaper.Replace(label.Value)))
+	}
+	return "{" + strings.Join(formatted, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// All of the samples of a metric (which differ only in their labels).
+type family struct {
+	name       string
+	help       string
+	metricType MetricType
+	samples    []string
+}
+
+// A Writer gathers metrics and writes them in the Prometheus text exposition format: each
+// metric once, with its HELP and TYPE, followed by all of its samples. Every sample has
+// the Writer's labels (before any labels of its own).
+type Writer struct {
+	labels   []Label
+	families []*family
+}
+
+func NewWriter(labels []Label) *Writer {
+	return &Writer{labels: labels}
+}
+
+func (w *Writer) family(name string, help string, metricType MetricType) *family {
+	for _, existing := range w.families {
+		if existing.name == name {
+			if existing.metricType != metricType {
+				panic(fmt.Sprintf("Metric %s is a %s, not a %s.", name, existing.metricType, metricType))
+			}
+			return existing
+		}
+	}
+	created := &family{name: name, help: help, metricType: metricType}
+	w.families = append(w.families, created)
+	return created
+}
+
+func (w *Writer) sample(f *family, name string, value float64, labels []Label) {
+	allLabels := append(append([]Label(nil), w.labels...), labels...)
+	f.samples = append(f.samples, fmt.Sprintf("%s%s %s\n", name, formatLabels(allLabels), formatValue(value)))
+}
+
+func (w *Writer) Gauge(name string, help string, value float64, labels ...Label) {
+	w.sample(w.family(name, help, Gauge), name, value, labels)
+}
+
+func (w *Writer) Histogram(name string, help string, histogram *HistogramValue, labels ...Label) {
+	f := w.family(name, help, Histogram)
+	for i, upperBound := range histogram.UpperBounds {
+		w.sample(f, name+"_bucket", float64(histogram.Counts[i]),
+			append(append([]Label(nil), labels...), Label{Name: "le", Value: formatValue(upperBound)}))
+	}
+	w.sample(f, name+"_bucket", float64(histogram.Count),
+		append(append([]Label(nil), labels...), Label{Name: "le", Value: "+Inf"}))
+	w.sample(f, name+"_sum", histogram.Sum, labels)
+	w.sample(f, name+"_count", float64(histogram.Count), labels)
+}
+
+func (w *Writer) WriteTo(destination io.Writer) (int64, error) {
+	var builder strings.Builder
+	helpEscaper := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	for _, f := range w.families {
+		builder.WriteString(fmt.Sprintf("# HELP %s %s\n", f.name, helpEscaper.Replace(f.help)))
+		builder.WriteString(fmt.Sprintf("# TYPE %s %s\n", f.name, f.metricType))
+		for _, sample := range f.samples {
+			builder.WriteString(sample)
+		}
+	}
+	written, err := io.WriteString(destination, builder.String())
+	return int64(written), err
+}
@@ -0,0 +1,67 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package prometheus
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels(`site=home, isp=Example "ISP"`)
+	if err != nil {
+		t.Fatalf("Could not parse the labels: %v", err)
+	}
+	if len(labels) != 2 || labels[1].Name != "isp" || labels[1].Value != `Example "ISP"` {
+		t.Fatalf("The labels were parsed incorrectly: %v", labels)
+	}
+	for _, invalid := range []string{"site", "1site=home", "__name__=x"} {
+		if _, err := ParseLabels(invalid); err == nil {
+			t.Fatalf("Labels %q should be invalid.", invalid)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	writer := NewWriter([]Label{{Name: "site", Value: "home"}})
+	writer.Gauge("test_rtt_seconds", "An RTT.", 0.5, Label{Name: "quantile", Value: "0.5"})
+	histogram := NewHistogramValue([]float64{1, 0.1})
+	for _, value := range []float64{0.05, 0.5, 2} {
+		histogram.Observe(value)
+	}
+	writer.Histogram("test_rtt_histogram_seconds", "RTTs.", histogram)
+	// Samples of the same metric are written together, however they were added.
+	writer.Gauge("test_rtt_seconds", "An RTT.", 0.9, Label{Name: "quantile", Value: "0.9"})
+
+	var output strings.Builder
+	if _, err := writer.WriteTo(&output); err != nil {
+		t.Fatalf("Could not write the metrics: %v", err)
+	}
+	expected := `# HELP test_rtt_seconds An RTT.
+# TYPE test_rtt_seconds gauge
+test_rtt_seconds{site="home",quantile="0.5"} 0.5
+test_rtt_seconds{site="home",quantile="0.9"} 0.9
+# HELP test_rtt_histogram_seconds RTTs.
+# TYPE test_rtt_histogram_seconds histogram
+test_rtt_histogram_seconds_bucket{site="home",le="0.1"} 1
+test_rtt_histogram_seconds_bucket{site="home",le="1"} 2
+test_rtt_histogram_seconds_bucket{site="home",le="+Inf"} 3
+test_rtt_histogram_seconds_sum{site="home"} 2.55
+test_rtt_histogram_seconds_count{site="home"} 3
+`
+	if output.String() != expected {
+		t.Fatalf("The metrics should be\n%s\nbut are\n%s", expected, output.String())
+	}
+}
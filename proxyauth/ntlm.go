@@ -0,0 +1,205 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package proxyauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// The parts of NTLM (see [MS-NLMP]) that a client needs to authenticate with NTLMv2.
+
+const (
+	ntlmNegotiateUnicode                 uint32 = 0x00000001
+	ntlmNegotiateOEM                     uint32 = 0x00000002
+	ntlmRequestTarget                    uint32 = 0x00000004
+	ntlmNegotiateNTLM                    uint32 = 0x00000200
+	ntlmNegotiateAlwaysSign              uint32 = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity uint32 = 0x00080000
+	ntlmNegotiateTargetInfo              uint32 = 0x00800000
+	ntlmNegotiate128                     uint32 = 0x20000000
+	ntlmNegotiate56                      uint32 = 0x80000000
+
+	ntlmNegotiateFlags = ntlmNegotiateUnicode | ntlmNegotiateOEM | ntlmRequestTarget |
+		ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSessionSecurity |
+		ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+
+	// The AV pair in the target info that holds the server's time.
+	ntlmAvTimestamp uint16 = 7
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// Split a user name of the form DOMAIN\user into its domain and user.
+func splitDomainUser(username string) (string, string) {
+	if domain, user, found := strings.Cut(username, `\`); found {
+		return domain, user
+	}
+	return "", username
+}
+
+func toUTF16(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	result := make([]byte, 2*len(encoded))
+	for i, code := range encoded {
+		binary.LittleEndian.PutUint16(result[2*i:], code)
+	}
+	return result
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func ntowfv2(domain string, user string, password string) []byte {
+	passwordHash := md4(toUTF16(password))
+	return hmacMD5(passwordHash[:], toUTF16(strings.ToUpper(user)+domain))
+}
+
+// The first message: the client's capabilities.
+func ntlmNegotiateMessage() []byte {
+	message := make([]byte, 32)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 1)
+	binary.LittleEndian.PutUint32(message[12:], ntlmNegotiateFlags)
+	// The (empty) domain and workstation fields are all zeros.
+	return message
+}
+
+// The second message: the server's challenge.
+type ntlmChallenge struct {
+	flags           uint32
+	serverChallenge []byte
+	targetInfo      []byte
+}
+
+func parseNTLMChallenge(message []byte) (ntlmChallenge, error) {
+	if len(message) < 32 || !bytes.Equal(message[:8], ntlmSignature) ||
+		binary.LittleEndian.Uint32(message[8:]) != 2 {
+		return ntlmChallenge{}, fmt.Errorf("not an NTLM challenge message")
+	}
+	challenge := ntlmChallenge{
+		flags:           binary.LittleEndian.Uint32(message[20:]),
+		serverChallenge: message[24:32],
+	}
+	if len(message) >= 48 {
+		length := int(binary.LittleEndian.Uint16(message[40:]))
+		offset := int(binary.LittleEndian.Uint32(message[44:]))
+		if offset+length > len(message) {
+			return ntlmChallenge{}, fmt.Errorf("NTLM challenge message has an invalid target info")
+		}
+		challenge.targetInfo = message[offset : offset+length]
+	}
+	return challenge, nil
+}
+
+// The server's time from the target info (if it is there).
+func (challenge ntlmChallenge) timestamp() ([]byte, bool) {
+	for info := challenge.targetInfo; len(info) >= 4; {
+		id := binary.LittleEndian.Uint16(info)
+		length := int(binary.LittleEndian.Uint16(info[2:]))
+		if len(info) < 4+length {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return info[4:12], true
+		}
+		info = info[4+length:]
+	}
+	return nil, false
+}
+
+// The NTLMv2 responses to a challenge (see [MS-NLMP] 3.3.2).
+func ntlmv2Responses(
+	responseKey []byte,
+	challenge ntlmChallenge,
+	clientChallenge []byte,
+	timestamp []byte,
+) (ntResponse []byte, lmResponse []byte) {
+	temp := []byte{0x01, 0x01, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, challenge.targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	ntProof := hmacMD5(responseKey, challenge.serverChallenge, temp)
+	ntResponse = append(ntProof, temp...)
+	lmResponse = append(hmacMD5(responseKey, challenge.serverChallenge, clientChallenge), clientChallenge...)
+	return
+}
+
+// Convert a time into Windows' FILETIME (100ns intervals since January 1, 1601).
+func fileTime(t time.Time) []byte {
+	const epochDifference = 116444736000000000
+	result := make([]byte, 8)
+	binary.LittleEndian.PutUint64(result, uint64(t.UnixNano()/100+epochDifference))
+	return result
+}
+
+// The third message: the client's response to the challenge.
+func ntlmAuthenticateMessage(username string, password string, challengeMessage []byte) ([]byte, error) {
+	challenge, err := parseNTLMChallenge(challengeMessage)
+	if err != nil {
+		return nil, err
+	}
+	domain, user := splitDomainUser(username)
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	timestamp, ok := challenge.timestamp()
+	if !ok {
+		timestamp = fileTime(time.Now())
+	}
+	ntResponse, lmResponse := ntlmv2Responses(ntowfv2(domain, user, password), challenge, clientChallenge, timestamp)
+	if ok {
+		// When the server gives its time, the LM response must be empty (zeros).
+		lmResponse = make([]byte, 24)
+	}
+
+	encode := toUTF16
+	flags := ntlmNegotiateFlags &^ ntlmNegotiateOEM
+	if challenge.flags&ntlmNegotiateUnicode == 0 {
+		encode = func(s string) []byte { return []byte(s) }
+		flags = ntlmNegotiateFlags &^ ntlmNegotiateUnicode
+	}
+
+	fields := [][]byte{lmResponse, ntResponse, encode(domain), encode(user), encode(""), {}}
+	message := make([]byte, 64)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 3)
+	for i, field := range fields {
+		// Each field is described by a "security buffer": its length (twice) and its offset.
+		binary.LittleEndian.PutUint16(message[12+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(message[14+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(message[16+8*i:], uint32(len(message)))
+		message = append(message, field...)
+	}
+	binary.LittleEndian.PutUint32(message[60:], flags)
+	return message, nil
+}
@@ -0,0 +1,168 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package proxyauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestMD4(t *testing.T) {
+	// From RFC 1320.
+	for message, expected := range map[string]string{
+		"":    "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc": "a448017aaf21d8525fc10ae87aa6729d",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	} {
+		digest := md4([]byte(message))
+		if actual := hex.EncodeToString(digest[:]); actual != expected {
+			t.Fatalf("MD4 of %q should be %s but is %s.", message, expected, actual)
+		}
+	}
+}
+
+func TestNTLMv2Response(t *testing.T) {
+	// From [MS-NLMP] 4.2.4.
+	responseKey := ntowfv2("Domain", "User", "Password")
+	if actual := hex.EncodeToString(responseKey); actual != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Fatalf("NTOWFv2 should be 0c868a403bfd7a93a3001ef22ef02e3f but is %s.", actual)
+	}
+
+	targetInfo := []byte{0x02, 0x00, 0x0c, 0x00}
+	targetInfo = append(targetInfo, toUTF16("Domain")...)
+	targetInfo = append(targetInfo, 0x01, 0x00, 0x0c, 0x00)
+	targetInfo = append(targetInfo, toUTF16("Server")...)
+	targetInfo = append(targetInfo, 0x00, 0x00, 0x00, 0x00)
+	challenge := ntlmChallenge{
+		serverChallenge: []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		targetInfo:      targetInfo,
+	}
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+	ntResponse, lmResponse := ntlmv2Responses(responseKey, challenge, clientChallenge, make([]byte, 8))
+	if actual := hex.EncodeToString(ntResponse[:16]); actual != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Fatalf("NTProofStr should be 68cd0ab851e51c96aabc927bebef6a1c but is %s.", actual)
+	}
+	if actual := hex.EncodeToString(lmResponse); actual != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Fatalf("LMv2 response should be 86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa but is %s.", actual)
+	}
+}
+
+// A proxy that requires NTLM authentication and then echoes whatever is sent through the tunnel.
+func ntlmProxy(listener net.Listener, closeAfterFirstChallenge bool) {
+	challenge := make([]byte, 48)
+	copy(challenge, ntlmSignature)
+	binary.LittleEndian.PutUint32(challenge[8:], 2)
+	binary.LittleEndian.PutUint32(challenge[20:], ntlmNegotiateFlags)
+	copy(challenge[24:], "CHALLENG")
+	binary.LittleEndian.PutUint32(challenge[44:], 48)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				request, err := http.ReadRequest(reader)
+				if err != nil {
+					return
+				}
+				authorization := request.Header.Get("Proxy-Authorization")
+				switch {
+				case authorization == "":
+					if closeAfterFirstChallenge {
+						conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: NTLM\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+						return
+					}
+					conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: NTLM\r\nContent-Length: 4\r\n\r\nnope"))
+				case bytes.HasPrefix([]byte(authorization), []byte("NTLM ")):
+					message, _ := base64.StdEncoding.DecodeString(authorization[5:])
+					switch binary.LittleEndian.Uint32(message[8:]) {
+					case 1:
+						conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: NTLM " +
+							base64.StdEncoding.EncodeToString(challenge) + "\r\nContent-Length: 0\r\n\r\n"))
+					case 3:
+						// Check the user name (the fourth security buffer).
+						length := binary.LittleEndian.Uint16(message[36:])
+						offset := binary.LittleEndian.Uint32(message[40:])
+						if !bytes.Equal(message[offset:offset+uint32(length)], toUTF16("user")) {
+							conn.Write([]byte("HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n"))
+							return
+						}
+						conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+						buffer := make([]byte, 5)
+						if _, err := reader.Read(buffer); err == nil {
+							conn.Write(buffer)
+						}
+						return
+					}
+				}
+			}
+		}(conn)
+	}
+}
+
+func TestConnectNTLM(t *testing.T) {
+	for _, closeAfterFirstChallenge := range []bool{false, true} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Could not listen: %v", err)
+		}
+		defer listener.Close()
+		go ntlmProxy(listener, closeAfterFirstChallenge)
+
+		proxyURL, _ := url.Parse("http://DOMAIN%5Cuser:password@" + listener.Addr().String())
+		dial := func(ctx context.Context) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "tcp", listener.Addr().String())
+		}
+		conn, err := Connect(context.Background(), dial, proxyURL, "example.com:443")
+		if err != nil {
+			t.Fatalf("Could not connect through the proxy (closing after the first challenge: %v): %v", closeAfterFirstChallenge, err)
+		}
+		conn.Write([]byte("hello"))
+		buffer := make([]byte, 5)
+		if _, err := conn.Read(buffer); err != nil || string(buffer) != "hello" {
+			t.Fatalf("Tunnel did not carry data: %q (%v)", buffer, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestConnectWithoutCredentials(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	defer listener.Close()
+	go ntlmProxy(listener, false)
+
+	proxyURL, _ := url.Parse("http://" + listener.Addr().String())
+	dial := func(ctx context.Context) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", listener.Addr().String())
+	}
+	if _, err := Connect(context.Background(), dial, proxyURL, "example.com:443"); err == nil {
+		t.Fatalf("Connecting through an NTLM proxy without credentials should fail.")
+	}
+}
@@ -0,0 +1,142 @@
+// Exports the empirical distribution of a quality attenuation so that it can be composed
+// and analyzed by tools for the quality attenuation framework of TR-452.1.
+
+package qualityattenuation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+)
+
+// A bucket of an exported distribution: the number of samples with a latency (in seconds)
+// above the bound of the previous bucket and at most UpperBound.
+type Bucket struct {
+	UpperBound float64 `json:"upper_bound"`
+	// Interpolated from the empirical distribution, so not necessarily a whole number.
+	Count float64 `json:"count"`
+	// The share of all the samples (the lost ones included) with a latency of at most
+	// UpperBound. Like the (improper) CDFs of TR-452.1, it falls short of 1 by the loss.
+	CumulativeProbability float64 `json:"cumulative_probability"`
+}
+
+// The empirical distribution of a quality attenuation.
+type Distribution struct {
+	Samples int64 `json:"samples"`
+	Losses  int64 `json:"losses"`
+	// Samples with a latency (in seconds) above this threshold were counted as losses.
+	LatencyEqLossThreshold float64  `json:"latency_eq_loss_threshold"`
+	Buckets                []Bucket `json:"buckets"`
+}
+
+// Generate boundaries that are evenly spaced on a logarithmic scale, with perDecade of them
+// in every decade, from minimum up to (and including) maximum.
+func LogBucketBoundaries(minimum, maximum float64, perDecade int) []float64 {
+	boundaries := make([]float64, 0)
+	if minimum <= 0 || maximum < minimum || perDecade <= 0 {
+		return boundaries
+	}
+	for i := 0; ; i++ {
+		bound := minimum * math.Pow(10, float64(i)/float64(perDecade))
+		if bound >= maximum*(1-1e-9) {
+			break
+		}
+		boundaries = append(boundaries, bound)
+	}
+	return append(boundaries, maximum)
+}
+
+// Generate boundaries that are width apart from minimum up to (and including) maximum.
+func LinearBucketBoundaries(minimum, maximum float64, width float64) []float64 {
+	boundaries := make([]float64, 0)
+	if maximum < minimum || width <= 0 {
+		return boundaries
+	}
+	for i := 0; ; i++ {
+		bound := minimum + float64(i)*width
+		if bound >= maximum-width*1e-9 {
+			break
+		}
+		boundaries = append(boundaries, bound)
+	}
+	return append(boundaries, maximum)
+}
+
+// Export the distribution of the latencies in buckets with the given (ascending) upper
+// bounds. Without boundaries, the buckets are those of the options of the quality attenuation
+// (see DistributionOptions). There is always a last bucket up to that threshold so that
+// every sample that was not lost is counted.
+func (qa *SimpleQualityAttenuation) Distribution(boundaries []float64) Distribution {
+	if len(boundaries) == 0 {
+		boundaries = qa.options.bucketBoundaries(qa.latencyEqLossThreshold)
+	}
+	if boundaries[len(boundaries)-1] < qa.latencyEqLossThreshold {
+		boundaries = append(boundaries[:len(boundaries):len(boundaries)], qa.latencyEqLossThreshold)
+	}
+
+	distribution := Distribution{
+		Samples:                qa.numberOfSamples,
+		Losses:                 qa.numberOfLosses,
+		LatencyEqLossThreshold: qa.latencyEqLossThreshold,
+		Buckets:                make([]Bucket, 0, len(boundaries)),
+	}
+	received := float64(qa.numberOfSamples - qa.numberOfLosses)
+	previous := 0.0
+	for _, bound := range boundaries {
+		if bound > qa.latencyEqLossThreshold {
+			break
+		}
+		cumulative := 0.0
+		if received > 0 {
+			cumulative = qa.empiricalDistribution.CDF(bound) * received
+		}
+		bucket := Bucket{UpperBound: bound, Count: cumulative - previous}
+		if qa.numberOfSamples > 0 {
+			bucket.CumulativeProbability = cumulative / float64(qa.numberOfSamples)
+		}
+		distribution.Buckets = append(distribution.Buckets, bucket)
+		previous = cumulative
+	}
+	return distribution
+}
+
+func (d Distribution) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+// Write the buckets as CSV, one per row. The lost samples, which belong to no bucket, are
+// in a last row with an infinite upper bound.
+func (d Distribution) WriteCSV(w io.Writer) error {
+	format := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"upper_bound", "count", "cumulative_probability"}); err != nil {
+		return err
+	}
+	for _, bucket := range d.Buckets {
+		if err := writer.Write([]string{
+			format(bucket.UpperBound),
+			format(bucket.Count),
+			format(bucket.CumulativeProbability),
+		}); err != nil {
+			return err
+		}
+	}
+	lossProbability := 0.0
+	if d.Samples > 0 {
+		lossProbability = 1
+	}
+	if err := writer.Write([]string{
+		"+Inf", strconv.FormatInt(d.Losses, 10), format(lossProbability),
+	}); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
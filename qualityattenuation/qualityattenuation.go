@@ -19,20 +19,83 @@ type SimpleQualityAttenuation struct {
 	latencyEqLossThreshold float64
 	minimumLatency         float64
 	maximumLatency         float64
+	options                DistributionOptions
+}
+
+// How precisely the empirical distribution is kept and how it is bucketed when it is exported
+// (see Distribution).
+type DistributionOptions struct {
+	// The compression of the t-digest that approximates the distribution; a greater compression
+	// keeps more detail (in more memory).
+	Compression float64 `json:"compression"`
+	// The upper bound (in seconds) of the first bucket. The bounds of the buckets go from here up
+	// to the latency-equals-loss threshold.
+	MinimumBucketBound float64 `json:"minimum_bucket_bound"`
+	// The width (in seconds) of every bucket when it is not 0; otherwise, the buckets are spaced
+	// logarithmically, with BucketsPerDecade of them in every decade.
+	BucketWidth      float64 `json:"bucket_width"`
+	BucketsPerDecade int     `json:"buckets_per_decade"`
+}
+
+func DefaultDistributionOptions() DistributionOptions {
+	return DistributionOptions{
+		Compression:        50,
+		MinimumBucketBound: 0.001,
+		BucketsPerDecade:   10,
+	}
+}
+
+func (options DistributionOptions) Validate() error {
+	if options.Compression <= 0 {
+		return fmt.Errorf("the compression must be greater than 0")
+	}
+	if options.MinimumBucketBound <= 0 {
+		return fmt.Errorf("the minimum bucket bound must be greater than 0")
+	}
+	if options.BucketWidth < 0 {
+		return fmt.Errorf("the bucket width must not be negative")
+	}
+	if options.BucketWidth == 0 && options.BucketsPerDecade <= 0 {
+		return fmt.Errorf("there must be a bucket width or at least one bucket per decade")
+	}
+	return nil
+}
+
+// The bounds of the buckets up to the latency-equals-loss threshold.
+func (options DistributionOptions) bucketBoundaries(latencyEqLossThreshold float64) []float64 {
+	if options.BucketWidth > 0 {
+		return LinearBucketBoundaries(options.MinimumBucketBound, latencyEqLossThreshold, options.BucketWidth)
+	}
+	return LogBucketBoundaries(options.MinimumBucketBound, latencyEqLossThreshold, options.BucketsPerDecade)
 }
 
-func NewSimpleQualityAttenuation() *SimpleQualityAttenuation {
+// Samples with a latency (in seconds) greater than latencyEqLossThreshold count as losses.
+func NewSimpleQualityAttenuation(latencyEqLossThreshold float64) *SimpleQualityAttenuation {
+	qa, _ := NewSimpleQualityAttenuationWithOptions(latencyEqLossThreshold, DefaultDistributionOptions())
+	return qa
+}
+
+// Like NewSimpleQualityAttenuation, but with a distribution that is kept and exported
+// according to the options.
+func NewSimpleQualityAttenuationWithOptions(
+	latencyEqLossThreshold float64,
+	options DistributionOptions,
+) (*SimpleQualityAttenuation, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
 	return &SimpleQualityAttenuation{
-		empiricalDistribution:  tdigest.NewWithCompression(50),
+		empiricalDistribution:  tdigest.NewWithCompression(options.Compression),
 		offset:                 0.1,
 		offsetSum:              0.0,
 		offsetSumOfSquares:     0.0,
 		numberOfSamples:        0,
 		numberOfLosses:         0,
-		latencyEqLossThreshold: 15.0, // Count latency greater than this value as a loss.
+		latencyEqLossThreshold: latencyEqLossThreshold,
 		minimumLatency:         0.0,
 		maximumLatency:         0.0,
-	}
+		options:                options,
+	}, nil
 }
 
 func (qa *SimpleQualityAttenuation) AddSample(sample float64) error {
@@ -59,6 +122,12 @@ func (qa *SimpleQualityAttenuation) AddSample(sample float64) error {
 	return nil
 }
 
+// Record a sample that was lost outright (e.g., a probe that timed out).
+func (qa *SimpleQualityAttenuation) AddLoss() {
+	qa.numberOfSamples++
+	qa.numberOfLosses++
+}
+
 func (qa *SimpleQualityAttenuation) GetNumberOfLosses() int64 {
 	return qa.numberOfLosses
 }
@@ -126,10 +195,11 @@ func (qa *SimpleQualityAttenuation) Merge(other *SimpleQualityAttenuation) error
 	qa.offsetSumOfSquares += other.offsetSumOfSquares
 	qa.numberOfSamples += other.numberOfSamples
 	qa.numberOfLosses += other.numberOfLosses
-	if other.minimumLatency < qa.minimumLatency {
+	// A minimum (or maximum) of 0 means that there were no latencies at all.
+	if other.minimumLatency != 0.0 && (qa.minimumLatency == 0.0 || other.minimumLatency < qa.minimumLatency) {
 		qa.minimumLatency = other.minimumLatency
 	}
-	if other.maximumLatency > qa.maximumLatency {
+	if other.maximumLatency != 0.0 && (qa.maximumLatency == 0.0 || other.maximumLatency > qa.maximumLatency) {
 		qa.maximumLatency = other.maximumLatency
 	}
 	return nil
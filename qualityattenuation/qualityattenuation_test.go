@@ -1,13 +1,15 @@
 package qualityattenuation
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBasicSimpleQualityAttenuation(t *testing.T) {
-	qa := NewSimpleQualityAttenuation()
+	qa := NewSimpleQualityAttenuation(15.0)
 	qa.AddSample(1.0)
 	qa.AddSample(2.0)
 	qa.AddSample(3.0)
@@ -38,7 +40,7 @@ func TestBasicSimpleQualityAttenuation(t *testing.T) {
 }
 
 func TestManySamples(t *testing.T) {
-	qa := NewSimpleQualityAttenuation()
+	qa := NewSimpleQualityAttenuation(15.0)
 	for i := 1; i < 160000; i++ {
 		qa.AddSample(float64(i) / 10000.0) //Linear ramp from 0.0001 to 16.0
 	}
@@ -63,3 +65,127 @@ func TestManySamples(t *testing.T) {
 	assert.InEpsilon(t, 6.249414, qa.GetLossPercentage(), 0.000001)
 	assert.InEpsilon(t, 7.999947, qa.GetRPM(), 0.000001)
 }
+
+func TestAddLoss(t *testing.T) {
+	qa := NewSimpleQualityAttenuation(15.0)
+	qa.AddSample(1.0)
+	qa.AddSample(3.0)
+	qa.AddLoss()
+	assert.Equal(t, qa.GetNumberOfSamples(), int64(3))
+	assert.Equal(t, qa.GetNumberOfLosses(), int64(1))
+	assert.InEpsilon(t, 2.0, qa.GetAverage(), 0.000001)
+}
+
+func TestDistribution(t *testing.T) {
+	qa := NewSimpleQualityAttenuation(15.0)
+	for _, sample := range []float64{0.01, 0.02, 0.03, 0.04, 20.0} {
+		qa.AddSample(sample)
+	}
+	qa.AddLoss()
+
+	distribution := qa.Distribution([]float64{0.005, 0.05})
+	assert.Equal(t, int64(6), distribution.Samples)
+	assert.Equal(t, int64(2), distribution.Losses)
+	// The last bucket reaches the latency-equals-loss threshold.
+	assert.Len(t, distribution.Buckets, 3)
+	assert.Equal(t, 15.0, distribution.Buckets[2].UpperBound)
+	assert.InDelta(t, 0.0, distribution.Buckets[0].Count, 0.000001)
+	assert.InDelta(t, 4.0, distribution.Buckets[1].Count, 0.000001)
+	assert.InDelta(t, 0.0, distribution.Buckets[2].Count, 0.000001)
+	assert.InDelta(t, 4.0/6.0, distribution.Buckets[2].CumulativeProbability, 0.000001)
+
+	var csvOutput strings.Builder
+	assert.NoError(t, distribution.WriteCSV(&csvOutput))
+	lines := strings.Split(strings.TrimSpace(csvOutput.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Equal(t, "upper_bound,count,cumulative_probability", lines[0])
+	assert.Equal(t, "+Inf,2,1", lines[4])
+
+	var jsonOutput strings.Builder
+	assert.NoError(t, distribution.WriteJSON(&jsonOutput))
+	assert.Contains(t, jsonOutput.String(), "\"latency_eq_loss_threshold\": 15")
+}
+
+func TestLogBucketBoundaries(t *testing.T) {
+	boundaries := LogBucketBoundaries(0.001, 1, 1)
+	assert.Len(t, boundaries, 4)
+	assert.InEpsilon(t, 0.01, boundaries[1], 0.000001)
+	assert.Equal(t, 1.0, boundaries[3])
+	assert.Empty(t, LogBucketBoundaries(0, 1, 1))
+}
+
+func TestLatencyEqLossThreshold(t *testing.T) {
+	qa := NewSimpleQualityAttenuation(30.0)
+	qa.AddSample(0.6)
+	qa.AddSample(20.0)
+	qa.AddSample(31.0)
+	assert.Equal(t, int64(3), qa.GetNumberOfSamples())
+	assert.Equal(t, int64(1), qa.GetNumberOfLosses())
+	assert.InEpsilon(t, 20.0, qa.GetMaximum(), 0.000001)
+
+	// Quality attenuations that count losses differently cannot be merged.
+	assert.Error(t, qa.Merge(NewSimpleQualityAttenuation(15.0)))
+}
+
+func TestDistributionOptions(t *testing.T) {
+	options := DefaultDistributionOptions()
+	options.Compression = 200
+	options.MinimumBucketBound = 0.0001
+	options.BucketWidth = 0.0001
+	qa, err := NewSimpleQualityAttenuationWithOptions(0.001, options)
+	assert.NoError(t, err)
+	for _, sample := range []float64{0.00015, 0.00025, 0.00035, 0.00045} {
+		qa.AddSample(sample)
+	}
+
+	// Sub-millisecond samples land in buckets of their own.
+	distribution := qa.Distribution(nil)
+	assert.Len(t, distribution.Buckets, 10)
+	assert.InEpsilon(t, 0.0002, distribution.Buckets[1].UpperBound, 0.000001)
+	assert.InDelta(t, 1.0, distribution.Buckets[9].CumulativeProbability, 0.000001)
+
+	options.BucketWidth = 0
+	options.BucketsPerDecade = 0
+	_, err = NewSimpleQualityAttenuationWithOptions(15.0, options)
+	assert.Error(t, err)
+}
+
+func TestLinearBucketBoundaries(t *testing.T) {
+	boundaries := LinearBucketBoundaries(0.01, 0.05, 0.02)
+	assert.Len(t, boundaries, 3)
+	assert.InEpsilon(t, 0.03, boundaries[1], 0.000001)
+	assert.Equal(t, 0.05, boundaries[2])
+	assert.Empty(t, LinearBucketBoundaries(0.01, 0.05, 0))
+}
+
+func TestMergeSerialized(t *testing.T) {
+	first := NewSimpleQualityAttenuation(15.0)
+	first.AddSample(1.0)
+	first.AddSample(2.0)
+	first.AddLoss()
+	second := NewSimpleQualityAttenuation(15.0)
+	second.AddSample(3.0)
+
+	// The quality attenuation of an earlier run comes back from its JSON.
+	serialized, err := json.Marshal(first)
+	assert.NoError(t, err)
+	restored := &SimpleQualityAttenuation{}
+	assert.NoError(t, json.Unmarshal(serialized, restored))
+	assert.Equal(t, int64(3), restored.GetNumberOfSamples())
+	assert.InEpsilon(t, 1.5, restored.GetAverage(), 0.000001)
+
+	assert.NoError(t, restored.Merge(second))
+	assert.Equal(t, int64(4), restored.GetNumberOfSamples())
+	assert.Equal(t, int64(1), restored.GetNumberOfLosses())
+	assert.InEpsilon(t, 1.0, restored.GetMinimum(), 0.000001)
+	assert.InEpsilon(t, 3.0, restored.GetMaximum(), 0.000001)
+	assert.InEpsilon(t, 2.0, restored.GetAverage(), 0.000001)
+	assert.InEpsilon(t, 2.0, restored.GetMedian(), 0.000001)
+
+	// Merging into a quality attenuation without samples keeps the minimum of the other.
+	empty := NewSimpleQualityAttenuation(15.0)
+	assert.NoError(t, empty.Merge(second))
+	assert.InEpsilon(t, 3.0, empty.GetMinimum(), 0.000001)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"latency_eq_loss_threshold": 15}`), restored))
+}
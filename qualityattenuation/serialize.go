@@ -0,0 +1,89 @@
+// Serializes quality attenuations so that the ones of different runs (or of different streams
+// of probes) can be merged later.
+
+package qualityattenuation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/influxdata/tdigest"
+)
+
+// A centroid of the t-digest that approximates the empirical distribution.
+type serializedCentroid struct {
+	Mean   float64 `json:"mean"`
+	Weight float64 `json:"weight"`
+}
+
+// Everything that there is to a SimpleQualityAttenuation, so that unmarshaling it gives back
+// one that merges exactly like the original.
+type serializedQualityAttenuation struct {
+	Offset                 float64              `json:"offset"`
+	OffsetSum              float64              `json:"offset_sum"`
+	OffsetSumOfSquares     float64              `json:"offset_sum_of_squares"`
+	NumberOfSamples        int64                `json:"samples"`
+	NumberOfLosses         int64                `json:"losses"`
+	LatencyEqLossThreshold float64              `json:"latency_eq_loss_threshold"`
+	MinimumLatency         float64              `json:"minimum_latency"`
+	MaximumLatency         float64              `json:"maximum_latency"`
+	Options                DistributionOptions  `json:"options"`
+	Centroids              []serializedCentroid `json:"centroids"`
+}
+
+func (qa *SimpleQualityAttenuation) MarshalJSON() ([]byte, error) {
+	serialized := serializedQualityAttenuation{
+		Offset:                 qa.offset,
+		OffsetSum:              qa.offsetSum,
+		OffsetSumOfSquares:     qa.offsetSumOfSquares,
+		NumberOfSamples:        qa.numberOfSamples,
+		NumberOfLosses:         qa.numberOfLosses,
+		LatencyEqLossThreshold: qa.latencyEqLossThreshold,
+		MinimumLatency:         qa.minimumLatency,
+		MaximumLatency:         qa.maximumLatency,
+		Options:                qa.options,
+		Centroids:              make([]serializedCentroid, 0),
+	}
+	for _, centroid := range qa.empiricalDistribution.Centroids() {
+		serialized.Centroids = append(
+			serialized.Centroids,
+			serializedCentroid{Mean: centroid.Mean, Weight: centroid.Weight},
+		)
+	}
+	return json.Marshal(serialized)
+}
+
+func (qa *SimpleQualityAttenuation) UnmarshalJSON(data []byte) error {
+	serialized := serializedQualityAttenuation{}
+	if err := json.Unmarshal(data, &serialized); err != nil {
+		return err
+	}
+	if err := serialized.Options.Validate(); err != nil {
+		return fmt.Errorf("invalid quality attenuation: %v", err)
+	}
+	if serialized.LatencyEqLossThreshold <= 0 {
+		return fmt.Errorf("invalid quality attenuation: the latency-equals-loss threshold must be greater than 0")
+	}
+	if serialized.NumberOfLosses < 0 || serialized.NumberOfLosses > serialized.NumberOfSamples {
+		return fmt.Errorf("invalid quality attenuation: %d losses of %d samples",
+			serialized.NumberOfLosses, serialized.NumberOfSamples)
+	}
+
+	empiricalDistribution := tdigest.NewWithCompression(serialized.Options.Compression)
+	for _, centroid := range serialized.Centroids {
+		empiricalDistribution.Add(centroid.Mean, centroid.Weight)
+	}
+	*qa = SimpleQualityAttenuation{
+		empiricalDistribution:  empiricalDistribution,
+		offset:                 serialized.Offset,
+		offsetSum:              serialized.OffsetSum,
+		offsetSumOfSquares:     serialized.OffsetSumOfSquares,
+		numberOfSamples:        serialized.NumberOfSamples,
+		numberOfLosses:         serialized.NumberOfLosses,
+		latencyEqLossThreshold: serialized.LatencyEqLossThreshold,
+		minimumLatency:         serialized.MinimumLatency,
+		maximumLatency:         serialized.MaximumLatency,
+		options:                serialized.Options,
+	}
+	return nil
+}
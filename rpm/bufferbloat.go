@@ -0,0 +1,78 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"fmt"
+	"math"
+)
+
+// The grades of bufferbloat and the growth of latency under load (in seconds) that each one
+// stays below (the scale that the common bufferbloat tests use); more than the last is an F.
+var bufferbloatGrades = []struct {
+	grade           string
+	maximumIncrease float64
+}{
+	{"A+", 0.005},
+	{"A", 0.030},
+	{"B", 0.060},
+	{"C", 0.200},
+	{"D", 0.400},
+}
+
+// A letter grade (A+ to F) for how much latency grows (inflates) when the network is loaded:
+// a verdict on bufferbloat that does not take knowing what an RPM is.
+type BufferbloatGrade struct {
+	Grade string `json:"grade"`
+	// The P90 latencies (in seconds) that the idle and loaded RPMs are calculated from.
+	IdleLatency   float64 `json:"idle_latency_seconds"`
+	LoadedLatency float64 `json:"loaded_latency_seconds"`
+	Increase      float64 `json:"increase_seconds"`
+	// LoadedLatency as a multiple of IdleLatency.
+	Inflation float64 `json:"inflation"`
+}
+
+func (bg BufferbloatGrade) String() string {
+	return fmt.Sprintf(
+		"%s (latency grows by %.3f ms under load: %.3f ms idle, %.3f ms loaded, %.1fx)",
+		bg.Grade,
+		bg.Increase*1000,
+		bg.IdleLatency*1000,
+		bg.LoadedLatency*1000,
+		bg.Inflation,
+	)
+}
+
+// Grade the growth from idleLatency to loadedLatency (both in seconds). There is no grade
+// (false) without a (positive) idle latency to compare to.
+func GradeBufferbloat(idleLatency float64, loadedLatency float64) (BufferbloatGrade, bool) {
+	if !(idleLatency > 0) || math.IsInf(idleLatency, 0) || !(loadedLatency > 0) || math.IsInf(loadedLatency, 0) {
+		return BufferbloatGrade{}, false
+	}
+	grade := BufferbloatGrade{
+		Grade:         "F",
+		IdleLatency:   idleLatency,
+		LoadedLatency: loadedLatency,
+		Increase:      math.Max(loadedLatency-idleLatency, 0),
+		Inflation:     loadedLatency / idleLatency,
+	}
+	for _, candidate := range bufferbloatGrades {
+		if grade.Increase < candidate.maximumIncrease {
+			grade.Grade = candidate.grade
+			break
+		}
+	}
+	return grade, true
+}
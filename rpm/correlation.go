@@ -0,0 +1,143 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// A self probe's RTT paired with the throughput of the load-generating connections (in the
+// probe's direction) at the time that the probe was sent.
+type ThroughputRttPair struct {
+	Throughput float64
+	Rtt        float64
+}
+
+// Pair each self probe with the most recent throughput measurement taken before it was sent.
+// The throughput measurements must be in chronological order and should be in the same
+// direction as the probes. Probes sent before the first throughput measurement are left out.
+func PairThroughputAndRtt(
+	throughputs []ThroughputDataPoint,
+	probes []probe.ProbeDataPoint,
+) []ThroughputRttPair {
+	pairs := make([]ThroughputRttPair, 0)
+	for _, probeDataPoint := range probes {
+		// Find the first throughput measurement *after* the probe; the one before it is the one we want.
+		after := sort.Search(len(throughputs), func(i int) bool {
+			return throughputs[i].Time.After(probeDataPoint.Time)
+		})
+		if after == 0 {
+			continue
+		}
+		pairs = append(pairs, ThroughputRttPair{
+			Throughput: throughputs[after-1].Throughput,
+			Rtt:        probeDataPoint.Duration.Seconds(),
+		})
+	}
+	return pairs
+}
+
+// The (Pearson) correlation between throughput and RTT. There is no correlation when there are
+// fewer than two pairs or when either throughput or RTT never changes.
+func ThroughputRttCorrelation(pairs []ThroughputRttPair) (float64, bool) {
+	if len(pairs) < 2 {
+		return 0, false
+	}
+	throughputMean, rttMean := float64(0), float64(0)
+	for _, pair := range pairs {
+		throughputMean += pair.Throughput
+		rttMean += pair.Rtt
+	}
+	throughputMean /= float64(len(pairs))
+	rttMean /= float64(len(pairs))
+
+	covariance, throughputVariance, rttVariance := float64(0), float64(0), float64(0)
+	for _, pair := range pairs {
+		covariance += (pair.Throughput - throughputMean) * (pair.Rtt - rttMean)
+		throughputVariance += (pair.Throughput - throughputMean) * (pair.Throughput - throughputMean)
+		rttVariance += (pair.Rtt - rttMean) * (pair.Rtt - rttMean)
+	}
+	if throughputVariance == 0 || rttVariance == 0 {
+		return 0, false
+	}
+	return covariance / math.Sqrt(throughputVariance*rttVariance), true
+}
+
+// The RTTs of the self probes sent while throughput was in a range.
+type ThroughputRttBin struct {
+	LowThroughput  float64 `json:"low_bytes_per_second"`
+	HighThroughput float64 `json:"high_bytes_per_second"`
+	Probes         int     `json:"probes"`
+	RttP50         float64 `json:"rtt_p50_seconds"`
+	RttP90         float64 `json:"rtt_p90_seconds"`
+}
+
+func (bin ThroughputRttBin) String() string {
+	return fmt.Sprintf(
+		"%.3f - %.3f Mbps: %d probes, P50 %.3f ms, P90 %.3f ms",
+		utilities.ToMbps(bin.LowThroughput), utilities.ToMbps(bin.HighThroughput),
+		bin.Probes, bin.RttP50*1000, bin.RttP90*1000,
+	)
+}
+
+// Divide the range of throughputs into equal-width bins and summarize the RTTs in each.
+func BinThroughputAndRtt(pairs []ThroughputRttPair, binCount int) []ThroughputRttBin {
+	bins := make([]ThroughputRttBin, 0)
+	if len(pairs) == 0 || binCount < 1 {
+		return bins
+	}
+	low, high := pairs[0].Throughput, pairs[0].Throughput
+	for _, pair := range pairs {
+		low = math.Min(low, pair.Throughput)
+		high = math.Max(high, pair.Throughput)
+	}
+	width := (high - low) / float64(binCount)
+	if width == 0 {
+		// All the measurements are in a single bin.
+		binCount = 1
+	}
+
+	rtts := make([]ms.MathematicalSeries[float64], binCount)
+	for i := range rtts {
+		rtts[i] = ms.NewInfiniteMathematicalSeries[float64]()
+	}
+	for _, pair := range pairs {
+		bin := 0
+		if width > 0 {
+			bin = int((pair.Throughput - low) / width)
+		}
+		// The highest throughput belongs in the last bin rather than a bin of its own.
+		if bin >= binCount {
+			bin = binCount - 1
+		}
+		rtts[bin].AddElement(pair.Rtt)
+	}
+	for i := range rtts {
+		bins = append(bins, ThroughputRttBin{
+			LowThroughput:  low + width*float64(i),
+			HighThroughput: low + width*float64(i+1),
+			Probes:         rtts[i].Len(),
+			RttP50:         intervalPercentile(rtts[i], 50),
+			RttP90:         intervalPercentile(rtts[i], 90),
+		})
+	}
+	return bins
+}
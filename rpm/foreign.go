@@ -0,0 +1,120 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/probe"
+)
+
+// Whether (and how) foreign probes reuse connections. A new connection for every probe
+// measures the handshakes every time but puts the most connection-rate pressure on the
+// server; reused connections measure only the HTTP round trip.
+type ForeignProbeReuseMode int
+
+const (
+	// Every foreign probe makes a new connection.
+	NewForeignProbeConnections ForeignProbeReuseMode = iota
+	// Every Count foreign probes share a new connection.
+	NewForeignProbeConnectionEvery
+	// Foreign probes take turns on a dedicated pool of Count connections.
+	ForeignProbeConnectionPool
+)
+
+type ForeignProbeReuse struct {
+	Mode  ForeignProbeReuseMode
+	Count int
+}
+
+// Parse none, every:N or pool:N.
+func ParseForeignProbeReuse(reuse string) (ForeignProbeReuse, error) {
+	if reuse == "" || reuse == "none" {
+		return ForeignProbeReuse{Mode: NewForeignProbeConnections}, nil
+	}
+	mode, count, found := strings.Cut(reuse, ":")
+	n, err := strconv.Atoi(count)
+	if !found || err != nil || n < 1 {
+		return ForeignProbeReuse{}, fmt.Errorf("unrecognized foreign probe reuse policy: %s", reuse)
+	}
+	switch mode {
+	case "every":
+		return ForeignProbeReuse{Mode: NewForeignProbeConnectionEvery, Count: n}, nil
+	case "pool":
+		return ForeignProbeReuse{Mode: ForeignProbeConnectionPool, Count: n}, nil
+	}
+	return ForeignProbeReuse{}, fmt.Errorf("unrecognized foreign probe reuse policy: %s", reuse)
+}
+
+func (reuse ForeignProbeReuse) String() string {
+	switch reuse.Mode {
+	case NewForeignProbeConnectionEvery:
+		return fmt.Sprintf("every:%d", reuse.Count)
+	case ForeignProbeConnectionPool:
+		return fmt.Sprintf("pool:%d", reuse.Count)
+	}
+	return "none"
+}
+
+// Hands out the clients for a prober's foreign probes according to a reuse policy. A client
+// keeps its connection between probes (as long as the probes do not overlap), so sharing a
+// client is sharing a connection.
+type foreignProbeClients struct {
+	reuse     ForeignProbeReuse
+	keyLogger io.Writer
+	debugging *debug.DebugWithPrefix
+	count     int
+	clients   []*http.Client
+}
+
+func newForeignProbeClients(
+	reuse ForeignProbeReuse,
+	keyLogger io.Writer,
+	debugging *debug.DebugWithPrefix,
+) *foreignProbeClients {
+	return &foreignProbeClients{reuse: reuse, keyLogger: keyLogger, debugging: debugging}
+}
+
+func (fpc *foreignProbeClients) next(configuration probe.ProbeConfiguration) *http.Client {
+	defer func() { fpc.count++ }()
+
+	switch fpc.reuse.Mode {
+	case NewForeignProbeConnectionEvery:
+		if fpc.count%fpc.reuse.Count == 0 {
+			fpc.close()
+			fpc.clients = []*http.Client{generateForeignProbeClient(configuration, fpc.keyLogger, fpc.debugging)}
+		}
+		return fpc.clients[0]
+	case ForeignProbeConnectionPool:
+		if len(fpc.clients) < fpc.reuse.Count {
+			fpc.clients = append(fpc.clients, generateForeignProbeClient(configuration, fpc.keyLogger, fpc.debugging))
+		}
+		return fpc.clients[fpc.count%fpc.reuse.Count]
+	}
+	return generateForeignProbeClient(configuration, fpc.keyLogger, fpc.debugging)
+}
+
+// Close the (idle) connections of the clients that are held on to.
+func (fpc *foreignProbeClients) close() {
+	for _, client := range fpc.clients {
+		client.CloseIdleConnections()
+	}
+	fpc.clients = nil
+}
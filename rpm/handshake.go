@@ -0,0 +1,86 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/phase"
+)
+
+// The TCP handshake RTT of a foreign probe's (new) connection. Every foreign probe makes a
+// handshake, so together they form a continuous (and free) series of RTTs that, unlike
+// the probes' HTTP durations, include no server processing time.
+type HandshakeRttDataPoint struct {
+	Time  time.Time     `Description:"Time of the probe."      Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	RTT   time.Duration `Description:"TCP handshake RTT."      Formatter:"Seconds"`
+	Phase phase.Phase   `Description:"The phase of the test." Formatter:"String"`
+}
+
+// How much the handshake RTT grew over the course of a test.
+type HandshakeRttInflation struct {
+	Handshakes int `json:"handshakes"`
+	// The median RTTs (in seconds) of the first and last handshakes.
+	Early float64 `json:"early_seconds"`
+	Late  float64 `json:"late_seconds"`
+}
+
+func (hri HandshakeRttInflation) Inflation() float64 {
+	return hri.Late - hri.Early
+}
+
+func (hri HandshakeRttInflation) String() string {
+	percent := float64(0)
+	if hri.Early > 0 {
+		percent = hri.Inflation() / hri.Early * 100
+	}
+	return fmt.Sprintf(
+		"%.3f ms early, %.3f ms late (%+.3f ms, %+.1f%%; %d handshakes)",
+		hri.Early*1000,
+		hri.Late*1000,
+		hri.Inflation()*1000,
+		percent,
+		hri.Handshakes,
+	)
+}
+
+// Compare the median RTT of the first window handshakes with that of the last window
+// handshakes. When there are fewer than two windows' worth of handshakes, the windows
+// shrink so that they do not overlap.
+func CalculateHandshakeRttInflation(
+	dataPoints []HandshakeRttDataPoint,
+	window int,
+) (HandshakeRttInflation, bool) {
+	if len(dataPoints) < 2 || window < 1 {
+		return HandshakeRttInflation{}, false
+	}
+	if window > len(dataPoints)/2 {
+		window = len(dataPoints) / 2
+	}
+	median := func(dataPoints []HandshakeRttDataPoint) float64 {
+		rtts := ms.NewInfiniteMathematicalSeries[float64]()
+		for _, dataPoint := range dataPoints {
+			rtts.AddElement(dataPoint.RTT.Seconds())
+		}
+		return rtts.Percentile(50)
+	}
+	return HandshakeRttInflation{
+		Handshakes: len(dataPoints),
+		Early:      median(dataPoints[:window]),
+		Late:       median(dataPoints[len(dataPoints)-window:]),
+	}, true
+}
@@ -0,0 +1,69 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"time"
+
+	"github.com/network-quality/goresponsiveness/probe"
+)
+
+// ProbeTraffic accounts for the bytes that probes send and receive. Those bytes share the
+// network with the load-generating connections but are not part of their throughput, so
+// they are reported separately (and alongside it).
+type ProbeTraffic struct {
+	SentBytes     uint64
+	ReceivedBytes uint64
+
+	// Since the end of the previous interval in each direction.
+	intervalSentBytes     uint64
+	intervalReceivedBytes uint64
+	intervalSentStart     time.Time
+	intervalReceivedStart time.Time
+}
+
+func NewProbeTraffic(start time.Time) *ProbeTraffic {
+	return &ProbeTraffic{intervalSentStart: start, intervalReceivedStart: start}
+}
+
+func (pt *ProbeTraffic) Add(dataPoint probe.ProbeDataPoint) {
+	pt.SentBytes += dataPoint.SentBytes
+	pt.ReceivedBytes += dataPoint.ReceivedBytes
+	pt.intervalSentBytes += dataPoint.SentBytes
+	pt.intervalReceivedBytes += dataPoint.ReceivedBytes
+}
+
+// The throughput (in bytes per second) of what the probes sent since the last time that
+// this was called. Call it at the end of every upload throughput interval.
+func (pt *ProbeTraffic) SentThroughput(intervalEnd time.Time) float64 {
+	throughput := intervalThroughput(pt.intervalSentBytes, pt.intervalSentStart, intervalEnd)
+	pt.intervalSentBytes, pt.intervalSentStart = 0, intervalEnd
+	return throughput
+}
+
+// The throughput (in bytes per second) of what the probes received since the last time
+// that this was called. Call it at the end of every download throughput interval.
+func (pt *ProbeTraffic) ReceivedThroughput(intervalEnd time.Time) float64 {
+	throughput := intervalThroughput(pt.intervalReceivedBytes, pt.intervalReceivedStart, intervalEnd)
+	pt.intervalReceivedBytes, pt.intervalReceivedStart = 0, intervalEnd
+	return throughput
+}
+
+func intervalThroughput(bytes uint64, start time.Time, end time.Time) float64 {
+	if !end.After(start) {
+		return 0
+	}
+	return float64(bytes) / end.Sub(start).Seconds()
+}
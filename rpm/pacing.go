@@ -0,0 +1,99 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/phase"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// One step of a pacing experiment: the latency that probes saw while the load-generating
+// connections were paced at a fraction of the link's (estimated) capacity. Taken together,
+// the steps form a curve of RTT versus offered load that characterizes the bottleneck's
+// queue (and whatever AQM manages it).
+type PacingDataPoint struct {
+	Time               time.Time   `Description:"Time of the end of the step."                 Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000" json:"time"`
+	OfferedLoad        float64     `Description:"Offered load (relative to estimated capacity)." Units:"percent" json:"offered_load_percent"`
+	DownloadRate       float64     `Description:"Paced download rate."                         Units:"bytes per second" json:"download_rate_bytes_per_second"`
+	DownloadThroughput float64     `Description:"Measured download throughput."                Units:"bytes per second" json:"download_bytes_per_second"`
+	UploadRate         float64     `Description:"Paced upload rate."                           Units:"bytes per second" json:"upload_rate_bytes_per_second"`
+	UploadThroughput   float64     `Description:"Measured upload throughput."                  Units:"bytes per second" json:"upload_bytes_per_second"`
+	SelfProbes         int         `Description:"Number of self probes in the step." json:"self_probes"`
+	SelfP50            float64     `Description:"P50 self probe RTT."                          Units:"seconds" json:"self_rtt_p50_seconds"`
+	SelfP90            float64     `Description:"P90 self probe RTT."                          Units:"seconds" json:"self_rtt_p90_seconds"`
+	ForeignProbes      int         `Description:"Number of foreign probes in the step." json:"foreign_probes"`
+	ForeignP50         float64     `Description:"P50 foreign probe RTT (per round trip)."      Units:"seconds" json:"foreign_rtt_p50_seconds"`
+	ForeignP90         float64     `Description:"P90 foreign probe RTT (per round trip)."      Units:"seconds" json:"foreign_rtt_p90_seconds"`
+	Phase              phase.Phase `Description:"The phase of the test."                     Formatter:"String" json:"-"`
+}
+
+func NewPacingDataPoint(
+	stepEnd time.Time,
+	offeredLoad float64,
+	downloadRate float64,
+	downloadThroughputs ms.MathematicalSeries[float64],
+	uploadRate float64,
+	uploadThroughputs ms.MathematicalSeries[float64],
+	selfRtts ms.MathematicalSeries[float64],
+	foreignRtts ms.MathematicalSeries[float64],
+) PacingDataPoint {
+	averageThroughput := func(throughputs ms.MathematicalSeries[float64]) float64 {
+		if throughputs.Len() == 0 {
+			return 0
+		}
+		return throughputs.CalculateAverage()
+	}
+	return PacingDataPoint{
+		Time:               stepEnd,
+		OfferedLoad:        offeredLoad,
+		DownloadRate:       downloadRate,
+		DownloadThroughput: averageThroughput(downloadThroughputs),
+		UploadRate:         uploadRate,
+		UploadThroughput:   averageThroughput(uploadThroughputs),
+		SelfProbes:         selfRtts.Len(),
+		SelfP50:            intervalPercentile(selfRtts, 50),
+		SelfP90:            intervalPercentile(selfRtts, 90),
+		ForeignProbes:      foreignRtts.Len(),
+		ForeignP50:         intervalPercentile(foreignRtts, 50),
+		ForeignP90:         intervalPercentile(foreignRtts, 90),
+		Phase:              phase.Pacing,
+	}
+}
+
+func (dp PacingDataPoint) String() string {
+	return fmt.Sprintf(
+		"%3.0f%%: Download %.3f/%.3f Mbps, Upload %.3f/%.3f Mbps; Self P50 %.3f ms, P90 %.3f ms; Foreign P50 %.3f ms, P90 %.3f ms",
+		dp.OfferedLoad,
+		utilities.ToMbps(dp.DownloadThroughput), utilities.ToMbps(dp.DownloadRate),
+		utilities.ToMbps(dp.UploadThroughput), utilities.ToMbps(dp.UploadRate),
+		dp.SelfP50*1000, dp.SelfP90*1000, dp.ForeignP50*1000, dp.ForeignP90*1000,
+	)
+}
+
+// The offered loads (as percentages of capacity) for the steps of a pacing experiment.
+func PacingSteps(start float64, end float64, step float64) []float64 {
+	steps := make([]float64, 0)
+	if step <= 0 {
+		return steps
+	}
+	for offeredLoad := start; offeredLoad <= end+step/1000; offeredLoad += step {
+		steps = append(steps, offeredLoad)
+	}
+	return steps
+}
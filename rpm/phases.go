@@ -0,0 +1,145 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"sort"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/phase"
+	"github.com/network-quality/goresponsiveness/probe"
+)
+
+// A summary of the probes and throughput measurements that arrived during one phase of a
+// test. RTTs are in seconds (per round trip) and throughputs in bytes per second; both are
+// 0 when there was nothing to measure them with.
+type PhaseSummary struct {
+	Phase              string    `json:"phase"`
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	SelfProbes         int       `json:"self_probes"`
+	SelfRttP50         float64   `json:"self_rtt_p50_seconds"`
+	SelfRttP90         float64   `json:"self_rtt_p90_seconds"`
+	ForeignProbes      int       `json:"foreign_probes"`
+	ForeignRttP50      float64   `json:"foreign_rtt_p50_seconds"`
+	ForeignRttP90      float64   `json:"foreign_rtt_p90_seconds"`
+	DownloadThroughput float64   `json:"download_bytes_per_second"`
+	UploadThroughput   float64   `json:"upload_bytes_per_second"`
+}
+
+type phaseMeasurements struct {
+	start               time.Time
+	end                 time.Time
+	selfRtts            ms.MathematicalSeries[float64]
+	foreignRtts         ms.MathematicalSeries[float64]
+	downloadThroughputs ms.MathematicalSeries[float64]
+	uploadThroughputs   ms.MathematicalSeries[float64]
+}
+
+// PhaseStatistics sorts the measurements of a test by the phase (see phase.Phase) that
+// they are tagged with.
+type PhaseStatistics struct {
+	phases map[phase.Phase]*phaseMeasurements
+}
+
+func NewPhaseStatistics() *PhaseStatistics {
+	return &PhaseStatistics{phases: make(map[phase.Phase]*phaseMeasurements)}
+}
+
+func (ps *PhaseStatistics) measurements(p phase.Phase, at time.Time) *phaseMeasurements {
+	measurements, ok := ps.phases[p]
+	if !ok {
+		measurements = &phaseMeasurements{
+			start:               at,
+			end:                 at,
+			selfRtts:            ms.NewInfiniteMathematicalSeries[float64](),
+			foreignRtts:         ms.NewInfiniteMathematicalSeries[float64](),
+			downloadThroughputs: ms.NewInfiniteMathematicalSeries[float64](),
+			uploadThroughputs:   ms.NewInfiniteMathematicalSeries[float64](),
+		}
+		ps.phases[p] = measurements
+	}
+	if at.Before(measurements.start) {
+		measurements.start = at
+	}
+	if at.After(measurements.end) {
+		measurements.end = at
+	}
+	return measurements
+}
+
+// Probes that timed out have no RTT, so they are not counted.
+func (ps *PhaseStatistics) AddProbe(dataPoint probe.ProbeDataPoint) {
+	if dataPoint.TimedOut || dataPoint.ErrorResponse() || dataPoint.RoundTripCount == 0 {
+		return
+	}
+	measurements := ps.measurements(dataPoint.Phase, dataPoint.Time)
+	switch dataPoint.Type {
+	case probe.SelfDown, probe.SelfUp:
+		measurements.selfRtts.AddElement(dataPoint.Duration.Seconds())
+	case probe.Foreign:
+		measurements.foreignRtts.AddElement(dataPoint.Duration.Seconds() / float64(dataPoint.RoundTripCount))
+	}
+}
+
+func (ps *PhaseStatistics) AddDownloadThroughput(dataPoint ThroughputDataPoint) {
+	ps.measurements(dataPoint.Phase, dataPoint.Time).downloadThroughputs.AddElement(dataPoint.Throughput)
+}
+
+func (ps *PhaseStatistics) AddUploadThroughput(dataPoint ThroughputDataPoint) {
+	ps.measurements(dataPoint.Phase, dataPoint.Time).uploadThroughputs.AddElement(dataPoint.Throughput)
+}
+
+// Summarize the phases that had any measurements, in the order that they happen.
+func (ps *PhaseStatistics) Summaries() []PhaseSummary {
+	phases := make([]phase.Phase, 0, len(ps.phases))
+	for p := range ps.phases {
+		phases = append(phases, p)
+	}
+	sort.Slice(phases, func(i, j int) bool { return phases[i] < phases[j] })
+
+	percentile := func(series ms.MathematicalSeries[float64], p int) float64 {
+		if series.Len() == 0 {
+			return 0
+		}
+		return series.Percentile(p)
+	}
+	average := func(series ms.MathematicalSeries[float64]) float64 {
+		if series.Len() == 0 {
+			return 0
+		}
+		return series.CalculateAverage()
+	}
+
+	summaries := make([]PhaseSummary, 0, len(phases))
+	for _, p := range phases {
+		measurements := ps.phases[p]
+		summaries = append(summaries, PhaseSummary{
+			Phase:              p.String(),
+			Start:              measurements.start,
+			End:                measurements.end,
+			SelfProbes:         measurements.selfRtts.Len(),
+			SelfRttP50:         percentile(measurements.selfRtts, 50),
+			SelfRttP90:         percentile(measurements.selfRtts, 90),
+			ForeignProbes:      measurements.foreignRtts.Len(),
+			ForeignRttP50:      percentile(measurements.foreignRtts, 50),
+			ForeignRttP90:      percentile(measurements.foreignRtts, 90),
+			DownloadThroughput: average(measurements.downloadThroughputs),
+			UploadThroughput:   average(measurements.uploadThroughputs),
+		})
+	}
+	return summaries
+}
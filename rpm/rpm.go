@@ -17,17 +17,22 @@ package rpm
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/network-quality/goresponsiveness/constants"
 	"github.com/network-quality/goresponsiveness/debug"
 	"github.com/network-quality/goresponsiveness/extendedstats"
 	"github.com/network-quality/goresponsiveness/lgc"
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/phase"
 	"github.com/network-quality/goresponsiveness/probe"
 	"github.com/network-quality/goresponsiveness/utilities"
 )
@@ -57,21 +62,156 @@ func addFlows(
 	return toAdd
 }
 
+// Replace the connections at the given positions (which failed) with new ones. Returns the
+// number of connections that were replaced and started.
+func replaceFlows(
+	ctx context.Context,
+	failed []int,
+	lgcc *lgc.LoadGeneratingConnectionCollection,
+	lgcGenerator func() lgc.LoadGeneratingConnection,
+	debugging *debug.DebugWithPrefix,
+) int {
+	lgcc.Lock.Lock()
+	defer lgcc.Lock.Unlock()
+	replaced := 0
+	for _, i := range failed {
+		failedId := (*lgcc.LGCs)[i].ClientId()
+		newGenerator := lgcGenerator()
+		if err := lgcc.Replace(i, newGenerator); err != nil {
+			continue
+		}
+		if !newGenerator.Start(ctx, debugging.Level) {
+			fmt.Printf(
+				"Error starting lgc with id %d (to replace the failed lgc with id %d)!\n",
+				newGenerator.ClientId(), failedId,
+			)
+			continue
+		}
+		if debug.IsDebug(debugging.Level) {
+			fmt.Printf(
+				"%v: Replaced the failed load-generating connection with id %d with one with id %d.\n",
+				debugging, failedId, newGenerator.ClientId(),
+			)
+		}
+		replaced++
+	}
+	return replaced
+}
+
 type GranularThroughputDataPoint struct {
-	Time       time.Time     `Description:"Time of the generation of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
-	Throughput float64       `Description:"Instantaneous throughput (B/s)."`
-	ConnID     uint32        `Description:"Position of connection (ID)."`
-	TCPRtt     time.Duration `Description:"The underlying connection's RTT at probe time."               Formatter:"Seconds"`
-	TCPCwnd    uint32        `Description:"The underlying connection's congestion window at probe time."`
-	Direction  string        `Description:"Direction of Throughput."`
+	Time         time.Time     `Description:"Time of the generation of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	Throughput   float64       `Description:"Instantaneous throughput (B/s)."                               Units:"bytes per second"`
+	ConnID       uint32        `Description:"Position of connection (ID)."`
+	ClientID     uint64        `Description:"Unique ID of the load-generating connection."`
+	TCPRtt       time.Duration `Description:"The underlying connection's RTT at probe time."               Formatter:"Seconds"`
+	TCPCwnd      uint32        `Description:"The underlying connection's congestion window at probe time."`
+	Direction    string        `Description:"Direction of Throughput."`
+	HTTPProtocol string        `Description:"The HTTP protocol that the connection speaks (e.g., HTTP/2)."`
+	Phase        phase.Phase   `Description:"The phase of the test."                                       Formatter:"String"`
+}
+
+// Ties a load-generating connection (as it appears in the granular throughput log) to its
+// network connection (as it appears in a packet capture) and TLS session (as it appears in
+// the SSL key log).
+type ConnectionDataPoint struct {
+	Time          time.Time `Description:"Time of the generation of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	Direction     string    `Description:"Direction of the load-generating connection."`
+	ConnID        uint32    `Description:"Position of connection (ID)."`
+	ClientID      uint64    `Description:"Unique ID of the load-generating connection."`
+	Protocol      string    `Description:"Transport protocol of the connection."`
+	LocalAddress  string    `Description:"Local address (and port) of the connection."`
+	RemoteAddress string    `Description:"Remote address (and port) of the connection."`
+	ClientRandom  string    `Description:"Client random of the connection's TLS session (as in the SSL key log)."`
+	HTTPProtocol  string    `Description:"The HTTP protocol that the connection spoke (e.g., HTTP/2)."`
 }
 
 type ThroughputDataPoint struct {
-	Time                         time.Time                     `Description:"Time of the generation of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
-	Throughput                   float64                       `Description:"Instantaneous throughput (B/s)."`
-	ActiveConnections            int                           `Description:"Number of active parallel connections."`
-	Connections                  int                           `Description:"Number of parallel connections."`
+	Time                time.Time `Description:"Time of the generation of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	Throughput          float64   `Description:"Instantaneous throughput (B/s)."                 Units:"bytes per second"`
+	ProbeThroughput     float64   `Description:"Throughput of the probes in the same direction (not part of the instantaneous throughput)." Units:"bytes per second"`
+	ActiveConnections   int       `Description:"Number of active parallel connections."`
+	Connections         int       `Description:"Number of parallel connections."`
+	ReplacedConnections int       `Description:"Number of connections that failed (e.g., were reset) and were replaced."`
+	ErrorResponses      int       `Description:"Number of connections that the server answered with a status other than 2xx."`
+	// Set (on the last data point that a load generator sends) when the server answered with a
+	// status that will not go away by trying again (e.g., 404).
+	Failure                      error                         `Description:"[OMIT]"`
 	GranularThroughputDataPoints []GranularThroughputDataPoint `Description:"[OMIT]"`
+	// The load-generating connections whose network connections were established since the
+	// previous data point.
+	ConnectionDataPoints []ConnectionDataPoint `Description:"[OMIT]"`
+	Phase                phase.Phase           `Description:"The phase of the test."                           Formatter:"String"`
+}
+
+// Working latency percentiles for the probes that completed during a single
+// measurement interval.
+type IntervalLatencyDataPoint struct {
+	Time          time.Time   `Description:"Time of the end of the interval."          Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	SelfProbes    int         `Description:"Number of self probes in the interval."`
+	SelfP50       float64     `Description:"P50 self probe RTT."                       Units:"seconds"`
+	SelfP90       float64     `Description:"P90 self probe RTT."                       Units:"seconds"`
+	SelfP99       float64     `Description:"P99 self probe RTT."                       Units:"seconds"`
+	ForeignProbes int         `Description:"Number of foreign probes in the interval."`
+	ForeignP50    float64     `Description:"P50 foreign probe RTT (per round trip)."   Units:"seconds"`
+	ForeignP90    float64     `Description:"P90 foreign probe RTT (per round trip)."   Units:"seconds"`
+	ForeignP99    float64     `Description:"P99 foreign probe RTT (per round trip)."   Units:"seconds"`
+	Phase         phase.Phase `Description:"The phase of the test at the end of the interval." Formatter:"String"`
+}
+
+func intervalPercentile(series ms.MathematicalSeries[float64], p int) float64 {
+	if series.Len() == 0 {
+		return 0
+	}
+	return series.Percentile(p)
+}
+
+// The specification calculates the RTT of a foreign probe as
+// 1/3*tcp_foreign + 1/3*tls_foreign + 1/3*http_foreign
+// where the statistic (e.g., P90) of each component is calculated separately. Components
+// without any measurements (e.g., TLS when the test is not encrypted) are left out.
+func ForeignRoundTripTime(
+	components []ms.MathematicalSeries[float64],
+	statistic func(ms.MathematicalSeries[float64]) float64,
+) (float64, bool) {
+	total := float64(0)
+	measured := 0
+	for _, component := range components {
+		if component.Len() == 0 {
+			continue
+		}
+		total += statistic(component)
+		measured++
+	}
+	if measured == 0 {
+		return 0, false
+	}
+	return total / float64(measured), true
+}
+
+func NewIntervalLatencyDataPoint(
+	intervalEnd time.Time,
+	selfRtts ms.MathematicalSeries[float64],
+	foreignRtts ms.MathematicalSeries[float64],
+) IntervalLatencyDataPoint {
+	return IntervalLatencyDataPoint{
+		Time:          intervalEnd,
+		SelfProbes:    selfRtts.Len(),
+		SelfP50:       intervalPercentile(selfRtts, 50),
+		SelfP90:       intervalPercentile(selfRtts, 90),
+		SelfP99:       intervalPercentile(selfRtts, 99),
+		ForeignProbes: foreignRtts.Len(),
+		ForeignP50:    intervalPercentile(foreignRtts, 50),
+		ForeignP90:    intervalPercentile(foreignRtts, 90),
+		ForeignP99:    intervalPercentile(foreignRtts, 99),
+	}
+}
+
+func (dp IntervalLatencyDataPoint) String() string {
+	return fmt.Sprintf(
+		"Self (%d probes): P50 %.3f ms, P90 %.3f ms, P99 %.3f ms; Foreign (%d probes): P50 %.3f ms, P90 %.3f ms, P99 %.3f ms",
+		dp.SelfProbes, dp.SelfP50*1000, dp.SelfP90*1000, dp.SelfP99*1000,
+		dp.ForeignProbes, dp.ForeignP50*1000, dp.ForeignP90*1000, dp.ForeignP99*1000,
+	)
 }
 
 type SelfDataCollectionResult struct {
@@ -81,6 +221,86 @@ type SelfDataCollectionResult struct {
 	LoggingContinuation func()
 }
 
+// Limits the total number of probes that may be sent during a test (across all the probers
+// that share it). A budget with a limit of 0 is unlimited.
+type ProbeBudget struct {
+	spent uint64
+	limit uint64
+}
+
+func NewProbeBudget(limit uint64) *ProbeBudget {
+	return &ProbeBudget{limit: limit}
+}
+
+// Try to take count probes from the budget. If there are not enough left, nothing is
+// taken and the result is false.
+func (pb *ProbeBudget) Spend(count uint64) bool {
+	for {
+		spent := atomic.LoadUint64(&pb.spent)
+		if pb.limit != 0 && spent+count > pb.limit {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&pb.spent, spent, spent+count) {
+			return true
+		}
+	}
+}
+
+func (pb *ProbeBudget) Spent() uint64 {
+	return atomic.LoadUint64(&pb.spent)
+}
+
+func debugBudgetExhausted(budget *ProbeBudget, debugging *debug.DebugWithPrefix) {
+	if debug.IsDebug(debugging.Level) {
+		fmt.Printf(
+			"(%s) The probe budget is exhausted after %d probes; no more probes will be sent.\n",
+			debugging.Prefix,
+			budget.Spent(),
+		)
+	}
+}
+
+func generateForeignProbeClient(
+	foreignProbeConfiguration probe.ProbeConfiguration,
+	keyLogger io.Writer,
+	debugging *debug.DebugWithPrefix,
+) *http.Client {
+	transport := &http.Transport{}
+	transport.TLSClientConfig = &tls.Config{}
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if !utilities.IsInterfaceNil(keyLogger) {
+		if debug.IsDebug(debugging.Level) {
+			fmt.Printf(
+				"Using an SSL Key Logger for this foreign probe.\n",
+			)
+		}
+
+		// The presence of a custom TLSClientConfig in a *generic* `transport`
+		// means that go will default to HTTP/1.1 and cowardly avoid HTTP/2:
+		// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L278
+		// Also, it would appear that the API's choice of HTTP vs HTTP2 can
+		// depend on whether the url contains
+		// https:// or http://:
+		// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L74
+		transport.TLSClientConfig.KeyLogWriter = utilities.TraceKeyLog(
+			keyLogger,
+			debug.IsDebug(debugging.Level),
+			fmt.Sprintf("%s foreign probe", debugging.Prefix),
+		)
+	}
+
+	transport.TLSClientConfig.InsecureSkipVerify =
+		foreignProbeConfiguration.InsecureSkipVerify
+	transport.TLSClientConfig.ClientSessionCache =
+		foreignProbeConfiguration.SessionCache
+
+	utilities.OverrideHostTransport(transport,
+		foreignProbeConfiguration.ConnectToAddr, foreignProbeConfiguration.ProxyDialer)
+
+	return &http.Client{Transport: transport}
+}
+
 func CombinedProber(
 	proberCtx context.Context,
 	networkActivityCtx context.Context,
@@ -88,7 +308,15 @@ func CombinedProber(
 	selfProbeConfigurationGenerator func() probe.ProbeConfiguration,
 	selfDownProbeConnection lgc.LoadGeneratingConnection,
 	selfUpProbeConnection lgc.LoadGeneratingConnection,
-	probeInterval time.Duration,
+	selfProbeMode SelfProbeMode,
+	downloadConnections *lgc.LoadGeneratingConnectionCollection, // The connections that selfProbeMode spreads
+	uploadConnections *lgc.LoadGeneratingConnectionCollection, // the self probes across.
+	selfProbeInterval time.Duration,
+	foreignProbeInterval time.Duration,
+	probeTimeout time.Duration,
+	connectProbeMode probe.ConnectProbeMode, // Optionally send a connect probe with every foreign probe.
+	foreignProbeReuse ForeignProbeReuse,
+	budget *ProbeBudget,
 	keyLogger io.Writer,
 	captureExtendedStats bool,
 	debugging *debug.DebugWithPrefix,
@@ -99,53 +327,217 @@ func CombinedProber(
 
 	go func() {
 		wg := sync.WaitGroup{}
-		probeCount := 0
+		foreignProbeClients := newForeignProbeClients(foreignProbeReuse, keyLogger, debugging)
+		foreignProbeCount := 0
+		selfProbeCount := 0
+
+		// Foreign probes are far more expensive than self probes (they establish an
+		// entirely new connection), so each type of probe is sent on its own schedule.
+		nextForeignProbeTime := time.Now().Add(foreignProbeInterval)
+		nextSelfProbeTime := time.Now().Add(selfProbeInterval)
 
 		// As long as our context says that we can continue to probe!
 		for proberCtx.Err() == nil {
 
-			time.Sleep(probeInterval)
+			nextProbeTime := nextForeignProbeTime
+			if nextSelfProbeTime.Before(nextProbeTime) {
+				nextProbeTime = nextSelfProbeTime
+			}
+			time.Sleep(time.Until(nextProbeTime))
+			now := time.Now()
 
-			foreignProbeConfiguration := foreignProbeConfigurationGenerator()
-			selfProbeConfiguration := selfProbeConfigurationGenerator()
+			if !now.Before(nextForeignProbeTime) {
+				nextForeignProbeTime = now.Add(foreignProbeInterval)
 
-			if debug.IsDebug(debugging.Level) {
-				fmt.Printf(
-					"(%s) About to send round %d of probes!\n",
-					debugging.Prefix,
-					probeCount+1,
+				if !budget.Spend(1) {
+					debugBudgetExhausted(budget, debugging)
+					break
+				}
+
+				foreignProbeConfiguration := foreignProbeConfigurationGenerator()
+
+				if debug.IsDebug(debugging.Level) {
+					fmt.Printf(
+						"(%s) About to send foreign probe %d!\n",
+						debugging.Prefix,
+						foreignProbeCount+1,
+					)
+				}
+				foreignProbeClient := foreignProbeClients.next(foreignProbeConfiguration)
+
+				// Start Foreign Connection Prober
+				foreignProbeCount++
+				go probe.Probe(
+					networkActivityCtx,
+					&wg,
+					foreignProbeClient,
+					nil,
+					foreignProbeConfiguration.URL,
+					foreignProbeConfiguration.Host,
+					foreignProbeConfiguration.Headers,
+					probe.Foreign,
+					probeTimeout,
+					&dataPoints,
+					captureExtendedStats,
+					debugging,
 				)
+
+				if connectProbeMode != probe.NoConnectProbes && budget.Spend(1) {
+					go probe.ConnectProbe(
+						networkActivityCtx,
+						&wg,
+						foreignProbeConfiguration,
+						keyLogger,
+						connectProbeMode,
+						probeTimeout,
+						&dataPoints,
+						debugging,
+					)
+				}
 			}
-			transport := &http.Transport{}
-			transport.TLSClientConfig = &tls.Config{}
-			transport.Proxy = http.ProxyFromEnvironment
 
-			if !utilities.IsInterfaceNil(keyLogger) {
+			if !now.Before(nextSelfProbeTime) {
+				nextSelfProbeTime = now.Add(selfProbeInterval)
+
+				// Every round of self probes is one up and one down on each of the connections
+				// that the mode picks.
+				selfDownProbeConnections := selfProbeConnections(selfProbeMode, selfProbeCount, selfDownProbeConnection, downloadConnections)
+				selfUpProbeConnections := selfProbeConnections(selfProbeMode, selfProbeCount, selfUpProbeConnection, uploadConnections)
+				// When the first connection is lost (and replaced), its successor takes over for
+				// the rest of the test.
+				if selfProbeMode == FirstConnectionSelfProbes {
+					if len(selfDownProbeConnections) > 0 {
+						selfDownProbeConnection = selfDownProbeConnections[0]
+					}
+					if len(selfUpProbeConnections) > 0 {
+						selfUpProbeConnection = selfUpProbeConnections[0]
+					}
+				}
+				if !budget.Spend(uint64(len(selfDownProbeConnections) + len(selfUpProbeConnections))) {
+					debugBudgetExhausted(budget, debugging)
+					break
+				}
+
+				selfProbeConfiguration := selfProbeConfigurationGenerator()
+
 				if debug.IsDebug(debugging.Level) {
 					fmt.Printf(
-						"Using an SSL Key Logger for this foreign probe.\n",
+						"(%s) About to send round %d of self probes!\n",
+						debugging.Prefix,
+						selfProbeCount+1,
 					)
 				}
+				selfProbeCount++
+
+				// Start Self Download Connection Probers
+				for _, connection := range selfDownProbeConnections {
+					// TODO: Make the following sanity check more than just a check.
+					// We only want to start a SelfDown probe on a connection that is
+					// in the RUNNING state.
+					if connection.Status() == lgc.LGC_STATUS_RUNNING {
+						go probe.Probe(
+							networkActivityCtx,
+							&wg,
+							connection.Client(),
+							connection,
+							selfProbeConfiguration.URL,
+							selfProbeConfiguration.Host,
+							selfProbeConfiguration.Headers,
+							probe.SelfDown,
+							probeTimeout,
+							&dataPoints,
+							captureExtendedStats,
+							debugging,
+						)
+					}
+				}
+
+				// Start Self Upload Connection Probers
+				for _, connection := range selfUpProbeConnections {
+					// TODO: Make the following sanity check more than just a check.
+					// We only want to start a SelfUp probe on a connection that is
+					// in the RUNNING state.
+					if connection.Status() == lgc.LGC_STATUS_RUNNING {
+						go probe.Probe(
+							proberCtx,
+							&wg,
+							connection.Client(),
+							connection,
+							selfProbeConfiguration.URL,
+							selfProbeConfiguration.Host,
+							selfProbeConfiguration.Headers,
+							probe.SelfUp,
+							probeTimeout,
+							&dataPoints,
+							captureExtendedStats,
+							debugging,
+						)
+					}
+				}
+			}
+		}
+		if debug.IsDebug(debugging.Level) {
+			fmt.Printf(
+				"(%s) Combined probe driver is going to start waiting for its probes to finish.\n",
+				debugging.Prefix,
+			)
+		}
+		utilities.OrTimeout(func() { wg.Wait() }, 2*time.Second)
+		foreignProbeClients.close()
+		if debug.IsDebug(debugging.Level) {
+			fmt.Printf(
+				"(%s) Combined probe driver is done waiting for its probes to finish.\n",
+				debugging.Prefix,
+			)
+		}
+		close(dataPoints)
+	}()
+	return
+}
+
+// Send only foreign probes (at the given interval) until proberCtx is canceled. Because
+// foreign probes do not rely on load-generating connections, this prober can keep running
+// after load generation has stopped (e.g., to watch latency recover once the network drains).
+func ForeignProber(
+	proberCtx context.Context,
+	networkActivityCtx context.Context,
+	foreignProbeConfigurationGenerator func() probe.ProbeConfiguration,
+	probeInterval time.Duration,
+	probeTimeout time.Duration,
+	foreignProbeReuse ForeignProbeReuse,
+	budget *ProbeBudget,
+	keyLogger io.Writer,
+	captureExtendedStats bool,
+	debugging *debug.DebugWithPrefix,
+) (dataPoints chan probe.ProbeDataPoint) {
+	dataPoints = make(chan probe.ProbeDataPoint)
 
-				// The presence of a custom TLSClientConfig in a *generic* `transport`
-				// means that go will default to HTTP/1.1 and cowardly avoid HTTP/2:
-				// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L278
-				// Also, it would appear that the API's choice of HTTP vs HTTP2 can
-				// depend on whether the url contains
-				// https:// or http://:
-				// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L74
-				transport.TLSClientConfig.KeyLogWriter = keyLogger
+	go func() {
+		wg := sync.WaitGroup{}
+		foreignProbeClients := newForeignProbeClients(foreignProbeReuse, keyLogger, debugging)
+		probeCount := 0
+
+		for proberCtx.Err() == nil {
+
+			time.Sleep(probeInterval)
+
+			if !budget.Spend(1) {
+				debugBudgetExhausted(budget, debugging)
+				break
 			}
 
-			transport.TLSClientConfig.InsecureSkipVerify =
-				foreignProbeConfiguration.InsecureSkipVerify
+			foreignProbeConfiguration := foreignProbeConfigurationGenerator()
 
-			utilities.OverrideHostTransport(transport,
-				foreignProbeConfiguration.ConnectToAddr)
+			if debug.IsDebug(debugging.Level) {
+				fmt.Printf(
+					"(%s) About to send foreign probe %d!\n",
+					debugging.Prefix,
+					probeCount+1,
+				)
+			}
 
-			foreignProbeClient := &http.Client{Transport: transport}
+			foreignProbeClient := foreignProbeClients.next(foreignProbeConfiguration)
 
-			// Start Foreign Connection Prober
 			probeCount++
 			go probe.Probe(
 				networkActivityCtx,
@@ -154,68 +546,25 @@ func CombinedProber(
 				nil,
 				foreignProbeConfiguration.URL,
 				foreignProbeConfiguration.Host,
+				foreignProbeConfiguration.Headers,
 				probe.Foreign,
+				probeTimeout,
 				&dataPoints,
 				captureExtendedStats,
 				debugging,
 			)
-
-			// Start Self Download Connection Prober
-
-			// TODO: Make the following sanity check more than just a check.
-			// We only want to start a SelfDown probe on a connection that is
-			// in the RUNNING state.
-			if selfDownProbeConnection.Status() == lgc.LGC_STATUS_RUNNING {
-				go probe.Probe(
-					networkActivityCtx,
-					&wg,
-					selfDownProbeConnection.Client(),
-					selfDownProbeConnection,
-					selfProbeConfiguration.URL,
-					selfProbeConfiguration.Host,
-					probe.SelfDown,
-					&dataPoints,
-					captureExtendedStats,
-					debugging,
-				)
-			} else {
-				panic(fmt.Sprintf("(%s) Combined probe driver evidently lost its underlying connection (Status: %v).\n",
-					debugging.Prefix, selfDownProbeConnection.Status()))
-			}
-
-			// Start Self Upload Connection Prober
-
-			// TODO: Make the following sanity check more than just a check.
-			// We only want to start a SelfDown probe on a connection that is
-			// in the RUNNING state.
-			if selfUpProbeConnection.Status() == lgc.LGC_STATUS_RUNNING {
-				go probe.Probe(
-					proberCtx,
-					&wg,
-					selfUpProbeConnection.Client(),
-					nil,
-					selfProbeConfiguration.URL,
-					selfProbeConfiguration.Host,
-					probe.SelfUp,
-					&dataPoints,
-					captureExtendedStats,
-					debugging,
-				)
-			} else {
-				panic(fmt.Sprintf("(%s) Combined probe driver evidently lost its underlying connection (Status: %v).\n",
-					debugging.Prefix, selfUpProbeConnection.Status()))
-			}
 		}
 		if debug.IsDebug(debugging.Level) {
 			fmt.Printf(
-				"(%s) Combined probe driver is going to start waiting for its probes to finish.\n",
+				"(%s) Foreign probe driver is going to start waiting for its probes to finish.\n",
 				debugging.Prefix,
 			)
 		}
 		utilities.OrTimeout(func() { wg.Wait() }, 2*time.Second)
+		foreignProbeClients.close()
 		if debug.IsDebug(debugging.Level) {
 			fmt.Printf(
-				"(%s) Combined probe driver is done waiting for its probes to finish.\n",
+				"(%s) Foreign probe driver is done waiting for its probes to finish.\n",
 				debugging.Prefix,
 			)
 		}
@@ -272,6 +621,12 @@ func LoadGenerator(
 			probeConnectionCommunicationChannel <- *zerothConnection
 		}()
 
+		// When (by client id) the failed connections that the server answered with an error
+		// may be replaced (it may have asked us to wait with Retry-After).
+		retryAt := make(map[uint64]time.Time)
+		// The connections (by client id) whose network connections have been reported.
+		connected := make(map[uint64]bool)
+
 		nextSampleStartTime := time.Now().Add(rampupInterval)
 
 		for currentInterval := uint64(0); true; currentInterval++ {
@@ -302,8 +657,12 @@ func LoadGenerator(
 			var instantaneousThroughputTotal float64 = 0
 			var instantaneousThroughputDataPoints uint = 0
 			granularThroughputDatapoints := make([]GranularThroughputDataPoint, 0)
+			connectionDatapoints := make([]ConnectionDataPoint, 0)
 			now = time.Now() // Used to align granular throughput data
 			allInvalid := true
+			failed := make([]int, 0)
+			errorResponses := 0
+			var failure error = nil
 			for i := range *loadGeneratingConnectionsCollection.LGCs {
 				loadGeneratingConnectionsCollection.Lock.Lock()
 				connectionState := (*loadGeneratingConnectionsCollection.LGCs)[i].Status()
@@ -321,6 +680,29 @@ func LoadGenerator(
 				case lgc.LGC_STATUS_ERROR,
 					lgc.LGC_STATUS_DONE:
 					{
+						// A connection that failed while we still need its load is replaced
+						// (once we know that the others have not failed, too).
+						if connectionState == lgc.LGC_STATUS_ERROR && loadGeneratorCtx.Err() == nil {
+							connection := (*loadGeneratingConnectionsCollection.LGCs)[i]
+							var responseError *lgc.ResponseError
+							if !errors.As(connection.Err(), &responseError) {
+								failed = append(failed, i)
+							} else if !responseError.Retryable() {
+								errorResponses++
+								failure = responseError
+							} else {
+								notBefore, seen := retryAt[connection.ClientId()]
+								if !seen {
+									errorResponses++
+									notBefore = time.Now().Add(responseError.RetryAfter)
+									retryAt[connection.ClientId()] = notBefore
+								}
+								if !time.Now().Before(notBefore) {
+									delete(retryAt, connection.ClientId())
+									failed = append(failed, i)
+								}
+							}
+						}
 						if debug.IsDebug(debugging.Level) {
 							fmt.Printf(
 								"%v: Load-generating connection with id %d is invalid or complete ... skipping.\n",
@@ -331,7 +713,17 @@ func LoadGenerator(
 						// TODO: Do we add null connection to throughput? and how do we define it? Throughput -1 or 0?
 						granularThroughputDatapoints = append(
 							granularThroughputDatapoints,
-							GranularThroughputDataPoint{now, 0, uint32(i), 0, 0, ""},
+							GranularThroughputDataPoint{
+								now,
+								0,
+								uint32(i),
+								(*loadGeneratingConnectionsCollection.LGCs)[i].ClientId(),
+								0,
+								0,
+								"",
+								"",
+								phase.Ramping,
+							},
 						)
 					}
 				case lgc.LGC_STATUS_NOT_STARTED:
@@ -371,21 +763,56 @@ func LoadGenerator(
 								}
 							}
 						}
+						clientId := (*loadGeneratingConnectionsCollection.LGCs)[i].ClientId()
+						identity := (*loadGeneratingConnectionsCollection.LGCs)[i].Identity()
 						granularThroughputDatapoints = append(
 							granularThroughputDatapoints,
 							GranularThroughputDataPoint{
 								now,
 								instantaneousConnectionThroughput,
 								uint32(i),
+								clientId,
 								tcpRtt,
 								tcpCwnd,
 								"",
+								identity.HTTPProtocol,
+								phase.Ramping,
 							},
 						)
+						// Report each network connection once, as soon as it is established (a
+						// replacement in a slot gets a new client id and so a report of its own).
+						if identity.LocalAddress != "" && !connected[clientId] {
+							connected[clientId] = true
+							connectionDatapoints = append(connectionDatapoints, ConnectionDataPoint{
+								Time:          now,
+								ConnID:        uint32(i),
+								ClientID:      clientId,
+								Protocol:      identity.Network,
+								LocalAddress:  identity.LocalAddress,
+								RemoteAddress: identity.RemoteAddress,
+								ClientRandom:  identity.ClientRandom,
+								HTTPProtocol:  identity.HTTPProtocol,
+							})
+						}
 					}
 				}
 			}
 
+			// There is no point in generating load against a server that will never give it to us.
+			if failure != nil {
+				if debug.IsDebug(debugging.Level) {
+					fmt.Printf("%v: Stopping because %v.\n", debugging, failure)
+				}
+				throughputCalculations <- ThroughputDataPoint{
+					Time:           time.Now(),
+					Connections:    len(*loadGeneratingConnectionsCollection.LGCs),
+					ErrorResponses: errorResponses,
+					Failure:        failure,
+					Phase:          phase.Ramping,
+				}
+				break
+			}
+
 			// For some reason, all the lgcs are invalid. This likely means that
 			// the network/server went away.
 			if allInvalid {
@@ -398,13 +825,28 @@ func LoadGenerator(
 				break
 			}
 
+			replaced := 0
+			if len(failed) > 0 {
+				replaced = replaceFlows(
+					networkActivityCtx,
+					failed,
+					loadGeneratingConnectionsCollection,
+					lgcGenerator,
+					debugging,
+				)
+			}
+
 			// We have generated a throughput calculation -- let's send it back to the coordinator
 			throughputDataPoint := ThroughputDataPoint{
-				time.Now(),
-				instantaneousThroughputTotal,
-				int(instantaneousThroughputDataPoints),
-				len(*loadGeneratingConnectionsCollection.LGCs),
-				granularThroughputDatapoints,
+				Time:                         time.Now(),
+				Throughput:                   instantaneousThroughputTotal,
+				ActiveConnections:            int(instantaneousThroughputDataPoints),
+				Connections:                  len(*loadGeneratingConnectionsCollection.LGCs),
+				ReplacedConnections:          replaced,
+				ErrorResponses:               errorResponses,
+				GranularThroughputDataPoints: granularThroughputDatapoints,
+				ConnectionDataPoints:         connectionDatapoints,
+				Phase:                        phase.Ramping,
 			}
 			throughputCalculations <- throughputDataPoint
 
@@ -426,3 +868,139 @@ func LoadGenerator(
 	}()
 	return
 }
+
+// Determine how long after loadStoppedTime it took for latency (as measured by the
+// given probes) to return to within tolerance percent of idleLatency (in seconds,
+// per round trip) and stay there for consecutive probes in a row. The second return
+// value is false if latency never got there.
+func CalculateDrainTime(
+	dataPoints []probe.ProbeDataPoint,
+	loadStoppedTime time.Time,
+	idleLatency float64,
+	tolerance float64,
+	consecutive int,
+) (time.Duration, bool) {
+	ordered := make([]probe.ProbeDataPoint, 0, len(dataPoints))
+	for _, dataPoint := range dataPoints {
+		// A probe that timed out saw (at least) high latency; one that got an error
+		// response says nothing about latency at all.
+		if dataPoint.ErrorResponse() || (dataPoint.RoundTripCount == 0 && !dataPoint.TimedOut) {
+			continue
+		}
+		ordered = append(ordered, dataPoint)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Time.Before(ordered[j].Time)
+	})
+
+	cutoff := idleLatency * (1.0 + tolerance/100.0)
+	run := 0
+	for i, dataPoint := range ordered {
+		if dataPoint.TimedOut || dataPoint.Duration.Seconds()/float64(dataPoint.RoundTripCount) > cutoff {
+			run = 0
+			continue
+		}
+		run++
+		if run < consecutive {
+			continue
+		}
+		recovery := ordered[i-run+1].Time
+		if recovery.Before(loadStoppedTime) {
+			return 0, true
+		}
+		return recovery.Sub(loadStoppedTime), true
+	}
+	return 0, false
+}
+
+// Whether (according to the throughput measurements) the load generators saturated the link.
+type SaturationAssessment struct {
+	// False when there were too few measurements to make a judgement.
+	Assessed  bool `json:"assessed"`
+	Saturated bool `json:"saturated"`
+	// When the link was not saturated, the percentage by which throughput was still growing
+	// at the end of the test -- a (conservative) estimate of how much capacity was left.
+	Headroom float64 `json:"headroom_percent"`
+}
+
+func (sa SaturationAssessment) String() string {
+	if !sa.Assessed {
+		return "unknown (too few measurements)"
+	}
+	if sa.Saturated {
+		return "saturated (adding connections no longer increased throughput)"
+	}
+	return fmt.Sprintf("not confirmed saturated (estimated headroom: %.0f%%)", sa.Headroom)
+}
+
+// The link is considered saturated when the average throughput over the final window
+// of measurements is less than gainThreshold percent more than the average throughput
+// over the window before it, even though connections were added in the meantime.
+func AssessSaturation(
+	measurements []ThroughputDataPoint,
+	window int,
+	gainThreshold float64,
+) SaturationAssessment {
+	if window <= 0 || len(measurements) < 2*window {
+		return SaturationAssessment{}
+	}
+	last := measurements[len(measurements)-window:]
+	previous := measurements[len(measurements)-2*window : len(measurements)-window]
+
+	average := func(dataPoints []ThroughputDataPoint) float64 {
+		total := float64(0)
+		for _, dataPoint := range dataPoints {
+			total += dataPoint.Throughput
+		}
+		return total / float64(len(dataPoints))
+	}
+	previousAverage := average(previous)
+	lastAverage := average(last)
+	connectionsAdded := last[len(last)-1].Connections > previous[len(previous)-1].Connections
+
+	if previousAverage == 0 {
+		return SaturationAssessment{Assessed: true, Saturated: false, Headroom: 0}
+	}
+	gain := utilities.SignedPercentDifference(lastAverage, previousAverage)
+	if connectionsAdded && gain < gainThreshold {
+		return SaturationAssessment{Assessed: true, Saturated: true}
+	}
+	if gain < 0 {
+		gain = 0
+	}
+	return SaturationAssessment{Assessed: true, Saturated: false, Headroom: gain}
+}
+
+// The average of the final count instantaneous throughputs (i.e., the last moving average
+// that a stabilizer with an I of count saw). 0 when there are no measurements.
+func FinalMovingAverage(measurements []ThroughputDataPoint, count int) float64 {
+	if count <= 0 || len(measurements) == 0 {
+		return 0
+	}
+	if count > len(measurements) {
+		count = len(measurements)
+	}
+	total := float64(0)
+	for _, measurement := range measurements[len(measurements)-count:] {
+		total += measurement.Throughput
+	}
+	return total / float64(count)
+}
+
+// The time of the earliest measurement (in any of the directions) that was tagged as
+// part of the saturated phase. False when throughput never saturated.
+func SaturationTime(directions ...[]ThroughputDataPoint) (time.Time, bool) {
+	saturated := time.Time{}
+	for _, measurements := range directions {
+		for _, measurement := range measurements {
+			if measurement.Phase != phase.Saturated {
+				continue
+			}
+			if saturated.IsZero() || measurement.Time.Before(saturated) {
+				saturated = measurement.Time
+			}
+			break
+		}
+	}
+	return saturated, !saturated.IsZero()
+}
@@ -0,0 +1,677 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/lgc"
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/phase"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/stats"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+func TestProbeBudget(t *testing.T) {
+	budget := NewProbeBudget(7)
+	if !budget.Spend(3) || !budget.Spend(3) {
+		t.Fatalf("Spending 6 probes from a budget of 7 should succeed.")
+	}
+	if budget.Spend(3) {
+		t.Fatalf("Spending 9 probes from a budget of 7 should fail.")
+	}
+	if !budget.Spend(1) {
+		t.Fatalf("Spending the last probe of the budget should succeed.")
+	}
+	if budget.Spent() != 7 {
+		t.Fatalf("Budget should have spent 7 probes but spent %d.", budget.Spent())
+	}
+}
+
+func TestProbeBudgetUnlimited(t *testing.T) {
+	budget := NewProbeBudget(0)
+	for i := 0; i < 1000; i++ {
+		if !budget.Spend(3) {
+			t.Fatalf("An unlimited budget should never be exhausted.")
+		}
+	}
+}
+
+func TestCalculateDrainTime(t *testing.T) {
+	loadStopped := time.Now()
+	probeAt := func(after time.Duration, rtt time.Duration) probe.ProbeDataPoint {
+		return probe.ProbeDataPoint{Time: loadStopped.Add(after), RoundTripCount: 3, Duration: 3 * rtt}
+	}
+	// One lucky probe (at 200ms) while the buffers are still draining, then latency stays
+	// within 20% of the 10ms idle latency from 400ms on.
+	dataPoints := []probe.ProbeDataPoint{
+		probeAt(100*time.Millisecond, 100*time.Millisecond),
+		probeAt(400*time.Millisecond, 11*time.Millisecond),
+		probeAt(200*time.Millisecond, 11*time.Millisecond),
+		probeAt(300*time.Millisecond, 50*time.Millisecond),
+		probeAt(600*time.Millisecond, 9*time.Millisecond),
+		probeAt(500*time.Millisecond, 10*time.Millisecond),
+	}
+	drainTime, drained := CalculateDrainTime(dataPoints, loadStopped, 0.010, 20.0, 3)
+	if !drained {
+		t.Fatalf("Latency should have drained.")
+	}
+	if drainTime != 400*time.Millisecond {
+		t.Fatalf("Drain time should have been 400ms but was %v.", drainTime)
+	}
+	if drainTime, _ := CalculateDrainTime(dataPoints, loadStopped, 0.010, 20.0, 1); drainTime != 200*time.Millisecond {
+		t.Fatalf("Drain time (after a single probe) should have been 200ms but was %v.", drainTime)
+	}
+
+	if _, drained := CalculateDrainTime(dataPoints[:4], loadStopped, 0.010, 20.0, 3); drained {
+		t.Fatalf("Latency should not have drained.")
+	}
+
+	timedOut := append([]probe.ProbeDataPoint{}, dataPoints...)
+	timedOut[5] = probe.ProbeDataPoint{Time: loadStopped.Add(500 * time.Millisecond), TimedOut: true}
+	if _, drained := CalculateDrainTime(timedOut, loadStopped, 0.010, 20.0, 3); drained {
+		t.Fatalf("A probe that timed out should have interrupted the probes close to idle.")
+	}
+}
+
+func throughputs(values ...float64) []ThroughputDataPoint {
+	result := make([]ThroughputDataPoint, 0)
+	for i, value := range values {
+		result = append(result, ThroughputDataPoint{Throughput: value, Connections: i + 1})
+	}
+	return result
+}
+
+func TestAssessSaturation(t *testing.T) {
+	if assessment := AssessSaturation(throughputs(1, 2, 3), 2, 5.0); assessment.Assessed {
+		t.Fatalf("Too few measurements should not yield an assessment.")
+	}
+
+	plateau := AssessSaturation(throughputs(10, 50, 100, 101, 100, 102), 2, 5.0)
+	if !plateau.Assessed || !plateau.Saturated {
+		t.Fatalf("A throughput plateau should be considered saturated: %v", plateau)
+	}
+
+	growing := AssessSaturation(throughputs(10, 20, 40, 50, 60, 70), 2, 5.0)
+	if !growing.Assessed || growing.Saturated {
+		t.Fatalf("Growing throughput should not be considered saturated: %v", growing)
+	}
+	if !utilities.ApproximatelyEqual(growing.Headroom, 44.444, 0.01) {
+		t.Fatalf("Headroom should be about 44%% but is %v.", growing.Headroom)
+	}
+}
+
+func TestFinalMovingAverage(t *testing.T) {
+	if average := FinalMovingAverage(throughputs(10, 20, 30, 40), 2); average != 35 {
+		t.Fatalf("The final moving average should be 35 but is %v.", average)
+	}
+	if average := FinalMovingAverage(throughputs(10, 20), 4); average != 15 {
+		t.Fatalf("The final moving average of too few measurements should be 15 but is %v.", average)
+	}
+	if average := FinalMovingAverage(nil, 4); average != 0 {
+		t.Fatalf("The final moving average of no measurements should be 0 but is %v.", average)
+	}
+}
+
+func TestSaturationTime(t *testing.T) {
+	start := time.Now()
+	download, upload := throughputs(1, 2, 3), throughputs(1, 2, 3)
+	if _, saturated := SaturationTime(download, upload); saturated {
+		t.Fatalf("Throughput that never saturated should not have a saturation time.")
+	}
+	for i := range download {
+		download[i].Time = start.Add(time.Duration(i) * time.Second)
+		upload[i].Time = start.Add(time.Duration(i)*time.Second + 500*time.Millisecond)
+	}
+	download[2].Phase = phase.Saturated
+	upload[1].Phase, upload[2].Phase = phase.Saturated, phase.Saturated
+	if saturation, saturated := SaturationTime(download, upload); !saturated || !saturation.Equal(upload[1].Time) {
+		t.Fatalf("Throughput should have saturated at %v but saturated at %v.", upload[1].Time, saturation)
+	}
+}
+
+func TestPacingSteps(t *testing.T) {
+	steps := PacingSteps(50, 120, 10)
+	expected := []float64{50, 60, 70, 80, 90, 100, 110, 120}
+	if len(steps) != len(expected) {
+		t.Fatalf("Pacing steps should be %v but are %v.", expected, steps)
+	}
+	for i := range expected {
+		if !utilities.ApproximatelyEqual(steps[i], expected[i], 0.001) {
+			t.Fatalf("Pacing steps should be %v but are %v.", expected, steps)
+		}
+	}
+}
+
+func TestForeignRoundTripTime(t *testing.T) {
+	series := func(values ...float64) ms.MathematicalSeries[float64] {
+		result := ms.NewInfiniteMathematicalSeries[float64]()
+		for _, value := range values {
+			result.AddElement(value)
+		}
+		return result
+	}
+	average := func(series ms.MathematicalSeries[float64]) float64 { return series.CalculateAverage() }
+
+	rtt, ok := ForeignRoundTripTime(
+		[]ms.MathematicalSeries[float64]{series(0.010, 0.030), series(0.040), series(0.090)},
+		average,
+	)
+	if !ok || !utilities.ApproximatelyEqual(rtt, 0.050, 0.0001) {
+		t.Fatalf("Foreign RTT should be the average of the components (0.050) but is %v.", rtt)
+	}
+
+	rtt, ok = ForeignRoundTripTime(
+		[]ms.MathematicalSeries[float64]{series(0.020), series(), series(0.040)},
+		average,
+	)
+	if !ok || !utilities.ApproximatelyEqual(rtt, 0.030, 0.0001) {
+		t.Fatalf("Components without measurements should be left out (0.030) but foreign RTT is %v.", rtt)
+	}
+
+	if _, ok := ForeignRoundTripTime([]ms.MathematicalSeries[float64]{series(), series()}, average); ok {
+		t.Fatalf("There is no foreign RTT without any measurements.")
+	}
+}
+
+func TestThroughputRttCorrelation(t *testing.T) {
+	start := time.Now()
+	measurements := []ThroughputDataPoint{
+		{Time: start, Throughput: 1000},
+		{Time: start.Add(time.Second), Throughput: 2000},
+		{Time: start.Add(2 * time.Second), Throughput: 3000},
+	}
+	probes := []probe.ProbeDataPoint{
+		{Time: start.Add(-time.Second), Duration: 5 * time.Millisecond},
+		{Time: start.Add(500 * time.Millisecond), Duration: 10 * time.Millisecond},
+		{Time: start.Add(1500 * time.Millisecond), Duration: 20 * time.Millisecond},
+		{Time: start.Add(2500 * time.Millisecond), Duration: 30 * time.Millisecond},
+	}
+	pairs := PairThroughputAndRtt(measurements, probes)
+	if len(pairs) != 3 {
+		t.Fatalf("Probes before the first throughput measurement should be left out: %v", pairs)
+	}
+	if pairs[1].Throughput != 2000 || pairs[1].Rtt != 0.020 {
+		t.Fatalf("Probes should be paired with the preceding throughput measurement: %v", pairs)
+	}
+	correlation, ok := ThroughputRttCorrelation(pairs)
+	if !ok || !utilities.ApproximatelyEqual(correlation, 1.0, 0.001) {
+		t.Fatalf("RTT that grows linearly with throughput should be perfectly correlated but is %v.", correlation)
+	}
+
+	bins := BinThroughputAndRtt(pairs, 2)
+	if len(bins) != 2 || bins[0].Probes != 1 || bins[1].Probes != 2 {
+		t.Fatalf("Probes were not binned by throughput correctly: %v", bins)
+	}
+
+	if _, ok := ThroughputRttCorrelation(pairs[:1]); ok {
+		t.Fatalf("A single pair should not have a correlation.")
+	}
+}
+
+func TestHandshakeRttInflation(t *testing.T) {
+	start := time.Now()
+	dataPoints := make([]HandshakeRttDataPoint, 0)
+	for i, rtt := range []int{10, 12, 11, 20, 30, 40, 41, 39} {
+		dataPoints = append(dataPoints, HandshakeRttDataPoint{
+			Time: start.Add(time.Duration(i) * time.Second),
+			RTT:  time.Duration(rtt) * time.Millisecond,
+		})
+	}
+	inflation, ok := CalculateHandshakeRttInflation(dataPoints, 3)
+	if !ok {
+		t.Fatalf("Handshake RTT inflation should have been calculated.")
+	}
+	if !utilities.ApproximatelyEqual(inflation.Early, 0.011, 0.0001) ||
+		!utilities.ApproximatelyEqual(inflation.Late, 0.040, 0.0001) {
+		t.Fatalf("Handshake RTT should have inflated from 11ms to 40ms: %v", inflation)
+	}
+
+	// The windows should shrink rather than overlap.
+	inflation, ok = CalculateHandshakeRttInflation(dataPoints[:2], 3)
+	if !ok || inflation.Early != 0.010 || inflation.Late != 0.012 {
+		t.Fatalf("Windows should have shrunk to a single handshake each: %v", inflation)
+	}
+
+	if _, ok := CalculateHandshakeRttInflation(dataPoints[:1], 3); ok {
+		t.Fatalf("A single handshake cannot show inflation.")
+	}
+}
+
+func TestSpecVersionTrim(t *testing.T) {
+	series := ms.NewInfiniteMathematicalSeries[float64]()
+	for _, value := range utilities.Iota(1, 21) {
+		series.AddElement(float64(value))
+	}
+
+	draft02, err := LookupSpecVersion("draft-02")
+	if err != nil {
+		t.Fatalf("Could not look up draft-02: %v", err)
+	}
+	if trimmed := draft02.Trim(series, 10); trimmed.Len() != 16 || trimmed.CalculateAverage() != 10.5 {
+		t.Fatalf("A double-sided trim of 10%% should keep 3 through 18: %v", trimmed.Values())
+	}
+
+	draft04, err := LookupSpecVersion("draft-04")
+	if err != nil {
+		t.Fatalf("Could not look up draft-04: %v", err)
+	}
+	if trimmed := draft04.Trim(series, 10); trimmed.Len() != 18 || trimmed.CalculateAverage() != 9.5 {
+		t.Fatalf("A top trim of 10%% should keep 1 through 18: %v", trimmed.Values())
+	}
+	if label := draft04.TrimmedMeanLabel(5); label != "Top 5% Trimmed Mean" {
+		t.Fatalf("The draft-04 trimmed mean is mislabeled: %s", label)
+	}
+	if label := draft02.TrimmedMeanLabel(0); label != "Mean" {
+		t.Fatalf("An untrimmed mean is mislabeled: %s", label)
+	}
+
+	if version, err := LookupSpecVersion(""); err != nil || version.Name != DefaultSpecVersion {
+		t.Fatalf("Looking up no version should give the default version.")
+	}
+	if _, err := LookupSpecVersion("draft-99"); err == nil {
+		t.Fatalf("Looking up an unknown version should fail.")
+	}
+}
+
+func TestProbeTraffic(t *testing.T) {
+	start := time.Now()
+	traffic := NewProbeTraffic(start)
+	traffic.Add(probe.ProbeDataPoint{SentBytes: 100, ReceivedBytes: 1000})
+	traffic.Add(probe.ProbeDataPoint{SentBytes: 50, ReceivedBytes: 500})
+
+	if throughput := traffic.SentThroughput(start.Add(500 * time.Millisecond)); throughput != 300 {
+		t.Fatalf("Probes that sent 150 bytes in half a second should have sent 300 B/s but sent %v B/s.", throughput)
+	}
+	if throughput := traffic.SentThroughput(start.Add(time.Second)); throughput != 0 {
+		t.Fatalf("Nothing was sent in the second interval but the throughput is %v B/s.", throughput)
+	}
+	if throughput := traffic.ReceivedThroughput(start.Add(time.Second)); throughput != 1500 {
+		t.Fatalf("Probes that received 1500 bytes in a second should have received 1500 B/s but received %v B/s.", throughput)
+	}
+	if traffic.SentBytes != 150 || traffic.ReceivedBytes != 1500 {
+		t.Fatalf("The totals should not be reset by the intervals: %v sent, %v received.", traffic.SentBytes, traffic.ReceivedBytes)
+	}
+}
+
+func TestPhaseStatistics(t *testing.T) {
+	start := time.Now()
+	statistics := NewPhaseStatistics()
+	statistics.AddProbe(probe.ProbeDataPoint{
+		Time: start.Add(2 * time.Second), Type: probe.SelfDown, RoundTripCount: 1,
+		Duration: 50 * time.Millisecond, Phase: phase.Saturated,
+	})
+	statistics.AddProbe(probe.ProbeDataPoint{
+		Time: start, Type: probe.Foreign, RoundTripCount: 3, Duration: 30 * time.Millisecond, Phase: phase.Idle,
+	})
+	statistics.AddProbe(probe.ProbeDataPoint{
+		Time: start, Type: probe.Foreign, RoundTripCount: 3, TimedOut: true, Phase: phase.Draining,
+	})
+	statistics.AddDownloadThroughput(ThroughputDataPoint{Time: start.Add(time.Second), Throughput: 1000, Phase: phase.Saturated})
+	statistics.AddDownloadThroughput(ThroughputDataPoint{Time: start.Add(3 * time.Second), Throughput: 3000, Phase: phase.Saturated})
+
+	summaries := statistics.Summaries()
+	if len(summaries) != 2 || summaries[0].Phase != "idle" || summaries[1].Phase != "saturated" {
+		t.Fatalf("There should be idle and saturated phases (in that order): %v", summaries)
+	}
+	if summaries[0].ForeignProbes != 1 || !utilities.ApproximatelyEqual(summaries[0].ForeignRttP50, 0.010, 0.0001) {
+		t.Fatalf("The idle phase should have a single foreign probe with a 10ms RTT: %v", summaries[0])
+	}
+	saturated := summaries[1]
+	if saturated.SelfProbes != 1 || saturated.DownloadThroughput != 2000 || saturated.UploadThroughput != 0 {
+		t.Fatalf("The saturated phase was summarized incorrectly: %v", saturated)
+	}
+	if !saturated.Start.Equal(start.Add(time.Second)) || !saturated.End.Equal(start.Add(3*time.Second)) {
+		t.Fatalf("The saturated phase should span its measurements: %v", saturated)
+	}
+}
+
+// A load-generating connection that is only ever in one state.
+type fakeConnection struct {
+	id     uint64
+	status lgc.LgcStatus
+	err    error
+}
+
+func (c *fakeConnection) Start(context.Context, debug.DebugLevel) bool {
+	c.status = lgc.LGC_STATUS_RUNNING
+	if c.err != nil {
+		c.status = lgc.LGC_STATUS_ERROR
+	}
+	return true
+}
+func (c *fakeConnection) TransferredInInterval() (uint64, time.Duration) { return 0, time.Second }
+func (c *fakeConnection) Client() *http.Client                           { return nil }
+func (c *fakeConnection) Status() lgc.LgcStatus                          { return c.status }
+func (c *fakeConnection) Err() error                                     { return c.err }
+func (c *fakeConnection) ClientId() uint64                               { return c.id }
+func (c *fakeConnection) Stats() *stats.TraceStats                       { return nil }
+func (c *fakeConnection) Identity() lgc.ConnectionIdentity               { return lgc.ConnectionIdentity{} }
+func (c *fakeConnection) WaitUntilStarted(context.Context) bool          { return true }
+
+func TestReplaceFlows(t *testing.T) {
+	collection := lgc.NewLoadGeneratingConnectionCollection()
+	*collection.LGCs = []lgc.LoadGeneratingConnection{
+		&fakeConnection{id: 1, status: lgc.LGC_STATUS_RUNNING},
+		&fakeConnection{id: 2, status: lgc.LGC_STATUS_ERROR},
+	}
+	nextId := uint64(3)
+	generator := func() lgc.LoadGeneratingConnection {
+		nextId++
+		return &fakeConnection{id: nextId - 1}
+	}
+
+	replaced := replaceFlows(context.Background(), []int{1}, &collection, generator, debug.NewDebugWithPrefix(debug.NoDebug, "test"))
+	if replaced != 1 || collection.Len() != 2 {
+		t.Fatalf("The failed connection should have been replaced in place (%d replaced, %d connections).", replaced, collection.Len())
+	}
+	if replacement := (*collection.LGCs)[1]; replacement.ClientId() != 3 || replacement.Status() != lgc.LGC_STATUS_RUNNING {
+		t.Fatalf("The replacement should have been started in the place of the failed connection.")
+	}
+	if (*collection.LGCs)[0].ClientId() != 1 {
+		t.Fatalf("The connection that did not fail should have been left alone.")
+	}
+}
+
+func TestLoadGeneratorStopsOnErrorResponse(t *testing.T) {
+	notFound := &lgc.ResponseError{URL: "https://example.com/large", StatusCode: 404, Status: "404 Not Found"}
+	nextId := uint64(0)
+	generator := func() lgc.LoadGeneratingConnection {
+		nextId++
+		return &fakeConnection{id: nextId, err: notFound}
+	}
+	collection := lgc.NewLoadGeneratingConnectionCollection()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, throughputs := LoadGenerator(ctx, ctx, 0, generator, &collection, false, debug.NewDebugWithPrefix(debug.NoDebug, "test"))
+	select {
+	case throughput := <-throughputs:
+		if throughput.Failure != notFound || throughput.ErrorResponses != collection.Len() {
+			t.Fatalf("The load generator should have stopped because of the error responses: %v", throughput)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The load generator should have stopped because of the error responses.")
+	}
+}
+
+// A fake connection that has made its network connection (on a port of its own).
+type addressedConnection struct {
+	fakeConnection
+}
+
+func (c *addressedConnection) Identity() lgc.ConnectionIdentity {
+	return lgc.ConnectionIdentity{
+		Network:       "tcp",
+		LocalAddress:  fmt.Sprintf("192.0.2.1:%d", 40000+c.id),
+		RemoteAddress: "192.0.2.2:443",
+		HTTPProtocol:  "HTTP/2",
+	}
+}
+
+func TestLoadGeneratorReportsConnections(t *testing.T) {
+	nextId := uint64(0)
+	generator := func() lgc.LoadGeneratingConnection {
+		nextId++
+		return &addressedConnection{fakeConnection{id: nextId}}
+	}
+	collection := lgc.NewLoadGeneratingConnectionCollection()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, throughputs := LoadGenerator(ctx, ctx, 0, generator, &collection, false, debug.NewDebugWithPrefix(debug.NoDebug, "test"))
+	reported := make(map[uint64]int)
+	for interval := 0; interval < 2; interval++ {
+		select {
+		case throughput := <-throughputs:
+			for _, granular := range throughput.GranularThroughputDataPoints {
+				if granular.ClientID == 0 {
+					t.Fatalf("A granular throughput data point should name its connection: %v", granular)
+				}
+			}
+			for _, connection := range throughput.ConnectionDataPoints {
+				if connection.Protocol != "tcp" || connection.LocalAddress == "" {
+					t.Fatalf("The connection was reported incorrectly: %v", connection)
+				}
+				reported[connection.ClientID]++
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The load generator should have measured throughput.")
+		}
+	}
+	if len(reported) == 0 {
+		t.Fatalf("The load generator should have reported its connections.")
+	}
+	for id, count := range reported {
+		if count != 1 {
+			t.Fatalf("Connection %d was reported %d times rather than once.", id, count)
+		}
+	}
+}
+
+func TestWireOverhead(t *testing.T) {
+	plain := WireOverhead(lgc.ConnectionIdentity{HTTPProtocol: "HTTP/1.1", RemoteAddress: "192.0.2.1:80"}, 0)
+	if expected := 1500.0 / DefaultMSS; math.Abs(plain-expected) > 1e-9 {
+		t.Fatalf("HTTP/1.1 without TLS should only carry the TCP/IP headers (%v, not %v).", expected, plain)
+	}
+	encrypted := WireOverhead(lgc.ConnectionIdentity{HTTPProtocol: "HTTP/2", TLS: true, RemoteAddress: "192.0.2.1:443"}, 0)
+	ipv6 := WireOverhead(lgc.ConnectionIdentity{HTTPProtocol: "HTTP/2", TLS: true, RemoteAddress: "[2001:db8::1]:443"}, 0)
+	jumbo := WireOverhead(lgc.ConnectionIdentity{HTTPProtocol: "HTTP/2", TLS: true, RemoteAddress: "192.0.2.1:443"}, 8948)
+	if !(plain < encrypted && encrypted < ipv6 && jumbo < encrypted) {
+		t.Fatalf("Overheads are out of order: plain %v, encrypted %v, IPv6 %v, jumbo %v", plain, encrypted, ipv6, jumbo)
+	}
+	if average := AverageWireOverhead(nil, 0); average != 1 {
+		t.Fatalf("Without connections, there should be no overhead (not %v).", average)
+	}
+}
+
+func TestSelfProbeConnections(t *testing.T) {
+	connected := func(id uint64) *connectedConnection {
+		return &connectedConnection{fakeConnection{id: id, status: lgc.LGC_STATUS_RUNNING}}
+	}
+	collection := lgc.NewLoadGeneratingConnectionCollection()
+	*collection.LGCs = []lgc.LoadGeneratingConnection{
+		connected(1),
+		// Running, but not connected yet (a probe would not reuse its connection).
+		&fakeConnection{id: 2, status: lgc.LGC_STATUS_RUNNING},
+		connected(3),
+		&connectedConnection{fakeConnection{id: 4, status: lgc.LGC_STATUS_ERROR}},
+	}
+	ids := func(connections []lgc.LoadGeneratingConnection) []uint64 {
+		result := make([]uint64, 0)
+		for _, connection := range connections {
+			result = append(result, connection.ClientId())
+		}
+		return result
+	}
+	first := (*collection.LGCs)[0]
+
+	if got := ids(selfProbeConnections(FirstConnectionSelfProbes, 5, first, &collection)); !reflect.DeepEqual(got, []uint64{1}) {
+		t.Fatalf("Self probes should all go on the first connection, not %v.", got)
+	}
+	if got := ids(selfProbeConnections(FanOutSelfProbes, 5, first, &collection)); !reflect.DeepEqual(got, []uint64{1, 3}) {
+		t.Fatalf("Self probes should go on every connected connection, not %v.", got)
+	}
+	for round, expected := range []uint64{1, 3, 1} {
+		if got := ids(selfProbeConnections(RoundRobinSelfProbes, round, first, &collection)); !reflect.DeepEqual(got, []uint64{expected}) {
+			t.Fatalf("Round %d of self probes should go on connection %d, not %v.", round, expected, got)
+		}
+	}
+
+	lost := &connectedConnection{fakeConnection{id: 5, status: lgc.LGC_STATUS_ERROR}}
+	if got := ids(selfProbeConnections(FirstConnectionSelfProbes, 5, lost, &collection)); !reflect.DeepEqual(got, []uint64{1}) {
+		t.Fatalf("Once the first connection is lost, self probes should go on the first that runs, not %v.", got)
+	}
+
+	empty := lgc.NewLoadGeneratingConnectionCollection()
+	if got := selfProbeConnections(RoundRobinSelfProbes, 0, first, &empty); len(got) != 0 {
+		t.Fatalf("Without connections, there should be nothing to probe on, not %v.", ids(got))
+	}
+	if got := selfProbeConnections(FirstConnectionSelfProbes, 0, lost, &empty); len(got) != 0 {
+		t.Fatalf("Without running connections, there should be nothing to probe on, not %v.", ids(got))
+	}
+	if _, err := ParseSelfProbeMode("everywhere"); err == nil {
+		t.Fatalf("An unknown self probe mode should not parse.")
+	}
+}
+
+// A fake connection that has made its connection.
+type connectedConnection struct {
+	fakeConnection
+}
+
+func (c *connectedConnection) Identity() lgc.ConnectionIdentity {
+	return lgc.ConnectionIdentity{HTTPProtocol: "HTTP/2"}
+}
+
+// A connected fake connection that counts the self probes sent on it and that can be lost
+// while a test runs.
+type probedConnection struct {
+	connectedConnection
+	lost   int32
+	probes int32
+}
+
+func (c *probedConnection) Status() lgc.LgcStatus {
+	if atomic.LoadInt32(&c.lost) != 0 {
+		return lgc.LGC_STATUS_ERROR
+	}
+	return lgc.LGC_STATUS_RUNNING
+}
+
+func (c *probedConnection) Client() *http.Client {
+	atomic.AddInt32(&c.probes, 1)
+	return nil
+}
+
+func TestCombinedProberReplacedFirstConnection(t *testing.T) {
+	probed := func(id uint64) *probedConnection {
+		return &probedConnection{connectedConnection: connectedConnection{fakeConnection{id: id}}}
+	}
+	first, upload := probed(1), probed(2)
+	downloads, uploads := lgc.NewLoadGeneratingConnectionCollection(), lgc.NewLoadGeneratingConnectionCollection()
+	*downloads.LGCs = []lgc.LoadGeneratingConnection{first}
+	*uploads.LGCs = []lgc.LoadGeneratingConnection{upload}
+	configuration := func() probe.ProbeConfiguration {
+		return probe.ProbeConfiguration{URL: "https://example.com/small"}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dataPoints := CombinedProber(
+		ctx, ctx, configuration, configuration, first, upload, FirstConnectionSelfProbes, &downloads, &uploads,
+		10*time.Millisecond, time.Hour, 0, probe.NoConnectProbes, ForeignProbeReuse{}, NewProbeBudget(0), nil,
+		false, debug.NewDebugWithPrefix(debug.NoDebug, "test"),
+	)
+	waitForProbes := func(connection *probedConnection) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if atomic.LoadInt32(&connection.probes) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	if !waitForProbes(first) {
+		t.Fatalf("The self probes should have gone on the first connection.")
+	}
+
+	// Kill the first connection and replace it (the way that the load generator does).
+	replacement := probed(3)
+	downloads.Lock.Lock()
+	atomic.StoreInt32(&first.lost, 1)
+	downloads.Replace(0, replacement)
+	downloads.Lock.Unlock()
+	if !waitForProbes(replacement) {
+		t.Fatalf("The self probes should have moved to the replacement of the first connection.")
+	}
+
+	cancel()
+	for range dataPoints {
+	}
+	if atomic.LoadInt32(&upload.probes) == 0 {
+		t.Fatalf("The self probes should have kept going on the upload connection.")
+	}
+}
+
+func TestForeignProbeClients(t *testing.T) {
+	for _, test := range []struct {
+		policy string
+		// Which of the probes (by index) got the same client as the probe before them.
+		shared []bool
+	}{
+		{"none", []bool{false, false, false, false, false}},
+		{"every:2", []bool{false, true, false, true, false}},
+		{"pool:2", []bool{false, false, false, false, false}},
+	} {
+		reuse, err := ParseForeignProbeReuse(test.policy)
+		if err != nil || reuse.String() != test.policy {
+			t.Fatalf("Could not parse the foreign probe reuse policy %s: %v", test.policy, err)
+		}
+		clients := newForeignProbeClients(reuse, nil, debug.NewDebugWithPrefix(debug.NoDebug, "test"))
+		handedOut := make([]*http.Client, 0)
+		for i, shared := range test.shared {
+			client := clients.next(probe.ProbeConfiguration{URL: "https://example.com/small"})
+			if i > 0 && (client == handedOut[i-1]) != shared {
+				t.Fatalf("With %s, probe %d should (%v) have shared the client of the probe before it.", test.policy, i, shared)
+			}
+			handedOut = append(handedOut, client)
+		}
+		clients.close()
+		if reuse.Mode == ForeignProbeConnectionPool && (handedOut[2] != handedOut[0] || handedOut[3] != handedOut[1]) {
+			t.Fatalf("With %s, the probes should have taken turns on the pool.", test.policy)
+		}
+	}
+
+	for _, policy := range []string{"every", "every:0", "pool:x", "sometimes:2"} {
+		if _, err := ParseForeignProbeReuse(policy); err == nil {
+			t.Fatalf("The foreign probe reuse policy %s should not parse.", policy)
+		}
+	}
+}
+
+func TestGradeBufferbloat(t *testing.T) {
+	for _, test := range []struct {
+		idle   float64
+		loaded float64
+		grade  string
+	}{
+		{0.020, 0.022, "A+"},
+		{0.020, 0.010, "A+"},
+		{0.020, 0.045, "A"},
+		{0.020, 0.075, "B"},
+		{0.020, 0.200, "C"},
+		{0.020, 0.400, "D"},
+		{0.020, 1.500, "F"},
+	} {
+		grade, graded := GradeBufferbloat(test.idle, test.loaded)
+		if !graded || grade.Grade != test.grade {
+			t.Fatalf("Latency growing from %v to %v should have graded %s, not %v.", test.idle, test.loaded, test.grade, grade)
+		}
+	}
+	if grade, graded := GradeBufferbloat(0.020, 0.080); !utilities.ApproximatelyEqual(grade.Increase, 0.060, 1e-9) || !utilities.ApproximatelyEqual(grade.Inflation, 4, 1e-9) || !graded {
+		t.Fatalf("The grade should have the growth of latency: %v", grade)
+	}
+	if _, graded := GradeBufferbloat(math.NaN(), 0.080); graded {
+		t.Fatalf("There should be no grade without an idle latency.")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"fmt"
+
+	"github.com/network-quality/goresponsiveness/lgc"
+)
+
+// Which load-generating connections the self probes are sent on. The first connection's
+// queue is not necessarily representative of the others', so the probes can be spread
+// across all of them.
+type SelfProbeMode int
+
+const (
+	// Every self probe goes on the first connection (in each direction).
+	FirstConnectionSelfProbes SelfProbeMode = iota
+	// Every round of self probes goes on the next (connected) connection in turn.
+	RoundRobinSelfProbes
+	// Every round of self probes goes on every (connected) connection.
+	FanOutSelfProbes
+)
+
+func ParseSelfProbeMode(mode string) (SelfProbeMode, error) {
+	switch mode {
+	case "", "first":
+		return FirstConnectionSelfProbes, nil
+	case "round-robin":
+		return RoundRobinSelfProbes, nil
+	case "fan-out":
+		return FanOutSelfProbes, nil
+	}
+	return FirstConnectionSelfProbes, fmt.Errorf("unrecognized self probe mode: %s", mode)
+}
+
+func (mode SelfProbeMode) String() string {
+	switch mode {
+	case RoundRobinSelfProbes:
+		return "round-robin"
+	case FanOutSelfProbes:
+		return "fan-out"
+	}
+	return "first"
+}
+
+// The connections of the collection that the given round (counting from 0) of self probes
+// goes on. first is the connection that FirstConnectionSelfProbes uses while it runs; once
+// it stops (e.g., it failed and was replaced), that mode moves on to the first connection of
+// the collection that runs. Otherwise, only connections that are running and already
+// connected (a self probe must reuse the connection) are picked. Either way, there may be
+// none.
+func selfProbeConnections(
+	mode SelfProbeMode,
+	round int,
+	first lgc.LoadGeneratingConnection,
+	collection *lgc.LoadGeneratingConnectionCollection,
+) []lgc.LoadGeneratingConnection {
+	if collection == nil || (mode == FirstConnectionSelfProbes && first.Status() == lgc.LGC_STATUS_RUNNING) {
+		return []lgc.LoadGeneratingConnection{first}
+	}
+
+	connected := make([]lgc.LoadGeneratingConnection, 0)
+	collection.Lock.Lock()
+	for i := 0; i < collection.Len(); i++ {
+		connection, _ := collection.Get(i)
+		if (*connection).Status() == lgc.LGC_STATUS_RUNNING && (*connection).Identity().HTTPProtocol != "" {
+			connected = append(connected, *connection)
+		}
+	}
+	collection.Lock.Unlock()
+
+	if (mode == FirstConnectionSelfProbes || mode == RoundRobinSelfProbes) && len(connected) > 0 {
+		index := 0
+		if mode == RoundRobinSelfProbes {
+			index = round % len(connected)
+		}
+		return []lgc.LoadGeneratingConnection{connected[index]}
+	}
+	if mode == FirstConnectionSelfProbes {
+		return []lgc.LoadGeneratingConnection{}
+	}
+	return connected
+}
@@ -0,0 +1,121 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/network-quality/goresponsiveness/ms"
+)
+
+// The RPM methodology has changed from one draft of the specification to the next. A
+// SpecVersion captures how a draft (as we implement it) aggregates the probes' RTTs.
+type SpecVersion struct {
+	Name string
+	// Whether the RTT of a foreign probe is the average of its TCP, TLS and HTTP components
+	// (see ForeignRoundTripTime) rather than its duration split evenly across its round trips.
+	ForeignComponents bool
+	// Whether the trimmed mean only trims the largest RTTs (rather than the largest and
+	// the smallest).
+	TrimUpperOnly bool
+	// The percentage of the RTTs that are trimmed (from each trimmed end) unless the user
+	// chooses otherwise.
+	DefaultTrimPercentage uint
+}
+
+const DefaultSpecVersion = "draft-02"
+
+var SpecVersions = []SpecVersion{
+	{Name: "draft-01", ForeignComponents: false, TrimUpperOnly: false, DefaultTrimPercentage: 10},
+	{Name: "draft-02", ForeignComponents: true, TrimUpperOnly: false, DefaultTrimPercentage: 10},
+	{Name: "draft-04", ForeignComponents: true, TrimUpperOnly: true, DefaultTrimPercentage: 5},
+}
+
+func LookupSpecVersion(name string) (SpecVersion, error) {
+	if name == "" {
+		name = DefaultSpecVersion
+	}
+	for _, version := range SpecVersions {
+		if version.Name == name {
+			return version, nil
+		}
+	}
+	return SpecVersion{}, fmt.Errorf("unrecognized specification version: %s", name)
+}
+
+func SpecVersionNames() []string {
+	names := make([]string, 0, len(SpecVersions))
+	for _, version := range SpecVersions {
+		names = append(names, version.Name)
+	}
+	return names
+}
+
+// Trim the given percentage of the RTTs the way that this version of the specification does.
+// Trimming only the largest RTTs needs every RTT, so it does not work with series (like
+// t-digests) that only keep a summary of them.
+func (sv SpecVersion) Trim(series ms.MathematicalSeries[float64], percent uint) ms.MathematicalSeries[float64] {
+	if !sv.TrimUpperOnly {
+		return series.DoubleSidedTrim(uint32(percent))
+	}
+	values := append([]float64(nil), series.Values()...)
+	sort.Float64s(values)
+	trimmed := ms.NewInfiniteMathematicalSeries[float64]()
+	for _, value := range values[:len(values)-int(float64(len(values))*float64(percent)/100.0)] {
+		trimmed.AddElement(value)
+	}
+	return trimmed
+}
+
+// The P90 and the trimmed mean of the RTTs. When this version of the specification breaks
+// foreign probes into their components (see ForeignRoundTripTime) and there are components,
+// they take the place of the RTTs.
+func (sv SpecVersion) RoundTripTimes(
+	rtts ms.MathematicalSeries[float64],
+	componentRtts []ms.MathematicalSeries[float64],
+	percent uint,
+) (p90 float64, trimmedMean float64) {
+	p90, trimmedMean = rtts.Percentile(90), sv.Trim(rtts, percent).CalculateAverage()
+	if !sv.ForeignComponents {
+		return
+	}
+	if componentsP90, ok := ForeignRoundTripTime(
+		componentRtts,
+		func(series ms.MathematicalSeries[float64]) float64 { return series.Percentile(90) },
+	); ok {
+		p90 = componentsP90
+	}
+	if componentsMean, ok := ForeignRoundTripTime(
+		componentRtts,
+		func(series ms.MathematicalSeries[float64]) float64 {
+			return sv.Trim(series, percent).CalculateAverage()
+		},
+	); ok {
+		trimmedMean = componentsMean
+	}
+	return
+}
+
+// How the trimmed mean is labeled in the results.
+func (sv SpecVersion) TrimmedMeanLabel(percent uint) string {
+	if percent == 0 {
+		return "Mean"
+	}
+	if sv.TrimUpperOnly {
+		return fmt.Sprintf("Top %d%% Trimmed Mean", percent)
+	}
+	return fmt.Sprintf("Double-Sided %d%% Trimmed Mean", percent)
+}
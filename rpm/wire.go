@@ -0,0 +1,76 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"net"
+
+	"github.com/network-quality/goresponsiveness/lgc"
+)
+
+// The MSS that the wire overhead assumes when the extended stats do not say: a 1500-byte MTU
+// less the IPv4 and TCP headers.
+const DefaultMSS = 1500 - ipv4HeaderBytes - tcpHeaderBytes
+
+const (
+	// HTTP/2 puts a 9-byte header on every DATA frame; servers usually fill frames up to the
+	// default maximum frame size.
+	http2FrameHeaderBytes  = 9
+	http2FramePayloadBytes = 16384
+	// A TLS 1.3 record has a 5-byte header and, inside the encryption, a byte for the content
+	// type and a 16-byte AEAD tag; a full record carries 16384 bytes.
+	tlsRecordOverheadBytes = 5 + 1 + 16
+	tlsRecordPayloadBytes  = 16384
+	ipv4HeaderBytes        = 20
+	ipv6HeaderBytes        = 40
+	// With the timestamp option (which every major stack negotiates).
+	tcpHeaderBytes = 20 + 12
+)
+
+// Estimate how many bytes go over the wire (as TCP/IP packets; the link layer's framing is not
+// counted) for every byte of HTTP body that a connection with identity carries in segments of
+// mss bytes (0 for DefaultMSS). The estimate assumes full frames, records and segments, so it
+// is a lower bound.
+func WireOverhead(identity lgc.ConnectionIdentity, mss uint64) float64 {
+	overhead := 1.0
+	if identity.HTTPProtocol == "HTTP/2" {
+		overhead *= 1 + float64(http2FrameHeaderBytes)/http2FramePayloadBytes
+	}
+	if identity.TLS {
+		overhead *= 1 + float64(tlsRecordOverheadBytes)/tlsRecordPayloadBytes
+	}
+	if mss == 0 {
+		mss = DefaultMSS
+	}
+	headers := ipv4HeaderBytes + tcpHeaderBytes
+	if host, _, err := net.SplitHostPort(identity.RemoteAddress); err == nil {
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			headers = ipv6HeaderBytes + tcpHeaderBytes
+		}
+	}
+	return overhead * (1 + float64(headers)/float64(mss))
+}
+
+// The average WireOverhead of the connections with identities (1 when there are none).
+func AverageWireOverhead(identities []lgc.ConnectionIdentity, mss uint64) float64 {
+	if len(identities) == 0 {
+		return 1
+	}
+	total := 0.0
+	for _, identity := range identities {
+		total += WireOverhead(identity, mss)
+	}
+	return total / float64(len(identities))
+}
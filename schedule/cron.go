@@ -0,0 +1,152 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A cron expression (minute, hour, day of the month, month and day of the week) that
+// matches the minutes when a test should run. Each field is *, a number, a range (a-b), a
+// step (*/n or a-b/n) or a comma-separated list of those. Days of the week are 0 (Sunday)
+// through 6 (7 is also Sunday). As with cron, when both days are restricted, a minute
+// matches when either of them does.
+type Expression struct {
+	source      string
+	minutes     []bool
+	hours       []bool
+	daysOfMonth []bool
+	months      []bool
+	daysOfWeek  []bool
+	// Whether the days of the month (or week) were restricted (i.e., not *).
+	daysOfMonthRestricted bool
+	daysOfWeekRestricted  bool
+}
+
+var macros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+func ParseExpression(source string) (Expression, error) {
+	expanded := source
+	if macro, ok := macros[strings.TrimSpace(source)]; ok {
+		expanded = macro
+	}
+	fields := strings.Fields(expanded)
+	if len(fields) != 5 {
+		return Expression{}, fmt.Errorf("cron expression %q does not have 5 fields", source)
+	}
+	expression := Expression{source: strings.TrimSpace(source)}
+	var err error
+	if expression.minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return Expression{}, fmt.Errorf("minute of %q: %w", source, err)
+	}
+	if expression.hours, err = parseField(fields[1], 0, 23); err != nil {
+		return Expression{}, fmt.Errorf("hour of %q: %w", source, err)
+	}
+	if expression.daysOfMonth, err = parseField(fields[2], 1, 31); err != nil {
+		return Expression{}, fmt.Errorf("day of the month of %q: %w", source, err)
+	}
+	if expression.months, err = parseField(fields[3], 1, 12); err != nil {
+		return Expression{}, fmt.Errorf("month of %q: %w", source, err)
+	}
+	if expression.daysOfWeek, err = parseField(fields[4], 0, 7); err != nil {
+		return Expression{}, fmt.Errorf("day of the week of %q: %w", source, err)
+	}
+	expression.daysOfWeek[0] = expression.daysOfWeek[0] || expression.daysOfWeek[7]
+	expression.daysOfMonthRestricted = fields[2] != "*"
+	expression.daysOfWeekRestricted = fields[4] != "*"
+	return expression, nil
+}
+
+// The values (between minimum and maximum) that a field matches, indexed by value.
+func parseField(field string, minimum int, maximum int) ([]bool, error) {
+	matches := make([]bool, maximum+1)
+	for _, part := range strings.Split(field, ",") {
+		values, stepSpecification, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepSpecification); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepSpecification)
+			}
+		}
+		low, high := minimum, maximum
+		if values != "*" {
+			lowSpecification, highSpecification, ranged := strings.Cut(values, "-")
+			var err error
+			if low, err = strconv.Atoi(lowSpecification); err != nil {
+				return nil, fmt.Errorf("invalid value %q", lowSpecification)
+			}
+			high = low
+			if ranged {
+				if high, err = strconv.Atoi(highSpecification); err != nil {
+					return nil, fmt.Errorf("invalid value %q", highSpecification)
+				}
+			} else if stepped {
+				// As with cron, a/n means from a to the maximum.
+				high = maximum
+			}
+		}
+		if low < minimum || high > maximum || low > high {
+			return nil, fmt.Errorf("%q is not within %d-%d", part, minimum, maximum)
+		}
+		for value := low; value <= high; value += step {
+			matches[value] = true
+		}
+	}
+	return matches, nil
+}
+
+func (e Expression) matchesDay(t time.Time) bool {
+	dayOfMonth, dayOfWeek := e.daysOfMonth[t.Day()], e.daysOfWeek[int(t.Weekday())]
+	if e.daysOfMonthRestricted && e.daysOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// The first minute after t that the expression matches. False when there is none within
+// the next five years (e.g., 0 0 30 2 *).
+func (e Expression) Next(t time.Time) (time.Time, bool) {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		switch {
+		case !e.months[int(next.Month())]:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !e.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !e.hours[next.Hour()]:
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !e.minutes[next.Minute()]:
+			next = next.Add(time.Minute)
+		default:
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (e Expression) String() string {
+	return e.source
+}
@@ -0,0 +1,147 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package schedule
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A Schedule runs a test whenever its expression matches, with the arguments (flags) that
+// are particular to it (e.g., a longer test at night).
+type Schedule struct {
+	Expression Expression
+	Arguments  []string
+}
+
+// Parse a schedule of the form "EXPRESSION [ARGUMENTS]" (e.g., "0 3 * * * -ping").
+func ParseSchedule(specification string) (Schedule, error) {
+	fields := strings.Fields(specification)
+	expressionFields := 5
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		expressionFields = 1
+	}
+	if len(fields) < expressionFields {
+		return Schedule{}, fmt.Errorf("schedule %q does not start with a cron expression", specification)
+	}
+	expression, err := ParseExpression(strings.Join(fields[:expressionFields], " "))
+	if err != nil {
+		return Schedule{}, err
+	}
+	return Schedule{Expression: expression, Arguments: fields[expressionFields:]}, nil
+}
+
+// Check the schedule's arguments against the flags that its tests will be run with so that a
+// mistake shows up when the schedule is given rather than when it first runs.
+func (s Schedule) Validate(flags *flag.FlagSet) error {
+	for i := 0; i < len(s.Arguments); i++ {
+		if !strings.HasPrefix(s.Arguments[i], "-") {
+			return fmt.Errorf("schedule %q has an argument that is not a flag: %s", s, s.Arguments[i])
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(s.Arguments[i], "-"), "=")
+		defined := flags.Lookup(name)
+		if defined == nil {
+			return fmt.Errorf("schedule %q has an unknown flag: -%s", s, name)
+		}
+		if defined.Name == "schedule" {
+			return fmt.Errorf("schedule %q cannot have schedules of its own", s)
+		}
+		if boolFlag, ok := defined.Value.(interface{ IsBoolFlag() bool }); hasValue || (ok && boolFlag.IsBoolFlag()) {
+			continue
+		}
+		if i+1 == len(s.Arguments) {
+			return fmt.Errorf("schedule %q does not give a value for -%s", s, name)
+		}
+		i++
+	}
+	return nil
+}
+
+func (s Schedule) String() string {
+	return strings.TrimSpace(s.Expression.String() + " " + strings.Join(s.Arguments, " "))
+}
+
+type Schedules []Schedule
+
+func SchedulesFlag(name string, usage string) *Schedules {
+	schedules := &Schedules{}
+	flag.Var(schedules, name, usage)
+	return schedules
+}
+
+func (schedules *Schedules) String() string {
+	if schedules == nil {
+		return ""
+	}
+	specifications := make([]string, 0, len(*schedules))
+	for _, schedule := range *schedules {
+		specifications = append(specifications, schedule.String())
+	}
+	return strings.Join(specifications, "; ")
+}
+
+// Unlike other lists of flags, schedules cannot be separated by commas (which cron
+// expressions use), so give the flag once per schedule.
+func (schedules *Schedules) Set(value string) error {
+	schedule, err := ParseSchedule(value)
+	if err != nil {
+		return err
+	}
+	*schedules = append(*schedules, schedule)
+	return nil
+}
+
+// The first minute after t when any of the schedules matches and all the schedules that
+// match then. False when none of them will ever match.
+func (schedules Schedules) Next(t time.Time) (time.Time, Schedules, bool) {
+	earliest := time.Time{}
+	due := Schedules{}
+	for _, schedule := range schedules {
+		next, ok := schedule.Expression.Next(t)
+		if !ok {
+			continue
+		}
+		if earliest.IsZero() || next.Before(earliest) {
+			earliest, due = next, Schedules{schedule}
+		} else if next.Equal(earliest) {
+			due = append(due, schedule)
+		}
+	}
+	return earliest, due, !earliest.IsZero()
+}
+
+// Run the schedules until ctx is canceled. Tests run one at a time: a test whose time
+// comes while another is still running is skipped.
+func (schedules Schedules) Run(ctx context.Context, run func(Schedule)) error {
+	for {
+		next, due, ok := schedules.Next(time.Now())
+		if !ok {
+			return fmt.Errorf("none of the schedules will ever run")
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		for _, schedule := range due {
+			run(schedule)
+		}
+	}
+}
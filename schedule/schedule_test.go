@@ -0,0 +1,114 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package schedule
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestExpressionNext(t *testing.T) {
+	// A Wednesday.
+	start := time.Date(2023, time.March, 15, 10, 7, 30, 0, time.UTC)
+	for _, test := range []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"*/30 * * * *", time.Date(2023, time.March, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2023, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"15 2 * * 0", time.Date(2023, time.March, 19, 2, 15, 0, 0, time.UTC)},
+		{"15 2 * * 7", time.Date(2023, time.March, 19, 2, 15, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2023, time.March, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 4,6 *", time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day matches when both are restricted.
+		{"0 0 20 * 5", time.Date(2023, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	} {
+		expression, err := ParseExpression(test.expression)
+		if err != nil {
+			t.Fatalf("Could not parse %q: %v", test.expression, err)
+		}
+		if next, ok := expression.Next(start); !ok || !next.Equal(test.expected) {
+			t.Fatalf("%q should next match at %v but matches at %v.", test.expression, test.expected, next)
+		}
+	}
+
+	never, _ := ParseExpression("0 0 30 2 *")
+	if _, ok := never.Next(start); ok {
+		t.Fatalf("February 30th should never match.")
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, invalid := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"} {
+		if _, err := ParseExpression(invalid); err == nil {
+			t.Fatalf("%q should be invalid.", invalid)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Int("rpmtimeout", 20, "")
+	flags.Bool("ping", false, "")
+	flags.Var(&Schedules{}, "schedule", "")
+
+	for _, specification := range []string{"@daily", "@daily -rpmtimeout 60 -ping", "@daily --rpmtimeout=60 -ping=false"} {
+		schedule, err := ParseSchedule(specification)
+		if err != nil {
+			t.Fatalf("Could not parse schedule %q: %v", specification, err)
+		}
+		if err := schedule.Validate(flags); err != nil {
+			t.Fatalf("Schedule %q should be valid: %v", specification, err)
+		}
+	}
+	for _, specification := range []string{
+		"@daily -max-load-test-time 60",
+		"@daily -ping -rpmtimeout",
+		"@daily 60",
+		"@daily -schedule @hourly",
+	} {
+		schedule, err := ParseSchedule(specification)
+		if err != nil {
+			t.Fatalf("Could not parse schedule %q: %v", specification, err)
+		}
+		if err := schedule.Validate(flags); err == nil {
+			t.Fatalf("Schedule %q should not be valid.", specification)
+		}
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	schedules := Schedules{}
+	for _, specification := range []string{"0 * * * * -rpmtimeout 10", "@daily -rpmtimeout 60 -ping"} {
+		if err := schedules.Set(specification); err != nil {
+			t.Fatalf("Could not parse schedule %q: %v", specification, err)
+		}
+	}
+	if len(schedules[1].Arguments) != 3 || schedules[1].Arguments[2] != "-ping" {
+		t.Fatalf("The schedule's arguments were parsed incorrectly: %v", schedules[1].Arguments)
+	}
+
+	next, due, ok := schedules.Next(time.Date(2023, time.March, 15, 23, 30, 0, 0, time.UTC))
+	if !ok || !next.Equal(time.Date(2023, time.March, 16, 0, 0, 0, 0, time.UTC)) || len(due) != 2 {
+		t.Fatalf("Both schedules should be due at midnight: %v %v", next, due)
+	}
+	next, due, _ = schedules.Next(next)
+	if !next.Equal(time.Date(2023, time.March, 16, 1, 0, 0, 0, time.UTC)) || len(due) != 1 {
+		t.Fatalf("Only the hourly schedule should be due at 1 AM: %v %v", next, due)
+	}
+}
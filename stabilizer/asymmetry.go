@@ -0,0 +1,70 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"fmt"
+)
+
+// The parameters of a stabilizer (see rev3.go for the meaning of I, K and S). Aggregate is
+// the number of measurement intervals that are combined into one and Decay is the decay
+// factor of the EWMA algorithm. Not every algorithm uses every parameter.
+type StabilityParameters struct {
+	I         uint64
+	K         uint64
+	S         float64
+	Aggregate uint64
+	Decay     float64
+}
+
+func (sp StabilityParameters) String() string {
+	return fmt.Sprintf("I: %d, K: %d, S: %.1f%%, Interval: %dx", sp.I, sp.K, sp.S, sp.Aggregate)
+}
+
+// On a highly asymmetric link, measurements in the slow direction are noisy enough that
+// its stabilizer can end up dominating the total test time. When the throughput in one
+// direction is at least ratioThreshold times the throughput in the other, the parameters
+// for the slow direction are tuned: it gets twice the measurement interval, half the I and
+// K (so that it does not take any longer to fill its windows) and twice the S.
+//
+// The result reports whether the parameters were tuned.
+func TuneForAsymmetry(
+	slowThroughput float64,
+	fastThroughput float64,
+	ratioThreshold float64,
+	parameters StabilityParameters,
+) (StabilityParameters, bool) {
+	if slowThroughput <= 0 || fastThroughput/slowThroughput < ratioThreshold {
+		return parameters, false
+	}
+	halve := func(value uint64) uint64 {
+		// We need at least two values in each window to calculate a standard deviation.
+		if value/2 < 2 {
+			return 2
+		}
+		return value / 2
+	}
+	aggregate := parameters.Aggregate
+	if aggregate == 0 {
+		aggregate = 1
+	}
+	return StabilityParameters{
+		I:         halve(parameters.I),
+		K:         halve(parameters.K),
+		S:         parameters.S * 2,
+		Aggregate: aggregate * 2,
+		Decay:     parameters.Decay,
+	}, true
+}
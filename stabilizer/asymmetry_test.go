@@ -0,0 +1,58 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
+func TestTuneForAsymmetry(t *testing.T) {
+	parameters := StabilityParameters{I: 4, K: 4, S: 5.0, Aggregate: 1}
+
+	if _, tuned := TuneForAsymmetry(50e6, 100e6, 10.0, parameters); tuned {
+		t.Fatalf("A mildly asymmetric link should not be tuned.")
+	}
+
+	tuned, ok := TuneForAsymmetry(1e6, 100e6, 10.0, parameters)
+	if !ok {
+		t.Fatalf("A highly asymmetric link should be tuned.")
+	}
+	expected := StabilityParameters{I: 2, K: 2, S: 10.0, Aggregate: 2}
+	if tuned != expected {
+		t.Fatalf("Tuned parameters should be %v but are %v.", expected, tuned)
+	}
+}
+
+func TestAggregatingThroughputStabilizer(t *testing.T) {
+	stabilizer := NewAggregatingThroughputStabilizer(
+		2, 2, 2, 5.0, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test"),
+	)
+	// Alternating measurements are wildly noisy on their own but perfectly stable once
+	// every pair of them is averaged together.
+	for i := 0; i < 8; i++ {
+		throughput := float64(1e6)
+		if i%2 == 1 {
+			throughput = 3e6
+		}
+		stabilizer.AddMeasurement(rpm.ThroughputDataPoint{Time: time.Now(), Throughput: throughput})
+	}
+	if !stabilizer.IsStable() {
+		t.Fatalf("Aggregated measurements should be stable.")
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"fmt"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
+// Coefficient of Variation Stabilizer Algorithm:
+// Stabilization is achieved when the standard deviation of the I * K most recent
+// instantaneous measurements is within S percent of their mean. Unlike the Rev3 algorithm,
+// the measurements are not smoothed with moving averages first; for probes, each
+// measurement is the mean RTT over an interval (see newValueProbeStabilizer).
+type coefficientOfVariation struct {
+	measurements *ms.WindowedMathematicalSeries[float64]
+	limit        float64
+	dbgLevel     debug.DebugLevel
+	dbgConfig    *debug.DebugWithPrefix
+}
+
+func newCoefficientOfVariation(
+	parameters StabilityParameters,
+	debugLevel debug.DebugLevel,
+	debug *debug.DebugWithPrefix,
+) *coefficientOfVariation {
+	return &coefficientOfVariation{
+		measurements: ms.NewWindowedMathematicalSeries[float64](parameters.I * parameters.K),
+		limit:        parameters.S,
+		dbgLevel:     debugLevel,
+		dbgConfig:    debug,
+	}
+}
+
+func (cov *coefficientOfVariation) add(value float64) {
+	cov.measurements.AddElement(value)
+}
+
+func (cov *coefficientOfVariation) isStable() bool {
+	average := cov.measurements.CalculateAverage()
+	if !cov.measurements.IsFull() || average == 0 {
+		return false
+	}
+	_, stddev := cov.measurements.StandardDeviation()
+	coefficient := stddev / average * 100.0
+	isStable := coefficient <= cov.limit
+
+	if debug.IsDebug(cov.dbgLevel) {
+		fmt.Printf(
+			"%s: Is Stable? %v; Coefficient of Variation: %.2f%%; Cutoff: %.2f%%.\n",
+			cov.dbgConfig.String(),
+			isStable,
+			coefficient,
+			cov.limit,
+		)
+	}
+	return isStable
+}
+
+func init() {
+	RegisterAlgorithm("cov", Algorithm{
+		Description: "the coefficient of variation of the most recent I*K measurements is at most S percent",
+		NewThroughputStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[rpm.ThroughputDataPoint] {
+			return newValueThroughputStabilizer(newCoefficientOfVariation(parameters, debugLevel, debug))
+		},
+		NewProbeStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[probe.ProbeDataPoint] {
+			return newValueProbeStabilizer(
+				newCoefficientOfVariation(parameters, debugLevel, debug),
+				constants.ProbeStabilityInterval,
+			)
+		},
+	})
+}
@@ -0,0 +1,183 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/rpm"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// EWMA Stabilizer Algorithm:
+// Like the Rev3 algorithm except that, instead of the moving average of the I most recent
+// instantaneous measurements, it uses an exponentially weighted moving average of all of
+// them: every new instantaneous measurement moves the average decay of the way toward
+// itself. A large decay tracks changes in the measurements quickly; a small decay smooths
+// out more of their noise. Stability is declared when the standard deviation of the K most
+// recent averages is within S percent of their mean.
+type EWMAStabilizer struct {
+	decay                      float64
+	average                    float64
+	averaged                   bool
+	averages                   ms.MathematicalSeries[float64]
+	stabilityStandardDeviation float64
+	m                          sync.Mutex
+	dbgLevel                   debug.DebugLevel
+	dbgConfig                  *debug.DebugWithPrefix
+}
+
+type (
+	EWMAProbeStabilizer      EWMAStabilizer
+	EWMAThroughputStabilizer EWMAStabilizer
+)
+
+func init() {
+	RegisterAlgorithm("ewma", Algorithm{
+		Description: "the standard deviation of the K most recent exponentially weighted moving averages is within S percent of their mean",
+		NewThroughputStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[rpm.ThroughputDataPoint] {
+			stabilizer := NewEWMAThroughputStabilizer(parameters.Decay, parameters.K, parameters.S, debugLevel, debug)
+			return &stabilizer
+		},
+		NewProbeStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[probe.ProbeDataPoint] {
+			stabilizer := NewEWMAProbeStabilizer(parameters.Decay, parameters.K, parameters.S, debugLevel, debug)
+			return &stabilizer
+		},
+	})
+}
+
+func newEWMAStabilizer(
+	decay float64,
+	k uint64,
+	s float64,
+	debugLevel debug.DebugLevel,
+	debug *debug.DebugWithPrefix,
+) EWMAStabilizer {
+	if decay <= 0 || decay > 1 {
+		panic(fmt.Sprintf("Cannot create an EWMA stabilizer with a decay of %v.", decay))
+	}
+	return EWMAStabilizer{
+		decay:                      decay,
+		averages:                   ms.NewCappedMathematicalSeries[float64](k),
+		stabilityStandardDeviation: s,
+		dbgConfig:                  debug,
+		dbgLevel:                   debugLevel,
+	}
+}
+
+func NewEWMAProbeStabilizer(
+	decay float64,
+	k uint64,
+	s float64,
+	debugLevel debug.DebugLevel,
+	debug *debug.DebugWithPrefix,
+) EWMAProbeStabilizer {
+	return EWMAProbeStabilizer(newEWMAStabilizer(decay, k, s, debugLevel, debug))
+}
+
+func NewEWMAThroughputStabilizer(
+	decay float64,
+	k uint64,
+	s float64,
+	debugLevel debug.DebugLevel,
+	debug *debug.DebugWithPrefix,
+) EWMAThroughputStabilizer {
+	return EWMAThroughputStabilizer(newEWMAStabilizer(decay, k, s, debugLevel, debug))
+}
+
+// The caller must hold the lock.
+func (ewma *EWMAStabilizer) add(measurement float64) {
+	if !ewma.averaged {
+		ewma.average = measurement
+		ewma.averaged = true
+	} else {
+		ewma.average += ewma.decay * (measurement - ewma.average)
+	}
+}
+
+func (ewma *EWMAStabilizer) isStable(units string) bool {
+	ewma.m.Lock()
+	defer ewma.m.Unlock()
+
+	isvalid, stddev := ewma.averages.StandardDeviation()
+	if !isvalid {
+		// Until there are K averages, we cannot know whether they have settled down.
+		return false
+	}
+
+	stabilityCutoff := ewma.averages.CalculateAverage() * (ewma.stabilityStandardDeviation / 100.0)
+	isStable := stddev <= stabilityCutoff
+
+	if debug.IsDebug(ewma.dbgLevel) {
+		fmt.Printf(
+			"%s: Is Stable? %v; Standard Deviation: %f %s; Standard Deviation Cutoff: %v %s).\n",
+			ewma.dbgConfig.String(),
+			isStable,
+			stddev,
+			units,
+			stabilityCutoff,
+			units,
+		)
+	}
+	return isStable
+}
+
+func (ewma *EWMAProbeStabilizer) AddMeasurement(measurement probe.ProbeDataPoint) {
+	ewma.m.Lock()
+	defer ewma.m.Unlock()
+
+	// As with the Rev3 stabilizer, a measurement of several round trips counts as that many
+	// instantaneous measurements.
+	for range utilities.Iota(0, int(measurement.RoundTripCount)) {
+		(*EWMAStabilizer)(ewma).add(measurement.Duration.Seconds() / float64(measurement.RoundTripCount))
+	}
+	ewma.averages.AddElement(ewma.average)
+
+	if debug.IsDebug(ewma.dbgLevel) {
+		fmt.Printf("%s: EWMA: %f s.\n", ewma.dbgConfig.String(), ewma.average)
+	}
+}
+
+func (ewma *EWMAProbeStabilizer) IsStable() bool {
+	return (*EWMAStabilizer)(ewma).isStable("s")
+}
+
+func (ewma *EWMAThroughputStabilizer) AddMeasurement(measurement rpm.ThroughputDataPoint) {
+	ewma.m.Lock()
+	defer ewma.m.Unlock()
+
+	(*EWMAStabilizer)(ewma).add(utilities.ToMbps(measurement.Throughput))
+	ewma.averages.AddElement(ewma.average)
+
+	if debug.IsDebug(ewma.dbgLevel) {
+		fmt.Printf("%s: EWMA: %f Mbps.\n", ewma.dbgConfig.String(), ewma.average)
+	}
+}
+
+func (ewma *EWMAThroughputStabilizer) IsStable() bool {
+	return (*EWMAStabilizer)(ewma).isStable("Mbps")
+}
@@ -0,0 +1,76 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
+func TestEWMAThroughputStabilizer(t *testing.T) {
+	stabilizer := NewEWMAThroughputStabilizer(
+		0.5, 3, 5.0, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test"),
+	)
+	stabilizer.AddMeasurement(rpm.ThroughputDataPoint{Time: time.Now(), Throughput: 1e6})
+	stabilizer.AddMeasurement(rpm.ThroughputDataPoint{Time: time.Now(), Throughput: 100e6})
+	if stabilizer.IsStable() {
+		t.Fatalf("Fewer than K averages should never be stable.")
+	}
+	for i := 0; i < 3; i++ {
+		stabilizer.AddMeasurement(rpm.ThroughputDataPoint{Time: time.Now(), Throughput: 100e6})
+	}
+	if stabilizer.IsStable() {
+		t.Fatalf("An average that is still converging should not be stable.")
+	}
+	for i := 0; i < 5; i++ {
+		stabilizer.AddMeasurement(rpm.ThroughputDataPoint{Time: time.Now(), Throughput: 100e6})
+	}
+	if !stabilizer.IsStable() {
+		t.Fatalf("An average that has converged should be stable.")
+	}
+}
+
+func TestEWMADecay(t *testing.T) {
+	// With a larger decay, the average should converge after a jump in fewer measurements.
+	measurementsToStability := func(decay float64) int {
+		stabilizer := NewEWMAProbeStabilizer(
+			decay, 3, 5.0, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test"),
+		)
+		stabilizer.AddMeasurement(probe.ProbeDataPoint{RoundTripCount: 1, Duration: 10 * time.Millisecond})
+		for i := 1; i < 100; i++ {
+			stabilizer.AddMeasurement(probe.ProbeDataPoint{RoundTripCount: 1, Duration: 50 * time.Millisecond})
+			if stabilizer.IsStable() {
+				return i
+			}
+		}
+		return 100
+	}
+	if fast, slow := measurementsToStability(0.8), measurementsToStability(0.2); fast >= slow {
+		t.Fatalf("A larger decay should have stabilized sooner (%d vs %d measurements).", fast, slow)
+	}
+}
+
+func TestEWMAInvalidDecay(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("A decay of 0 should not be allowed.")
+		}
+	}()
+	NewEWMAThroughputStabilizer(0, 3, 5.0, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test"))
+}
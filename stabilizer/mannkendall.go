@@ -0,0 +1,137 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/ms"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
+// The |Z| above which the Mann-Kendall test finds a trend (a two-sided test at the 5%
+// significance level).
+const mannKendallCriticalZ = 1.96
+
+// Mann-Kendall Stabilizer Algorithm:
+// Stabilization is achieved when the Mann-Kendall test finds no monotonic trend (up or
+// down) in the I * K most recent instantaneous measurements (for probes, the mean RTTs of
+// intervals; see newValueProbeStabilizer). Rather than asking whether the
+// measurements are close together, it asks whether they have stopped going somewhere, which
+// makes it tolerant of links that are noisy but steady.
+type mannKendall struct {
+	measurements *ms.WindowedMathematicalSeries[float64]
+	dbgLevel     debug.DebugLevel
+	dbgConfig    *debug.DebugWithPrefix
+}
+
+func newMannKendall(
+	parameters StabilityParameters,
+	debugLevel debug.DebugLevel,
+	debug *debug.DebugWithPrefix,
+) *mannKendall {
+	return &mannKendall{
+		measurements: ms.NewWindowedMathematicalSeries[float64](parameters.I * parameters.K),
+		dbgLevel:     debugLevel,
+		dbgConfig:    debug,
+	}
+}
+
+// The (continuity-corrected and tie-corrected) Z statistic of the Mann-Kendall test: it is
+// positive for an upward trend and negative for a downward one.
+func mannKendallZ(values []float64) float64 {
+	n := len(values)
+	s := 0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if values[j] > values[i] {
+				s++
+			} else if values[j] < values[i] {
+				s--
+			}
+		}
+	}
+
+	ties := make(map[float64]int)
+	for _, value := range values {
+		ties[value]++
+	}
+	variance := float64(n * (n - 1) * (2*n + 5))
+	for _, t := range ties {
+		variance -= float64(t * (t - 1) * (2*t + 5))
+	}
+	variance /= 18
+	if variance <= 0 {
+		return 0
+	}
+
+	switch {
+	case s > 0:
+		return float64(s-1) / math.Sqrt(variance)
+	case s < 0:
+		return float64(s+1) / math.Sqrt(variance)
+	}
+	return 0
+}
+
+func (mk *mannKendall) add(value float64) {
+	mk.measurements.AddElement(value)
+}
+
+func (mk *mannKendall) isStable() bool {
+	if !mk.measurements.IsFull() {
+		return false
+	}
+	z := mannKendallZ(mk.measurements.Values())
+	isStable := math.Abs(z) < mannKendallCriticalZ
+
+	if debug.IsDebug(mk.dbgLevel) {
+		fmt.Printf(
+			"%s: Is Stable? %v; Mann-Kendall Z: %.3f; Critical Z: %.3f.\n",
+			mk.dbgConfig.String(),
+			isStable,
+			z,
+			mannKendallCriticalZ,
+		)
+	}
+	return isStable
+}
+
+func init() {
+	RegisterAlgorithm("mann-kendall", Algorithm{
+		Description: "the Mann-Kendall test finds no trend in the most recent I*K measurements",
+		NewThroughputStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[rpm.ThroughputDataPoint] {
+			return newValueThroughputStabilizer(newMannKendall(parameters, debugLevel, debug))
+		},
+		NewProbeStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[probe.ProbeDataPoint] {
+			return newValueProbeStabilizer(
+				newMannKendall(parameters, debugLevel, debug),
+				constants.ProbeStabilityInterval,
+			)
+		},
+	})
+}
@@ -32,6 +32,10 @@ type DataPointStabilizer struct {
 	m                          sync.Mutex
 	dbgLevel                   debug.DebugLevel
 	dbgConfig                  *debug.DebugWithPrefix
+	// The number of measurements given to AddMeasurement that are averaged together
+	// to form a single instantaneous measurement (i.e., a longer interval).
+	aggregate         uint64
+	pendingAggregates []float64
 }
 
 type ProbeStabilizer DataPointStabilizer
@@ -56,6 +60,30 @@ type ThroughputStabilizer DataPointStabilizer
 // calculate the standard deviation of those values. If the calculated standard deviation is less than S, we declare
 // stability.
 
+func init() {
+	RegisterAlgorithm(DefaultAlgorithm, Algorithm{
+		Description: "the standard deviation of the K most recent moving averages of I measurements is within S percent of their mean",
+		NewThroughputStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[rpm.ThroughputDataPoint] {
+			stabilizer := NewAggregatingThroughputStabilizer(
+				parameters.Aggregate, parameters.I, parameters.K, parameters.S, debugLevel, debug,
+			)
+			return &stabilizer
+		},
+		NewProbeStabilizer: func(
+			parameters StabilityParameters,
+			debugLevel debug.DebugLevel,
+			debug *debug.DebugWithPrefix,
+		) Stabilizer[probe.ProbeDataPoint] {
+			stabilizer := NewProbeStabilizer(parameters.I, parameters.K, parameters.S, debugLevel, debug)
+			return &stabilizer
+		},
+	})
+}
+
 func NewProbeStabilizer(
 	i uint64,
 	k uint64,
@@ -138,20 +166,51 @@ func NewThroughputStabilizer(
 	debugLevel debug.DebugLevel,
 	debug *debug.DebugWithPrefix,
 ) ThroughputStabilizer {
+	return NewAggregatingThroughputStabilizer(1, i, k, s, debugLevel, debug)
+}
+
+// Like a ThroughputStabilizer except that every aggregate measurements are averaged together
+// to form a single instantaneous measurement. This effectively lengthens the measurement
+// interval which smooths out the noise of very slow connections.
+func NewAggregatingThroughputStabilizer(
+	aggregate uint64,
+	i uint64,
+	k uint64,
+	s float64,
+	debugLevel debug.DebugLevel,
+	debug *debug.DebugWithPrefix,
+) ThroughputStabilizer {
+	if aggregate == 0 {
+		aggregate = 1
+	}
 	return ThroughputStabilizer{
 		instantaneousMeasurements:  ms.NewCappedMathematicalSeries[float64](i),
 		movingAverages:             ms.NewCappedMathematicalSeries[float64](k),
 		stabilityStandardDeviation: s,
 		dbgConfig:                  debug,
 		dbgLevel:                   debugLevel,
+		aggregate:                  aggregate,
+		pendingAggregates:          make([]float64, 0, aggregate),
 	}
 }
 
 func (r3 *ThroughputStabilizer) AddMeasurement(measurement rpm.ThroughputDataPoint) {
 	r3.m.Lock()
 	defer r3.m.Unlock()
+
+	r3.pendingAggregates = append(r3.pendingAggregates, utilities.ToMbps(measurement.Throughput))
+	if uint64(len(r3.pendingAggregates)) < r3.aggregate {
+		return
+	}
+	aggregated := float64(0)
+	for _, pending := range r3.pendingAggregates {
+		aggregated += pending
+	}
+	aggregated /= float64(len(r3.pendingAggregates))
+	r3.pendingAggregates = r3.pendingAggregates[:0]
+
 	// Add this instantaneous measurement to the mix of the I previous instantaneous measurements.
-	r3.instantaneousMeasurements.AddElement(utilities.ToMbps(measurement.Throughput))
+	r3.instantaneousMeasurements.AddElement(aggregated)
 	// Calculate the moving average of the I previous instantaneous measurements and add it to
 	// the mix of K previous moving averages.
 	r3.movingAverages.AddElement(r3.instantaneousMeasurements.CalculateAverage())
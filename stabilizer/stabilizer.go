@@ -14,7 +14,63 @@
 
 package stabilizer
 
+import (
+	"fmt"
+	"sort"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
 type Stabilizer[T any] interface {
 	AddMeasurement(T)
 	IsStable() bool
 }
+
+// An Algorithm creates the stabilizers that decide when throughput and responsiveness
+// are stable. Algorithms register themselves (see RegisterAlgorithm) so that they can be
+// selected by name.
+type Algorithm struct {
+	Description             string
+	NewThroughputStabilizer ThroughputStabilizerFactory
+	NewProbeStabilizer      ProbeStabilizerFactory
+}
+
+type (
+	ThroughputStabilizerFactory func(StabilityParameters, debug.DebugLevel, *debug.DebugWithPrefix) Stabilizer[rpm.ThroughputDataPoint]
+	ProbeStabilizerFactory      func(StabilityParameters, debug.DebugLevel, *debug.DebugWithPrefix) Stabilizer[probe.ProbeDataPoint]
+)
+
+const DefaultAlgorithm = "moving-average"
+
+var algorithms = make(map[string]Algorithm)
+
+// Make an algorithm available under the given name. Meant to be called from init().
+func RegisterAlgorithm(name string, algorithm Algorithm) {
+	if _, exists := algorithms[name]; exists {
+		panic(fmt.Sprintf("A stabilizer algorithm named %s is already registered.", name))
+	}
+	algorithms[name] = algorithm
+}
+
+func LookupAlgorithm(name string) (Algorithm, error) {
+	if name == "" {
+		name = DefaultAlgorithm
+	}
+	algorithm, exists := algorithms[name]
+	if !exists {
+		return Algorithm{}, fmt.Errorf("unrecognized stabilizer algorithm: %s", name)
+	}
+	return algorithm, nil
+}
+
+// The names of the registered algorithms, in alphabetical order.
+func AlgorithmNames() []string {
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,173 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/rpm"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+func TestAlgorithmRegistry(t *testing.T) {
+	expected := []string{"cov", "ewma", "mann-kendall", "moving-average"}
+	names := AlgorithmNames()
+	if len(names) != len(expected) {
+		t.Fatalf("Registered algorithms should be %v but are %v.", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Registered algorithms should be %v but are %v.", expected, names)
+		}
+	}
+
+	parameters := StabilityParameters{I: 4, K: 4, S: 5.0, Aggregate: 1, Decay: 0.3}
+	for _, name := range names {
+		algorithm, err := LookupAlgorithm(name)
+		if err != nil {
+			t.Fatalf("Could not look up the %s algorithm: %v", name, err)
+		}
+		throughputStabilizer := algorithm.NewThroughputStabilizer(parameters, debug.Error, debug.NewDebugWithPrefix(debug.Error, name))
+		probeStabilizer := algorithm.NewProbeStabilizer(parameters, debug.Error, debug.NewDebugWithPrefix(debug.Error, name))
+		start := time.Now()
+		for i := 0; i < 32; i++ {
+			throughputStabilizer.AddMeasurement(rpm.ThroughputDataPoint{Time: time.Now(), Throughput: 100e6})
+			probeStabilizer.AddMeasurement(probe.ProbeDataPoint{
+				Time: start.Add(time.Duration(i) * time.Second), RoundTripCount: 1, Duration: 20 * time.Millisecond,
+			})
+		}
+		if !throughputStabilizer.IsStable() || !probeStabilizer.IsStable() {
+			t.Fatalf("Constant measurements should be stable according to the %s algorithm.", name)
+		}
+	}
+
+	if _, err := LookupAlgorithm("magic"); err == nil {
+		t.Fatalf("Looking up an unregistered algorithm should fail.")
+	}
+	if algorithm, err := LookupAlgorithm(""); err != nil || algorithm.Description != algorithms[DefaultAlgorithm].Description {
+		t.Fatalf("Looking up no algorithm should give the default algorithm.")
+	}
+}
+
+func TestMannKendallZ(t *testing.T) {
+	// From a worked example: S = 6 and Var(S) = 8.667 (n = 4, no ties).
+	if z := mannKendallZ([]float64{1, 2, 3, 4}); !utilities.ApproximatelyEqual(z, 5/math.Sqrt(26.0/3.0), 0.0001) {
+		t.Fatalf("Z of an increasing series should be %v but is %v.", 5/math.Sqrt(26.0/3.0), z)
+	}
+	if z := mannKendallZ([]float64{4, 3, 2, 1}); z >= 0 {
+		t.Fatalf("Z of a decreasing series should be negative but is %v.", z)
+	}
+	if z := mannKendallZ([]float64{5, 5, 5, 5}); z != 0 {
+		t.Fatalf("Z of a constant series should be 0 but is %v.", z)
+	}
+}
+
+func TestMannKendallStabilizer(t *testing.T) {
+	parameters := StabilityParameters{I: 2, K: 4}
+	rising := newValueThroughputStabilizer(newMannKendall(parameters, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test")))
+	noisy := newValueThroughputStabilizer(newMannKendall(parameters, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test")))
+	for i, noise := range []float64{3, -2, 4, -3, 1, -4, 2, -1} {
+		rising.AddMeasurement(rpm.ThroughputDataPoint{Throughput: float64(i+1) * 10e6})
+		noisy.AddMeasurement(rpm.ThroughputDataPoint{Throughput: 100e6 + noise*10e6})
+	}
+	if rising.IsStable() {
+		t.Fatalf("Throughput that is still rising should not be stable.")
+	}
+	if !noisy.IsStable() {
+		t.Fatalf("Throughput that is noisy but without a trend should be stable.")
+	}
+}
+
+func TestCoefficientOfVariationStabilizer(t *testing.T) {
+	parameters := StabilityParameters{I: 2, K: 2, S: 5.0}
+	stabilizer := newValueProbeStabilizer(
+		newCoefficientOfVariation(parameters, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test")),
+		time.Second,
+	)
+	start := time.Now()
+	probeAt := func(after time.Duration, roundTrips uint64, rtt time.Duration) probe.ProbeDataPoint {
+		return probe.ProbeDataPoint{Time: start.Add(after), RoundTripCount: roundTrips, Duration: time.Duration(roundTrips) * rtt}
+	}
+	// Each interval's round trips count as one measurement: their mean RTT.
+	for second := 0; second < 4; second++ {
+		stabilizer.AddMeasurement(probeAt(time.Duration(second)*time.Second, 3, 10*time.Millisecond))
+		stabilizer.AddMeasurement(probeAt(time.Duration(second)*time.Second+500*time.Millisecond, 1, 30*time.Millisecond))
+	}
+	if stabilizer.IsStable() {
+		t.Fatalf("Fewer than I*K intervals should not be stable.")
+	}
+	stabilizer.AddMeasurement(probeAt(4*time.Second, 1, 40*time.Millisecond))
+	if !stabilizer.IsStable() {
+		t.Fatalf("Intervals whose mean RTTs are the same should be stable.")
+	}
+	stabilizer.AddMeasurement(probeAt(5*time.Second, 1, 40*time.Millisecond))
+	if stabilizer.IsStable() {
+		t.Fatalf("Intervals whose mean RTTs are far from their mean should not be stable.")
+	}
+}
+
+func TestValueProbeStabilizersOnNoisyRtts(t *testing.T) {
+	parameters := StabilityParameters{I: 4, K: 4, S: 5.0}
+	// Probes every 100ms (alternating one round trip and three) whose RTTs vary by up to
+	// 25% around a loaded latency of 50ms -- and, for the second test, one that keeps on
+	// growing by 1ms a second.
+	noise := rand.New(rand.NewSource(1))
+	noisyRtts := func(growth time.Duration) []probe.ProbeDataPoint {
+		start := time.Now()
+		probes := make([]probe.ProbeDataPoint, 0)
+		for i := 0; i < 200; i++ {
+			after := time.Duration(i) * 100 * time.Millisecond
+			roundTrips := uint64(1 + 2*(i%2))
+			dataPoint := probe.ProbeDataPoint{Time: start.Add(after), RoundTripCount: roundTrips}
+			for j := uint64(0); j < roundTrips; j++ {
+				rtt := 50*time.Millisecond + time.Duration(after.Seconds()*float64(growth))
+				dataPoint.Duration += time.Duration(float64(rtt) * (0.75 + 0.5*noise.Float64()))
+			}
+			probes = append(probes, dataPoint)
+		}
+		return probes
+	}
+	for _, algorithm := range []struct {
+		name string
+		new  func() valueAlgorithm
+	}{
+		{"cov", func() valueAlgorithm {
+			return newCoefficientOfVariation(parameters, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test"))
+		}},
+		{"mann-kendall", func() valueAlgorithm {
+			return newMannKendall(parameters, debug.Error, debug.NewDebugWithPrefix(debug.Error, "Test"))
+		}},
+	} {
+		steady := newValueProbeStabilizer(algorithm.new(), time.Second)
+		for _, dataPoint := range noisyRtts(0) {
+			steady.AddMeasurement(dataPoint)
+		}
+		if !steady.IsStable() {
+			t.Fatalf("RTTs that are noisy but steady should be stable (with %s).", algorithm.name)
+		}
+		growing := newValueProbeStabilizer(algorithm.new(), time.Second)
+		for _, dataPoint := range noisyRtts(time.Millisecond) {
+			growing.AddMeasurement(dataPoint)
+		}
+		if growing.IsStable() {
+			t.Fatalf("RTTs that keep on growing should not be stable (with %s).", algorithm.name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stabilizer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/probe"
+	"github.com/network-quality/goresponsiveness/rpm"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// Many algorithms only need a series of numbers: throughputs (in Mbps) or the mean RTTs of
+// the round trips of the probes in each interval (in seconds). A valueStabilizer feeds the
+// numbers in a measurement to such an algorithm.
+type valueAlgorithm interface {
+	add(value float64)
+	isStable() bool
+}
+
+type valueStabilizer[T any] struct {
+	algorithm valueAlgorithm
+	values    func(T) []float64
+	m         sync.Mutex
+}
+
+func (vs *valueStabilizer[T]) AddMeasurement(measurement T) {
+	vs.m.Lock()
+	defer vs.m.Unlock()
+	for _, value := range vs.values(measurement) {
+		vs.algorithm.add(value)
+	}
+}
+
+func (vs *valueStabilizer[T]) IsStable() bool {
+	vs.m.Lock()
+	defer vs.m.Unlock()
+	return vs.algorithm.isStable()
+}
+
+func newValueThroughputStabilizer(algorithm valueAlgorithm) Stabilizer[rpm.ThroughputDataPoint] {
+	return &valueStabilizer[rpm.ThroughputDataPoint]{
+		algorithm: algorithm,
+		values: func(measurement rpm.ThroughputDataPoint) []float64 {
+			return []float64{utilities.ToMbps(measurement.Throughput)}
+		},
+	}
+}
+
+// The RTTs of individual round trips under load vary far too much for (e.g.) their coefficient
+// of variation to ever come within S, so (like the throughputs) they are aggregated by interval
+// first: the algorithm gets the mean RTT of the round trips of the probes (by their times) in
+// each interval once the interval is over.
+func newValueProbeStabilizer(algorithm valueAlgorithm, interval time.Duration) Stabilizer[probe.ProbeDataPoint] {
+	intervalStart := time.Time{}
+	total, roundTrips := 0.0, uint64(0)
+	return &valueStabilizer[probe.ProbeDataPoint]{
+		algorithm: algorithm,
+		values: func(measurement probe.ProbeDataPoint) []float64 {
+			if measurement.RoundTripCount == 0 {
+				return nil
+			}
+			var values []float64 = nil
+			if intervalStart.IsZero() {
+				intervalStart = measurement.Time
+			}
+			if elapsed := measurement.Time.Sub(intervalStart); elapsed >= interval {
+				values = append(values, total/float64(roundTrips))
+				total, roundTrips = 0, 0
+				intervalStart = intervalStart.Add(elapsed - elapsed%interval)
+			}
+			total += measurement.Duration.Seconds()
+			roundTrips += measurement.RoundTripCount
+			return values
+		},
+	}
+}
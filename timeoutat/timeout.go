@@ -0,0 +1,115 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package timeoutat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+// A Timeout is a deadline that can be moved while it runs: extended (e.g., when the test is
+// close to stable) or reset to a new duration (e.g., when the time that is left is for
+// something else). It fires (see C) once the deadline passes or its context is done; moving
+// the deadline after that arms it again.
+type Timeout struct {
+	lock     sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	fired    chan struct{}
+	// The deadline that last fired (so that a timer for a deadline that was moved while it
+	// went off does not fire it twice).
+	firedFor   time.Time
+	debugLevel debug.DebugLevel
+}
+
+func NewTimeout(ctx context.Context, when time.Time, debugLevel debug.DebugLevel) *Timeout {
+	t := &Timeout{deadline: when, fired: make(chan struct{}, 1), debugLevel: debugLevel}
+	if debug.IsDebug(debugLevel) {
+		fmt.Printf("Timeout expected to end at %v\n", when)
+	}
+	t.timer = time.AfterFunc(time.Until(when), t.expire)
+	go func() {
+		<-ctx.Done()
+		t.lock.Lock()
+		t.deadline = time.Now()
+		t.lock.Unlock()
+		t.expire()
+	}()
+	return t
+}
+
+// Receives when the deadline passes.
+func (t *Timeout) C() <-chan struct{} {
+	return t.fired
+}
+
+func (t *Timeout) Deadline() time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.deadline
+}
+
+// Move the deadline to when, unless it is already that late. Returns whether it moved.
+func (t *Timeout) ExtendTo(when time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !when.After(t.deadline) {
+		return false
+	}
+	t.rearm(when)
+	return true
+}
+
+// Move the deadline to duration from now (whether that is earlier or later).
+func (t *Timeout) Reset(duration time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rearm(time.Now().Add(duration))
+}
+
+// Call with the lock held.
+func (t *Timeout) rearm(when time.Time) {
+	t.deadline = when
+	t.timer.Stop()
+	// A firing that has not been received yet was for the old deadline.
+	select {
+	case <-t.fired:
+	default:
+	}
+	t.timer = time.AfterFunc(time.Until(when), t.expire)
+	if debug.IsDebug(t.debugLevel) {
+		fmt.Printf("Timeout moved to end at %v\n", when)
+	}
+}
+
+func (t *Timeout) expire() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if time.Now().Before(t.deadline) || t.firedFor.Equal(t.deadline) {
+		return
+	}
+	t.firedFor = t.deadline
+	select {
+	case t.fired <- struct{}{}:
+	default:
+	}
+	if debug.IsDebug(t.debugLevel) {
+		fmt.Printf("Timeout ended at %v\n", time.Now())
+	}
+}
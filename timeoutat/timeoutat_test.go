@@ -25,3 +25,54 @@ func TestTimeoutAt(t *testing.T) {
 		t.Fatalf("Should have taken 5 seconds but it really took %v!", actualTime)
 	}
 }
+
+func TestTimeoutExtendTo(t *testing.T) {
+	start := time.Now()
+	timeout := NewTimeout(context.Background(), start.Add(100*time.Millisecond), debug.NoDebug)
+
+	if !timeout.ExtendTo(start.Add(300 * time.Millisecond)) {
+		t.Fatalf("Extending the timeout to later should have moved its deadline.")
+	}
+	if timeout.ExtendTo(start.Add(200 * time.Millisecond)) {
+		t.Fatalf("Extending the timeout to earlier should not have moved its deadline.")
+	}
+	<-timeout.C()
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("The timeout fired after %v, before its extended deadline.", elapsed)
+	}
+
+	// Moving the deadline after the timeout fired arms it again.
+	timeout.ExtendTo(time.Now().Add(100 * time.Millisecond))
+	select {
+	case <-timeout.C():
+	case <-time.After(time.Second):
+		t.Fatalf("The timeout did not fire again after it was extended.")
+	}
+}
+
+func TestTimeoutReset(t *testing.T) {
+	start := time.Now()
+	timeout := NewTimeout(context.Background(), start.Add(time.Hour), debug.NoDebug)
+
+	timeout.Reset(100 * time.Millisecond)
+	select {
+	case <-timeout.C():
+	case <-time.After(time.Second):
+		t.Fatalf("The timeout did not fire after it was reset to an earlier deadline.")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("The timeout fired after %v, before its deadline.", elapsed)
+	}
+}
+
+func TestTimeoutContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timeout := NewTimeout(ctx, time.Now().Add(time.Hour), debug.NoDebug)
+
+	cancel()
+	select {
+	case <-timeout.C():
+	case <-time.After(time.Second):
+		t.Fatalf("The timeout did not fire when its context was done.")
+	}
+}
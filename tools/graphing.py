@@ -91,7 +91,7 @@ def probeClean(df):
     # ConnRTT and ConnCongestionWindow refer to Underlying Connection
     df.columns = ["CreationTime", "NumRTT", "Duration", "ConnRTT", "ConnCongestionWindow", "Type", "Empty"]
     df = df.drop(columns=["Empty"])
-    df["CreationTime"] = pd.to_datetime(df["CreationTime"], format="%m-%d-%Y-%H-%M-%S.%f")
+    df["CreationTime"] = pd.to_datetime(df["CreationTime"], unit="s")
     df["Type"] = df["Type"].apply(str.strip)
     df["ADJ_Duration"] = df["Duration"] / df["NumRTT"]
     df = df.sort_values(by=["CreationTime"])
@@ -101,7 +101,7 @@ def probeClean(df):
 def throughputClean(df):
     df.columns = ["CreationTime", "Throughput", "NumberConnections", "Empty"]
     df = df.drop(columns=["Empty"])
-    df["CreationTime"] = pd.to_datetime(df["CreationTime"], format="%m-%d-%Y-%H-%M-%S.%f")
+    df["CreationTime"] = pd.to_datetime(df["CreationTime"], unit="s")
     df["ADJ_Throughput"] = df["Throughput"] / 1000000
     df = df.sort_values(by=["CreationTime"])
     return df
@@ -110,7 +110,7 @@ def throughputClean(df):
 def granularClean(df):
     df.columns = ["CreationTime", "Throughput", "ID", "RTT", "Cwnd", "Type", "Empty"]
     df = df.drop(columns=["Empty"])
-    df["CreationTime"] = pd.to_datetime(df["CreationTime"], format="%m-%d-%Y-%H-%M-%S.%f")
+    df["CreationTime"] = pd.to_datetime(df["CreationTime"], unit="s")
     df["Type"] = df["Type"].apply(str.strip)
     df["ADJ_Throughput"] = df["Throughput"] / 1000000
     df = df.sort_values(by=["CreationTime"])
@@ -127,11 +127,11 @@ def make90Percentile(df):
 
 def main(title, paths):
     # Data Ingestion
-    foreign = pd.read_csv(paths["foreign"])
-    self = pd.read_csv(paths["self"])
-    download = pd.read_csv(paths["download"])
-    upload = pd.read_csv(paths["upload"])
-    granular = pd.read_csv(paths["granular"])
+    foreign = pd.read_csv(paths["foreign"], comment="#")
+    self = pd.read_csv(paths["self"], comment="#")
+    download = pd.read_csv(paths["download"], comment="#")
+    upload = pd.read_csv(paths["upload"], comment="#")
+    granular = pd.read_csv(paths["granular"], comment="#")
 
     # Data Cleaning
     foreign = probeClean(foreign)
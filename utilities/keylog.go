@@ -0,0 +1,57 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package utilities
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Passes the lines of a TLS key log along to the key logger that every TLS client in the
+// program shares and, when debugging, says which connection each session belongs to.
+type keyLogTracer struct {
+	keyLogger    io.Writer
+	connection   string
+	lock         sync.Mutex
+	clientRandom string
+}
+
+// Log the keys of connection's TLS sessions to keyLogger. When debugging, the client random
+// of each session (which is how the key log and Wireshark identify it) is printed along with
+// connection. Returns nil when there is no key logger.
+func TraceKeyLog(keyLogger io.Writer, debugging bool, connection string) io.Writer {
+	if IsInterfaceNil(keyLogger) {
+		return nil
+	}
+	if !debugging {
+		return keyLogger
+	}
+	return &keyLogTracer{keyLogger: keyLogger, connection: connection}
+}
+
+func (tracer *keyLogTracer) Write(line []byte) (int, error) {
+	// Every line is <label> <client random> <secret>; TLS 1.3 logs several per session.
+	if fields := bytes.Fields(line); len(fields) == 3 {
+		tracer.lock.Lock()
+		if clientRandom := string(fields[1]); clientRandom != tracer.clientRandom {
+			tracer.clientRandom = clientRandom
+			fmt.Printf("(%s) Logging the keys of the TLS session with client random %s.\n", tracer.connection, clientRandom)
+		}
+		tracer.lock.Unlock()
+	}
+	return tracer.keyLogger.Write(line)
+}
@@ -0,0 +1,154 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package utilities
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// The headers that requests to the test server carry besides the User-Agent: the
+// credentials (if there are any) and the headers that the user added. They only go to the
+// hosts in Hosts so that they do not leak to the other servers that a configuration may
+// name.
+type RequestHeaders struct {
+	// The names of the hosts (without ports) that get the headers.
+	Hosts []string
+	// The value of the Authorization header (empty when there is none).
+	Authorization string
+	// Headers that the user added. A Host header overrides the host of the request.
+	Extra http.Header
+}
+
+// Send a bearer token with the requests.
+func (headers *RequestHeaders) UseBearerToken(token string) {
+	headers.Authorization = "Bearer " + token
+}
+
+// Send a user name and password (see RFC 7617) with the requests.
+func (headers *RequestHeaders) UseBasicAuth(user string, password string) {
+	headers.Authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func (headers *RequestHeaders) Add(name string, value string) {
+	if headers.Extra == nil {
+		headers.Extra = http.Header{}
+	}
+	headers.Extra.Add(name, value)
+}
+
+// Whether the headers go to the host of the URL.
+func (headers *RequestHeaders) appliesTo(url *url.URL) bool {
+	for _, host := range headers.Hosts {
+		if strings.EqualFold(host, url.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add the headers that the request carries: the User-Agent and, when the request goes to
+// one of the hosts of the headers (which may be nil), the credentials and the headers that
+// the user added (which take the place of the others with the same names).
+func SetRequestHeaders(request *http.Request, headers *RequestHeaders) {
+	request.Header.Set("User-Agent", UserAgent())
+	if headers == nil || !headers.appliesTo(request.URL) {
+		return
+	}
+	if headers.Authorization != "" {
+		request.Header.Set("Authorization", headers.Authorization)
+	}
+	for name, values := range headers.Extra {
+		// Go sends the request's Host rather than a Host header.
+		if name == "Host" {
+			request.Host = values[0]
+			continue
+		}
+		request.Header[name] = append([]string(nil), values...)
+	}
+}
+
+// Parse a header of the form "Name: value".
+func ParseHeader(specification string) (string, string, error) {
+	name, value, found := strings.Cut(specification, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return "", "", fmt.Errorf("header %q does not have the form \"Name: value\"", specification)
+	}
+	return textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// Headers (each of the form "Name: value") that can be given as a repeatable flag. Their
+// values (which may be keys) are not shown when the flags are listed.
+type HeaderList []string
+
+func HeadersFlag(name string, usage string) *HeaderList {
+	list := &HeaderList{}
+	flag.Var(list, name, usage)
+	return list
+}
+
+func (list *HeaderList) String() string {
+	if list == nil {
+		return ""
+	}
+	names := make([]string, 0, len(*list))
+	for _, header := range *list {
+		name, _, _ := ParseHeader(header)
+		names = append(names, name+": (redacted)")
+	}
+	return strings.Join(names, ", ")
+}
+
+func (list *HeaderList) Set(value string) error {
+	if _, _, err := ParseHeader(value); err != nil {
+		return err
+	}
+	*list = append(*list, value)
+	return nil
+}
+
+// A flag whose value (e.g., a password) must not show up when the flags are listed (e.g.,
+// in the results).
+type SecretFlag struct {
+	value string
+}
+
+func NewSecretFlag(name string, usage string) *SecretFlag {
+	secret := &SecretFlag{}
+	flag.Var(secret, name, usage)
+	return secret
+}
+
+func (secret *SecretFlag) String() string {
+	if secret == nil || secret.value == "" {
+		return ""
+	}
+	return "(redacted)"
+}
+
+func (secret *SecretFlag) Set(value string) error {
+	secret.value = value
+	return nil
+}
+
+func (secret *SecretFlag) Value() string {
+	return secret.value
+}
@@ -16,14 +16,50 @@ package utilities
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
+	"github.com/network-quality/goresponsiveness/constants"
 	"golang.org/x/net/http2"
 )
 
-func OverrideHostTransport(transport *http.Transport, connectToAddr string) {
+var http1Forced = false
+
+// Limit every transport to HTTP/1.1. Call this before making any connections.
+func ForceHTTP1() {
+	http1Forced = true
+}
+
+func HTTP1Forced() bool {
+	return http1Forced
+}
+
+// Opens a tunnel to target (host:port) through the proxy at proxyURL (connecting to the proxy
+// with dial). The transports use one (e.g., proxyauth.Connect) in place of their own CONNECT
+// for proxies that need authentication that Go does not support.
+type ProxyDialer func(
+	ctx context.Context,
+	dial func(context.Context) (net.Conn, error),
+	proxyURL *url.URL,
+	target string,
+) (net.Conn, error)
+
+// The port of a proxy whose URL does not give one (see http.ProxyFromEnvironment).
+func proxyPort(proxyURL *url.URL) string {
+	if port := proxyURL.Port(); port != "" {
+		return port
+	}
+	if proxyURL.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+func OverrideHostTransport(transport *http.Transport, connectToAddr string, proxyDialer ProxyDialer) {
 	dialer := &net.Dialer{
 		Timeout: 10 * time.Second,
 	}
@@ -41,6 +77,62 @@ func OverrideHostTransport(transport *http.Transport, connectToAddr string) {
 		return dialer.DialContext(ctx, network, addr)
 	}
 
+	// The transport tunnels to https targets through a proxy with a CONNECT of its own, which
+	// cannot authenticate with (e.g.) NTLM. For those targets, tell the transport to go
+	// directly and open the tunnel with proxyDialer when it dials. Plain http requests take
+	// the standard path (through the proxy, without a tunnel).
+	if proxyDialer != nil && transport.Proxy != nil {
+		proxy := transport.Proxy
+		// The (plain http) proxy that each https target (host:port) goes through.
+		tunnels := sync.Map{}
+		transport.Proxy = func(request *http.Request) (*url.URL, error) {
+			proxyURL, err := proxy(request)
+			if err != nil || proxyURL == nil || request.URL.Scheme != "https" || proxyURL.Scheme != "http" {
+				return proxyURL, err
+			}
+			port := request.URL.Port()
+			if port == "" {
+				port = "443"
+			}
+			tunnels.Store(net.JoinHostPort(request.URL.Hostname(), port), proxyURL)
+			return nil, nil
+		}
+		direct := transport.DialContext
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			tunnel, ok := tunnels.Load(addr)
+			if !ok {
+				return direct(ctx, network, addr)
+			}
+			proxyURL := tunnel.(*url.URL)
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if len(connectToAddr) > 0 {
+				host = connectToAddr
+			}
+			proxyAddr := net.JoinHostPort(proxyURL.Hostname(), proxyPort(proxyURL))
+			return proxyDialer(
+				ctx,
+				func(ctx context.Context) (net.Conn, error) { return dialer.DialContext(ctx, network, proxyAddr) },
+				proxyURL,
+				net.JoinHostPort(host, port),
+			)
+		}
+	}
+
+	if http1Forced {
+		// A non-nil (but empty) TLSNextProto keeps the transport from negotiating HTTP/2.
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		// Without HTTP/2, every request in flight needs a connection of its own. Make sure that
+		// the transport never makes one wait for another to the same host (and keeps the ones
+		// that are idle around to be reused).
+		transport.MaxConnsPerHost = 0
+		transport.MaxIdleConns = 0
+		transport.MaxIdleConnsPerHost = constants.HTTP1MaxIdleConnsPerHost
+		return
+	}
+
 	http2.ConfigureTransport(transport)
 
 }
@@ -16,12 +16,15 @@ package utilities
 
 import (
 	"context"
+	crand "crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"math/rand"
 	"os"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -82,6 +85,47 @@ func ToMBps(bytes float64) float64 {
 	return float64(bytes) / float64(1024*1024)
 }
 
+// The multipliers of the units that ParseByteCount understands.
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// Parse a number of bytes with an optional (decimal or binary) unit, e.g., 500000, 500kB,
+// 1.5GB or 200MiB.
+func ParseByteCount(count string) (uint64, error) {
+	count = strings.TrimSpace(count)
+	number := strings.TrimRightFunc(count, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(count[len(number):]))]
+	if !ok {
+		return 0, fmt.Errorf("%q does not have a known unit (e.g., MB or MiB)", count)
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%q is not a number of bytes", count)
+	}
+	return uint64(value * unit), nil
+}
+
+// The inverse of ToMbps: the bytes (per second) in mbps.
+func FromMbps(mbps float64) float64 {
+	return mbps / float64(8) * float64(1024*1024)
+}
+
 type MeasurementResult struct {
 	Delay            time.Duration
 	MeasurementCount uint16
@@ -100,6 +144,16 @@ var GenerateUniqueId func() uint64 = func() func() uint64 {
 	}
 }()
 
+// Generate an identifier for a single run of the client so that the different
+// artifacts that it produces (e.g., data logs) can be correlated with each other.
+func GenerateRunId() string {
+	raw := make([]byte, 8)
+	if _, err := crand.Read(raw); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(raw)
+}
+
 type Optional[S any] struct {
 	value S
 	some  bool
@@ -147,6 +201,13 @@ func Max(x, y uint64) uint64 {
 	return y
 }
 
+func Min(x, y uint64) uint64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
 func ChannelToSlice[S any](channel <-chan S) (slice []S) {
 	slice = make([]S, 0)
 	for element := range channel {
@@ -163,6 +224,14 @@ func Fmap[S any, F any](elements []S, mapper func(S) F) []F {
 	return result
 }
 
+// Hand out the elements in turn (safely from several goroutines).
+func RoundRobin[S any](elements []S) func() S {
+	next := uint64(0)
+	return func() S {
+		return elements[(atomic.AddUint64(&next, 1)-1)%uint64(len(elements))]
+	}
+}
+
 func CalculatePercentile[S float32 | int32 | float64 | int64](elements []S, percentile int) S {
 	sort.Slice(elements, func(a, b int) bool { return elements[a] < elements[b] })
 	elementsCount := len(elements)
@@ -212,8 +281,11 @@ func UserAgent() string {
 	return fmt.Sprintf("goresponsiveness/%s", GitVersion)
 }
 
+// Wait (on c, whose lock is mu) until the condition holds or ctxt is done. Returns with mu
+// unlocked.
 func WaitWithContext(ctxt context.Context, condition *func() bool, mu *sync.Mutex, c *sync.Cond) bool {
 	mu.Lock()
+	defer mu.Unlock()
 	for !(*condition)() && ctxt.Err() == nil {
 		c.Wait()
 	}
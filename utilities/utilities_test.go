@@ -15,7 +15,13 @@ package utilities
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -115,4 +121,189 @@ func TestWaitWithContext(t *testing.T) {
 	}()
 
 	wg.Wait()
+	if !mu.TryLock() {
+		t.Fatalf("WaitWithContext should have unlocked the mutex.")
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	next := RoundRobin([]string{"a", "b", "c"})
+	for _, expected := range []string{"a", "b", "c", "a", "b"} {
+		if actual := next(); actual != expected {
+			t.Fatalf("Round robin should have handed out %s but handed out %s.", expected, actual)
+		}
+	}
+}
+
+func TestForceHTTP1(t *testing.T) {
+	ForceHTTP1()
+	defer func() { http1Forced = false }()
+
+	transport := &http.Transport{}
+	OverrideHostTransport(transport, "", nil)
+	if transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Fatalf("A transport limited to HTTP/1.1 should not be able to negotiate HTTP/2.")
+	}
+	if transport.MaxConnsPerHost != 0 || transport.MaxIdleConnsPerHost < 2 {
+		t.Fatalf("A transport limited to HTTP/1.1 should not limit the connections to a host.")
+	}
+}
+
+func TestProxyDialer(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	// A (plain) proxy that answers every request itself.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proxied", r.URL.String())
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	var tunnels []string
+	proxyDialer := func(ctx context.Context, dial func(context.Context) (net.Conn, error), through *url.URL, to string) (net.Conn, error) {
+		if through.String() != proxyURL.String() {
+			t.Fatalf("The tunnel should go through %v (not %v).", proxyURL, through)
+		}
+		tunnels = append(tunnels, to)
+		return net.Dial("tcp", to)
+	}
+	transport := &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	OverrideHostTransport(transport, "", proxyDialer)
+	client := &http.Client{Transport: transport}
+
+	response, err := client.Get(target.URL)
+	if err != nil {
+		t.Fatalf("Could not get %v through the tunnel: %v", target.URL, err)
+	}
+	response.Body.Close()
+	if len(tunnels) != 1 || tunnels[0] != target.Listener.Addr().String() {
+		t.Fatalf("There should have been one tunnel to %v (not %v).", target.Listener.Addr(), tunnels)
+	}
+
+	response, err = client.Get("http://example.com/small")
+	if err != nil {
+		t.Fatalf("Could not get a plain http URL through the proxy: %v", err)
+	}
+	response.Body.Close()
+	if response.Header.Get("X-Proxied") != "http://example.com/small" || len(tunnels) != 1 {
+		t.Fatalf("A plain http URL should have gone to the proxy without a tunnel: %v", response.Header)
+	}
+}
+
+func TestSetRequestHeaders(t *testing.T) {
+	request, _ := http.NewRequest("GET", "https://example.com/", nil)
+	SetRequestHeaders(request, nil)
+	if request.Header.Get("Authorization") != "" || request.Header.Get("User-Agent") != UserAgent() {
+		t.Fatalf("Without credentials, only the User-Agent should be set: %v", request.Header)
+	}
+
+	headers := &RequestHeaders{Hosts: []string{"example.com"}}
+	headers.UseBasicAuth("user", "pass")
+	SetRequestHeaders(request, headers)
+	if user, password, ok := request.BasicAuth(); !ok || user != "user" || password != "pass" {
+		t.Fatalf("The request should carry the user name and password: %v", request.Header)
+	}
+
+	headers.UseBearerToken("token")
+	SetRequestHeaders(request, headers)
+	if request.Header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("The request should carry the bearer token: %v", request.Header)
+	}
+
+	elsewhere, _ := http.NewRequest("GET", "https://cdn.example.net:8443/", nil)
+	SetRequestHeaders(elsewhere, headers)
+	if elsewhere.Header.Get("Authorization") != "" || elsewhere.Header.Get("User-Agent") != UserAgent() {
+		t.Fatalf("A request to another host should not carry the credentials: %v", elsewhere.Header)
+	}
+}
+
+func TestCustomHeaders(t *testing.T) {
+	var headerList HeaderList
+	headers := &RequestHeaders{Hosts: []string{"192.0.2.1"}}
+	for _, header := range []string{"x-api-key: secret", "Host: test.example.com", "User-Agent: custom"} {
+		if err := headerList.Set(header); err != nil {
+			t.Fatalf("Could not parse header %q: %v", header, err)
+		}
+		name, value, _ := ParseHeader(header)
+		headers.Add(name, value)
+	}
+	if err := headerList.Set("no colon"); err == nil {
+		t.Fatalf("A header without a colon should be invalid.")
+	}
+	if strings.Contains(headerList.String(), "secret") || !strings.Contains(headerList.String(), "X-Api-Key") {
+		t.Fatalf("The headers' names (but not their values) should be listed: %s", headerList.String())
+	}
+
+	request, _ := http.NewRequest("GET", "https://192.0.2.1/", nil)
+	SetRequestHeaders(request, headers)
+	if request.Header.Get("X-Api-Key") != "secret" || request.Header.Get("User-Agent") != "custom" ||
+		request.Host != "test.example.com" {
+		t.Fatalf("The request should have the custom headers: %v (host %s)", request.Header, request.Host)
+	}
+
+	elsewhere, _ := http.NewRequest("GET", "https://192.0.2.2/", nil)
+	SetRequestHeaders(elsewhere, headers)
+	if elsewhere.Header.Get("X-Api-Key") != "" || elsewhere.Host != "192.0.2.2" {
+		t.Fatalf("A request to another host should not have the custom headers: %v (host %s)", elsewhere.Header, elsewhere.Host)
+	}
+}
+
+func TestSecretFlag(t *testing.T) {
+	secret := &SecretFlag{}
+	if secret.String() != "" {
+		t.Fatalf("An empty secret should be empty.")
+	}
+	secret.Set("hunter2")
+	if secret.Value() != "hunter2" || strings.Contains(secret.String(), "hunter2") {
+		t.Fatalf("A secret should be kept but not shown: %q", secret.String())
+	}
+}
+
+func TestTraceKeyLog(t *testing.T) {
+	var keyLog strings.Builder
+	if TraceKeyLog(nil, true, "test") != nil {
+		t.Fatalf("Without a key logger, there is nothing to trace.")
+	}
+	if TraceKeyLog(&keyLog, false, "test") != &keyLog {
+		t.Fatalf("Without debugging, the key logger should be used as is.")
+	}
+
+	tracer := TraceKeyLog(&keyLog, true, "test")
+	lines := "CLIENT_HANDSHAKE_TRAFFIC_SECRET 0102 aaaa\nSERVER_HANDSHAKE_TRAFFIC_SECRET 0102 bbbb\n"
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		if line == "" {
+			continue
+		}
+		if _, err := tracer.Write([]byte(line)); err != nil {
+			t.Fatalf("Could not log a key: %v", err)
+		}
+	}
+	if keyLog.String() != lines {
+		t.Fatalf("The traced key log should pass every line along: %q", keyLog.String())
+	}
+	if tracer.(*keyLogTracer).clientRandom != "0102" {
+		t.Fatalf("The tracer should have seen the session's client random.")
+	}
+}
+
+func TestParseByteCount(t *testing.T) {
+	for count, expected := range map[string]uint64{
+		"500000": 500000,
+		"500kB":  500000,
+		"1.5GB":  1500000000,
+		"200MiB": 200 * 1024 * 1024,
+		" 2 mb ": 2000000,
+	} {
+		if parsed, err := ParseByteCount(count); err != nil || parsed != expected {
+			t.Fatalf("%q should be %d bytes, not %d (%v).", count, expected, parsed, err)
+		}
+	}
+	for _, count := range []string{"", "MB", "12 parsecs", "-5MB"} {
+		if _, err := ParseByteCount(count); err == nil {
+			t.Fatalf("%q should not parse as a number of bytes.", count)
+		}
+	}
 }
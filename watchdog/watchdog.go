@@ -0,0 +1,86 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+// A Watchdog notices when its owner stops making progress. The owner feeds the watchdog
+// every time that it does something useful; if it goes longer than the watchdog's period
+// without being fed, the watchdog's Starved channel is closed.
+type Watchdog struct {
+	period  time.Duration
+	mu      sync.Mutex
+	lastFed time.Time
+	starved chan interface{}
+}
+
+func NewWatchdog(ctx context.Context, period time.Duration, debugLevel debug.DebugLevel) *Watchdog {
+	watchdog := &Watchdog{period: period, lastFed: time.Now(), starved: make(chan interface{})}
+	go func() {
+		ticker := time.NewTicker(period / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if lastFed := watchdog.LastFed(); now.Sub(lastFed) >= period {
+					if debug.IsDebug(debugLevel) {
+						fmt.Printf("Watchdog starved (last fed at %v)\n", lastFed)
+					}
+					close(watchdog.starved)
+					return
+				}
+			}
+		}
+	}()
+	return watchdog
+}
+
+// Record that the owner made progress.
+func (w *Watchdog) Feed() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastFed = time.Now()
+}
+
+func (w *Watchdog) LastFed() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastFed
+}
+
+func (w *Watchdog) Period() time.Duration {
+	return w.period
+}
+
+// Closed when the watchdog has gone a full period without being fed.
+func (w *Watchdog) Starved() <-chan interface{} {
+	return w.starved
+}
+
+// Write the stacks of every goroutine to writer.
+func DumpGoroutines(writer io.Writer) error {
+	return pprof.Lookup("goroutine").WriteTo(writer, 2)
+}
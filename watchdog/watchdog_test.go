@@ -0,0 +1,60 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+func TestWatchdogStarves(t *testing.T) {
+	watchdog := NewWatchdog(context.Background(), 100*time.Millisecond, debug.NoDebug)
+	select {
+	case <-watchdog.Starved():
+	case <-time.After(time.Second):
+		t.Fatalf("Watchdog should have starved when it was not fed.")
+	}
+}
+
+func TestWatchdogFed(t *testing.T) {
+	watchdog := NewWatchdog(context.Background(), 200*time.Millisecond, debug.NoDebug)
+	stopFeeding := time.After(500 * time.Millisecond)
+feeding:
+	for {
+		select {
+		case <-watchdog.Starved():
+			t.Fatalf("Watchdog should not starve while it is being fed.")
+		case <-stopFeeding:
+			break feeding
+		case <-time.After(20 * time.Millisecond):
+			watchdog.Feed()
+		}
+	}
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := DumpGoroutines(&buffer); err != nil {
+		t.Fatalf("Could not dump goroutines: %v", err)
+	}
+	if !strings.Contains(buffer.String(), "TestDumpGoroutines") {
+		t.Fatalf("Goroutine dump does not include the running test: %s", buffer.String())
+	}
+}